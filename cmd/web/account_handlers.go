@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type passwordForgotForm struct {
+	Email string
+	Sent  bool
+	validator.Validator
+}
+
+type passwordResetForm struct {
+	Token    string
+	Password string
+	validator.Validator
+}
+
+type verifyEmailForm struct {
+	Email    string
+	Sent     bool
+	Verified bool
+	validator.Validator
+}
+
+func validEmail(v *validator.Validator, email string) {
+	v.CheckField(validator.NotBlank(email), "email", "This field cannot be blank")
+	v.CheckField(validator.Matches(email, validator.EmailRX), "email", "This field must be a valid email address")
+}
+
+// userPasswordForgot mails a reset link. The page reads the same whether or
+// not the address has an account.
+func (app *application) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/password/forgot" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var form passwordForgotForm
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		form.Email = r.PostForm.Get("email")
+		validEmail(&form.Validator, form.Email)
+
+		if form.Valid() {
+			if err := app.authService.RequestPasswordReset(r.Context(), form.Email); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			form.Sent = true
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "forgot.tmpl.html", data)
+}
+
+// userPasswordReset sets a new password from a mailed link and signs the
+// account out everywhere.
+func (app *application) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/password/reset" {
+		app.notFound(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data := app.newTemplateData(r)
+		data.Form = passwordResetForm{Token: r.URL.Query().Get("token")}
+		app.render(w, http.StatusOK, "reset.tmpl.html", data)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := passwordResetForm{
+		Token:    r.PostForm.Get("token"),
+		Password: r.PostForm.Get("password"),
+	}
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+
+	if form.Valid() {
+		err := app.authService.ResetPassword(r.Context(), form.Token, form.Password)
+		if err == nil {
+			sessioncookie.ClearSessionCookie(w)
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, models.ErrInvalidToken) {
+			app.serverError(w, err)
+			return
+		}
+		form.AddNonFieldError("This reset link is invalid or has expired. Ask for a new one.")
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusUnprocessableEntity, "reset.tmpl.html", data)
+}
+
+// userVerify confirms an address from a mailed link (GET with token) and
+// re-sends the link (POST with email).
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/verify" {
+		app.notFound(w)
+		return
+	}
+
+	var form verifyEmailForm
+	switch r.Method {
+	case http.MethodGet:
+		if token := r.URL.Query().Get("token"); token != "" {
+			err := app.authService.VerifyEmail(r.Context(), token)
+			switch {
+			case err == nil:
+				form.Verified = true
+			case errors.Is(err, models.ErrInvalidToken):
+				form.AddNonFieldError("This verification link is invalid or has expired. Ask for a new one below.")
+			default:
+				app.serverError(w, err)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		form.Email = r.PostForm.Get("email")
+		validEmail(&form.Validator, form.Email)
+
+		if form.Valid() {
+			if err := app.authService.SendVerification(r.Context(), form.Email); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			form.Sent = true
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "verify.tmpl.html", data)
+}
+
+// linkedProvider is one row of the settings page; Identity is nil when the
+// provider is not linked.
+type linkedProvider struct {
+	oauthProvider
+	Identity *models.OAuthIdentity
+}
+
+type settingsForm struct {
+	Providers []linkedProvider
+	validator.Validator
+}
+
+// userSettings lists the providers the user can sign in with.
+func (app *application) userSettings(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/settings" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.renderSettings(w, r, userID, "")
+}
+
+// renderSettings shows the settings page of userID with an optional error.
+func (app *application) renderSettings(w http.ResponseWriter, r *http.Request, userID int, problem string) {
+	identities, err := app.authService.ListIdentities(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var form settingsForm
+	for _, p := range app.oauthProviders() {
+		row := linkedProvider{oauthProvider: p}
+		for _, i := range identities {
+			if i.Provider == p.Name {
+				row.Identity = i
+			}
+		}
+		form.Providers = append(form.Providers, row)
+	}
+
+	status := http.StatusOK
+	if problem != "" {
+		form.AddNonFieldError(problem)
+		status = http.StatusUnprocessableEntity
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, status, "settings.tmpl.html", data)
+}
+
+// userSettingsUnlink removes a provider login, unless it is the only way
+// into the account.
+func (app *application) userSettingsUnlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[1] != "user" || parts[2] != "settings" || parts[3] != "unlink" || parts[4] == "" {
+		app.notFound(w)
+		return
+	}
+	provider := parts[4]
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.authService.UnlinkIdentity(userID, provider)
+	switch {
+	case err == nil:
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+	case errors.Is(err, models.ErrLastSignIn):
+		app.renderSettings(w, r, userID, "This is the only way into your account. Set a password with \"Forgot your password?\" on the login page before unlinking "+
+			app.oauthProviderLabel(provider)+".")
+	default:
+		app.serverError(w, err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
+)
+
+type apiSignupInput struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type apiLoginInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type apiSession struct {
+	Token   string    `json:"token"`
+	UserID  int       `json:"user_id"`
+	Expires time.Time `json:"expires"`
+}
+
+// apiDevice is a signed-in session as listed to its owner; the token is
+// never shown again after login.
+type apiDevice struct {
+	ID        int       `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+	Expires   time.Time `json:"expires"`
+	Current   bool      `json:"current"`
+}
+
+type apiUser struct {
+	ID   int `json:"id"`
+	Role int `json:"role"`
+}
+
+func (app *application) apiSignup(w http.ResponseWriter, r *http.Request) {
+	var input apiSignupInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := userSingupForm{Name: input.Name, Email: input.Email, Password: input.Password}
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	err := app.authService.Signup(r.Context(), form.Name, form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email or name address is already in use")
+			form.AddFieldError("name", "Email or name address is already in use")
+			app.apiValidationError(w, form.Validator)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.authService.SendVerification(r.Context(), form.Email); err != nil {
+		app.errorLog.Printf("send verification to %s: %v", form.Email, err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (app *application) apiLogin(w http.ResponseWriter, r *http.Request) {
+	var input apiLoginInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := userLoginForm{Email: input.Email, Password: input.Password}
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	session, err := app.authService.Login(r.Context(), form.Email, form.Password, sessionDevice(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.apiError(w, http.StatusUnauthorized, "Email or password is incorrect")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
+	app.apiWrite(w, http.StatusOK, apiSession{Token: session.Token, UserID: session.UserID, Expires: session.Expiry})
+}
+
+func (app *application) apiLogout(w http.ResponseWriter, r *http.Request) {
+	if err := app.authService.Logout(r.Context(), apiToken(r)); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	sessioncookie.ClearSessionCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiMe(w http.ResponseWriter, r *http.Request) {
+	userID := app.apiUserID(r)
+	role, err := app.authService.Role(userID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, apiUser{ID: userID, Role: role})
+}
+
+func (app *application) apiSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.authService.ListSessions(r.Context(), app.apiUserID(r), apiToken(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	out := make([]apiDevice, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, apiDevice{
+			ID:        s.ID,
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			Created:   s.Created,
+			LastSeen:  s.LastSeen,
+			Expires:   s.Expiry,
+			Current:   s.Current,
+		})
+	}
+	app.apiWrite(w, http.StatusOK, out)
+}
+
+func (app *application) apiSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	if err := app.authService.RevokeSession(r.Context(), app.apiUserID(r), id); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
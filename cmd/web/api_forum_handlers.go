@@ -0,0 +1,472 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type apiForumInput struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	Expires *int     `json:"expires"`
+}
+
+type apiCommentInput struct {
+	Comment string `json:"comment"`
+}
+
+type apiReactionInput struct {
+	Reaction string `json:"reaction"`
+}
+
+type apiTagsInput struct {
+	Tags []string `json:"tags"`
+}
+
+type apiTagRenameInput struct {
+	Name string `json:"name"`
+}
+
+type apiTagMergeInput struct {
+	Into string `json:"into"`
+}
+
+// apiForumForm validates a create/update body with the same rules as the
+// HTML forms.
+func (app *application) apiForumForm(w http.ResponseWriter, r *http.Request) (forumCreateForm, bool) {
+	var input apiForumInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return forumCreateForm{}, false
+	}
+
+	lifetimes, err := app.forumService.ExpiryPolicies()
+	if err != nil {
+		app.apiServerError(w, err)
+		return forumCreateForm{}, false
+	}
+	// Left out, expires is the default lifetime; 0 asks for a post that
+	// never expires, where that is offered.
+	expires := defaultLifetime(lifetimes)
+	if input.Expires != nil {
+		expires = *input.Expires
+	}
+
+	form := forumCreateForm{
+		Title:     input.Title,
+		Content:   input.Content,
+		Tags:      strings.Join(app.processTags(input.Tags, ""), ", "),
+		Expires:   expires,
+		Lifetimes: lifetimes,
+	}
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return form, false
+	}
+
+	return form, true
+}
+
+func (app *application) apiForumList(w http.ResponseWriter, r *http.Request) {
+	var list []*models.Forum
+	var err error
+	if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+		list, err = app.forumService.ShowCategory(app.processTags(tags, ""))
+	} else {
+		list, err = app.forumService.ShowAll()
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, app.apiForums(list))
+}
+
+func (app *application) apiSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := models.SearchQuery{
+		Text:   query.Get("q"),
+		Tag:    query.Get("tag"),
+		Author: query.Get("author"),
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(q.Text), "q", "This field cannot be blank")
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		v.CheckField(err == nil && n > 0 && n <= models.MaxSearchLimit, "limit",
+			fmt.Sprintf("This field must be between 1 and %d", models.MaxSearchLimit))
+		q.Limit = n
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	results, err := app.forumService.Find(q)
+	if errors.Is(err, models.ErrSearchUnavailable) {
+		app.apiError(w, http.StatusServiceUnavailable, "Search is not available on this server")
+		return
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPISearchResults(results))
+}
+
+func (app *application) apiForumLatest(w http.ResponseWriter, r *http.Request) {
+	list, err := app.forumService.Latest()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, app.apiForums(list))
+}
+
+func (app *application) apiForumView(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	forum, err := app.forumService.View(id, app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	out := newAPIForum(forum)
+	app.addImageURLs(&out)
+	app.apiWrite(w, http.StatusOK, out)
+}
+
+func (app *application) apiForumCreate(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.apiForumForm(w, r)
+	if !ok {
+		return
+	}
+
+	post := forumsvc.Post{Title: form.Title, Content: form.Content, Tags: form.Tags, Expires: form.Expires}
+	id, err := app.forumService.Create(r.Context(), post, app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/forums/"+strconv.Itoa(id))
+	app.apiWrite(w, http.StatusCreated, map[string]int{"id": id})
+}
+
+func (app *application) apiForumUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	form, ok := app.apiForumForm(w, r)
+	if !ok {
+		return
+	}
+
+	post := forumsvc.Post{Title: form.Title, Content: form.Content, Tags: form.Tags, Expires: form.Expires}
+	if err := app.forumService.Update(r.Context(), id, post, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, map[string]int{"id": id})
+}
+
+func (app *application) apiForumDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	if err := app.forumService.Delete(r.Context(), id, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiReaction(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var input apiReactionInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+
+	likeStatus, ok := likeStatusFromReaction(input.Reaction)
+	if !ok {
+		var v validator.Validator
+		v.AddFieldError("reaction", "This field must equal like or dislike")
+		app.apiValidationError(w, v)
+		return 0, false
+	}
+	return likeStatus, true
+}
+
+func (app *application) apiForumReact(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	likeStatus, ok := app.apiReaction(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.forumService.React(id, app.apiUserID(r), likeStatus); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiForumView(w, r)
+}
+
+func (app *application) apiCommentForm(w http.ResponseWriter, r *http.Request) (forumCommentForm, bool) {
+	var input apiCommentInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return forumCommentForm{}, false
+	}
+
+	form := forumCommentForm{Comment: input.Comment}
+	form.CheckField(validator.NotBlank(form.Comment), "comment", "This field cannot be blank")
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return form, false
+	}
+	return form, true
+}
+
+func (app *application) apiCommentCreate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	form, ok := app.apiCommentForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.forumService.Comment(id, app.apiUserID(r), form.Comment); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/forums/"+strconv.Itoa(id))
+	app.apiWrite(w, http.StatusCreated, map[string]int{"forum_id": id})
+}
+
+func (app *application) apiCommentReply(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	form, ok := app.apiCommentForm(w, r)
+	if !ok {
+		return
+	}
+
+	forumID, replyID, err := app.forumService.Reply(id, app.apiUserID(r), form.Comment)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/forums/"+strconv.Itoa(forumID))
+	app.apiWrite(w, http.StatusCreated, map[string]int{"id": replyID, "forum_id": forumID, "parent_id": id})
+}
+
+func (app *application) apiCommentUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	form, ok := app.apiCommentForm(w, r)
+	if !ok {
+		return
+	}
+
+	forumID, err := app.forumService.EditComment(id, app.apiUserID(r), form.Comment)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, apiComment{ID: id, ForumID: forumID, Comment: form.Comment})
+}
+
+func (app *application) apiCommentDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	if err := app.forumService.DeleteComment(id, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiCommentReact(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	likeStatus, ok := app.apiReaction(w, r)
+	if !ok {
+		return
+	}
+
+	forumID, err := app.forumService.ReactComment(id, app.apiUserID(r), likeStatus)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, map[string]int{"id": id, "forum_id": forumID})
+}
+
+func (app *application) apiUserForums(w http.ResponseWriter, r *http.Request) {
+	list, err := app.forumService.UserPosts(app.apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, app.apiForums(list))
+}
+
+func (app *application) apiUserReactions(w http.ResponseWriter, r *http.Request) {
+	list, err := app.forumService.UserLikes(app.apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, app.apiForums(list))
+}
+
+func (app *application) apiUserComments(w http.ResponseWriter, r *http.Request) {
+	list, err := app.forumService.UserComments(app.apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPICommentList(list))
+}
+
+func (app *application) apiTagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.forumService.AllTags()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, tags)
+}
+
+func (app *application) apiTagCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiTagsInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var v validator.Validator
+	v.CheckField(len(input.Tags) > 0, "tags", "This field cannot be blank")
+	for _, tag := range input.Tags {
+		v.CheckField(validator.NotBlank(tag), "tags", "Tags cannot be blank")
+		v.CheckField(validator.IncorrectInput(tag), "tags", "Incorrect tags formation")
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	if err := app.forumService.AddTags(input.Tags, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusCreated, input.Tags)
+}
+
+func (app *application) apiTagDelete(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("tag")
+	if err := app.forumService.RemoveTags([]string{tag}, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiTagCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.forumService.TagCounts()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPITagCountList(counts))
+}
+
+func (app *application) apiTagRename(w http.ResponseWriter, r *http.Request) {
+	var input apiTagRenameInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	app.apiTagChange(w, r, tagChangeForm{Action: "rename", From: r.PathValue("tag"), To: input.Name}, "name")
+}
+
+func (app *application) apiTagMerge(w http.ResponseWriter, r *http.Request) {
+	var input apiTagMergeInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	app.apiTagChange(w, r, tagChangeForm{Action: "merge", From: r.PathValue("tag"), To: input.Into}, "into")
+}
+
+// apiTagChange validates and applies a rename or merge, reporting errors on
+// the "to" field under the request's own field name.
+func (app *application) apiTagChange(w http.ResponseWriter, r *http.Request, form tagChangeForm, field string) {
+	form.validate()
+	if !form.Valid() {
+		if msg, ok := form.FieldErrors["to"]; ok {
+			delete(form.FieldErrors, "to")
+			form.FieldErrors[field] = msg
+		}
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	var err error
+	if form.Action == "rename" {
+		err = app.forumService.RenameTag(form.From, form.To, app.apiUserID(r))
+	} else {
+		err = app.forumService.MergeTags(form.From, form.To, app.apiUserID(r))
+	}
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,740 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type apiTestResponse struct {
+	Code   int
+	Header http.Header
+	Body   string
+}
+
+func apiDo(t *testing.T, h http.Handler, method, target, token, body string) apiTestResponse {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = "198.51.100.7:4000"
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return apiTestResponse{Code: rr.Code, Header: rr.Header(), Body: rr.Body.String()}
+}
+
+func apiDecode(t *testing.T, res apiTestResponse, dst interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(res.Body), dst); err != nil {
+		t.Fatalf("decode %q: %v", res.Body, err)
+	}
+}
+
+func apiLoginToken(t *testing.T, h http.Handler, email string) string {
+	t.Helper()
+	res := apiDo(t, h, http.MethodPost, "/api/v1/auth/login", "", `{"email":"`+email+`","password":"password123"}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("login status=%d body=%s", res.Code, res.Body)
+	}
+	var out struct {
+		Data apiSession `json:"data"`
+	}
+	apiDecode(t, res, &out)
+	if out.Data.Token == "" {
+		t.Fatalf("login returned empty token")
+	}
+	return out.Data.Token
+}
+
+func TestAPIAuthFlow(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/auth/signup", "", `{"name":"ann","email":"ann@example.com","password":"password123"}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("signup status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/auth/signup", "", `{"name":"ann","email":"ann@example.com","password":"password123"}`)
+	if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body, `"email"`) {
+		t.Fatalf("duplicate signup status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/auth/signup", "", `{"name":"","email":"bad","password":"short"}`)
+	if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body, `"password"`) {
+		t.Fatalf("invalid signup status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/auth/signup", "", `{"name":"x","unknown":1}`)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("unknown field status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/auth/login", "", `{"email":"ann@example.com","password":"wrongpassword"}`)
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("bad login status=%d body=%s", res.Code, res.Body)
+	}
+
+	token := apiLoginToken(t, h, "ann@example.com")
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me", token, "")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"role":2`) {
+		t.Fatalf("me status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/auth/logout", token, "")
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("logout status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me", token, "")
+	if res.Code != http.StatusUnauthorized || res.Header.Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("me after logout status=%d header=%q", res.Code, res.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestAPIForumLifecycle(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	seedWebUser(t, app, "eve", "eve@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+	eveToken := apiLoginToken(t, h, "eve@example.com")
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/forums", "", `{"title":"t","content":"c"}`)
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous create status=%d", res.Code)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/forums", bobToken, `{"title":"","content":"","expires":3}`)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid create status=%d body=%s", res.Code, res.Body)
+	}
+	var invalid struct {
+		Error struct {
+			Fields map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	apiDecode(t, res, &invalid)
+	for _, field := range []string{"title", "content", "expires"} {
+		if invalid.Error.Fields[field] == "" {
+			t.Fatalf("missing field error %q in %s", field, res.Body)
+		}
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/forums", adminToken, `{"title":"Hello","content":"World","tags":["go"]}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create status=%d body=%s", res.Code, res.Body)
+	}
+	var created struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	apiDecode(t, res, &created)
+	forumPath := "/api/v1/forums/" + strconv.Itoa(created.Data.ID)
+	if res.Header.Get("Location") != forumPath {
+		t.Fatalf("Location=%q, want %q", res.Header.Get("Location"), forumPath)
+	}
+
+	res = apiDo(t, h, http.MethodGet, forumPath, "", "")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"title":"Hello"`) || !strings.Contains(res.Body, `"tags":["go"]`) {
+		t.Fatalf("view status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/forums", "", "")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"Hello"`) {
+		t.Fatalf("list status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPut, forumPath, eveToken, `{"title":"Hijack","content":"x"}`)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("foreign update status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, forumPath+"/reaction", bobToken, `{"reaction":"meh"}`)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid reaction status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, forumPath+"/reaction", bobToken, `{"reaction":"like"}`)
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"likes":1`) || !strings.Contains(res.Body, `"reaction":"like"`) {
+		t.Fatalf("react status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, forumPath+"/comments", bobToken, `{"comment":"nice"}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("comment status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me/comments", bobToken, "")
+	var comments struct {
+		Data []apiComment `json:"data"`
+	}
+	apiDecode(t, res, &comments)
+	if len(comments.Data) != 1 || comments.Data[0].Comment != "nice" {
+		t.Fatalf("user comments body=%s", res.Body)
+	}
+	commentPath := "/api/v1/comments/" + strconv.Itoa(comments.Data[0].ID)
+
+	res = apiDo(t, h, http.MethodPut, commentPath, eveToken, `{"comment":"mine"}`)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("foreign comment edit status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPut, commentPath, bobToken, `{"comment":"very nice"}`)
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"very nice"`) {
+		t.Fatalf("comment edit status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, commentPath+"/reaction", eveToken, `{"reaction":"dislike"}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("comment react status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodDelete, commentPath, bobToken, "")
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("comment delete status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodDelete, forumPath, adminToken, "")
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("delete status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, forumPath, "", "")
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("view deleted status=%d body=%s", res.Code, res.Body)
+	}
+}
+
+func TestAPIModerationAndTags(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	bobID := seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/tags", bobToken, `{"tags":["rust"]}`)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("user tag create status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/tags", adminToken, `{"tags":["rust"]}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("admin tag create status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/tags", "", "")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body, `"rust"`) {
+		t.Fatalf("tag list status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodDelete, "/api/v1/tags/rust", adminToken, "")
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("tag delete status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/moderation/requests", bobToken, "")
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("moderation request status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/notifications", adminToken, "")
+	var inbox struct {
+		Data []apiNotification `json:"data"`
+	}
+	apiDecode(t, res, &inbox)
+	if res.Code != http.StatusOK || len(inbox.Data) == 0 {
+		t.Fatalf("admin notifications status=%d body=%s", res.Code, res.Body)
+	}
+	approvePath := "/api/v1/moderation/requests/" + strconv.Itoa(inbox.Data[0].ID) + "/approve"
+
+	res = apiDo(t, h, http.MethodPost, approvePath, bobToken, `{"user_id":`+strconv.Itoa(bobID)+`}`)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("user approve status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, approvePath, adminToken, `{"user_id":0}`)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("approve without user status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, approvePath, adminToken, `{"user_id":`+strconv.Itoa(bobID)+`}`)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("approve status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me", bobToken, "")
+	if !strings.Contains(res.Body, `"role":3`) {
+		t.Fatalf("bob was not promoted: %s", res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/forums/1/reports", bobToken, `{"reasons":["`+strings.Repeat("x", 201)+`"]}`)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("long report status=%d body=%s", res.Code, res.Body)
+	}
+}
+
+func TestAPIRoutingErrorsAreJSON(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.routes()
+
+	res := apiDo(t, h, http.MethodGet, "/api/v1/nope", "", "")
+	if res.Code != http.StatusNotFound || !strings.Contains(res.Body, `"code":"not_found"`) {
+		t.Fatalf("unknown route status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPatch, "/api/v1/forums/1", "", "")
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("PATCH status=%d body=%s", res.Code, res.Body)
+	}
+	if allow := res.Header.Get("Allow"); allow != "GET, PUT, DELETE" {
+		t.Fatalf("Allow=%q", allow)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/forums/abc", "", "")
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("bad id status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/forums/99", "", "")
+	if res.Code != http.StatusNotFound || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("missing forum status=%d type=%q", res.Code, res.Header.Get("Content-Type"))
+	}
+}
+
+func TestAPICommentReplies(t *testing.T) {
+	app, db := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/forums", adminToken, `{"title":"Threads","content":"body"}`)
+	var created struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	apiDecode(t, res, &created)
+	forumPath := "/api/v1/forums/" + strconv.Itoa(created.Data.ID)
+
+	apiDo(t, h, http.MethodPost, forumPath+"/comments", adminToken, `{"comment":"root"}`)
+	var rootID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE comment = 'root'`).Scan(&rootID); err != nil {
+		t.Fatalf("query root: %v", err)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/comments/"+strconv.Itoa(rootID)+"/replies", bobToken, `{"comment":"reply"}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("reply status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/comments/9999/replies", bobToken, `{"comment":"reply"}`)
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("reply to missing status=%d body=%s", res.Code, res.Body)
+	}
+
+	origDepth := models.MaxCommentDepth
+	models.MaxCommentDepth = 0
+	t.Cleanup(func() { models.MaxCommentDepth = origDepth })
+	res = apiDo(t, h, http.MethodPost, "/api/v1/comments/"+strconv.Itoa(rootID)+"/replies", bobToken, `{"comment":"deep"}`)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("too deep status=%d body=%s", res.Code, res.Body)
+	}
+	models.MaxCommentDepth = origDepth
+
+	res = apiDo(t, h, http.MethodGet, forumPath, "", "")
+	var view struct {
+		Data apiForum `json:"data"`
+	}
+	apiDecode(t, res, &view)
+	if len(view.Data.Comments) != 1 || len(view.Data.Comments[0].Replies) != 1 {
+		t.Fatalf("thread body=%s", res.Body)
+	}
+	if reply := view.Data.Comments[0].Replies[0]; reply.ParentID != rootID || reply.Depth != 1 || reply.Comment != "reply" {
+		t.Fatalf("reply = %+v", reply)
+	}
+}
+
+func TestAPISearch(t *testing.T) {
+	app, db := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	token := apiLoginToken(t, h, "admin@example.com")
+	res := apiDo(t, h, http.MethodPost, "/api/v1/forums", token, `{"title":"Gopher <b>news</b>","content":"body"}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/search", "", "")
+	if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body, `"q"`) {
+		t.Fatalf("blank query status=%d body=%s", res.Code, res.Body)
+	}
+	res = apiDo(t, h, http.MethodGet, "/api/v1/search?q=gopher&limit=500", "", "")
+	if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body, `"limit"`) {
+		t.Fatalf("bad limit status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/search?q=news", "", "")
+	available, err := (&models.SearchModel{DB: db}).Available()
+	if err != nil {
+		t.Fatalf("Available: %v", err)
+	}
+	if !available {
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("search without FTS5 status=%d body=%s", res.Code, res.Body)
+		}
+		return
+	}
+
+	var results struct {
+		Data []apiSearchResult `json:"data"`
+	}
+	apiDecode(t, res, &results)
+	if len(results.Data) != 1 {
+		t.Fatalf("search body=%s", res.Body)
+	}
+	got := results.Data[0]
+	if got.Kind != "post" || got.Author != "admin" || got.Snippet != "Gopher &lt;b&gt;<mark>news</mark>&lt;/b&gt;" {
+		t.Fatalf("result = %+v", got)
+	}
+}
+
+func TestAPITagCountsRenameAndMerge(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+
+	for _, body := range []string{
+		`{"title":"One","content":"body","tags":["golang","web"]}`,
+		`{"title":"Two","content":"body","tags":["go"]}`,
+	} {
+		if res := apiDo(t, h, http.MethodPost, "/api/v1/forums", adminToken, body); res.Code != http.StatusCreated {
+			t.Fatalf("create status=%d body=%s", res.Code, res.Body)
+		}
+	}
+
+	res := apiDo(t, h, http.MethodPut, "/api/v1/tags/golang", bobToken, `{"name":"gopher"}`)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("user rename status=%d body=%s", res.Code, res.Body)
+	}
+	res = apiDo(t, h, http.MethodPut, "/api/v1/tags/golang", adminToken, `{"name":"a,b"}`)
+	if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body, `"name"`) {
+		t.Fatalf("bad rename status=%d body=%s", res.Code, res.Body)
+	}
+	res = apiDo(t, h, http.MethodPut, "/api/v1/tags/golang", adminToken, `{"name":"go"}`)
+	if res.Code != http.StatusConflict {
+		t.Fatalf("rename onto existing status=%d body=%s", res.Code, res.Body)
+	}
+	res = apiDo(t, h, http.MethodPut, "/api/v1/tags/missing", adminToken, `{"name":"other"}`)
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("rename missing status=%d body=%s", res.Code, res.Body)
+	}
+	res = apiDo(t, h, http.MethodPut, "/api/v1/tags/web", adminToken, `{"name":"www"}`)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("rename status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, "/api/v1/tags/golang/merge", adminToken, `{"into":"go"}`)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("merge status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/tags/counts", "", "")
+	var counts struct {
+		Data []apiTagCount `json:"data"`
+	}
+	apiDecode(t, res, &counts)
+	got := make(map[string]int)
+	for _, c := range counts.Data {
+		got[c.Name] = c.Count
+	}
+	if got["go"] != 2 || got["www"] != 1 {
+		t.Fatalf("tag counts body=%s", res.Body)
+	}
+	if _, ok := got["golang"]; ok {
+		t.Fatalf("merged tag still listed: %s", res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/forums?tag=www", "", "")
+	var list struct {
+		Data []apiForum `json:"data"`
+	}
+	apiDecode(t, res, &list)
+	if len(list.Data) != 1 || list.Data[0].Title != "One" || len(list.Data[0].Tags) != 2 {
+		t.Fatalf("renamed tag filter body=%s", res.Body)
+	}
+}
+
+func TestAPIInbox(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/forums", adminToken, `{"title":"Hello","content":"World","tags":["go"]}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create status=%d body=%s", res.Code, res.Body)
+	}
+	var created struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	apiDecode(t, res, &created)
+	forumPath := "/api/v1/forums/" + strconv.Itoa(created.Data.ID)
+
+	if res := apiDo(t, h, http.MethodPost, forumPath+"/comments", bobToken, `{"comment":"first!"}`); res.Code != http.StatusCreated {
+		t.Fatalf("comment status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodPost, forumPath+"/reaction", bobToken, `{"reaction":"like"}`); res.Code >= 300 {
+		t.Fatalf("reaction status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me/inbox", "", "")
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous inbox status=%d", res.Code)
+	}
+
+	res = apiDo(t, h, http.MethodGet, "/api/v1/me/inbox", adminToken, "")
+	var inbox struct {
+		Data []apiInboxNotification `json:"data"`
+	}
+	apiDecode(t, res, &inbox)
+	if len(inbox.Data) != 2 || inbox.Data[0].Event != models.EventPostLike || inbox.Data[1].Event != models.EventPostComment {
+		t.Fatalf("inbox = %+v", inbox.Data)
+	}
+	if inbox.Data[1].Actor != "bob" || inbox.Data[1].Body != "first!" || inbox.Data[1].ForumTitle != "Hello" {
+		t.Fatalf("comment notification = %+v", inbox.Data[1])
+	}
+
+	readPath := "/api/v1/me/inbox/" + strconv.Itoa(inbox.Data[0].ID) + "/read"
+	if res := apiDo(t, h, http.MethodPost, readPath, bobToken, ""); res.Code != http.StatusNotFound {
+		t.Fatalf("read another user's notification status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodPost, readPath, adminToken, ""); res.Code != http.StatusNoContent {
+		t.Fatalf("read status=%d body=%s", res.Code, res.Body)
+	}
+
+	var unread struct {
+		Data apiUnreadCount `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/me/inbox/unread", adminToken, ""), &unread)
+	if unread.Data.Unread != 1 {
+		t.Fatalf("unread = %d, want 1", unread.Data.Unread)
+	}
+
+	if res := apiDo(t, h, http.MethodPost, "/api/v1/me/inbox/read", adminToken, ""); res.Code != http.StatusNoContent {
+		t.Fatalf("read all status=%d body=%s", res.Code, res.Body)
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/me/inbox?unread=true", adminToken, ""), &inbox)
+	if len(inbox.Data) != 0 {
+		t.Fatalf("unread inbox after read all = %+v", inbox.Data)
+	}
+}
+
+func TestAPIReports(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "moder", "moder@example.com", models.ModeratorRole)
+	seedWebUser(t, app, "bob", "bob@example.com", models.UserRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	moderToken := apiLoginToken(t, h, "moder@example.com")
+	bobToken := apiLoginToken(t, h, "bob@example.com")
+
+	res := apiDo(t, h, http.MethodPost, "/api/v1/forums", adminToken, `{"title":"Spam","content":"Buy now","tags":["go"]}`)
+	var created struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	apiDecode(t, res, &created)
+	reportsPath := "/api/v1/forums/" + strconv.Itoa(created.Data.ID) + "/reports"
+
+	if res := apiDo(t, h, http.MethodPost, reportsPath, bobToken, `{"reasons":["obscene"]}`); res.Code != http.StatusForbidden {
+		t.Fatalf("user report status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodPost, reportsPath, moderToken, `{"reasons":[]}`); res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("report without reasons status=%d body=%s", res.Code, res.Body)
+	}
+
+	res = apiDo(t, h, http.MethodPost, reportsPath, moderToken, `{"reasons":["obscene","illegal"],"details":"spam link"}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("report status=%d body=%s", res.Code, res.Body)
+	}
+	var filed struct {
+		Data apiReport `json:"data"`
+	}
+	apiDecode(t, res, &filed)
+	if filed.Data.State != models.ReportOpen || len(filed.Data.Reasons) != 2 || filed.Data.Reporter != "moder" {
+		t.Fatalf("filed report = %+v", filed.Data)
+	}
+	reportPath := "/api/v1/moderation/reports/" + strconv.Itoa(filed.Data.ID)
+
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/moderation/reports", bobToken, ""); res.Code != http.StatusForbidden {
+		t.Fatalf("user queue status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/moderation/reports?state=stale", moderToken, ""); res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad state status=%d", res.Code)
+	}
+	var queue struct {
+		Data []apiReport `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/moderation/reports", moderToken, ""), &queue)
+	if len(queue.Data) != 1 || queue.Data[0].ID != filed.Data.ID {
+		t.Fatalf("queue = %+v", queue.Data)
+	}
+
+	if res := apiDo(t, h, http.MethodPost, reportPath+"/assign", moderToken, `{}`); res.Code != http.StatusNoContent {
+		t.Fatalf("assign status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodPost, reportPath+"/resolve", moderToken, `{}`); res.Code != http.StatusForbidden {
+		t.Fatalf("moderator resolve status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodPost, reportPath+"/resolve", adminToken, `{"note":"spam"}`); res.Code != http.StatusNoContent {
+		t.Fatalf("resolve status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodPost, reportPath+"/dismiss", adminToken, `{}`); res.Code != http.StatusConflict {
+		t.Fatalf("dismiss closed report status=%d body=%s", res.Code, res.Body)
+	}
+
+	var report struct {
+		Data apiReport `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, reportPath, moderToken, ""), &report)
+	if report.Data.State != models.ReportResolved || report.Data.Assignee != "moder" || report.Data.Resolution != "spam" {
+		t.Fatalf("resolved report = %+v", report.Data)
+	}
+
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/moderation/audit", moderToken, ""); res.Code != http.StatusForbidden {
+		t.Fatalf("moderator audit status=%d", res.Code)
+	}
+	var audit struct {
+		Data []apiModerationAction `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/moderation/audit?report="+strconv.Itoa(filed.Data.ID), adminToken, ""), &audit)
+	if len(audit.Data) != 3 || audit.Data[0].Action != models.ActionReportResolved || audit.Data[2].Actor != "moder" {
+		t.Fatalf("audit = %+v", audit.Data)
+	}
+}
+
+func TestAPIPermissions(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+	seedWebUser(t, app, "moder", "moder@example.com", models.ModeratorRole)
+	adminToken := apiLoginToken(t, h, "admin@example.com")
+	moderToken := apiLoginToken(t, h, "moder@example.com")
+
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/admin/permissions", moderToken, ""); res.Code != http.StatusForbidden {
+		t.Fatalf("moderator permissions status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodPost, "/api/v1/tags", moderToken, `{"tags":["rust"]}`); res.Code != http.StatusForbidden {
+		t.Fatalf("moderator add tag status=%d body=%s", res.Code, res.Body)
+	}
+
+	var list struct {
+		Data []apiRolePermissions `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/admin/permissions", adminToken, ""), &list)
+	if len(list.Data) != 4 || list.Data[2].Name != "moderator" || len(list.Data[2].Permissions) != 5 {
+		t.Fatalf("permissions = %+v", list.Data)
+	}
+
+	if res := apiDo(t, h, http.MethodPut, "/api/v1/admin/permissions/9", adminToken, `{"permissions":[]}`); res.Code != http.StatusNotFound {
+		t.Fatalf("unknown role status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodPut, "/api/v1/admin/permissions/3", adminToken, `{"permissions":["tag.frobnicate"]}`); res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unknown permission status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodPut, "/api/v1/admin/permissions/4", adminToken, `{"permissions":["tag.manage"]}`); res.Code != http.StatusConflict {
+		t.Fatalf("admin lockout status=%d body=%s", res.Code, res.Body)
+	}
+
+	res := apiDo(t, h, http.MethodPut, "/api/v1/admin/permissions/3", adminToken, `{"permissions":["tag.manage","report.review"]}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("update status=%d body=%s", res.Code, res.Body)
+	}
+	var updated struct {
+		Data apiRolePermissions `json:"data"`
+	}
+	apiDecode(t, res, &updated)
+	if updated.Data.Role != 3 || strings.Join(updated.Data.Permissions, ",") != "report.review,tag.manage" {
+		t.Fatalf("updated = %+v", updated.Data)
+	}
+
+	if res := apiDo(t, h, http.MethodPost, "/api/v1/tags", moderToken, `{"tags":["rust"]}`); res.Code != http.StatusCreated {
+		t.Fatalf("granted add tag status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/notifications", moderToken, ""); res.Code != http.StatusForbidden {
+		t.Fatalf("revoked notifications status=%d", res.Code)
+	}
+}
+
+func TestAPISessions(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.apiRoutes()
+
+	seedWebUser(t, app, "multi", "multi@example.com", models.UserRole)
+	first := apiLoginToken(t, h, "multi@example.com")
+	second := apiLoginToken(t, h, "multi@example.com")
+
+	var list struct {
+		Data []apiDevice `json:"data"`
+	}
+	apiDecode(t, apiDo(t, h, http.MethodGet, "/api/v1/auth/sessions", first, ""), &list)
+	if len(list.Data) != 2 {
+		t.Fatalf("sessions = %+v", list.Data)
+	}
+	var otherID int
+	for _, s := range list.Data {
+		if !s.Current {
+			otherID = s.ID
+		}
+	}
+	if otherID == 0 {
+		t.Fatalf("no other session in %+v", list.Data)
+	}
+
+	if res := apiDo(t, h, http.MethodDelete, "/api/v1/auth/sessions/999", first, ""); res.Code != http.StatusNotFound {
+		t.Fatalf("revoke unknown status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodDelete, "/api/v1/auth/sessions/"+strconv.Itoa(otherID), first, ""); res.Code != http.StatusNoContent {
+		t.Fatalf("revoke status=%d body=%s", res.Code, res.Body)
+	}
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/me", second, ""); res.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token status=%d", res.Code)
+	}
+	if res := apiDo(t, h, http.MethodGet, "/api/v1/me", first, ""); res.Code != http.StatusOK {
+		t.Fatalf("current token status=%d", res.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+	"github.com/aspandyar/forum/internal/transport/http/jsonresponse"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type contextKey string
+
+const apiUserIDContextKey = contextKey("apiUserID")
+
+const maxAPIBodyBytes = 1 << 20
+
+func (app *application) apiWrite(w http.ResponseWriter, status int, data interface{}) {
+	if err := jsonresponse.Write(w, status, data); err != nil {
+		app.errorLog.Output(2, err.Error())
+	}
+}
+
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	_ = jsonresponse.Error(w, status, message, nil)
+}
+
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	app.apiError(w, http.StatusInternalServerError, "")
+}
+
+// apiValidationError reports the validator's field and non-field errors as a
+// 422 error envelope.
+func (app *application) apiValidationError(w http.ResponseWriter, v validator.Validator) {
+	message := "The request contains invalid fields"
+	if len(v.NonFieldErrors) > 0 {
+		message = strings.Join(v.NonFieldErrors, "; ")
+	}
+	_ = jsonresponse.Error(w, http.StatusUnprocessableEntity, message, v.FieldErrors)
+}
+
+// apiServiceError maps service-layer errors onto HTTP statuses.
+func (app *application) apiServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, forumsvc.ErrNotFound), errors.Is(err, models.ErrNoRecord):
+		app.apiError(w, http.StatusNotFound, "")
+	case errors.Is(err, forumsvc.ErrForbidden):
+		app.apiError(w, http.StatusForbidden, "")
+	case errors.Is(err, models.ErrDuplicateTag):
+		app.apiError(w, http.StatusConflict, "A tag with that name already exists")
+	case errors.Is(err, models.ErrMaxDepth):
+		app.apiError(w, http.StatusUnprocessableEntity, "Replies cannot be nested any deeper")
+	case errors.Is(err, models.ErrInvalidTransition):
+		app.apiError(w, http.StatusConflict, "The report is already closed")
+	case errors.Is(err, models.ErrInvalidState):
+		app.apiError(w, http.StatusConflict, "The post cannot move to that state")
+	case errors.Is(err, models.ErrUnknownExpiry):
+		app.apiError(w, http.StatusUnprocessableEntity, "That lifetime is not offered")
+	case errors.Is(err, models.ErrDefaultExpiry):
+		app.apiError(w, http.StatusConflict, "The default lifetime cannot be removed")
+	case errors.Is(err, policy.ErrUnknownRole):
+		app.apiError(w, http.StatusNotFound, "")
+	case errors.Is(err, policy.ErrUnknownPermission):
+		app.apiError(w, http.StatusUnprocessableEntity, "Unknown permission")
+	case errors.Is(err, policy.ErrLockout):
+		app.apiError(w, http.StatusConflict, "Admins must keep role.assign")
+	default:
+		app.apiServerError(w, err)
+	}
+}
+
+// readJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and bodies over maxAPIBodyBytes.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains an incorrect JSON type for field %q", typeError.Field)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return errors.New("body contains badly-formed JSON")
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+	return nil
+}
+
+func pathValueID(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// apiToken returns the session token from an "Authorization: Bearer" header,
+// falling back to the browser session cookie.
+func apiToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// apiUserID returns the authenticated user, or 0 for guests.
+func (app *application) apiUserID(r *http.Request) int {
+	if userID, ok := r.Context().Value(apiUserIDContextKey).(int); ok {
+		return userID
+	}
+
+	userID, err := app.authService.UserID(r.Context(), apiToken(r))
+	if err != nil {
+		return 0
+	}
+	return userID
+}
+
+func (app *application) requireAPIAuthentication(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := app.authService.UserID(r.Context(), apiToken(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		ctx := context.WithValue(r.Context(), apiUserIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiRequirePermission answers 403 unless the caller's role holds perm. It
+// runs inside requireAPIAuthentication.
+func (app *application) apiRequirePermission(perm policy.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := app.authService.Role(app.apiUserID(r))
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		ok, err := app.policy.Can(role, perm)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if !ok {
+			app.apiError(w, http.StatusForbidden, "")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func likeStatusFromReaction(reaction string) (int, bool) {
+	switch reaction {
+	case "like":
+		return 1, true
+	case "dislike":
+		return -1, true
+	default:
+		return 0, false
+	}
+}
+
+// apiForums is newAPIForumList with image URLs filled in.
+func (app *application) apiForums(forums []*models.Forum) []apiForum {
+	out := newAPIForumList(forums)
+	for i := range out {
+		app.addImageURLs(&out[i])
+	}
+	return out
+}
+
+// addImageURLs sets short-lived URLs of the image of f, if it has one.
+func (app *application) addImageURLs(f *apiForum) {
+	if f.ImagePath != "" {
+		f.ImageURL, f.ThumbnailURL = app.images.URLs(f.ImagePath)
+	}
+}
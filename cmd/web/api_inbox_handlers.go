@@ -0,0 +1,48 @@
+package main
+
+import "net/http"
+
+type apiUnreadCount struct {
+	Unread int `json:"unread"`
+}
+
+func (app *application) apiInbox(w http.ResponseWriter, r *http.Request) {
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, err := app.forumService.Inbox(app.apiUserID(r), unreadOnly)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIInboxList(notifications))
+}
+
+func (app *application) apiInboxUnread(w http.ResponseWriter, r *http.Request) {
+	n, err := app.forumService.UnreadNotifications(app.apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, apiUnreadCount{Unread: n})
+}
+
+func (app *application) apiInboxRead(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	if _, err := app.forumService.ReadNotification(id, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiInboxReadAll(w http.ResponseWriter, r *http.Request) {
+	if err := app.forumService.ReadAllNotifications(app.apiUserID(r)); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type apiReportInput struct {
+	Reasons []string `json:"reasons"`
+	Details string   `json:"details"`
+}
+
+type apiNotificationRef struct {
+	NotificationID int `json:"notification_id"`
+}
+
+type apiReportDecisionInput struct {
+	Note string `json:"note"`
+}
+
+type apiReportAssignInput struct {
+	AssigneeID int `json:"assignee_id"`
+}
+
+type apiPermissionsInput struct {
+	Permissions []string `json:"permissions"`
+}
+
+type apiModeratorRequestInput struct {
+	UserID int `json:"user_id"`
+}
+
+func (app *application) apiNotifications(w http.ResponseWriter, r *http.Request) {
+	notifications, err := app.forumService.Notifications(app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPINotificationList(notifications))
+}
+
+func (app *application) apiNotificationDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	if err := app.forumService.DismissNotification(r.Context(), id, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiModeratorRequest(w http.ResponseWriter, r *http.Request) {
+	if err := app.forumService.RequestModeration(app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *application) apiModeratorRequestApprove(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiModeratorRequestInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.UserID < 1 {
+		var v validator.Validator
+		v.AddFieldError("user_id", "This field must be a positive integer")
+		app.apiValidationError(w, v)
+		return
+	}
+
+	if err := app.forumService.SetModerator(r.Context(), id, input.UserID, true, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiModeratorDemote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiNotificationRef
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := app.forumService.SetModerator(r.Context(), input.NotificationID, userID, false, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiForumApprove(w http.ResponseWriter, r *http.Request) {
+	forumID, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiNotificationRef
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := app.forumService.ApprovePost(r.Context(), input.NotificationID, forumID, app.apiUserID(r)); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiForumReport(w http.ResponseWriter, r *http.Request) {
+	forumID, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiReportInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := forumReportForm{Reasons: input.Reasons, Details: strings.TrimSpace(input.Details)}
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	userID := app.apiUserID(r)
+	id, err := app.forumService.Report(forumID, userID, form.Reasons, form.Details)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+
+	report, err := app.forumService.GetReport(id, userID)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusCreated, newAPIReport(report))
+}
+
+func (app *application) apiReports(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if state != "" && !validator.PermittedValue(state, models.ReportStates...) {
+		var v validator.Validator
+		v.AddFieldError("state", "This field must be one of "+strings.Join(models.ReportStates, ", "))
+		app.apiValidationError(w, v)
+		return
+	}
+
+	reports, err := app.forumService.ReportQueue(state, app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIReportList(reports))
+}
+
+func (app *application) apiReport(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	report, err := app.forumService.GetReport(id, app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIReport(report))
+}
+
+// apiReportAssign puts a report in review, by the caller unless assignee_id
+// names someone else.
+func (app *application) apiReportAssign(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiReportAssignInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := app.apiUserID(r)
+	if input.AssigneeID == 0 {
+		input.AssigneeID = userID
+	}
+	if input.AssigneeID < 0 {
+		var v validator.Validator
+		v.AddFieldError("assignee_id", "This field must be a positive integer")
+		app.apiValidationError(w, v)
+		return
+	}
+
+	if err := app.forumService.AssignReport(id, input.AssigneeID, userID); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiReportResolve(w http.ResponseWriter, r *http.Request) {
+	app.apiReportDecision(w, r, app.forumService.ResolveReport)
+}
+
+func (app *application) apiReportDismiss(w http.ResponseWriter, r *http.Request) {
+	app.apiReportDecision(w, r, app.forumService.DismissReport)
+}
+
+func (app *application) apiReportDecision(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, reportID, userID int, note string) error) {
+	id, ok := pathValueID(r, "id")
+	if !ok {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiReportDecisionInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Note = strings.TrimSpace(input.Note)
+	var v validator.Validator
+	v.CheckField(validator.MaxChars(input.Note, 500), "note", "This field cannot be more than 500 characters long")
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	if err := decide(r.Context(), id, app.apiUserID(r), input.Note); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiAuditLog(w http.ResponseWriter, r *http.Request) {
+	var filter models.AuditFilter
+	var v validator.Validator
+	for key, dst := range map[string]*int{"report": &filter.ReportID, "before": &filter.Before} {
+		if s := r.URL.Query().Get(key); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				v.AddFieldError(key, "This field must be a positive integer")
+				continue
+			}
+			*dst = n
+		}
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	actions, err := app.forumService.AuditLog(filter, app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIModerationActionList(actions))
+}
+
+func (app *application) apiPermissions(w http.ResponseWriter, r *http.Request) {
+	grants, err := app.forumService.Permissions(app.apiUserID(r))
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIRolePermissionsList(grants))
+}
+
+// apiPermissionsUpdate replaces every permission of one role.
+func (app *application) apiPermissionsUpdate(w http.ResponseWriter, r *http.Request) {
+	role, ok := pathValueID(r, "role")
+	if !ok || policy.RoleName(role) == "unknown" {
+		app.apiError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var input apiPermissionsInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	perms := make([]policy.Permission, 0, len(input.Permissions))
+	var v validator.Validator
+	for _, name := range input.Permissions {
+		if !policy.Valid(policy.Permission(name)) {
+			v.AddFieldError("permissions", "Unknown permission "+strconv.Quote(name))
+			continue
+		}
+		perms = append(perms, policy.Permission(name))
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	userID := app.apiUserID(r)
+	if err := app.forumService.SetPermissions(r.Context(), role, perms, userID); err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+
+	grants, err := app.forumService.Permissions(userID)
+	if err != nil {
+		app.apiServiceError(w, err)
+		return
+	}
+	app.apiWrite(w, http.StatusOK, newAPIRolePermissions(role, grants[role]))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+// apiRoutes returns the JSON API mounted under /api/v1/.
+func (app *application) apiRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	auth := func(h http.HandlerFunc) http.Handler {
+		return app.requireAPIAuthentication(h)
+	}
+	allow := func(perm policy.Permission, h http.HandlerFunc) http.Handler {
+		return auth(app.apiRequirePermission(perm, h))
+	}
+
+	mux.HandleFunc("POST /api/v1/auth/signup", app.apiSignup)
+	mux.HandleFunc("POST /api/v1/auth/login", app.apiLogin)
+	mux.Handle("POST /api/v1/auth/logout", auth(app.apiLogout))
+	mux.Handle("GET /api/v1/auth/sessions", auth(app.apiSessions))
+	mux.Handle("DELETE /api/v1/auth/sessions/{id}", auth(app.apiSessionRevoke))
+	mux.Handle("GET /api/v1/me", auth(app.apiMe))
+
+	mux.HandleFunc("GET /api/v1/forums", app.apiForumList)
+	mux.HandleFunc("GET /api/v1/forums/latest", app.apiForumLatest)
+	mux.HandleFunc("GET /api/v1/search", app.apiSearch)
+	mux.Handle("POST /api/v1/forums", auth(app.apiForumCreate))
+	mux.HandleFunc("GET /api/v1/forums/{id}", app.apiForumView)
+	mux.Handle("PUT /api/v1/forums/{id}", auth(app.apiForumUpdate))
+	mux.Handle("DELETE /api/v1/forums/{id}", auth(app.apiForumDelete))
+	mux.Handle("POST /api/v1/forums/{id}/reaction", auth(app.apiForumReact))
+	mux.Handle("POST /api/v1/forums/{id}/comments", auth(app.apiCommentCreate))
+	mux.Handle("POST /api/v1/forums/{id}/reports", allow(policy.ReportFile, app.apiForumReport))
+
+	mux.Handle("PUT /api/v1/comments/{id}", auth(app.apiCommentUpdate))
+	mux.Handle("DELETE /api/v1/comments/{id}", auth(app.apiCommentDelete))
+	mux.Handle("POST /api/v1/comments/{id}/reaction", auth(app.apiCommentReact))
+	mux.Handle("POST /api/v1/comments/{id}/replies", auth(app.apiCommentReply))
+
+	mux.Handle("GET /api/v1/me/forums", auth(app.apiUserForums))
+	mux.Handle("GET /api/v1/me/reactions", auth(app.apiUserReactions))
+	mux.Handle("GET /api/v1/me/comments", auth(app.apiUserComments))
+	mux.Handle("GET /api/v1/me/inbox", auth(app.apiInbox))
+	mux.Handle("GET /api/v1/me/inbox/unread", auth(app.apiInboxUnread))
+	mux.Handle("POST /api/v1/me/inbox/read", auth(app.apiInboxReadAll))
+	mux.Handle("POST /api/v1/me/inbox/{id}/read", auth(app.apiInboxRead))
+
+	mux.HandleFunc("GET /api/v1/tags", app.apiTagList)
+	mux.Handle("POST /api/v1/tags", allow(policy.TagManage, app.apiTagCreate))
+	mux.HandleFunc("GET /api/v1/tags/counts", app.apiTagCounts)
+	mux.Handle("PUT /api/v1/tags/{tag}", allow(policy.TagManage, app.apiTagRename))
+	mux.Handle("DELETE /api/v1/tags/{tag}", allow(policy.TagManage, app.apiTagDelete))
+	mux.Handle("POST /api/v1/tags/{tag}/merge", allow(policy.TagManage, app.apiTagMerge))
+
+	mux.Handle("GET /api/v1/notifications", allow(policy.ModerationQueue, app.apiNotifications))
+	mux.Handle("DELETE /api/v1/notifications/{id}", allow(policy.ModerationQueue, app.apiNotificationDelete))
+
+	mux.Handle("POST /api/v1/moderation/requests", allow(policy.ModerationRequest, app.apiModeratorRequest))
+	mux.Handle("POST /api/v1/moderation/requests/{id}/approve", allow(policy.RoleAssign, app.apiModeratorRequestApprove))
+	mux.Handle("POST /api/v1/moderation/moderators/{id}/demote", allow(policy.RoleAssign, app.apiModeratorDemote))
+	mux.Handle("POST /api/v1/moderation/forums/{id}/approve", allow(policy.PostApprove, app.apiForumApprove))
+	mux.Handle("GET /api/v1/moderation/reports", allow(policy.ReportReview, app.apiReports))
+	mux.Handle("GET /api/v1/moderation/reports/{id}", allow(policy.ReportReview, app.apiReport))
+	mux.Handle("POST /api/v1/moderation/reports/{id}/assign", allow(policy.ReportReview, app.apiReportAssign))
+	mux.Handle("POST /api/v1/moderation/reports/{id}/resolve", allow(policy.ReportResolve, app.apiReportResolve))
+	mux.Handle("POST /api/v1/moderation/reports/{id}/dismiss", allow(policy.ReportReview, app.apiReportDismiss))
+	mux.Handle("GET /api/v1/moderation/audit", allow(policy.AuditRead, app.apiAuditLog))
+
+	mux.Handle("GET /api/v1/admin/permissions", allow(policy.RoleAssign, app.apiPermissions))
+	mux.Handle("PUT /api/v1/admin/permissions/{role}", allow(policy.RoleAssign, app.apiPermissionsUpdate))
+
+	return app.apiNotFound(mux)
+}
+
+var apiMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+// apiNotFound answers unmatched API requests with JSON 404 and 405 errors
+// instead of the mux's plain-text defaults.
+func (app *application) apiNotFound(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		var allowed []string
+		for _, method := range apiMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) == 0 {
+			app.apiError(w, http.StatusNotFound, "")
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		app.apiError(w, http.StatusMethodNotAllowed, "")
+	})
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+type apiForum struct {
+	ID           int          `json:"id"`
+	Title        string       `json:"title"`
+	Content      string       `json:"content"`
+	Tags         []string     `json:"tags"`
+	Created      time.Time    `json:"created"`
+	Expires      time.Time    `json:"expires"`
+	Edited       *time.Time   `json:"edited,omitempty"`
+	State        string       `json:"state,omitempty"`
+	StateReason  string       `json:"state_reason,omitempty"`
+	ImagePath    string       `json:"image_path,omitempty"`
+	ImageURL     string       `json:"image_url,omitempty"`
+	ThumbnailURL string       `json:"thumbnail_url,omitempty"`
+	Likes        *int         `json:"likes,omitempty"`
+	Dislikes     *int         `json:"dislikes,omitempty"`
+	Reaction     string       `json:"reaction,omitempty"`
+	CommentCount *int         `json:"comment_count,omitempty"`
+	CanEdit      bool         `json:"can_edit,omitempty"`
+	Comments     []apiComment `json:"comments,omitempty"`
+}
+
+type apiComment struct {
+	ID       int          `json:"id"`
+	ForumID  int          `json:"forum_id"`
+	ParentID int          `json:"parent_id,omitempty"`
+	Depth    int          `json:"depth"`
+	User     string       `json:"user,omitempty"`
+	Comment  string       `json:"comment"`
+	Edited   *time.Time   `json:"edited,omitempty"`
+	Deleted  bool         `json:"deleted,omitempty"`
+	Likes    *int         `json:"likes,omitempty"`
+	Dislikes *int         `json:"dislikes,omitempty"`
+	Reaction string       `json:"reaction,omitempty"`
+	CanEdit  bool         `json:"can_edit,omitempty"`
+	CanReply bool         `json:"can_reply,omitempty"`
+	Replies  []apiComment `json:"replies,omitempty"`
+}
+
+type apiNotification struct {
+	ID              int    `json:"id"`
+	Status          string `json:"status"`
+	Body            string `json:"body"`
+	ForumID         int    `json:"forum_id"`
+	UserID          int    `json:"user_id"`
+	UserCommented   string `json:"from_user"`
+	UserCommentedID int    `json:"from_user_id"`
+}
+
+type apiReport struct {
+	ID         int       `json:"id"`
+	ForumID    int       `json:"forum_id"`
+	ForumTitle string    `json:"forum_title"`
+	ReporterID int       `json:"reporter_id"`
+	Reporter   string    `json:"reporter"`
+	Reasons    []string  `json:"reasons"`
+	Details    string    `json:"details,omitempty"`
+	State      string    `json:"state"`
+	AssigneeID int       `json:"assignee_id,omitempty"`
+	Assignee   string    `json:"assignee,omitempty"`
+	Resolution string    `json:"resolution,omitempty"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+type apiModerationAction struct {
+	ID           int       `json:"id"`
+	ActorID      int       `json:"actor_id"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	ReportID     int       `json:"report_id,omitempty"`
+	ForumID      int       `json:"forum_id,omitempty"`
+	TargetUserID int       `json:"target_user_id,omitempty"`
+	TargetUser   string    `json:"target_user,omitempty"`
+	Note         string    `json:"note,omitempty"`
+	Created      time.Time `json:"created"`
+}
+
+type apiInboxNotification struct {
+	ID         int       `json:"id"`
+	Event      string    `json:"event"`
+	ActorID    int       `json:"actor_id"`
+	Actor      string    `json:"actor"`
+	ForumID    int       `json:"forum_id"`
+	ForumTitle string    `json:"forum_title"`
+	CommentID  int       `json:"comment_id,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Created    time.Time `json:"created"`
+	Read       bool      `json:"read"`
+}
+
+type apiRolePermissions struct {
+	Role        int      `json:"role"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type apiTagCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type apiSearchResult struct {
+	Kind      string  `json:"kind"`
+	ForumID   int     `json:"forum_id"`
+	CommentID int     `json:"comment_id,omitempty"`
+	Title     string  `json:"title"`
+	Author    string  `json:"author"`
+	Snippet   string  `json:"snippet"`
+	Rank      float64 `json:"rank"`
+}
+
+func splitTags(tags string) []string {
+	out := []string{}
+	for _, tag := range strings.Split(tags, ", ") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func reactionName(reacted, liked bool) string {
+	if !reacted {
+		return ""
+	}
+	if liked {
+		return "like"
+	}
+	return "dislike"
+}
+
+func newAPIForumSummary(f *models.Forum) apiForum {
+	likes, dislikes, comments := f.LikesCount, f.DislikesCount, f.CommentsCount
+	return apiForum{
+		ID:           f.ID,
+		Title:        f.Title,
+		Content:      f.Content,
+		Tags:         splitTags(f.Tags),
+		Created:      f.Created,
+		Expires:      f.Expires,
+		State:        f.State,
+		StateReason:  f.StateReason,
+		ImagePath:    f.ImagePath,
+		Likes:        &likes,
+		Dislikes:     &dislikes,
+		CommentCount: &comments,
+	}
+}
+
+func newAPIForumList(forums []*models.Forum) []apiForum {
+	out := make([]apiForum, 0, len(forums))
+	for _, f := range forums {
+		out = append(out, newAPIForumSummary(f))
+	}
+	return out
+}
+
+// editedAt is nil for content that was never edited.
+func editedAt(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func newAPIForum(f *models.Forum) apiForum {
+	out := newAPIForumSummary(f)
+	out.Edited = editedAt(f.Edited)
+	out.Reaction = reactionName(f.Reacted, f.Liked)
+	out.CanEdit = f.IsOwnForum
+	out.Comments = newAPICommentThread(f.ID, f.Comment)
+	return out
+}
+
+func newAPICommentThread(forumID int, comments []models.UserComment) []apiComment {
+	out := make([]apiComment, 0, len(comments))
+	for _, c := range comments {
+		likes, dislikes := c.LikesCount, c.DislikesCount
+		out = append(out, apiComment{
+			ID:       c.CommentID,
+			ForumID:  forumID,
+			ParentID: c.ParentID,
+			Depth:    c.Depth,
+			User:     c.User,
+			Comment:  c.Comment,
+			Edited:   editedAt(c.Edited),
+			Deleted:  c.Deleted,
+			Likes:    &likes,
+			Dislikes: &dislikes,
+			Reaction: reactionName(c.Reacted, c.Liked),
+			CanEdit:  c.IsOwnComment,
+			CanReply: c.CanReply(),
+			Replies:  newAPICommentThread(forumID, c.Replies),
+		})
+	}
+	return out
+}
+
+func newAPICommentList(comments []*models.ForumComment) []apiComment {
+	out := make([]apiComment, 0, len(comments))
+	for _, c := range comments {
+		out = append(out, apiComment{ID: c.ID, ForumID: c.ForumID, ParentID: c.ParentID, Depth: c.Depth, Comment: c.Comment})
+	}
+	return out
+}
+
+func newAPINotificationList(notifications []*models.Notification) []apiNotification {
+	out := make([]apiNotification, 0, len(notifications))
+	for _, n := range notifications {
+		out = append(out, apiNotification{
+			ID:              n.ID,
+			Status:          n.Status,
+			Body:            n.Body,
+			ForumID:         n.ForumID,
+			UserID:          n.UserID,
+			UserCommented:   n.UserCommented,
+			UserCommentedID: n.UserCommentedID,
+		})
+	}
+	return out
+}
+
+func newAPIReport(r *models.Report) apiReport {
+	reasons := r.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return apiReport{
+		ID:         r.ID,
+		ForumID:    r.ForumID,
+		ForumTitle: r.ForumTitle,
+		ReporterID: r.ReporterID,
+		Reporter:   r.ReporterName,
+		Reasons:    reasons,
+		Details:    r.Details,
+		State:      r.State,
+		AssigneeID: r.AssigneeID,
+		Assignee:   r.AssigneeName,
+		Resolution: r.Resolution,
+		Created:    r.Created,
+		Updated:    r.Updated,
+	}
+}
+
+func newAPIReportList(reports []*models.Report) []apiReport {
+	out := make([]apiReport, 0, len(reports))
+	for _, r := range reports {
+		out = append(out, newAPIReport(r))
+	}
+	return out
+}
+
+func newAPIModerationActionList(actions []*models.ModerationAction) []apiModerationAction {
+	out := make([]apiModerationAction, 0, len(actions))
+	for _, a := range actions {
+		out = append(out, apiModerationAction{
+			ID:           a.ID,
+			ActorID:      a.ActorID,
+			Actor:        a.ActorName,
+			Action:       a.Action,
+			ReportID:     a.ReportID,
+			ForumID:      a.ForumID,
+			TargetUserID: a.TargetUserID,
+			TargetUser:   a.TargetName,
+			Note:         a.Note,
+			Created:      a.Created,
+		})
+	}
+	return out
+}
+
+func newAPIInboxList(notifications []*models.InboxNotification) []apiInboxNotification {
+	out := make([]apiInboxNotification, 0, len(notifications))
+	for _, n := range notifications {
+		out = append(out, apiInboxNotification{
+			ID:         n.ID,
+			Event:      n.Event,
+			ActorID:    n.ActorID,
+			Actor:      n.ActorName,
+			ForumID:    n.ForumID,
+			ForumTitle: n.ForumTitle,
+			CommentID:  n.CommentID,
+			Body:       n.Body,
+			Created:    n.Created,
+			Read:       n.Read,
+		})
+	}
+	return out
+}
+
+func newAPIRolePermissions(role int, perms []policy.Permission) apiRolePermissions {
+	out := apiRolePermissions{Role: role, Name: policy.RoleName(role), Permissions: make([]string, 0, len(perms))}
+	for _, perm := range perms {
+		out.Permissions = append(out.Permissions, string(perm))
+	}
+	return out
+}
+
+func newAPIRolePermissionsList(grants map[int][]policy.Permission) []apiRolePermissions {
+	out := make([]apiRolePermissions, 0, len(policy.Roles))
+	for _, role := range policy.Roles {
+		out = append(out, newAPIRolePermissions(role, grants[role]))
+	}
+	return out
+}
+
+func newAPITagCountList(counts []*models.TagCount) []apiTagCount {
+	out := make([]apiTagCount, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, apiTagCount{ID: c.ID, Name: c.Name, Count: c.Count})
+	}
+	return out
+}
+
+func newAPISearchResults(results []*models.SearchResult) []apiSearchResult {
+	out := make([]apiSearchResult, 0, len(results))
+	for _, r := range results {
+		kind := "post"
+		if r.IsComment() {
+			kind = "comment"
+		}
+		out = append(out, apiSearchResult{
+			Kind:      kind,
+			ForumID:   r.ForumID,
+			CommentID: r.CommentID,
+			Title:     r.Title,
+			Author:    r.Author,
+			Snippet:   snippetHTML(r.Snippet),
+			Rank:      r.Rank,
+		})
+	}
+	return out
+}
+
+// snippetHTML escapes the snippet text and wraps matched terms in <mark>.
+func snippetHTML(parts []models.SnippetPart) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Match {
+			b.WriteString("<mark>" + html.EscapeString(p.Text) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(p.Text))
+		}
+	}
+	return b.String()
+}
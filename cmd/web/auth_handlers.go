@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
 	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
-	"github.com/aspandyar/forum/internal/validator"
 )
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
@@ -39,14 +39,10 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		Name:     r.PostForm.Get("name"),
 		Email:    r.PostForm.Get("email"),
 		Password: r.PostForm.Get("password"),
-		Role:     userRole,
+		Role:     policy.User,
 	}
 
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.validate()
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -55,7 +51,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password, form.Role)
+	err = app.users.Insert(r.Context(), form.Name, form.Email, form.Password, form.Role)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email or name address is already in use")
@@ -69,6 +65,12 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account works without verification; a lost email is not worth
+	// failing the signup over, and the user can ask for another link.
+	if err := app.authService.SendVerification(r.Context(), form.Email); err != nil {
+		app.errorLog.Printf("send verification to %s: %v", form.Email, err)
+	}
+
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
@@ -103,9 +105,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		Password: r.PostForm.Get("password"),
 	}
 
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.validate()
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -127,24 +127,28 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := app.sessions.CreateSession(userID)
-	if err != nil {
+	if err := app.startSession(w, r, userID); err != nil {
 		app.serverError(w, err)
 		return
 	}
-	sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
 
 	http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
 }
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	cookie, err := r.Cookie("session")
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	err = app.sessions.InvalidateSession(cookie.Value)
+	err = app.sessions.InvalidateSession(r.Context(), cookie.Value)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+var errCountersUsage = errors.New("usage: forum counters repair")
+
+// runCounters implements `forum counters repair`, which recomputes the vote
+// and comment counters kept on posts and comments.
+func runCounters(forums *models.ForumModel, args []string, out io.Writer) error {
+	if len(args) != 1 || args[0] != "repair" {
+		return errCountersUsage
+	}
+
+	posts, comments, err := forums.RepairCounters()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "repaired counters of %d post(s) and %d comment(s)\n", posts, comments)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunCounters(t *testing.T) {
+	app, db := newWebTestApp(t)
+	userID := seedWebUser(t, app, "counted", "counted@example.com", 2)
+	forumID, err := app.forums.Insert(context.Background(), "counted", "body", "go", 7, userID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE forums SET dislikes_count = 3 WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runCounters(app.forums, []string{"repair"}, &out); err != nil {
+		t.Fatalf("counters repair: %v", err)
+	}
+	if got := out.String(); got != "repaired counters of 1 post(s) and 0 comment(s)\n" {
+		t.Fatalf("output = %q", got)
+	}
+	for _, args := range [][]string{nil, {"fix"}, {"repair", "now"}} {
+		if err := runCounters(app.forums, args, &out); !errors.Is(err, errCountersUsage) {
+			t.Fatalf("runCounters(%q) err = %v, want usage", args, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type expiryForm struct {
+	Policies []*models.ExpiryPolicy
+	Days     string
+	Label    string
+	validator.Validator
+}
+
+// maxExpiryDays bounds the lifetimes admins can offer, to about ten years.
+const maxExpiryDays = 3650
+
+// adminExpiry lists the lifetimes offered for posts and changes them:
+// action is set (days and label, 0 days for never), remove or default.
+func (app *application) adminExpiry(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/expiry" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	policies, err := app.forumService.ExpiryPolicies()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		data := app.newTemplateData(r)
+		data.Form = expiryForm{Policies: policies}
+		app.render(w, http.StatusOK, "expiry.tmpl.html", data)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := expiryForm{
+		Policies: policies,
+		Days:     r.PostForm.Get("days"),
+		Label:    strings.TrimSpace(r.PostForm.Get("label")),
+	}
+	days, err := strconv.Atoi(form.Days)
+	if err != nil {
+		days = -1
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	switch r.PostForm.Get("action") {
+	case "set":
+		form.CheckField(days >= 0 && days <= maxExpiryDays, "days", "This field must be between 0 and "+strconv.Itoa(maxExpiryDays))
+		form.CheckField(validator.NotBlank(form.Label), "label", "This field cannot be blank")
+		form.CheckField(validator.MaxChars(form.Label, 40), "label", "This field cannot be more than 40 characters long")
+		if !form.Valid() {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "expiry.tmpl.html", data)
+			return
+		}
+		err = app.forumService.SetExpiryPolicy(r.Context(), days, form.Label, userID)
+	case "remove":
+		err = app.forumService.RemoveExpiryPolicy(r.Context(), days, userID)
+	case "default":
+		err = app.forumService.SetDefaultExpiry(r.Context(), days, userID)
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/expiry", http.StatusSeeOther)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+func TestExpiryHandlers(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["view.tmpl.html"] = mustTemplate(`{{define "base"}}{{with .Form}}{{.State}}:{{.Open}}{{end}}:{{len .ExpiryPolicies}}{{end}}`)
+	app.tempalteCache["expiry.tmpl.html"] = mustTemplate(`{{define "base"}}{{range .Form.Policies}}{{.Days}}{{if .Default}}*{{end}} {{end}}{{range $k, $v := .Form.FieldErrors}}{{$k}} {{end}}{{end}}`)
+	h := app.routes()
+	const remoteAddr = "198.51.100.22:4000"
+
+	authorID := seedWebUser(t, app, "author", "author@example.com", models.UserRole)
+	otherID := seedWebUser(t, app, "other", "other@example.com", models.UserRole)
+	adminID := seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+
+	forumID, err := app.forums.Insert(context.Background(), "short lived", "body", "go", 1, authorID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.forums.ChangeForumStatus(forumID, models.PostPublished); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(forumID)
+
+	do := func(method, target string, userID int, form url.Values) (int, string) {
+		req, rr := newRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		if userID > 0 {
+			attachSessionCookie(t, app, req, userID)
+		}
+		attachCSRF(req)
+		h.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	view := "/forum/view/" + id
+	state := "/forum/state/" + id
+	renew := func(days string) url.Values { return url.Values{"action": {"renew"}, "expires": {days}} }
+
+	if code, body := do(http.MethodGet, view, authorID, nil); code != http.StatusOK || body != "published:true:3" {
+		t.Fatalf("author viewing a live post = %d %q", code, body)
+	}
+
+	// An hour past its expiry the sweep archives it.
+	if _, err := db.Exec(`UPDATE forums SET expires = datetime('now', '-1 hour') WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+	if _, archived, _, err := app.forumService.SweepExpiry(context.Background(), time.Now()); err != nil || archived != 1 {
+		t.Fatalf("SweepExpiry archived %d, %v", archived, err)
+	}
+	if code, body := do(http.MethodGet, view, otherID, nil); code != http.StatusGone || body != "archived:false:0" {
+		t.Fatalf("viewing an expired post = %d %q, want 410", code, body)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, url.Values{"action": {"unarchive"}}); code != http.StatusConflict {
+		t.Fatalf("unarchiving an expired post = %d, want 409", code)
+	}
+
+	if code, _ := do(http.MethodPost, state, otherID, renew("7")); code != http.StatusForbidden {
+		t.Fatalf("renewing another user's post = %d, want 403", code)
+	}
+	for _, days := range []string{"30", "soon"} {
+		if code, _ := do(http.MethodPost, state, authorID, renew(days)); code != http.StatusUnprocessableEntity {
+			t.Fatalf("renewing for %q = %d, want 422", days, code)
+		}
+	}
+	if code, _ := do(http.MethodPost, state, authorID, renew("7")); code != http.StatusSeeOther {
+		t.Fatalf("renewing = %d", code)
+	}
+	if code, body := do(http.MethodGet, view, otherID, nil); code != http.StatusOK || body != "published:true:0" {
+		t.Fatalf("viewing a renewed post = %d %q", code, body)
+	}
+
+	// Admins choose the lifetimes.
+	const admin = "/admin/expiry"
+	if code, _ := do(http.MethodGet, admin, authorID, nil); code != http.StatusForbidden {
+		t.Fatalf("user on the expiry page = %d, want 403", code)
+	}
+	if code, body := do(http.MethodGet, admin, adminID, nil); code != http.StatusOK || body != "1 7 365* " {
+		t.Fatalf("expiry page = %d %q", code, body)
+	}
+	set := url.Values{"action": {"set"}, "days": {"0"}, "label": {"Never"}}
+	if code, _ := do(http.MethodPost, admin, adminID, set); code != http.StatusSeeOther {
+		t.Fatalf("offering never = %d", code)
+	}
+	bad := url.Values{"action": {"set"}, "days": {"-3"}, "label": {" "}}
+	if code, body := do(http.MethodPost, admin, adminID, bad); code != http.StatusUnprocessableEntity || body != "1 7 365* 0 days label " {
+		t.Fatalf("offering a bad lifetime = %d %q", code, body)
+	}
+	remove := func(days string) url.Values { return url.Values{"action": {"remove"}, "days": {days}} }
+	if code, _ := do(http.MethodPost, admin, adminID, remove("365")); code != http.StatusConflict {
+		t.Fatalf("removing the default = %d, want 409", code)
+	}
+	if code, _ := do(http.MethodPost, admin, adminID, remove("30")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("removing an unknown lifetime = %d, want 422", code)
+	}
+	if code, _ := do(http.MethodPost, admin, adminID, url.Values{"action": {"default"}, "days": {"0"}}); code != http.StatusSeeOther {
+		t.Fatalf("making never the default = %d", code)
+	}
+	if code, _ := do(http.MethodPost, admin, adminID, remove("1")); code != http.StatusSeeOther {
+		t.Fatalf("removing a lifetime = %d", code)
+	}
+	if code, body := do(http.MethodGet, admin, adminID, nil); code != http.StatusOK || body != "7 365 0* " {
+		t.Fatalf("expiry page after changes = %d %q", code, body)
+	}
+	var changes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM moderation_actions WHERE action = ?`, models.ActionExpiryChanged).Scan(&changes); err != nil {
+		t.Fatal(err)
+	}
+	if changes != 3 {
+		t.Fatalf("%d expiry changes audited, want 3", changes)
+	}
+
+	// The removed lifetime is no longer accepted; never is.
+	if code, _ := do(http.MethodPost, state, authorID, renew("1")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("renewing for a removed lifetime = %d, want 422", code)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, renew("0")); code != http.StatusSeeOther {
+		t.Fatalf("renewing for ever = %d", code)
+	}
+	f, err := app.forums.Get(forumID, authorID, true)
+	if err != nil || !f.NeverExpires() {
+		t.Fatalf("renewed for ever, expires %v, %v", f.Expires, err)
+	}
+}
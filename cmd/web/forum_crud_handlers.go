@@ -3,15 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/aspandyar/forum/internal/markdown"
+	"github.com/aspandyar/forum/internal/media"
 	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
 	"github.com/aspandyar/forum/internal/validator"
 )
 
@@ -34,51 +33,29 @@ func (app *application) ForumCreateGet(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
-	form := forumCreateForm{Expires: 365, AllTags: tags}
+	lifetimes, err := app.forumService.ExpiryPolicies()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	form := forumCreateForm{Expires: defaultLifetime(lifetimes), AllTags: tags, Lifetimes: lifetimes}
 	data.Form = form
 	app.render(w, http.StatusOK, "create.tmpl.html", data)
 }
 
 func (app *application) ForumCreatePost(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	maxFileSize := int64(20 * 1024 * 1024)
-	err = r.ParseMultipartForm(maxFileSize)
+	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadGateway)
 		return
 	}
-	var imagePath string
-	file, fileHeader, err := r.FormFile("image-upload")
-	if err == nil {
-		filename := fileHeader.Filename
-		extension := filepath.Ext(filename)
-		newFilename := strconv.FormatInt(time.Now().UnixNano(), 10) + extension
-		imagePath = filepath.Join("/static/images", newFilename)
-		imageOut := "/ui" + imagePath
-		f, err := os.OpenFile("."+imageOut, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		defer f.Close()
-		if fileHeader.Size > maxFileSize {
-			app.clientError(w, http.StatusBadRequest)
-			return
-		}
-		_, err = io.Copy(f, file)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		defer file.Close()
-	}
-	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+	lifetimes, err := app.forumService.ExpiryPolicies()
 	if err != nil {
-		app.clientError(w, http.StatusBadGateway)
+		app.serverError(w, err)
 		return
 	}
 	tags := app.processTags(r.Form["tags"], r.PostForm.Get("custom_tags"))
@@ -88,54 +65,80 @@ func (app *application) ForumCreatePost(w http.ResponseWriter, r *http.Request)
 		Content:   r.PostForm.Get("content"),
 		Tags:      tagsStr,
 		Expires:   expires,
-		ImagePath: imagePath,
-	}
-	form.CheckField(validator.IncorrectInput(form.Tags), "tags", "Incorrect tags formation")
-	form.CheckField(validator.MaxChars(form.Tags, 50), "tags", "This field cannot be more than 50 characters long")
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+		Lifetimes: lifetimes,
+	}
+	form.validate()
+	img, err := readUpload(r, &form.Validator)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 		return
 	}
-	cookie, err := r.Cookie("session")
+	userID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	userID, _, err := app.sessions.GetSession(cookie.Value)
+	if img != nil {
+		if form.ImagePath, err = app.images.Save(img); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	post := forumsvc.Post{
+		Title:     form.Title,
+		Content:   form.Content,
+		Tags:      form.Tags,
+		Expires:   form.Expires,
+		ImagePath: form.ImagePath,
+		Draft:     r.PostForm.Get("action") == "draft",
+	}
+	id, err := app.forumService.Create(r.Context(), post, userID)
 	if err != nil {
+		app.forumService.ReleaseImage(r.Context(), form.ImagePath)
 		app.serverError(w, err)
 		return
 	}
-	id, err := app.forums.Insert(form.Title, form.Content, form.Tags, expires, userID, imagePath)
-	if err != nil {
-		app.serverError(w, err)
+	// Drafts are not listed anywhere but the author's posts, so take the
+	// author to it.
+	if post.Draft {
+		http.Redirect(w, r, fmt.Sprintf("/forum/view/%d", id), http.StatusSeeOther)
 		return
 	}
-	role, err := app.forums.GetRoleByUserID(userID)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// uploadMemory is how much of a multipart form is held in memory; the rest
+// of a file is buffered on disk.
+const uploadMemory = 10 << 20
+
+// readUpload processes the image-upload file of a post form, if one was
+// sent. A file that is not an image we accept is reported on v.
+func readUpload(r *http.Request, v *validator.Validator) (*media.Image, error) {
+	file, _, err := r.FormFile("image-upload")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
 	if err != nil {
-		app.serverError(w, err)
-		return
+		return nil, err
 	}
-	if role == adminRole {
-		err = app.forums.ChangeForumStatus(id, visibleStatus)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-	} else {
-		err = app.forums.AskForNewForum(id, userID, form.Title+"\n"+form.Content)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	defer file.Close()
+
+	img, err := media.Process(file)
+	switch {
+	case errors.Is(err, media.ErrUnsupported):
+		v.AddFieldError("image", "This file must be a JPEG, PNG, GIF or WebP image")
+	case errors.Is(err, media.ErrTooLarge):
+		v.AddFieldError("image", "This image is too large")
+	case err != nil:
+		return nil, err
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return img, nil
 }
 
 func (app *application) handleForumEdit(w http.ResponseWriter, r *http.Request) {
@@ -167,18 +170,22 @@ func (app *application) ForumEditGet(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		userID = 0
 	} else {
-		userID, _, err = app.sessions.GetSession(cookie.Value)
+		userID, _, err = app.sessions.GetSession(r.Context(), cookie.Value)
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
 	}
-	userFromForum, err := app.forums.GetUserIDFromForum(id)
+	status, err := app.forums.Status(id)
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
-	isOwn := app.isOwnForum(userFromForum, r)
+	isOwn := app.isOwnForum(status.OwnerID, r)
 	forum, err := app.forums.Get(id, userID, isOwn)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
@@ -188,9 +195,14 @@ func (app *application) ForumEditGet(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	lifetimes, err := app.forumService.ExpiryPolicies()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data := app.newTemplateData(r)
 	data.Forum = forum
-	data.Form = forumCreateForm{Expires: 365}
+	data.Form = forumCreateForm{Expires: defaultLifetime(lifetimes), Lifetimes: lifetimes}
 	app.render(w, http.StatusOK, "edit.tmpl.html", data)
 }
 
@@ -206,45 +218,18 @@ func (app *application) ForumEditPost(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	err = r.ParseForm()
-	if err != nil {
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	maxFileSize := int64(20 * 1024 * 1024)
-	err = r.ParseMultipartForm(maxFileSize)
+	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadGateway)
 		return
 	}
-	var imagePath string
-	file, fileHeader, err := r.FormFile("image-upload")
-	if err == nil {
-		filename := fileHeader.Filename
-		extension := filepath.Ext(filename)
-		newFilename := strconv.FormatInt(time.Now().UnixNano(), 10) + extension
-		imagePath = filepath.Join("/static/images", newFilename)
-		imageOut := "/ui" + imagePath
-		f, err := os.OpenFile("."+imageOut, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		defer f.Close()
-		if fileHeader.Size > maxFileSize {
-			app.clientError(w, http.StatusBadRequest)
-			return
-		}
-		_, err = io.Copy(f, file)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		defer file.Close()
-	}
-	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+	lifetimes, err := app.forumService.ExpiryPolicies()
 	if err != nil {
-		app.clientError(w, http.StatusBadGateway)
+		app.serverError(w, err)
 		return
 	}
 	tags := app.processTags(r.Form["tags"], r.PostForm.Get("custom_tags"))
@@ -254,34 +239,68 @@ func (app *application) ForumEditPost(w http.ResponseWriter, r *http.Request) {
 		Content:   r.PostForm.Get("content"),
 		Tags:      tagsStr,
 		Expires:   expires,
-		ImagePath: imagePath,
-	}
-	form.CheckField(validator.IncorrectInput(form.Tags), "tags", "Incorrect tags formation")
-	form.CheckField(validator.MaxChars(form.Tags, 50), "tags", "This field cannot be more than 50 characters long")
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+		Lifetimes: lifetimes,
+	}
+	form.validate()
+	img, err := readUpload(r, &form.Validator)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 		return
 	}
-	cookie, err := r.Cookie("session")
+	userID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	userID, _, err := app.sessions.GetSession(cookie.Value)
-	if err != nil {
-		app.serverError(w, err)
-		return
+	if img != nil {
+		if form.ImagePath, err = app.images.Save(img); err != nil {
+			app.serverError(w, err)
+			return
+		}
 	}
-	err = app.forums.Edit(form.Title, form.Content, form.Tags, expires, userID, imagePath, forumID)
+	post := forumsvc.Post{
+		Title:       form.Title,
+		Content:     form.Content,
+		Tags:        form.Tags,
+		Expires:     form.Expires,
+		ImagePath:   form.ImagePath,
+		RemoveImage: r.PostForm.Get("remove-image") != "",
+	}
+	err = app.forumService.Update(r.Context(), forumID, post, userID)
 	if err != nil {
-		app.serverError(w, err)
+		app.forumService.ReleaseImage(r.Context(), form.ImagePath)
+		switch {
+		case errors.Is(err, forumsvc.ErrNotFound):
+			app.notFound(w)
+		case errors.Is(err, forumsvc.ErrForbidden):
+			app.clientError(w, http.StatusForbidden)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d", forumID), http.StatusSeeOther)
 }
+
+// forumPreview renders the Markdown in the content field the way a post
+// shows it, for the preview on the create and edit pages.
+func (app *application) forumPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, markdown.Render(r.PostForm.Get("content")))
+}
@@ -19,15 +19,15 @@ func TestForumCreateGetAndPostHardBranches(t *testing.T) {
 	req, rr = newRequest(http.MethodPost, "/forum/create", strings.NewReader("%%%"))
 	req.Header.Set("Content-Type", "multipart/form-data")
 	app.ForumCreatePost(rr, req)
-	if rr.Code != http.StatusInternalServerError {
+	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("ForumCreatePost malformed multipart status=%d", rr.Code)
 	}
 
 	req, rr = newRequest(http.MethodPost, "/forum/create", strings.NewReader("expires=bad"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	app.ForumCreatePost(rr, req)
-	if rr.Code != http.StatusInternalServerError {
-		t.Fatalf("ForumCreatePost bad expires status=%d", rr.Code)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("ForumCreatePost urlencoded form status=%d", rr.Code)
 	}
 }
 
@@ -52,7 +52,7 @@ func TestForumEditGetAndPostHardBranches(t *testing.T) {
 	req, rr = newRequest(http.MethodPost, "/forum/edit/1", strings.NewReader("%%%"))
 	req.Header.Set("Content-Type", "multipart/form-data")
 	app.ForumEditPost(rr, req)
-	if rr.Code != http.StatusInternalServerError {
+	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("ForumEditPost malformed multipart status=%d", rr.Code)
 	}
 }
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
 )
 
 func (app *application) handleForumRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	path := r.URL.Path
 	parts := strings.Split(path, "/")
 
@@ -22,21 +31,22 @@ func (app *application) handleForumRemove(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	userFromForum, err := app.forums.GetUserIDFromForum(forumID)
+	userID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	isOwn := app.isOwnForum(userFromForum, r)
-	if !isOwn {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	err = app.forums.Remove(forumID)
+	err = app.forumService.Delete(r.Context(), forumID, userID)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, forumsvc.ErrNotFound):
+			app.notFound(w)
+		case errors.Is(err, forumsvc.ErrForbidden):
+			app.clientError(w, http.StatusBadRequest)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
 	"github.com/aspandyar/forum/internal/validator"
 )
 
@@ -196,6 +198,65 @@ func (app *application) ForumCommentPost(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d", id), http.StatusSeeOther)
 }
 
+func (app *application) ForumReplyCommentPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 {
+		http.NotFound(w, r)
+		return
+	}
+
+	parentID, err := strconv.Atoi(parts[4])
+	if err != nil || parentID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forumCommentForm{
+		UserID:  userID,
+		Comment: r.PostForm.Get("comment"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Comment), "comment", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	forumID, replyID, err := app.forumService.Reply(parentID, form.UserID, form.Comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, forumsvc.ErrNotFound):
+			app.notFound(w)
+		case errors.Is(err, models.ErrMaxDepth):
+			app.clientError(w, http.StatusUnprocessableEntity)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d#comment-%d", forumID, replyID), http.StatusSeeOther)
+}
+
 func (app *application) handleForumEditComment(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -235,7 +296,7 @@ func (app *application) ForumEditCommentGet(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		userID = 0
 	} else {
-		userID, _, err = app.sessions.GetSession(cookie.Value)
+		userID, _, err = app.sessions.GetSession(r.Context(), cookie.Value)
 		if err != nil {
 			app.serverError(w, err)
 			return
@@ -258,6 +319,9 @@ func (app *application) ForumEditCommentGet(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+	if app.can(r, policy.CommentEditAny) {
+		forum.ManageComments()
+	}
 
 	data := app.newTemplateData(r)
 	data.Forum = forum
@@ -279,6 +343,11 @@ func (app *application) ForumEditCommentPost(w http.ResponseWriter, r *http.Requ
 
 	path := r.URL.Path
 	parts := strings.Split(path, "/")
+	if len(parts) != 6 || parts[1] != "forum" || parts[2] != "comment" || parts[3] != "edit" {
+		http.NotFound(w, r)
+		return
+	}
+
 	idStr := parts[4]
 	forumId, err := strconv.Atoi(idStr)
 	if err != nil || forumId < 1 {
@@ -313,16 +382,29 @@ func (app *application) ForumEditCommentPost(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.forumComment.EditCommentPost(form.ForumID, form.UserID, form.Comment, commentID)
+	forumID, err := app.forumService.EditComment(commentID, form.UserID, form.Comment)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, forumsvc.ErrNotFound):
+			app.notFound(w)
+		case errors.Is(err, forumsvc.ErrForbidden):
+			app.clientError(w, http.StatusForbidden)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d", form.ForumID), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d#comment-%d", forumID, commentID), http.StatusSeeOther)
 }
 
 func (app *application) ForumRemoveCommentPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	path := r.URL.Path
 	parts := strings.Split(path, "/")
 
@@ -344,13 +426,19 @@ func (app *application) ForumRemoveCommentPost(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	isOwn := app.isOwnForum(userFromForum, r)
+	isOwn := app.canManage(userFromForum, r, policy.CommentDeleteAny)
 	if !isOwn {
 		app.clientError(w, http.StatusMethodNotAllowed)
 		return
 	}
 
-	err = app.forumComment.RemoveCommentPost(commentID)
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.forumComment.RemoveCommentPost(commentID, userID)
 	if err != nil {
 		app.serverError(w, err)
 		return
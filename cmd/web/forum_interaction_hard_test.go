@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"testing"
@@ -9,7 +10,7 @@ import (
 func TestForumInteractionEditRemoveGuardBranches(t *testing.T) {
 	app, db := newWebTestApp(t)
 	uid := seedWebUser(t, app, "intu", "intu@example.com", 2)
-	forumID, err := app.forums.Insert("t", "c", "go", 7, uid, "")
+	forumID, err := app.forums.Insert(context.Background(), "t", "c", "go", 7, uid, "")
 	if err != nil {
 		t.Fatalf("insert forum: %v", err)
 	}
@@ -35,7 +36,7 @@ func TestForumInteractionEditRemoveGuardBranches(t *testing.T) {
 		t.Fatalf("ForumEditCommentPost bad comment id status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/forum/comment/remove/bad/path", nil)
+	req, rr = newRequest(http.MethodPost, "/forum/comment/remove/bad/path", nil)
 	app.ForumRemoveCommentPost(rr, req)
 	if rr.Code != http.StatusOK {
 		t.Fatalf("ForumRemoveCommentPost invalid ids should early return 200, got %d", rr.Code)
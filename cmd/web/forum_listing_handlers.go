@@ -1,13 +1,81 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/aspandyar/forum/internal/models"
+	renderpkg "github.com/aspandyar/forum/internal/transport/http/render"
 )
 
+var sortLabels = map[string]string{
+	models.SortNewest:         "Newest",
+	models.SortOldest:         "Oldest",
+	models.SortMostLiked:      "Most liked",
+	models.SortMostCommented:  "Most commented",
+	models.SortRecentlyActive: "Recently active",
+}
+
+// listForums loads the page of filter selected by the sort and cursor
+// parameters into data, along with links that keep params. It reports false
+// after writing an error response.
+func (app *application) listForums(w http.ResponseWriter, r *http.Request, data *templateData, filter models.ForumFilter, params url.Values) bool {
+	query := r.URL.Query()
+	sortMode := query.Get("sort")
+	if sortMode == "" {
+		sortMode = models.SortNewest
+	}
+
+	page, err := app.forums.List(filter, models.PageQuery{Sort: sortMode, Cursor: query.Get("cursor")})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidSort) || errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
+		return false
+	}
+
+	link := func(mode, cursor string) string {
+		v := url.Values{}
+		for key, values := range params {
+			v[key] = values
+		}
+		if mode != models.SortNewest {
+			v.Set("sort", mode)
+		}
+		if cursor != "" {
+			v.Set("cursor", cursor)
+		}
+		if len(v) == 0 {
+			return r.URL.Path
+		}
+		return r.URL.Path + "?" + v.Encode()
+	}
+
+	pagination := &renderpkg.Pagination{}
+	for _, mode := range models.Sorts {
+		pagination.Sorts = append(pagination.Sorts, renderpkg.SortLink{
+			Label:   sortLabels[mode],
+			URL:     link(mode, ""),
+			Current: mode == page.Sort,
+		})
+	}
+	if page.Prev != "" {
+		pagination.Prev = link(page.Sort, page.Prev)
+	}
+	if page.Next != "" {
+		pagination.Next = link(page.Sort, page.Next)
+	}
+
+	data.Forums = page.Forums
+	data.Pagination = pagination
+	return true
+}
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		app.notFound(w)
@@ -28,13 +96,10 @@ func (app *application) allForum(w http.ResponseWriter, r *http.Request) {
 		app.notFound(w)
 		return
 	}
-	forums, err := app.forums.ShowAll()
-	if err != nil {
-		app.serverError(w, err)
+	data := app.newTemplateData(r)
+	if !app.listForums(w, r, data, models.ForumFilter{}, nil) {
 		return
 	}
-	data := app.newTemplateData(r)
-	data.Forums = forums
 	app.render(w, http.StatusOK, "allForums.tmpl.html", data)
 }
 
@@ -48,13 +113,10 @@ func (app *application) forumAllUserPosts(w http.ResponseWriter, r *http.Request
 		app.serverError(w, err)
 		return
 	}
-	forums, err := app.forums.ShowAllUserPosts(userID)
-	if err != nil {
-		app.serverError(w, err)
+	data := app.newTemplateData(r)
+	if !app.listForums(w, r, data, models.ForumFilter{AuthorID: userID, AnyState: true}, nil) {
 		return
 	}
-	data := app.newTemplateData(r)
-	data.Forums = forums
 	app.render(w, http.StatusOK, "allForums.tmpl.html", data)
 }
 
@@ -68,13 +130,10 @@ func (app *application) forumAllUserLikes(w http.ResponseWriter, r *http.Request
 		app.serverError(w, err)
 		return
 	}
-	forums, err := app.forums.ShowAllUserLikes(userID)
-	if err != nil {
-		app.serverError(w, err)
+	data := app.newTemplateData(r)
+	if !app.listForums(w, r, data, models.ForumFilter{ReactedBy: userID}, nil) {
 		return
 	}
-	data := app.newTemplateData(r)
-	data.Forums = forums
 	app.render(w, http.StatusOK, "allForums.tmpl.html", data)
 }
 
@@ -103,31 +162,25 @@ func (app *application) forumCategory(w http.ResponseWriter, r *http.Request) {
 		app.notFound(w)
 		return
 	}
-	var forum []*models.Forum
-	var err error
-	if r.Method == http.MethodPost {
-		err := r.ParseForm()
-		if err != nil {
-			app.clientError(w, http.StatusBadRequest)
-			return
-		}
-		selectedTags := r.Form["tags"]
-		customTagsStr := r.PostForm.Get("custom_tags")
-		tags := app.processTags(selectedTags, customTagsStr)
-		forum, err = app.forums.ShowCategory(tags)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-	} else {
-		forum, err = app.forums.ShowAll()
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
 	}
+	tags := app.processTags(r.Form["tags"], r.Form.Get("custom_tags"))
+	sort.Strings(tags)
+
 	data := app.newTemplateData(r)
-	data.Forums = forum
+	if !app.listForums(w, r, data, models.ForumFilter{Tags: tags}, url.Values{"tags": tags}) {
+		return
+	}
+
+	counts, err := app.forums.TagCounts()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Form = counts
 	app.render(w, http.StatusOK, "category.tmpl.html", data)
 }
 
@@ -147,32 +200,79 @@ func (app *application) forumView(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		userID = 0
 	} else {
-		userID, _, err = app.sessions.GetSession(cookie.Value)
+		userID, _, err = app.sessions.GetSession(r.Context(), cookie.Value)
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
 	}
-	userFromForum, err := app.forums.GetUserIDFromForum(id)
+	forum, err := app.forumService.View(id, userID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			app.notFound(w)
+		app.moderationError(w, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Form = forum
+	if forum.ImagePath != "" {
+		data.ImageURL, data.ThumbnailURL = app.images.URLs(forum.ImagePath)
+	}
+	if forum.IsOwnForum {
+		if data.ExpiryPolicies, err = app.forumService.ExpiryPolicies(); err != nil {
+			app.serverError(w, err)
 			return
 		}
-		app.serverError(w, err)
+	}
+	// Archived posts are still shown, but say they are gone.
+	status := http.StatusOK
+	if forum.Gone() {
+		status = http.StatusGone
+	}
+	app.render(w, status, "view.tmpl.html", data)
+}
+
+type searchForm struct {
+	Query       string
+	Tag         string
+	Author      string
+	Results     []*models.SearchResult
+	Unavailable bool
+}
+
+func (app *application) forumSearch(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/search" {
+		app.notFound(w)
 		return
 	}
-	isOwn := app.isOwnForum(userFromForum, r)
-	forum, err := app.forums.Get(id, userID, isOwn)
-	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	form := searchForm{
+		Query:  query.Get("q"),
+		Tag:    query.Get("tag"),
+		Author: query.Get("author"),
+	}
+
+	if strings.TrimSpace(form.Query) != "" {
+		results, err := app.forumService.Find(models.SearchQuery{
+			Text:   form.Query,
+			Tag:    form.Tag,
+			Author: form.Author,
+		})
+		switch {
+		case errors.Is(err, models.ErrSearchUnavailable):
+			form.Unavailable = true
+		case err != nil:
 			app.serverError(w, err)
+			return
 		}
-		return
+		form.Results = results
 	}
+
 	data := app.newTemplateData(r)
-	data.Form = forum
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	data.Form = form
+	app.render(w, http.StatusOK, "search.tmpl.html", data)
 }
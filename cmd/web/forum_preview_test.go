@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestForumPreview(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	userID := seedWebUser(t, app, "writer", "writer@example.com", 2)
+	h := app.routes()
+
+	preview := func(content string, signedIn, csrf bool) *httptest.ResponseRecorder {
+		body := url.Values{"content": {content}}.Encode()
+		req, rr := newRequest(http.MethodPost, "/forum/preview", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if signedIn {
+			attachSessionCookie(t, app, req, userID)
+		}
+		if csrf {
+			attachCSRF(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := preview("# Hi\n\n**bold** <script>alert(1)</script> [x](javascript:alert)", true, true)
+	want := "<h1>Hi</h1>\n<p><strong>bold</strong> &lt;script&gt;alert(1)&lt;/script&gt; x</p>\n"
+	if rr.Code != http.StatusOK || rr.Body.String() != want {
+		t.Fatalf("preview = %d %q, want %q", rr.Code, rr.Body.String(), want)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	if rr := preview("x", true, false); rr.Code != http.StatusForbidden {
+		t.Fatalf("preview without CSRF token = %d, want 403", rr.Code)
+	}
+	if rr := preview("x", false, true); rr.Code != http.StatusSeeOther {
+		t.Fatalf("signed-out preview = %d, want a redirect to login", rr.Code)
+	}
+
+	req, rr := newRequest(http.MethodGet, "/forum/preview", nil)
+	attachSessionCookie(t, app, req, userID)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != http.MethodPost {
+		t.Fatalf("GET preview = %d, Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
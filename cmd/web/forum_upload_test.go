@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspandyar/forum/internal/media"
+	"github.com/aspandyar/forum/internal/models"
+)
+
+// uploadBody builds a post form with file as its image-upload, or without
+// one when file is nil.
+func uploadBody(t *testing.T, fields map[string]string, filename string, file []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if file != nil {
+		fw, err := mw.CreateFormFile("image-upload", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(file)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, mw.FormDataContentType()
+}
+
+func testPNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// storedFile maps a media URL path to the file on disk.
+func storedFile(app *application, path string) string {
+	dir := app.images.Blobs.(*media.Disk).Dir
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path, app.images.URLPrefix)))
+}
+
+func TestForumImageUploadLifecycle(t *testing.T) {
+	app, db := newWebTestApp(t)
+	addBaseTemplate(app, "create.tmpl.html")
+	userID := seedWebUser(t, app, "uploader", "uploader@example.com", 2)
+	fields := map[string]string{"title": "Photo", "content": "look", "expires": "7", "custom_tags": "pics"}
+
+	// A script named like an image is refused and nothing is stored.
+	body, ct := uploadBody(t, fields, "cat.png", []byte("<script>alert(1)</script>"))
+	req, rr := newRequest(http.MethodPost, "/forum/create", body)
+	req.Header.Set("Content-Type", ct)
+	attachSessionCookie(t, app, req, userID)
+	app.ForumCreatePost(rr, req)
+	if rr.Code == http.StatusSeeOther {
+		t.Fatal("non-image upload was accepted")
+	}
+	var posts int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forums`).Scan(&posts); err != nil || posts != 0 {
+		t.Fatalf("rejected upload created %d posts: %v", posts, err)
+	}
+	if entries, _ := os.ReadDir(app.images.Blobs.(*media.Disk).Dir); len(entries) != 0 {
+		t.Fatalf("rejected upload left %d entries", len(entries))
+	}
+
+	body, ct = uploadBody(t, fields, "red.exe", testPNG(t, color.NRGBA{R: 255, A: 255}))
+	req, rr = newRequest(http.MethodPost, "/forum/create", body)
+	req.Header.Set("Content-Type", ct)
+	attachSessionCookie(t, app, req, userID)
+	app.ForumCreatePost(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("create status=%d body=%s", rr.Code, rr.Body.String())
+	}
+
+	var forumID int
+	var first string
+	if err := db.QueryRow(`SELECT id, image_path FROM forums ORDER BY id DESC LIMIT 1`).Scan(&forumID, &first); err != nil {
+		t.Fatalf("query forum: %v", err)
+	}
+	if !app.images.Owns(first) || !strings.HasSuffix(first, ".png") {
+		t.Fatalf("image_path = %q", first)
+	}
+	if _, err := os.Stat(storedFile(app, first)); err != nil {
+		t.Fatalf("stored image: %v", err)
+	}
+	if err := app.forums.ChangeForumStatus(forumID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editing without a file keeps the image.
+	body, ct = uploadBody(t, fields, "", nil)
+	req, rr = newRequest(http.MethodPost, "/forum/edit/"+itoa(forumID), body)
+	req.Header.Set("Content-Type", ct)
+	attachSessionCookie(t, app, req, userID)
+	app.ForumEditPost(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("edit status=%d", rr.Code)
+	}
+	if path, _ := app.forums.ImagePath(forumID); path != first {
+		t.Fatalf("image after plain edit = %q, want %q", path, first)
+	}
+
+	// Replacing it keeps the old file for the edit history.
+	body, ct = uploadBody(t, fields, "blue.png", testPNG(t, color.NRGBA{B: 255, A: 255}))
+	req, rr = newRequest(http.MethodPost, "/forum/edit/"+itoa(forumID), body)
+	req.Header.Set("Content-Type", ct)
+	attachSessionCookie(t, app, req, userID)
+	app.ForumEditPost(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("replace status=%d", rr.Code)
+	}
+	second, _ := app.forums.ImagePath(forumID)
+	if second == first || !app.images.Owns(second) {
+		t.Fatalf("image after replace = %q", second)
+	}
+	if _, err := os.Stat(storedFile(app, first)); err != nil {
+		t.Fatalf("replaced image gone from disk: %v", err)
+	}
+
+	// Removing the post keeps its images while it is in the trash; purging
+	// it deletes them, old ones included.
+	req, rr = newRequest(http.MethodPost, "/forum/remove/"+itoa(forumID), nil)
+	attachSessionCookie(t, app, req, userID)
+	app.handleForumRemove(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("remove status=%d", rr.Code)
+	}
+	if _, err := os.Stat(storedFile(app, second)); err != nil {
+		t.Fatalf("trashed post's image gone from disk: %v", err)
+	}
+	if _, _, err := app.forumService.PurgeTrash(context.Background(), time.Now().Add(models.TrashRetention+time.Minute)); err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	for _, path := range []string{first, second} {
+		if _, err := os.Stat(storedFile(app, path)); !os.IsNotExist(err) {
+			t.Fatalf("removed post's image %s still on disk: %v", path, err)
+		}
+	}
+}
+
+func TestForumImageSharedByPosts(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	userID := seedWebUser(t, app, "sharer", "sharer@example.com", 2)
+	img := testPNG(t, color.NRGBA{G: 255, A: 255})
+
+	var ids []int
+	var path string
+	for i := 0; i < 2; i++ {
+		body, ct := uploadBody(t, map[string]string{"title": "Same", "content": "pic", "expires": "7", "custom_tags": "pics"}, "g.png", img)
+		req, rr := newRequest(http.MethodPost, "/forum/create", body)
+		req.Header.Set("Content-Type", ct)
+		attachSessionCookie(t, app, req, userID)
+		app.ForumCreatePost(rr, req)
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("create %d status=%d", i, rr.Code)
+		}
+	}
+	rows, err := app.forums.DB.Query(`SELECT id, image_path FROM forums`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id, &path); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 2 {
+		t.Fatalf("got %d posts", len(ids))
+	}
+
+	for _, id := range ids {
+		if err := app.forums.ChangeForumStatus(id, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	purgeAt := time.Now().Add(models.TrashRetention + time.Minute)
+	if err := app.forumService.Delete(context.Background(), ids[0], userID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := app.forumService.PurgeTrash(context.Background(), purgeAt); err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if _, err := os.Stat(storedFile(app, path)); err != nil {
+		t.Fatalf("shared image deleted while still in use: %v", err)
+	}
+	if err := app.forumService.Delete(context.Background(), ids[1], userID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := app.forumService.PurgeTrash(context.Background(), purgeAt); err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if _, err := os.Stat(storedFile(app, path)); !os.IsNotExist(err) {
+		t.Fatalf("shared image kept after last post: %v", err)
+	}
+}
+
+func TestReleaseImageWaitsForCommit(t *testing.T) {
+	app, db := newWebTestApp(t)
+	img, err := media.Process(bytes.NewReader(testPNG(t, color.NRGBA{B: 255, A: 255})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := app.images.Save(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := errors.New("stop")
+	release := func(ctx context.Context) error {
+		if err := app.forumService.ReleaseImage(ctx, path); err != nil {
+			return err
+		}
+		if _, err := os.Stat(storedFile(app, path)); err != nil {
+			t.Fatalf("image deleted before commit: %v", err)
+		}
+		return errStop
+	}
+	if err := models.WithTx(context.Background(), db, release); !errors.Is(err, errStop) {
+		t.Fatalf("WithTx = %v, want errStop", err)
+	}
+	if _, err := os.Stat(storedFile(app, path)); err != nil {
+		t.Fatalf("image deleted by a rolled back release: %v", err)
+	}
+
+	err = models.WithTx(context.Background(), db, func(ctx context.Context) error {
+		return app.forumService.ReleaseImage(ctx, path)
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := os.Stat(storedFile(app, path)); !os.IsNotExist(err) {
+		t.Fatalf("released image still on disk: %v", err)
+	}
+}
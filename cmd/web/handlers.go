@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
 	"github.com/aspandyar/forum/internal/validator"
 )
 
@@ -15,9 +19,39 @@ type forumCreateForm struct {
 	Expires   int
 	ImagePath string
 	AllTags   []string
+	// Lifetimes are the expiry policies offered; Expires must be one of
+	// them.
+	Lifetimes []*models.ExpiryPolicy
 	validator.Validator
 }
 
+func (form *forumCreateForm) validate() {
+	form.CheckField(validator.IncorrectInput(form.Tags), "tags", "Incorrect tags formation")
+	form.CheckField(validator.MaxChars(form.Tags, 50), "tags", "This field cannot be more than 50 characters long")
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	days := make([]int, len(form.Lifetimes))
+	for i, p := range form.Lifetimes {
+		days[i] = p.Days
+	}
+	form.CheckField(validator.PermittedInt(form.Expires, days...), "expires", "This field must be one of the offered lifetimes")
+}
+
+// defaultLifetime returns the days of the policy preselected on the post
+// forms.
+func defaultLifetime(lifetimes []*models.ExpiryPolicy) int {
+	for _, p := range lifetimes {
+		if p.Default {
+			return p.Days
+		}
+	}
+	if len(lifetimes) > 0 {
+		return lifetimes[0].Days
+	}
+	return 0
+}
+
 type userSingupForm struct {
 	Name     string
 	Email    string
@@ -26,12 +60,26 @@ type userSingupForm struct {
 	validator.Validator
 }
 
+func (form *userSingupForm) validate() {
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+}
+
 type userLoginForm struct {
 	Email    string
 	Password string
 	validator.Validator
 }
 
+func (form *userLoginForm) validate() {
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+}
+
 type forumLikeForm struct {
 	LikeStatus int
 	ForumID    int
@@ -48,50 +96,50 @@ type forumCommentForm struct {
 }
 
 type forumReportForm struct {
-	reportTypes   string
-	reportDetails string
+	Reasons []string
+	Details string
 	validator.Validator
 }
 
-const (
-	adminID = 1
+func (form *forumReportForm) validate() {
+	form.CheckField(len(form.Reasons) > 0, "reasons", "Choose at least one reason")
+	for _, reason := range form.Reasons {
+		if !validator.PermittedValue(reason, models.ReportReasons...) {
+			form.AddFieldError("reasons", "This field must be one of "+strings.Join(models.ReportReasons, ", "))
+			break
+		}
+	}
+	form.CheckField(validator.MaxChars(form.Details, 1000), "details", "This field cannot be more than 1000 characters long")
+}
 
-	guestRole     = 1
-	userRole      = 2
-	moderatorRole = 3
-	adminRole     = 4
+// tagChangeForm carries an admin rename ("rename") or merge ("merge") of
+// one tag onto another.
+type tagChangeForm struct {
+	Action string
+	From   string
+	To     string
+	validator.Validator
+}
 
+func (form *tagChangeForm) validate() {
+	form.CheckField(validator.NotBlank(form.From), "from", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.To), "to", "This field cannot be blank")
+	form.CheckField(validator.IncorrectInput(form.To) && !strings.Contains(form.To, ","), "to", "Incorrect tags formation")
+	form.CheckField(validator.MaxChars(form.To, 50), "to", "This field cannot be more than 50 characters long")
+	form.CheckField(form.Action == "rename" || form.Action == "merge", "action", "This field must equal rename or merge")
+}
+
+const (
 	visibleStatus   = 1
 	invisibleStatus = 0
 )
 
-var (
-	clientID        = os.Getenv("GOOGLE_CLIENT_ID")
-	clientSecret    = os.Getenv("GOOGLE_CLIENT_SECRET")
-	clientGitID     = os.Getenv("GITHUB_CLIENT_ID")
-	clientGitSecret = os.Getenv("GITHUB_CLIENT_SECRET")
-	redirectURI     = func() string {
-		if value := os.Getenv("OAUTH_REDIRECT_URI"); value != "" {
-			return value
-		}
-		return "https://localhost:4000/callback"
-	}()
-)
-
-type UserInfo struct {
-	ID       string `json:"id"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Login    string `json:"login"`
-	Password string
-}
-
 func (app *application) createAdmin() error {
 	adminName := os.Getenv("ADMIN_NAME")
 	adminPassword := os.Getenv("ADMIN_PASSWORD")
 	adminEmail := os.Getenv("ADMIN_EMAIL")
 
-	app.users.Insert(adminName, adminEmail, adminPassword, adminRole)
+	app.users.Insert(context.Background(), adminName, adminEmail, adminPassword, policy.Admin)
 	app.users.InsertTags("tag 1")
 	app.users.InsertTags("tag 2")
 	app.users.InsertTags("tag 3")
@@ -106,7 +154,7 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 		return false
 	}
 
-	_, expiry, err := app.sessions.GetSession(cookie.Value)
+	_, expiry, err := app.sessions.GetSession(r.Context(), cookie.Value)
 	if err != nil {
 		return false
 	}
@@ -116,11 +164,11 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 func (app *application) getRole(r *http.Request) int {
 	if !app.isAuthenticated(r) {
-		return guestRole
+		return policy.Guest
 	}
 
 	cookie, _ := r.Cookie("session")
-	userID, _, _ := app.sessions.GetSession(cookie.Value)
+	userID, _, _ := app.sessions.GetSession(r.Context(), cookie.Value)
 
 	role, err := app.users.GetUserRole(userID)
 	if err != nil {
@@ -130,16 +178,33 @@ func (app *application) getRole(r *http.Request) int {
 	return role
 }
 
-func (app *application) isOwnForum(userID int, r *http.Request) bool {
-	cookie, err := r.Cookie("session")
+// can reports whether the requester's role holds perm. Guests are checked
+// against the guest role.
+func (app *application) can(r *http.Request, perm policy.Permission) bool {
+	ok, err := app.policy.Can(app.getRole(r), perm)
 	if err != nil {
+		app.errorLog.Print(err)
 		return false
 	}
+	return ok
+}
 
-	getUserID, expiry, err := app.sessions.GetSession(cookie.Value)
+// canManage reports whether the signed-in user is ownerID or holds perm,
+// which lets them act on anyone's content.
+func (app *application) canManage(ownerID int, r *http.Request, perm policy.Permission) bool {
+	cookie, err := r.Cookie("session")
 	if err != nil {
 		return false
 	}
 
-	return time.Now().Before(expiry) && (userID == getUserID || getUserID == adminID)
+	userID, expiry, err := app.sessions.GetSession(r.Context(), cookie.Value)
+	if err != nil || !time.Now().Before(expiry) {
+		return false
+	}
+
+	return userID == ownerID || app.can(r, perm)
+}
+
+func (app *application) isOwnForum(userID int, r *http.Request) bool {
+	return app.canManage(userID, r, policy.PostEditAny)
 }
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
+	"html/template"
 	"net/http"
 	"strings"
 	"testing"
-	"text/template"
 )
 
 func addBaseTemplate(app *application, name string) {
@@ -60,7 +61,7 @@ func TestListingHandlers_GuardBranches(t *testing.T) {
 func TestListingHandlers_SimpleSuccessBranches(t *testing.T) {
 	app, db := newWebTestApp(t)
 	userID := seedWebUser(t, app, "u1", "u1@example.com", 2)
-	_, err := app.forums.Insert("title", "body", "go", 7, userID, "")
+	_, err := app.forums.Insert(context.Background(), "title", "body", "go", 7, userID, "")
 	if err != nil {
 		t.Fatalf("insert forum: %v", err)
 	}
@@ -127,55 +128,69 @@ func TestAuthAndOAuthSimpleHandlers(t *testing.T) {
 		t.Fatalf("logout missing cookie status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/auth", nil)
-	app.handleGoogleAuth(rr, req)
+	withFakeProviders(app)
+	req, rr = newRequest(http.MethodGet, "/login/google/", nil)
+	app.oauthLogin(rr, req)
 	if rr.Code != http.StatusFound {
 		t.Fatalf("google auth redirect status=%d", rr.Code)
 	}
 
 	req, rr = newRequest(http.MethodGet, "/login/github/", nil)
-	app.gitHubLoginHandler(rr, req)
-	if rr.Code != http.StatusSeeOther {
+	app.oauthLogin(rr, req)
+	if rr.Code != http.StatusFound {
 		t.Fatalf("github login redirect status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/loggedin", nil)
-	app.loggedinHandler(rr, req, "")
-	if rr.Code != http.StatusInternalServerError {
-		t.Fatalf("loggedin empty data status=%d", rr.Code)
-	}
-
-	pass, err := generateRandomPassword(8)
-	if err != nil || pass == "" {
-		t.Fatalf("generateRandomPassword err=%v pass=%q", err, pass)
+	req, rr = newRequest(http.MethodGet, "/login/github/callback?code=abc&state=forged", nil)
+	app.oauthLogin(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("github callback without state cookie status=%d", rr.Code)
 	}
 }
 
 func TestModerationAndForumGuardBranches(t *testing.T) {
 	app, _ := newWebTestApp(t)
 
-	req, rr := newRequest(http.MethodGet, "/moderation/ask", nil)
+	req, rr := newRequest(http.MethodPost, "/moderation/ask", nil)
 	app.moderAskHandler(rr, req)
 	if rr.Code != http.StatusInternalServerError {
 		t.Fatalf("moderAsk missing cookie status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/forum/remove/bad", nil)
+	req, rr = newRequest(http.MethodPost, "/forum/remove/bad", nil)
 	app.handleForumRemove(rr, req)
 	if rr.Code != http.StatusNotFound {
 		t.Fatalf("forum remove bad path status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/user/notification/remove/abc", nil)
+	req, rr = newRequest(http.MethodPost, "/user/notification/remove/abc", nil)
 	app.userNotificationRemove(rr, req)
 	if rr.Code != http.StatusInternalServerError {
 		t.Fatalf("notification remove missing cookie status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/user/report/remove/1/2/3", nil)
-	app.forumReportRemoveHandler(rr, req)
+	req, rr = newRequest(http.MethodPost, "/moderation/reports/1/resolve", nil)
+	app.reportAction(rr, req)
 	if rr.Code != http.StatusInternalServerError {
-		t.Fatalf("report remove missing cookie status=%d", rr.Code)
+		t.Fatalf("report resolve missing cookie status=%d", rr.Code)
+	}
+
+	// Actions that change state are not reachable through links.
+	for path, h := range map[string]http.HandlerFunc{
+		"/forum/remove/1":             app.handleForumRemove,
+		"/forum/comment/remove/1/1":   app.ForumRemoveCommentPost,
+		"/user/notification/remove/1": app.userNotificationRemove,
+		"/moderation/accept/1/2":      app.userModerationDone,
+		"/moderation/denote/2/0":      app.moderDenoteHandler,
+		"/moderation/forum/1/1":       app.forumAcceptHandler,
+		"/moderation/ask":             app.moderAskHandler,
+		"/user/logout":                app.userLogoutPost,
+	} {
+		req, rr = newRequest(http.MethodGet, path, nil)
+		h(rr, req)
+		if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != http.MethodPost {
+			t.Fatalf("GET %s status=%d allow=%q", path, rr.Code, rr.Header().Get("Allow"))
+		}
 	}
 }
 
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"html"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
 )
 
 func TestForumIsLikeRejectsNonPost(t *testing.T) {
@@ -68,3 +75,325 @@ func TestForumEditCommentPostRejectsNonPost(t *testing.T) {
 		t.Fatalf("status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
 	}
 }
+
+func TestForumReplyCommentPost(t *testing.T) {
+	app, db := newWebTestApp(t)
+	authorID := seedWebUser(t, app, "author", "author@example.com", 2)
+	replierID := seedWebUser(t, app, "replier", "replier@example.com", 2)
+
+	forumID, err := app.forums.Insert(context.Background(), "title", "content", "go", 7, authorID, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+	if err := app.forums.ChangeForumStatus(forumID, 1); err != nil {
+		t.Fatalf("publish forum: %v", err)
+	}
+	if _, err := app.forumComment.CommentPost(forumID, authorID, "root"); err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+	var rootID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE forum_id = ?`, forumID).Scan(&rootID); err != nil {
+		t.Fatalf("query comment: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/forum/comment/reply/1", nil)
+	rr := httptest.NewRecorder()
+	app.ForumReplyCommentPost(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/forum/comment/reply/x", "comment=hi", http.StatusNotFound},
+		{"/forum/comment/reply/9999", "comment=hi", http.StatusNotFound},
+		{"/forum/comment/reply/" + strconv.Itoa(rootID), "comment=", http.StatusUnprocessableEntity},
+		{"/forum/comment/reply/" + strconv.Itoa(rootID), "comment=hello", http.StatusSeeOther},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, replierID)
+		rr := httptest.NewRecorder()
+		app.ForumReplyCommentPost(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("POST %s %q status = %d, want %d", tc.path, tc.body, rr.Code, tc.want)
+		}
+		if tc.want == http.StatusSeeOther && !strings.HasPrefix(rr.Header().Get("Location"), "/forum/view/"+strconv.Itoa(forumID)+"#comment-") {
+			t.Fatalf("redirect = %q", rr.Header().Get("Location"))
+		}
+	}
+
+	var parentID int
+	if err := db.QueryRow(`SELECT parent_id FROM forum_comments WHERE comment = 'hello'`).Scan(&parentID); err != nil {
+		t.Fatalf("query reply: %v", err)
+	}
+	if parentID != rootID {
+		t.Fatalf("reply parent = %d, want %d", parentID, rootID)
+	}
+}
+
+func TestForumSearch(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["search.tmpl.html"] = mustTemplate(
+		`{{define "base"}}{{with .Form}}{{.Unavailable}}|{{range .Results}}{{.ForumID}}:{{.CommentID}};{{end}}{{end}}{{end}}`)
+
+	userID := seedWebUser(t, app, "searcher", "searcher@example.com", 2)
+	forumID, err := app.forums.Insert(context.Background(), "Gopher gathering", "details", "go", 1, userID, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE forums SET status = 1 WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+
+	req, rr := newRequest(http.MethodPost, "/search", nil)
+	app.forumSearch(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+
+	req, rr = newRequest(http.MethodGet, "/search/extra", nil)
+	app.forumSearch(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("bad path status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	req, rr = newRequest(http.MethodGet, "/search", nil)
+	app.forumSearch(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "false|" {
+		t.Fatalf("empty query status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	req, rr = newRequest(http.MethodGet, "/search?q=gopher", nil)
+	app.forumSearch(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("search status = %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	available, err := (&models.SearchModel{DB: db}).Available()
+	if err != nil {
+		t.Fatalf("Available: %v", err)
+	}
+	want := "true|"
+	if available {
+		want = "false|" + strconv.Itoa(forumID) + ":0;"
+	}
+	if rr.Body.String() != want {
+		t.Fatalf("search body = %q, want %q", rr.Body.String(), want)
+	}
+}
+
+func TestAllForumPagination(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["allForums.tmpl.html"] = mustTemplate(
+		`{{define "base"}}{{range .Forums}}{{.ID}};{{end}}|{{with .Pagination}}{{range .Sorts}}{{if .Current}}{{.Label}}{{end}}{{end}}|{{.Next}}{{end}}{{end}}`)
+
+	userID := seedWebUser(t, app, "pager", "pager@example.com", 2)
+	for i := 0; i < models.DefaultPageSize+1; i++ {
+		if _, err := app.forums.Insert(context.Background(), "post", "body", "go", 1, userID, ""); err != nil {
+			t.Fatalf("insert forum: %v", err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE forums SET status = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	req, rr := newRequest(http.MethodGet, "/showAll?sort=oldest", nil)
+	app.allForum(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	// The link is HTML-escaped in the page, as a browser would read it.
+	parts := strings.Split(html.UnescapeString(rr.Body.String()), "|")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "1;2;") || parts[1] != "Oldest" {
+		t.Fatalf("unexpected first page %q", rr.Body.String())
+	}
+	if !strings.HasPrefix(parts[2], "/showAll?cursor=") || !strings.Contains(parts[2], "sort=oldest") {
+		t.Fatalf("next link = %q", parts[2])
+	}
+
+	req, rr = newRequest(http.MethodGet, parts[2], nil)
+	app.allForum(rr, req)
+	want := strconv.Itoa(models.DefaultPageSize+1) + ";|Oldest|"
+	if rr.Code != http.StatusOK || rr.Body.String() != want {
+		t.Fatalf("second page status=%d body=%q, want %q", rr.Code, rr.Body.String(), want)
+	}
+
+	for _, target := range []string{"/showAll?sort=random", "/showAll?cursor=bogus"} {
+		req, rr = newRequest(http.MethodGet, target, nil)
+		app.allForum(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s status = %d, want %d", target, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUserInbox(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	app.tempalteCache["inbox.tmpl.html"] = mustTemplate(
+		`{{define "base"}}{{.UnreadNotifications}}|{{with .Form}}{{range .Notifications}}{{.Event}}:{{.Read}};{{end}}{{end}}{{end}}`)
+
+	owner := seedWebUser(t, app, "owner", "owner@example.com", 2)
+	reader := seedWebUser(t, app, "reader", "reader@example.com", 2)
+	forumID, err := app.forums.Insert(context.Background(), "Post", "body", "go", 1, owner, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+	if _, err := app.forumComment.CommentPost(forumID, reader, "hello"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+
+	req, rr := newRequest(http.MethodGet, "/user/inbox", nil)
+	attachSessionCookie(t, app, req, owner)
+	app.userInbox(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "1|post-comment:false;" {
+		t.Fatalf("inbox status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	list, err := app.forumService.Inbox(owner, false)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Inbox = %v, %v", list, err)
+	}
+	readPath := "/user/inbox/read/" + strconv.Itoa(list[0].ID)
+
+	req, rr = newRequest(http.MethodPost, readPath, nil)
+	attachSessionCookie(t, app, req, reader)
+	app.userInboxRead(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("foreign read status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	req, rr = newRequest(http.MethodGet, readPath, nil)
+	app.userInboxRead(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET read status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+
+	req, rr = newRequest(http.MethodPost, readPath, nil)
+	attachSessionCookie(t, app, req, owner)
+	app.userInboxRead(rr, req)
+	want := "/forum/view/" + strconv.Itoa(forumID) + "#comment-" + strconv.Itoa(list[0].CommentID)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != want {
+		t.Fatalf("read status=%d location=%q, want %q", rr.Code, rr.Header().Get("Location"), want)
+	}
+
+	req, rr = newRequest(http.MethodGet, "/user/inbox?unread=1", nil)
+	attachSessionCookie(t, app, req, owner)
+	app.userInbox(rr, req)
+	if rr.Body.String() != "0|" {
+		t.Fatalf("unread inbox body=%q", rr.Body.String())
+	}
+
+	req, rr = newRequest(http.MethodPost, "/user/inbox/read-all", nil)
+	attachSessionCookie(t, app, req, owner)
+	app.userInboxReadAll(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/inbox" {
+		t.Fatalf("read all status=%d location=%q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+// readEvent reads one Server-Sent Event, skipping retry lines and
+// heartbeats, and returns its id, type and data.
+func readEvent(t *testing.T, r *bufio.Reader) (id, event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if event != "" {
+				return id, event, data
+			}
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func openStream(t *testing.T, url, lastID string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	return resp
+}
+
+func TestForumEventsStream(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	app.live.MaxPerClient = 1
+
+	owner := seedWebUser(t, app, "owner", "owner@example.com", 2)
+	reader := seedWebUser(t, app, "reader", "reader@example.com", 2)
+	forumID, err := app.forums.Insert(context.Background(), "Post", "body", "go", 1, owner, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.forumEvents))
+	defer srv.Close()
+	url := srv.URL + "/forum/events/" + strconv.Itoa(forumID)
+
+	missing := openStream(t, srv.URL+"/forum/events/999", "")
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing post status = %d, want %d", missing.StatusCode, http.StatusNotFound)
+	}
+
+	resp := openStream(t, url, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	second := openStream(t, url, "")
+	second.Body.Close()
+	if second.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second stream status = %d, want %d", second.StatusCode, http.StatusTooManyRequests)
+	}
+
+	if _, err := app.forumComment.CommentPost(forumID, reader, "hello"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+	id, event, data := readEvent(t, bufio.NewReader(resp.Body))
+	if event != models.LiveComment || !strings.Contains(data, `"comment":"hello"`) {
+		t.Fatalf("event=%q data=%q", event, data)
+	}
+	resp.Body.Close()
+
+	// The vote lands while the client is away and is replayed on resume,
+	// once the server has released the closed stream's slot.
+	if _, err := app.forumLike.LikeOrDislike(forumID, reader, 1); err != nil {
+		t.Fatalf("LikeOrDislike: %v", err)
+	}
+	var resumed *http.Response
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resumed = openStream(t, url, id)
+		if resumed.StatusCode != http.StatusTooManyRequests || time.Now().After(deadline) {
+			break
+		}
+		resumed.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer resumed.Body.Close()
+	_, event, data = readEvent(t, bufio.NewReader(resumed.Body))
+	if event != models.LiveReaction || !strings.Contains(data, `"likes":1`) {
+		t.Fatalf("resumed event=%q data=%q", event, data)
+	}
+}
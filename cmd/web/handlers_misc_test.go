@@ -1,8 +1,20 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/aspandyar/forum/internal/mail"
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
 )
 
 func TestMethodSwitchHandlersRejectUnsupportedMethods(t *testing.T) {
@@ -43,8 +55,8 @@ func TestAuthenticationAndOwnershipHelpers(t *testing.T) {
 	if app.isAuthenticated(req) {
 		t.Fatal("expected unauthenticated without session cookie")
 	}
-	if role := app.getRole(req); role != guestRole {
-		t.Fatalf("role=%d, want guest role=%d", role, guestRole)
+	if role := app.getRole(req); role != policy.Guest {
+		t.Fatalf("role=%d, want guest role=%d", role, policy.Guest)
 	}
 
 	attachSessionCookie(t, app, req, userID)
@@ -67,3 +79,231 @@ func TestAuthenticationAndOwnershipHelpers(t *testing.T) {
 		t.Fatal("expected admin to be considered owner for moderation checks")
 	}
 }
+
+func TestUserSessions(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "sessions.tmpl.html")
+	userID := seedWebUser(t, app, "devices", "devices@example.com", policy.User)
+	otherID := seedWebUser(t, app, "other", "other@example.com", policy.User)
+
+	laptop, err := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{UserAgent: "laptop"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	phone, _ := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{UserAgent: "phone"})
+	tablet, _ := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{UserAgent: "tablet"})
+	foreign, _ := app.sessions.CreateSession(context.Background(), otherID, models.SessionDevice{})
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req, rr := newRequest(method, target, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: laptop.Token})
+		attachCSRF(req)
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodGet, "/user/sessions"); rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/user/sessions/revoke/"+strconv.Itoa(phone.ID)); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET revoke status = %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/user/sessions/revoke/"+strconv.Itoa(foreign.ID)); rr.Code != http.StatusNotFound {
+		t.Fatalf("revoke foreign status = %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/user/sessions/revoke/"+strconv.Itoa(phone.ID)); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/sessions" {
+		t.Fatalf("revoke status = %d location = %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if _, _, err := app.sessions.GetSession(context.Background(), phone.Token); err == nil {
+		t.Fatal("revoked session still exists")
+	}
+
+	if rr := do(http.MethodPost, "/user/sessions/revoke-others"); rr.Code != http.StatusSeeOther {
+		t.Fatalf("revoke others status = %d", rr.Code)
+	}
+	if _, _, err := app.sessions.GetSession(context.Background(), tablet.Token); err == nil {
+		t.Fatal("other session survived")
+	}
+	if _, _, err := app.sessions.GetSession(context.Background(), foreign.Token); err != nil {
+		t.Fatalf("another user's session was revoked: %v", err)
+	}
+
+	rr := do(http.MethodPost, "/user/sessions/revoke/"+strconv.Itoa(laptop.ID))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Fatalf("revoke current status = %d location = %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := do(http.MethodGet, "/user/sessions"); rr.Code != http.StatusSeeOther {
+		t.Fatalf("list after sign-out status = %d", rr.Code)
+	}
+}
+
+// mailedToken returns the link token of the newest message app has mailed.
+func mailedToken(t *testing.T, app *application) string {
+	t.Helper()
+	dir := app.authService.Mailer.(*mail.FileMailer).Dir
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) == 0 {
+		t.Fatal("no mail sent")
+	}
+	body, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("no token link in mail:\n%s", body)
+	}
+	return string(m[1])
+}
+
+func TestPasswordReset(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "forgot.tmpl.html")
+	addBaseTemplate(app, "reset.tmpl.html")
+	userID := seedWebUser(t, app, "forgetful", "forgetful@example.com", policy.User)
+	old, _ := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{})
+
+	post := func(target string, form url.Values) *httptest.ResponseRecorder {
+		req, rr := newRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachCSRF(req)
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post("/user/password/forgot", url.Values{"email": {"nobody@example.com"}}); rr.Code != http.StatusOK {
+		t.Fatalf("forgot unknown status = %d", rr.Code)
+	}
+	if files, _ := filepath.Glob(filepath.Join(app.authService.Mailer.(*mail.FileMailer).Dir, "*.eml")); len(files) != 0 {
+		t.Fatalf("mail sent for an unknown address: %v", files)
+	}
+
+	if rr := post("/user/password/forgot", url.Values{"email": {"forgetful@example.com"}}); rr.Code != http.StatusOK {
+		t.Fatalf("forgot status = %d", rr.Code)
+	}
+	token := mailedToken(t, app)
+
+	if rr := post("/user/password/reset", url.Values{"token": {token}, "password": {"short"}}); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("short password status = %d", rr.Code)
+	}
+	if rr := post("/user/password/reset", url.Values{"token": {"bogus"}, "password": {"brandnew123"}}); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bogus token status = %d", rr.Code)
+	}
+	if _, err := app.users.Authenticate("forgetful@example.com", "brandnew123"); err == nil {
+		t.Fatal("password changed without a valid token")
+	}
+
+	rr := post("/user/password/reset", url.Values{"token": {token}, "password": {"brandnew123"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Fatalf("reset status = %d location = %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if _, err := app.users.Authenticate("forgetful@example.com", "brandnew123"); err != nil {
+		t.Fatalf("new password rejected: %v", err)
+	}
+	if _, _, err := app.sessions.GetSession(context.Background(), old.Token); err == nil {
+		t.Fatal("existing session survived the reset")
+	}
+
+	post("/user/password/reset", url.Values{"token": {token}, "password": {"another123"}})
+	if _, err := app.users.Authenticate("forgetful@example.com", "another123"); err == nil {
+		t.Fatal("reset token worked twice")
+	}
+}
+
+func TestPasswordResetIsAtomic(t *testing.T) {
+	app, db := newWebTestApp(t)
+	addBaseTemplate(app, "forgot.tmpl.html")
+	addBaseTemplate(app, "reset.tmpl.html")
+	userID := seedWebUser(t, app, "atomreset", "atomreset@example.com", policy.User)
+	old, _ := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{})
+	if err := app.authService.RequestPasswordReset(context.Background(), "atomreset@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	token := mailedToken(t, app)
+
+	// Signing out everywhere is the last step.
+	_, err := db.Exec(`CREATE TRIGGER fail_revoke BEFORE DELETE ON sessions
+	BEGIN SELECT RAISE(ABORT, 'injected failure'); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset := func() int {
+		form := url.Values{"token": {token}, "password": {"brandnew123"}}
+		req, rr := newRequest(http.MethodPost, "/user/password/reset", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachCSRF(req)
+		app.routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := reset(); code != http.StatusInternalServerError {
+		t.Fatalf("reset status = %d, want 500", code)
+	}
+	if _, err := app.users.Authenticate("atomreset@example.com", "brandnew123"); err == nil {
+		t.Fatal("failed reset changed the password")
+	}
+	if _, _, err := app.sessions.GetSession(context.Background(), old.Token); err != nil {
+		t.Fatalf("failed reset ended the session: %v", err)
+	}
+
+	// The token was not used up, so the reset can be retried.
+	if _, err := db.Exec(`DROP TRIGGER fail_revoke`); err != nil {
+		t.Fatal(err)
+	}
+	if code := reset(); code != http.StatusSeeOther {
+		t.Fatalf("retried reset status = %d", code)
+	}
+	if _, err := app.users.Authenticate("atomreset@example.com", "brandnew123"); err != nil {
+		t.Fatalf("new password rejected: %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "verify.tmpl.html")
+
+	form := url.Values{"name": {"fresh"}, "email": {"fresh@example.com"}, "password": {"password123"}}
+	req, rr := newRequest(http.MethodPost, "/user/signup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	attachCSRF(req)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("signup status = %d", rr.Code)
+	}
+	token := mailedToken(t, app)
+
+	verified := func() bool {
+		u, err := app.users.ByEmail("fresh@example.com")
+		if err != nil {
+			t.Fatalf("ByEmail: %v", err)
+		}
+		return u.EmailVerified
+	}
+	if verified() {
+		t.Fatal("verified before following the link")
+	}
+
+	req, rr = newRequest(http.MethodGet, "/user/verify?token=bogus", nil)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || verified() {
+		t.Fatalf("bogus token status = %d verified = %v", rr.Code, verified())
+	}
+
+	req, rr = newRequest(http.MethodGet, "/user/verify?token="+token, nil)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !verified() {
+		t.Fatalf("verify status = %d verified = %v", rr.Code, verified())
+	}
+
+	// A verified address gets no further links.
+	dir := app.authService.Mailer.(*mail.FileMailer).Dir
+	before, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	req, rr = newRequest(http.MethodPost, "/user/verify", strings.NewReader("email=fresh%40example.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	attachCSRF(req)
+	app.routes().ServeHTTP(rr, req)
+	after, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if rr.Code != http.StatusOK || len(after) != len(before) {
+		t.Fatalf("resend status = %d, mails %d -> %d", rr.Code, len(before), len(after))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	mw "github.com/aspandyar/forum/internal/transport/http/middleware"
 	renderpkg "github.com/aspandyar/forum/internal/transport/http/render"
 )
 
@@ -33,14 +34,35 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 }
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
+	role := app.getRole(r)
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           "",
-		IsAuthenticated: app.isAuthenticated(r),
-		Role:            app.getRole(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               "",
+		IsAuthenticated:     app.isAuthenticated(r),
+		Role:                role,
+		Can:                 app.permissions(role),
+		UnreadNotifications: app.unreadNotifications(r),
+		CSRFToken:           mw.CSRFToken(r),
+		SignInProviders:     app.oauthProviders(),
 	}
 }
 
+// permissions returns the permissions of role for templates. A policy error
+// is logged and renders the page as if nothing were granted.
+func (app *application) permissions(role int) map[string]bool {
+	granted, err := app.policy.Granted(role)
+	if err != nil {
+		app.errorLog.Print(err)
+		return nil
+	}
+
+	can := make(map[string]bool, len(granted))
+	for _, perm := range granted {
+		can[string(perm)] = true
+	}
+	return can
+}
+
 func (app *application) processTags(selectedTags []string, customTagsStr string) []string {
 	customTags := strings.Split(customTagsStr, ",")
 
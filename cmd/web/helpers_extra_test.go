@@ -2,11 +2,11 @@ package main
 
 import (
 	"errors"
+	"html/template"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
-	"text/template"
 	"time"
 )
 
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/diff"
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+type historyForm struct {
+	ForumID    int
+	Post       []historyVersion
+	Comments   []historyVersion
+	CanRestore bool
+}
+
+// historyVersion is one version of a post or comment with the lines that
+// changed since the version before it. The first version shows every line
+// as added.
+type historyVersion struct {
+	*models.Revision
+	Diff []diff.Line
+}
+
+// postText is what the history compares between two versions of a post.
+func postText(r *models.Revision) string {
+	image := r.ImagePath
+	if image == "" {
+		image = "none"
+	}
+	return "Title: " + r.Title + "\nTags: " + r.Tags + "\nImage: " + image + "\n\n" + r.Content
+}
+
+// versions pairs each version with its diff against the next, older one of
+// the same post or comment. history is newest first.
+func versions(history []*models.Revision, text func(*models.Revision) string) []historyVersion {
+	out := make([]historyVersion, len(history))
+	for i, r := range history {
+		older := ""
+		if i+1 < len(history) && history[i+1].CommentID == r.CommentID {
+			older = text(history[i+1])
+		}
+		out[i] = historyVersion{Revision: r, Diff: diff.Lines(older, text(r))}
+	}
+	return out
+}
+
+// forumHistory handles GET /forum/history/{id}, the edit history of a post
+// and its comments, and POST /forum/history/{post|comment}/{revision}/restore.
+func (app *application) forumHistory(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	switch {
+	case len(parts) == 4 && parts[1] == "forum" && parts[2] == "history":
+		app.forumHistoryGet(w, r, parts[3])
+	case len(parts) == 6 && parts[1] == "forum" && parts[2] == "history" && parts[5] == "restore" &&
+		(parts[3] == "post" || parts[3] == "comment"):
+		app.forumHistoryRestore(w, r, parts[3], parts[4])
+	default:
+		app.notFound(w)
+	}
+}
+
+func (app *application) forumHistoryGet(w http.ResponseWriter, r *http.Request, idStr string) {
+	forumID, err := strconv.Atoi(idStr)
+	if err != nil || forumID < 1 {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	history, err := app.forumService.History(forumID, userID)
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = historyForm{
+		ForumID:    forumID,
+		Post:       versions(history.Post, postText),
+		Comments:   versions(history.Comments, func(r *models.Revision) string { return r.Content }),
+		CanRestore: app.can(r, policy.HistoryRestore),
+	}
+	app.render(w, http.StatusOK, "history.tmpl.html", data)
+}
+
+func (app *application) forumHistoryRestore(w http.ResponseWriter, r *http.Request, kind, idStr string) {
+	revisionID, err := strconv.Atoi(idStr)
+	if err != nil || revisionID < 1 {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var forumID int
+	if kind == "post" {
+		forumID, err = app.forumService.RestoreRevision(r.Context(), revisionID, userID)
+	} else {
+		forumID, err = app.forumService.RestoreCommentRevision(r.Context(), revisionID, userID)
+	}
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/forum/history/%d", forumID), http.StatusSeeOther)
+}
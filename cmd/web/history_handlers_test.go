@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+	"github.com/aspandyar/forum/internal/testutil"
+)
+
+func TestForumHistory(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["history.tmpl.html"] = mustTemplate(`{{define "base"}}{{with .Form}}{{.CanRestore}}|` +
+		`{{range .Post}}{{.ID}}:{{range .Diff}}{{.Op}} {{.Text}};{{end}}|{{end}}` +
+		`{{range .Comments}}c{{.ID}}:{{range .Diff}}{{.Op}} {{.Text}};{{end}}|{{end}}{{end}}{{end}}`)
+	h := app.routes()
+
+	authorID := seedWebUser(t, app, "author", "author@example.com", models.UserRole)
+	otherID := seedWebUser(t, app, "other", "other@example.com", models.UserRole)
+	moderID := seedWebUser(t, app, "moder", "moder@example.com", models.ModeratorRole)
+	adminID := seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+
+	forumID, err := app.forums.Insert(context.Background(), "Title", "line one\nline two", "go", 7, authorID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.forums.ChangeForumStatus(forumID, models.VisibleStatus); err != nil {
+		t.Fatal(err)
+	}
+	post := forumsvc.Post{Title: "Title", Content: "line one\nline 2", Tags: "go", Expires: 7}
+	if err := app.forumService.Update(context.Background(), forumID, post, authorID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := app.forumComment.CommentPost(forumID, authorID, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var commentID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE forum_id = ?`, forumID).Scan(&commentID); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, target string, userID int, form url.Values) (int, string) {
+		var body *strings.Reader
+		if form == nil {
+			body = strings.NewReader("")
+		} else {
+			body = strings.NewReader(form.Encode())
+		}
+		req, rr := newRequest(method, target, body)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, userID)
+		attachCSRF(req)
+		h.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+
+	// Someone else's comment edit is refused; the author's own is kept as
+	// history.
+	editComment := "/forum/comment/edit/" + strconv.Itoa(forumID) + "/" + strconv.Itoa(commentID)
+	if code, _ := do(http.MethodPost, editComment, otherID, url.Values{"comment": {"hijacked"}}); code != http.StatusForbidden {
+		t.Fatalf("editing someone else's comment = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodPost, editComment, authorID, url.Values{"comment": {"hello there"}}); code != http.StatusSeeOther {
+		t.Fatalf("editing own comment = %d", code)
+	}
+
+	history := "/forum/history/" + strconv.Itoa(forumID)
+	code, body := do(http.MethodGet, history, authorID, nil)
+	if code != http.StatusOK {
+		t.Fatalf("author history = %d", code)
+	}
+	wantPost := "false|0:equal Title: Title;equal Tags: go;equal Image: none;equal ;equal line one;delete line two;insert line 2;|"
+	if !strings.HasPrefix(body, wantPost) {
+		t.Fatalf("history = %q, want prefix %q", body, wantPost)
+	}
+	if !strings.Contains(body, "c0:delete hello;insert hello there;|") {
+		t.Fatalf("history without the comment diff: %q", body)
+	}
+
+	if code, _ := do(http.MethodGet, history, otherID, nil); code != http.StatusForbidden {
+		t.Fatalf("other user's history = %d, want 403", code)
+	}
+	if code, body := do(http.MethodGet, history, moderID, nil); code != http.StatusOK || !strings.HasPrefix(body, "false|") {
+		t.Fatalf("moderator history = %d %q", code, body)
+	}
+	if code, body := do(http.MethodGet, history, adminID, nil); code != http.StatusOK || !strings.HasPrefix(body, "true|") {
+		t.Fatalf("admin history = %d %q", code, body)
+	}
+	if code, _ := do(http.MethodGet, "/forum/history/999", adminID, nil); code != http.StatusNotFound {
+		t.Fatalf("missing post history = %d, want 404", code)
+	}
+
+	var revisionID int
+	if err := db.QueryRow(`SELECT id FROM forum_revisions WHERE forum_id = ?`, forumID).Scan(&revisionID); err != nil {
+		t.Fatal(err)
+	}
+	restore := "/forum/history/post/" + strconv.Itoa(revisionID) + "/restore"
+	if code, _ := do(http.MethodPost, restore, moderID, url.Values{}); code != http.StatusForbidden {
+		t.Fatalf("moderator restore = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodGet, restore, adminID, nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET restore = %d, want 405", code)
+	}
+	if code, _ := do(http.MethodPost, restore, adminID, url.Values{}); code != http.StatusSeeOther {
+		t.Fatalf("admin restore = %d", code)
+	}
+	var content string
+	if err := db.QueryRow(`SELECT content FROM forums WHERE id = ?`, forumID).Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if content != "line one\nline two" {
+		t.Fatalf("restored content = %q", content)
+	}
+
+	var commentRevisionID int
+	if err := db.QueryRow(`SELECT id FROM comment_revisions WHERE comment_id = ?`, commentID).Scan(&commentRevisionID); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := do(http.MethodPost, "/forum/history/comment/"+strconv.Itoa(commentRevisionID)+"/restore", adminID, url.Values{}); code != http.StatusSeeOther {
+		t.Fatalf("comment restore = %d", code)
+	}
+	if err := db.QueryRow(`SELECT comment FROM forum_comments WHERE id = ?`, commentID).Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if content != "hello" {
+		t.Fatalf("restored comment = %q", content)
+	}
+
+	var logged int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM moderation_actions WHERE action = ?`, models.ActionRevisionRestored).Scan(&logged); err != nil {
+		t.Fatal(err)
+	}
+	if logged != 2 {
+		t.Fatalf("%d restores logged, want 2", logged)
+	}
+}
+
+func TestForumHistoryOfHiddenPosts(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	app.tempalteCache["history.tmpl.html"] = mustTemplate(`{{define "base"}}{{with .Form}}{{range .Post}}{{.Content}}|{{end}}{{end}}{{end}}`)
+	h := app.routes()
+
+	authorID := seedWebUser(t, app, "hauthor", "hauthor@example.com", models.UserRole)
+	adminID := seedWebUser(t, app, "hadmin", "hadmin@example.com", models.AdminRole)
+
+	publish := func() int {
+		t.Helper()
+		forumID, err := app.forums.Insert(context.Background(), "Title", "first", "go", 7, authorID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.forums.ChangeForumStatus(forumID, models.VisibleStatus); err != nil {
+			t.Fatal(err)
+		}
+		post := forumsvc.Post{Title: "Title", Content: "second", Tags: "go", Expires: 7}
+		if err := app.forumService.Update(context.Background(), forumID, post, authorID); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		return forumID
+	}
+
+	rejected := publish()
+	if err := app.forums.SetState(context.Background(), rejected, models.PostRejected, adminID, "spam"); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	trashed := publish()
+	if err := app.forums.Remove(context.Background(), trashed, authorID); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	for name, forumID := range map[string]int{"rejected": rejected, "trashed": trashed} {
+		for _, userID := range []int{authorID, adminID} {
+			req, rr := newRequest(http.MethodGet, "/forum/history/"+strconv.Itoa(forumID), nil)
+			attachSessionCookie(t, app, req, userID)
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK || rr.Body.String() != "second|first|" {
+				t.Fatalf("%s post history for user %d = %d %q", name, userID, rr.Code, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestRestoreRevisionIsAtomic(t *testing.T) {
+	app, db := newWebTestApp(t)
+	h := app.routes()
+
+	authorID := seedWebUser(t, app, "rauthor", "rauthor@example.com", models.UserRole)
+	adminID := seedWebUser(t, app, "radmin", "radmin@example.com", models.AdminRole)
+	forumID, err := app.forums.Insert(context.Background(), "Title", "first", "go", 7, authorID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.forums.ChangeForumStatus(forumID, models.VisibleStatus); err != nil {
+		t.Fatal(err)
+	}
+	post := forumsvc.Post{Title: "Title", Content: "second", Tags: "go", Expires: 7}
+	if err := app.forumService.Update(context.Background(), forumID, post, authorID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	commentID, err := app.forumComment.CommentPost(forumID, authorID, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.forumComment.EditCommentPost(forumID, authorID, "hello there", commentID, authorID); err != nil {
+		t.Fatal(err)
+	}
+
+	restore := testutil.FailInserts(t, db, "moderation_actions")
+	defer restore()
+
+	tests := map[string]string{
+		"post":    `SELECT id FROM forum_revisions WHERE forum_id = ?`,
+		"comment": `SELECT r.id FROM comment_revisions r JOIN forum_comments c ON c.id = r.comment_id WHERE c.forum_id = ?`,
+	}
+	for kind, query := range tests {
+		var revisionID int
+		if err := db.QueryRow(query, forumID).Scan(&revisionID); err != nil {
+			t.Fatal(err)
+		}
+		req, rr := newRequest(http.MethodPost, "/forum/history/"+kind+"/"+strconv.Itoa(revisionID)+"/restore", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, adminID)
+		attachCSRF(req)
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("%s restore = %d, want 500", kind, rr.Code)
+		}
+	}
+
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM forums WHERE id = ? AND content = 'second'`, forumID); n != 1 {
+		t.Fatal("failed post restore changed the post")
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM forum_comments WHERE id = ? AND comment = 'hello there'`, commentID); n != 1 {
+		t.Fatal("failed comment restore changed the comment")
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM forum_revisions WHERE forum_id = ?`, forumID); n != 1 {
+		t.Fatalf("failed post restore left %d revisions, want 1", n)
+	}
+}
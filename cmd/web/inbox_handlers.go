@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+)
+
+type inboxForm struct {
+	Notifications []*models.InboxNotification
+	UnreadOnly    bool
+}
+
+// unreadNotifications returns the unread inbox count for the nav bar. Errors
+// only hide the counter.
+func (app *application) unreadNotifications(r *http.Request) int {
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		return 0
+	}
+	n, err := app.forumService.UnreadNotifications(userID)
+	if err != nil {
+		app.errorLog.Printf("unread notifications: %v", err)
+		return 0
+	}
+	return n
+}
+
+func (app *application) userInbox(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/inbox" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := inboxForm{UnreadOnly: r.URL.Query().Get("unread") == "1"}
+	form.Notifications, err = app.forumService.Inbox(userID, form.UnreadOnly)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "inbox.tmpl.html", data)
+}
+
+// userInboxRead marks a notification as read and follows it to the post,
+// or to the comment when there is one.
+func (app *application) userInboxRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[1] != "user" || parts[2] != "inbox" || parts[3] != "read" {
+		app.notFound(w)
+		return
+	}
+	id, err := strconv.Atoi(parts[4])
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	n, err := app.forumService.ReadNotification(id, userID)
+	if err != nil {
+		if errors.Is(err, forumsvc.ErrNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	target := fmt.Sprintf("/forum/view/%d", n.ForumID)
+	if n.CommentID != 0 {
+		target += fmt.Sprintf("#comment-%d", n.CommentID)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (app *application) userInboxReadAll(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/inbox/read-all" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err := app.forumService.ReadAllNotifications(userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/user/inbox", http.StatusSeeOther)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+// forumState handles POST /forum/state/{id}, moving a post through its
+// lifecycle: action is submit, withdraw, archive, unarchive or renew, which
+// takes the new lifetime in expires.
+func (app *application) forumState(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 || parts[1] != "forum" || parts[2] != "state" {
+		app.notFound(w)
+		return
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	switch r.PostForm.Get("action") {
+	case "submit":
+		err = app.forumService.Submit(r.Context(), id, userID)
+	case "withdraw":
+		err = app.forumService.Withdraw(r.Context(), id, userID)
+	case "archive":
+		err = app.forumService.Archive(r.Context(), id, userID)
+	case "unarchive":
+		err = app.forumService.Unarchive(r.Context(), id, userID)
+	case "renew":
+		days, convErr := strconv.Atoi(r.PostForm.Get("expires"))
+		if convErr != nil {
+			app.clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		err = app.forumService.Renew(id, days, userID)
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/forum/view/%d", id), http.StatusSeeOther)
+}
+
+// forumRejectPost handles POST /moderation/forum/{id}/reject. The reason
+// is required; it is shown to the author with the post.
+func (app *application) forumRejectPost(w http.ResponseWriter, r *http.Request, idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.PostForm.Get("reason"))
+	if !validator.NotBlank(reason) || !validator.MaxChars(reason, 500) {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.forumService.RejectPost(r.Context(), id, userID, reason); err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
+}
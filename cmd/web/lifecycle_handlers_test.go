@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+func TestPostLifecycleHandlers(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["view.tmpl.html"] = mustTemplate(`{{define "base"}}{{with .Form}}{{.State}}:{{.StateReason}}:{{.IsAuthor}}{{end}}{{end}}`)
+	h := app.routes()
+	// Its own address, so the requests here do not count against the rate
+	// limit the other handler tests share.
+	const remoteAddr = "198.51.100.21:4000"
+
+	authorID := seedWebUser(t, app, "author", "author@example.com", models.UserRole)
+	otherID := seedWebUser(t, app, "other", "other@example.com", models.UserRole)
+	moderID := seedWebUser(t, app, "moder", "moder@example.com", models.ModeratorRole)
+
+	// Saving a draft from the create form takes the author to it.
+	fields := map[string]string{"title": "Plans", "content": "not yet", "expires": "7", "custom_tags": "go", "action": "draft"}
+	body, ct := uploadBody(t, fields, "", nil)
+	req, rr := newRequest(http.MethodPost, "/forum/create", body)
+	req.Header.Set("Content-Type", ct)
+	req.RemoteAddr = remoteAddr
+	attachSessionCookie(t, app, req, authorID)
+	attachCSRF(req)
+	h.ServeHTTP(rr, req)
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusSeeOther || !strings.HasPrefix(location, "/forum/view/") {
+		t.Fatalf("saving a draft = %d %q", rr.Code, location)
+	}
+	forumID, err := strconv.Atoi(strings.TrimPrefix(location, "/forum/view/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(forumID)
+
+	do := func(method, target string, userID int, form url.Values) (int, string) {
+		req, rr := newRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		if userID > 0 {
+			attachSessionCookie(t, app, req, userID)
+		}
+		attachCSRF(req)
+		h.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	action := func(a string) url.Values { return url.Values{"action": {a}} }
+	view := "/forum/view/" + id
+	state := "/forum/state/" + id
+	reject := "/moderation/forum/" + id + "/reject"
+
+	if code, body := do(http.MethodGet, view, authorID, nil); code != http.StatusOK || body != "draft::true" {
+		t.Fatalf("author viewing a draft = %d %q", code, body)
+	}
+	for _, userID := range []int{0, otherID, moderID} {
+		if code, _ := do(http.MethodGet, view, userID, nil); code != http.StatusNotFound {
+			t.Fatalf("user %d viewing a draft = %d, want 404", userID, code)
+		}
+	}
+
+	if code, _ := do(http.MethodPost, state, otherID, action("submit")); code != http.StatusForbidden {
+		t.Fatalf("submitting another user's draft = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, action("submit")); code != http.StatusSeeOther {
+		t.Fatalf("submitting a draft = %d", code)
+	}
+	if code, body := do(http.MethodGet, view, moderID, nil); code != http.StatusOK || body != "pending::false" {
+		t.Fatalf("moderator viewing a pending post = %d %q", code, body)
+	}
+	if code, _ := do(http.MethodGet, view, otherID, nil); code != http.StatusNotFound {
+		t.Fatalf("other user viewing a pending post = %d, want 404", code)
+	}
+
+	if code, _ := do(http.MethodPost, reject, otherID, url.Values{"reason": {"spam"}}); code != http.StatusForbidden {
+		t.Fatalf("user rejecting = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodPost, reject, moderID, url.Values{"reason": {"  "}}); code != http.StatusUnprocessableEntity {
+		t.Fatalf("rejecting without a reason = %d, want 422", code)
+	}
+	if code, _ := do(http.MethodPost, reject, moderID, url.Values{"reason": {"needs sources"}}); code != http.StatusSeeOther {
+		t.Fatalf("rejecting = %d", code)
+	}
+	if code, body := do(http.MethodGet, view, authorID, nil); code != http.StatusOK || body != "rejected:needs sources:true" {
+		t.Fatalf("author viewing a rejected post = %d %q", code, body)
+	}
+	if code, _ := do(http.MethodPost, reject, moderID, url.Values{"reason": {"again"}}); code != http.StatusConflict {
+		t.Fatalf("rejecting twice = %d, want 409", code)
+	}
+	var event string
+	if err := db.QueryRow(`SELECT event FROM user_notifications WHERE recipient_id = ?`, authorID).Scan(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event != models.EventPostRejected {
+		t.Fatalf("author was told %q", event)
+	}
+
+	// Resubmitted and approved from the queue, then archived and back.
+	if code, _ := do(http.MethodPost, state, authorID, action("submit")); code != http.StatusSeeOther {
+		t.Fatalf("resubmitting = %d", code)
+	}
+	var notID int
+	err = db.QueryRow(`SELECT id FROM forum_notifications WHERE forum_link = ? AND user_id = -1`, forumID).Scan(&notID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept := "/moderation/forum/" + strconv.Itoa(notID) + "/" + id
+	if code, _ := do(http.MethodPost, accept, moderID, nil); code != http.StatusSeeOther {
+		t.Fatalf("approving = %d", code)
+	}
+	if code, body := do(http.MethodGet, view, otherID, nil); code != http.StatusOK || body != "published::false" {
+		t.Fatalf("viewing a published post = %d %q", code, body)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, action("withdraw")); code != http.StatusConflict {
+		t.Fatalf("withdrawing a published post = %d, want 409", code)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, action("unarchive")); code != http.StatusConflict {
+		t.Fatalf("unarchiving a published post = %d, want 409", code)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, action("archive")); code != http.StatusSeeOther {
+		t.Fatalf("archiving = %d", code)
+	}
+	if code, body := do(http.MethodGet, view, otherID, nil); code != http.StatusGone || body != "archived::false" {
+		t.Fatalf("viewing an archived post = %d %q, want 410", code, body)
+	}
+	if code, _ := do(http.MethodPost, state, authorID, action("unarchive")); code != http.StatusSeeOther {
+		t.Fatalf("unarchiving = %d", code)
+	}
+
+	if code, _ := do(http.MethodPost, state, authorID, action("bogus")); code != http.StatusBadRequest {
+		t.Fatalf("unknown action = %d, want 400", code)
+	}
+	if code, _ := do(http.MethodGet, state, authorID, nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET state = %d, want 405", code)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"testing"
@@ -11,7 +12,7 @@ func TestForumView_NoSessionBadSessionNoRowsAndNoRecord(t *testing.T) {
 	addBaseTemplate(app, "view.tmpl.html")
 
 	userID := seedWebUser(t, app, "viewu", "viewu@example.com", 2)
-	forumID, err := app.forums.Insert("view title", "view body", "go", 7, userID, "")
+	forumID, err := app.forums.Insert(context.Background(), "view title", "view body", "go", 7, userID, "")
 	if err != nil {
 		t.Fatalf("insert forum: %v", err)
 	}
@@ -38,7 +39,7 @@ func TestForumView_NoSessionBadSessionNoRowsAndNoRecord(t *testing.T) {
 		t.Fatalf("forumView missing row status=%d", rr.Code)
 	}
 
-	_, err = db.Exec(`INSERT INTO forums(title,content,tags,user_id,created,expires,image_path,status) VALUES('expired','x','go',?,datetime('now'),datetime('now','-1 day'),'',1)`, userID)
+	_, err = db.Exec(`INSERT INTO forums(title,content,user_id,created,expires,image_path,status) VALUES('expired','x',?,datetime('now'),datetime('now','-1 day'),'',1)`, userID)
 	if err != nil {
 		t.Fatalf("insert expired forum: %v", err)
 	}
@@ -48,8 +49,8 @@ func TestForumView_NoSessionBadSessionNoRowsAndNoRecord(t *testing.T) {
 	}
 	req, rr = newRequest(http.MethodGet, "/forum/view/"+itoa(expiredID), nil)
 	app.forumView(rr, req)
-	if rr.Code != http.StatusNotFound {
-		t.Fatalf("forumView expired no record status=%d", rr.Code)
+	if rr.Code != http.StatusGone {
+		t.Fatalf("forumView expired status=%d, want 410", rr.Code)
 	}
 }
 
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aspandyar/forum/internal/live"
+	"github.com/aspandyar/forum/internal/models"
+)
+
+var (
+	// liveHeartbeat is how often an idle stream sends a comment line so
+	// proxies keep the connection open.
+	liveHeartbeat = 15 * time.Second
+	// liveMaxStream ends a stream after this long; the browser reconnects
+	// with its Last-Event-ID and misses nothing.
+	liveMaxStream = 30 * time.Minute
+)
+
+// forumEvents streams new comments and vote totals of a post.
+func (app *application) forumEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := pathParts(r)
+	if len(parts) != 4 || parts[1] != "forum" || parts[2] != "events" {
+		app.notFound(w)
+		return
+	}
+	id, err := pathInt(parts, 3)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Status reads one row; the stream only needs to know the post exists.
+	if _, err := app.forums.Status(id); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.streamEvents(w, r, models.ForumTopic(id))
+}
+
+// userInboxEvents streams the signed-in user's new notifications and unread
+// count.
+func (app *application) userInboxEvents(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/inbox/events" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.streamEvents(w, r, models.InboxTopic(userID))
+}
+
+// liveClient is the key stream limits are counted against: the signed-in
+// user, or the remote address for visitors.
+func (app *application) liveClient(r *http.Request) string {
+	if userID, err := app.sessionUserID(r); err == nil {
+		return "user:" + strconv.Itoa(userID)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// lastEventID reads the resume point sent by EventSource on reconnect, or
+// the lastEventId query parameter used for the first connection of a page.
+func lastEventID(r *http.Request) uint64 {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// streamEvents subscribes to topic and writes its events as Server-Sent
+// Events until the client goes away or the stream reaches liveMaxStream.
+func (app *application) streamEvents(w http.ResponseWriter, r *http.Request, topic string) {
+	sub, err := app.live.Subscribe(topic, app.liveClient(r), lastEventID(r))
+	if err != nil {
+		if errors.Is(err, live.ErrTooManyStreams) {
+			app.clientError(w, http.StatusTooManyRequests)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	defer sub.Close()
+
+	// The server's WriteTimeout would cut every stream after a few seconds.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if sub.Reset {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range sub.Backlog {
+		writeEvent(w, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	lifetime := time.NewTimer(liveMaxStream)
+	defer lifetime.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lifetime.C:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client resumes from its
+				// last ID.
+				return
+			}
+			writeEvent(w, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e live.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
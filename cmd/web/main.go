@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"flag"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/aspandyar/forum/internal/config/envfile"
+	"github.com/aspandyar/forum/internal/live"
+	"github.com/aspandyar/forum/internal/mail"
+	"github.com/aspandyar/forum/internal/media"
 	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/oauth"
+	"github.com/aspandyar/forum/internal/policy"
 	"github.com/aspandyar/forum/internal/security/tlsconfig"
+	authsvc "github.com/aspandyar/forum/internal/service/auth"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -25,13 +34,16 @@ type application struct {
 	users         *models.UserModel
 	forumLike     *models.ForumLikesModel
 	forumComment  *models.ForumCommentModel
+	live          *live.Hub
+	policy        *policy.Policy
+	forumService  *forumsvc.Service
+	authService   *authsvc.Service
+	oauth         *oauth.Registry
+	images        *media.Store
 	tempalteCache map[string]*template.Template
 }
 
-const (
-	newDbName       = "./st.db"
-	initSqlFileName = "./init-up.sql"
-)
+const newDbName = "./st.db"
 
 func main() {
 	addr := flag.String("addr", ":4000", "HTTP network address")
@@ -44,6 +56,37 @@ func main() {
 		infoLog.Printf("No .env loaded, using environment variables: %v", err)
 	}
 
+	if flag.Arg(0) == "media" {
+		if err := runMedia(flag.Args()[1:], os.Getenv, os.Stdout); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "migrate" {
+		db, err := openDB(newDbName)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer db.Close()
+		if err := runMigrate(db, flag.Args()[1:], os.Stdout); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "counters" {
+		db, err := openDB(newDbName)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer db.Close()
+		if err := runCounters(&models.ForumModel{DB: db}, flag.Args()[1:], os.Stdout); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
 
@@ -51,26 +94,75 @@ func main() {
 		errorLog.Fatal("Missing database credentials. Set DB_USER and DB_PASSWORD environment variables.")
 	}
 
+	if v := os.Getenv("COMMENT_MAX_DEPTH"); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 0 {
+			errorLog.Fatalf("invalid COMMENT_MAX_DEPTH %q", v)
+		}
+		models.MaxCommentDepth = depth
+	}
+
+	for name, retention := range map[string]*time.Duration{
+		"NOTIFICATION_RETENTION_READ":   &models.ReadNotificationRetention,
+		"NOTIFICATION_RETENTION_UNREAD": &models.UnreadNotificationRetention,
+		"SESSION_IDLE_TIMEOUT":          &models.SessionIdleTimeout,
+		"SESSION_MAX_LIFETIME":          &models.SessionMaxLifetime,
+		"TRASH_RETENTION":               &models.TrashRetention,
+		"EXPIRY_REMINDER":               &models.ExpiryReminder,
+		"ARCHIVE_RETENTION":             &models.ArchiveRetention,
+	} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				errorLog.Fatalf("invalid %s %q", name, v)
+			}
+			*retention = d
+		}
+	}
+
 	db, err := openDB(newDbName)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	defer db.Close()
-	readDB(initSqlFileName, db)
+
+	if err := migrateDB(db); err != nil {
+		errorLog.Fatal(err)
+	}
 
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
+	hub := live.NewHub()
+	images, err := newImageStore(os.Getenv)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	forumService, authService := newServices(db, hub, newMailer(infoLog), images)
+	if v := os.Getenv("BASE_URL"); v != "" {
+		authService.BaseURL = strings.TrimRight(v, "/")
+	}
+	providers, err := newOAuthRegistry(os.Getenv, authService.BaseURL)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	app := &application{
 		errorLog:      errorLog,
 		infoLog:       infoLog,
-		forums:        &models.ForumModel{DB: db},
+		forums:        &models.ForumModel{DB: db, Live: hub},
 		users:         &models.UserModel{DB: db},
 		sessions:      &models.SessionModel{DB: db},
-		forumLike:     &models.ForumLikesModel{DB: db},
-		forumComment:  &models.ForumCommentModel{DB: db},
+		forumLike:     &models.ForumLikesModel{DB: db, Live: hub},
+		forumComment:  &models.ForumCommentModel{DB: db, Live: hub},
+		live:          hub,
+		policy:        forumService.Policy,
+		forumService:  forumService,
+		authService:   authService,
+		oauth:         providers,
+		images:        images,
 		tempalteCache: templateCache,
 	}
 
@@ -79,6 +171,11 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	go app.pruneInbox(time.Hour)
+	go app.pruneSessions(time.Hour)
+	go app.purgeTrash(time.Hour)
+	go app.sweepExpiry(time.Hour)
+
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 		MinVersion:       tls.VersionTLS12,
@@ -121,36 +218,108 @@ func main() {
 	errorLog.Fatal(srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"))
 }
 
-func openDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return nil, err
+// pruneInbox drops notifications past their retention window now and then
+// every interval.
+func (app *application) pruneInbox(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := app.forumService.PruneInbox(time.Now())
+		if err != nil {
+			app.errorLog.Printf("prune inbox: %v", err)
+		} else if removed > 0 {
+			app.infoLog.Printf("pruned %d old notifications", removed)
+		}
+		<-ticker.C
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
+}
+
+// pruneSessions drops expired sessions and spent account tokens now and
+// then every interval.
+func (app *application) pruneSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := app.authService.PruneSessions(context.Background(), time.Now())
+		if err != nil {
+			app.errorLog.Printf("prune sessions: %v", err)
+		} else if removed > 0 {
+			app.infoLog.Printf("pruned %d expired sessions", removed)
+		}
+		if _, err := app.authService.PruneTokens(time.Now()); err != nil {
+			app.errorLog.Printf("prune account tokens: %v", err)
+		}
+		<-ticker.C
 	}
-	return db, nil
 }
 
-func readDB(path string, db *sql.DB) {
-	sqlScript, err := os.ReadFile(path)
-	if err != nil {
-		log.Fatal(err)
+// purgeTrash deletes for good the posts and comments that have been in the
+// trash longer than TRASH_RETENTION, now and then every interval.
+func (app *application) purgeTrash(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		posts, comments, err := app.forumService.PurgeTrash(context.Background(), time.Now())
+		if err != nil {
+			app.errorLog.Printf("purge trash: %v", err)
+		} else if posts > 0 || comments > 0 {
+			app.infoLog.Printf("purged %d posts and %d comments from the trash", posts, comments)
+		}
+		<-ticker.C
 	}
+}
 
-	statements := strings.Split(string(sqlScript), ";")
+// sweepExpiry reminds authors of posts expiring within EXPIRY_REMINDER,
+// archives expired posts and purges those archived longer than
+// ARCHIVE_RETENTION, now and then every interval.
+func (app *application) sweepExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	for _, stmt := range statements {
-		trimmedStmt := strings.TrimSpace(stmt)
-		if len(trimmedStmt) > 0 {
-			_, err := db.Exec(trimmedStmt)
-			if err != nil {
-				log.Println("Error executing statement:", err)
-			}
+	for {
+		reminded, archived, purged, err := app.forumService.SweepExpiry(context.Background(), time.Now())
+		if err != nil {
+			app.errorLog.Printf("sweep expiry: %v", err)
+		} else if reminded > 0 || archived > 0 || purged > 0 {
+			app.infoLog.Printf("expiry: reminded %d, archived %d and purged %d posts", reminded, archived, purged)
 		}
+		<-ticker.C
 	}
 }
 
+// newMailer sends through MAIL_SMTP_ADDR when it is set. Otherwise mail is
+// written to MAIL_DIR, or logged when that is unset too.
+func newMailer(infoLog *log.Logger) mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "forum@localhost"
+	}
+
+	if addr := os.Getenv("MAIL_SMTP_ADDR"); addr != "" {
+		return &mail.SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv("MAIL_SMTP_USERNAME"),
+			Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	return &mail.FileMailer{Dir: os.Getenv("MAIL_DIR"), From: from, Log: infoLog}
+}
+
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
 func ConfigureCipherSuites(config *tls.Config, cipherSuites []uint16) {
 	tlsconfig.ConfigureCipherSuites(config, cipherSuites)
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aspandyar/forum/internal/media"
+)
+
+// mediaURLPrefix is where posts record their images and where the disk
+// backend serves them.
+const mediaURLPrefix = "/media"
+
+// newImageStore builds the upload store for MEDIA_BACKEND, which defaults
+// to the local disk. URLs handed out for images last MEDIA_URL_TTL, from a
+// second up to media.MaxURLTTL.
+func newImageStore(getenv func(string) string) (*media.Store, error) {
+	spec := getenv("MEDIA_BACKEND")
+	if spec == "" {
+		spec = "disk"
+	}
+	blobs, err := newBlobStore(getenv, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key made up at startup would break every URL handed out before a
+	// restart and differ between instances sharing the uploads.
+	if disk, ok := blobs.(*media.Disk); ok && len(disk.Secret) == 0 {
+		return nil, errors.New("disk storage needs MEDIA_SIGNING_KEY to sign image URLs")
+	}
+
+	store := &media.Store{Blobs: blobs, URLPrefix: mediaURLPrefix, URLTTL: time.Hour}
+	if v := getenv("MEDIA_URL_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < time.Second || ttl > media.MaxURLTTL {
+			return nil, fmt.Errorf("invalid MEDIA_URL_TTL %q: want a duration from 1s to %s", v, media.MaxURLTTL)
+		}
+		store.URLTTL = ttl
+	}
+	return store, nil
+}
+
+// newBlobStore opens the backend named by spec: "disk" or "disk:<dir>"
+// (MEDIA_DIR, default ./uploads) or "s3" or "s3:<bucket>" (S3_BUCKET), with
+// the service described by the S3_* variables.
+func newBlobStore(getenv func(string) string, spec string) (media.BlobStore, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "disk":
+		dir := arg
+		if dir == "" {
+			dir = getenv("MEDIA_DIR")
+		}
+		if dir == "" {
+			dir = "./uploads"
+		}
+		secret := []byte(getenv("MEDIA_SIGNING_KEY"))
+		return &media.Disk{Dir: dir, URL: mediaURLPrefix, Secret: secret}, nil
+
+	case "s3":
+		s := &media.S3{
+			Endpoint:  getenv("S3_ENDPOINT"),
+			Region:    getenv("S3_REGION"),
+			Bucket:    arg,
+			AccessKey: getenv("S3_ACCESS_KEY_ID"),
+			SecretKey: getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		if s.Bucket == "" {
+			s.Bucket = getenv("S3_BUCKET")
+		}
+		if v := getenv("S3_PATH_STYLE"); v != "" {
+			pathStyle, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid S3_PATH_STYLE %q", v)
+			}
+			s.PathStyle = pathStyle
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("s3 storage needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", spec)
+	}
+}
+
+var errMediaUsage = errors.New("usage: forum media migrate FROM TO [-move] (backends: disk[:dir], s3[:bucket])")
+
+// runMedia implements `forum media migrate FROM TO [-move]`, which copies
+// uploads from one backend to another.
+func runMedia(args []string, getenv func(string) string, out io.Writer) error {
+	if len(args) < 3 || len(args) > 4 || args[0] != "migrate" {
+		return errMediaUsage
+	}
+	move := false
+	if len(args) == 4 {
+		if args[3] != "-move" {
+			return errMediaUsage
+		}
+		move = true
+	}
+
+	src, err := newBlobStore(getenv, args[1])
+	if err != nil {
+		return err
+	}
+	dst, err := newBlobStore(getenv, args[2])
+	if err != nil {
+		return err
+	}
+
+	copied, skipped, err := media.Migrate(dst, src, move)
+	fmt.Fprintf(out, "copied %d file(s), %d already present\n", copied, skipped)
+	return err
+}
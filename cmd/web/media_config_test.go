@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspandyar/forum/internal/media"
+)
+
+func TestNewImageStore(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	store, err := newImageStore(env(map[string]string{"MEDIA_SIGNING_KEY": "signing key"}))
+	if err != nil {
+		t.Fatalf("default store: %v", err)
+	}
+	disk, ok := store.Blobs.(*media.Disk)
+	if !ok || disk.Dir != "./uploads" || string(disk.Secret) != "signing key" || store.URLTTL != time.Hour {
+		t.Fatalf("default store = %#v, %#v", store, store.Blobs)
+	}
+
+	store, err = newImageStore(env(map[string]string{
+		"MEDIA_BACKEND":        "s3",
+		"MEDIA_URL_TTL":        "10m",
+		"S3_ENDPOINT":          "http://127.0.0.1:9000",
+		"S3_BUCKET":            "uploads",
+		"S3_ACCESS_KEY_ID":     "key",
+		"S3_SECRET_ACCESS_KEY": "secret",
+		"S3_PATH_STYLE":        "true",
+	}))
+	if err != nil {
+		t.Fatalf("s3 store: %v", err)
+	}
+	s3, ok := store.Blobs.(*media.S3)
+	if !ok || s3.Bucket != "uploads" || !s3.PathStyle || store.URLTTL != 10*time.Minute {
+		t.Fatalf("s3 store = %#v", store.Blobs)
+	}
+
+	for name, vars := range map[string]map[string]string{
+		"unknown backend":    {"MEDIA_BACKEND": "ftp"},
+		"disk without a key": {"MEDIA_BACKEND": "disk"},
+		"bad ttl":            {"MEDIA_SIGNING_KEY": "k", "MEDIA_URL_TTL": "forever"},
+		"ttl too short":      {"MEDIA_SIGNING_KEY": "k", "MEDIA_URL_TTL": "500ms"},
+		"ttl too long":       {"MEDIA_SIGNING_KEY": "k", "MEDIA_URL_TTL": "169h"},
+		"s3 without keys":    {"MEDIA_BACKEND": "s3", "S3_ENDPOINT": "http://127.0.0.1:9000", "S3_BUCKET": "b"},
+		"bad path style":     {"MEDIA_BACKEND": "s3:b", "S3_PATH_STYLE": "maybe"},
+	} {
+		if _, err := newImageStore(env(vars)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRunMediaMigrate(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	getenv := func(string) string { return "" }
+	src := &media.Disk{Dir: from}
+	for _, key := range []string{"ab/one.png", "cd/two.jpg"} {
+		if err := src.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&media.Disk{Dir: to}).Put("ab/one.png", []byte("ab/one.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runMedia([]string{"migrate", "disk:" + from, "disk:" + to, "-move"}, getenv, &out); err != nil {
+		t.Fatalf("runMedia: %v", err)
+	}
+	if got := out.String(); got != "copied 1 file(s), 1 already present\n" {
+		t.Fatalf("output = %q", got)
+	}
+	if data, err := os.ReadFile(filepath.Join(to, "cd", "two.jpg")); err != nil || string(data) != "cd/two.jpg" {
+		t.Fatalf("copied file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(from, "cd", "two.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("-move left the source file: %v", err)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"migrate", "disk"},
+		{"copy", "disk:a", "disk:b"},
+		{"migrate", "disk:a", "disk:b", "-force"},
+	} {
+		if err := runMedia(args, getenv, &out); err != errMediaUsage {
+			t.Fatalf("runMedia(%q) err = %v, want usage", args, err)
+		}
+	}
+	if err := runMedia([]string{"migrate", "disk:" + from, "s3"}, getenv, &out); err == nil {
+		t.Fatal("expected an error for unconfigured s3")
+	}
+}
+
+func TestMediaServedWithSignedURLs(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	img, err := media.Process(bytes.NewReader(testPNG(t, color.NRGBA{R: 255, A: 255})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := app.images.Save(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, thumb := app.images.URLs(path)
+	for _, target := range []string{full, thumb} {
+		req, rr := newRequest(http.MethodGet, target, nil)
+		app.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("GET %s = %d %q", target, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+
+	req, rr := newRequest(http.MethodGet, path, nil)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("unsigned GET = %d, want 403", rr.Code)
+	}
+
+	req, rr = newRequest(http.MethodGet, strings.Replace(full, "sig=", "sig=f", 1), nil)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("tampered GET = %d, want 403", rr.Code)
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/aspandyar/forum/internal/media"
+	"github.com/aspandyar/forum/internal/policy"
 	mw "github.com/aspandyar/forum/internal/transport/http/middleware"
+	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
 )
 
 var (
@@ -18,6 +22,11 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 	return rateLimiter(next)
 }
 
+// maxBodySize leaves room for the form fields of a post next to its image.
+const maxBodySize = media.MaxUploadSize + 1<<20
+
+var limitBody = mw.LimitBody(maxBodySize)
+
 func secureHeaders(next http.Handler) http.Handler {
 	return mw.SecureHeaders(next)
 }
@@ -33,3 +42,51 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return mw.RequireAuthentication(app.isAuthenticated)(next)
 }
+
+// renewSession slides the requester's session forward while it is in use.
+// The cookie is reissued with the new expiry; bearer tokens are renewed
+// the same way but have no cookie to update.
+func (app *application) renewSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := apiToken(r)
+		expiry, renewed, err := app.authService.Renew(r.Context(), token)
+		if err != nil {
+			app.errorLog.Printf("renew session: %v", err)
+		} else if renewed && r.Header.Get("Authorization") == "" {
+			sessioncookie.SetSessionCookie(w, token, expiry)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrf rejects forged form posts with 403. API calls are exempt when they
+// authenticate with a bearer token or carry no session cookie, since a
+// browser cannot be made to send either on another site's behalf.
+func (app *application) csrf(next http.Handler) http.Handler {
+	exempt := func(r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			return false
+		}
+		if r.Header.Get("Authorization") != "" {
+			return true
+		}
+		_, err := r.Cookie("session")
+		return err != nil
+	}
+	fail := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+		app.clientError(w, http.StatusForbidden)
+	}
+	return mw.CSRF(exempt, fail)(next)
+}
+
+// requirePermission answers 403 unless the requester's role holds perm. It
+// goes inside requireAuthentication, so guests are sent to log in first.
+func (app *application) requirePermission(perm policy.Permission, next http.Handler) http.Handler {
+	allowed := func(r *http.Request) bool { return app.can(r, perm) }
+	deny := func(w http.ResponseWriter) { app.clientError(w, http.StatusForbidden) }
+	return mw.RequirePermission(allowed, deny)(next)
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aspandyar/forum/internal/models"
 	mw "github.com/aspandyar/forum/internal/transport/http/middleware"
 )
 
@@ -88,3 +91,89 @@ func TestRequireAuthenticationRedirectsWithoutSession(t *testing.T) {
 		t.Fatalf("expected redirect to login, got %q", rr.Header().Get("Location"))
 	}
 }
+
+func TestRenewSessionSlidesCookie(t *testing.T) {
+	app, db := newWebTestApp(t)
+	userID := seedWebUser(t, app, "slider", "slider@example.com", 2)
+	h := app.renewSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req, rr := newRequest(http.MethodGet, "/", nil)
+	attachSessionCookie(t, app, req, userID)
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Set-Cookie"); got != "" {
+		t.Fatalf("fresh session reissued cookie %q", got)
+	}
+
+	stale := time.Now().Add(-10 * time.Minute).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`UPDATE sessions SET last_seen = ?`, stale); err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+	_, rr = newRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || !strings.Contains(rr.Header().Get("Set-Cookie"), "session=") {
+		t.Fatalf("renewal status=%d Set-Cookie=%q", rr.Code, rr.Header().Get("Set-Cookie"))
+	}
+
+	// Bearer tokens are renewed without a cookie.
+	if _, err := db.Exec(`UPDATE sessions SET last_seen = ?`, stale); err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+	cookie, _ := req.Cookie("session")
+	req, rr = newRequest(http.MethodGet, "/api/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+cookie.Value)
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Set-Cookie"); got != "" {
+		t.Fatalf("bearer renewal set cookie %q", got)
+	}
+	var lastSeen string
+	if err := db.QueryRow(`SELECT last_seen FROM sessions`).Scan(&lastSeen); err != nil || lastSeen == stale {
+		t.Fatalf("bearer session not renewed: %q %v", lastSeen, err)
+	}
+}
+
+func TestCSRFOnRoutes(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	h := app.routes()
+	userID := seedWebUser(t, app, "csrf", "csrf@example.com", 2)
+
+	post := func(target string, withToken bool) *httptest.ResponseRecorder {
+		req, rr := newRequest(http.MethodPost, target, strings.NewReader("comment=hi"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, userID)
+		if withToken {
+			attachCSRF(req)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post("/user/logout", false); rr.Code != http.StatusForbidden {
+		t.Fatalf("logout without token status=%d", rr.Code)
+	}
+	if rr := post("/user/logout", true); rr.Code != http.StatusSeeOther {
+		t.Fatalf("logout with token status=%d", rr.Code)
+	}
+
+	// Cookie-authenticated API calls need the header too; bearer ones do not.
+	if rr := post("/api/v1/auth/logout", false); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), `"error"`) {
+		t.Fatalf("API cookie logout without token status=%d body=%s", rr.Code, rr.Body)
+	}
+	session, err := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	req, rr := newRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("API bearer logout status=%d", rr.Code)
+	}
+
+	req, rr = newRequest(http.MethodGet, "/user/login", nil)
+	h.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Header().Get("Set-Cookie"), mw.CSRFCookie+"=") {
+		t.Fatalf("GET did not issue a CSRF cookie: %q", rr.Header().Values("Set-Cookie"))
+	}
+}
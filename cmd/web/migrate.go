@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aspandyar/forum/internal/migrations"
+	"github.com/aspandyar/forum/internal/models"
+)
+
+var errMigrateUsage = errors.New("usage: forum migrate up [n] | down [n] | status")
+
+// runMigrate implements `forum migrate up|down|status`.
+func runMigrate(db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errMigrateUsage
+	}
+
+	steps := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return errMigrateUsage
+		}
+		steps = n
+	}
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.UpSteps(steps)
+		fmt.Fprintf(out, "applied %d migration(s)\n", count)
+		return err
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		count, err := migrator.Down(steps)
+		fmt.Fprintf(out, "reverted %d migration(s)\n", count)
+		return err
+	case "status":
+		if len(args) != 1 {
+			return errMigrateUsage
+		}
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied, file missing"
+			case s.Modified:
+				state = "applied, checksum mismatch"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return errMigrateUsage
+	}
+}
+
+// migrateDB applies pending migrations and then sets up the full-text search
+// index, which only exists in builds with FTS5.
+func migrateDB(db *sql.DB) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err = migrator.Up(); err != nil {
+		return err
+	}
+
+	search := &models.SearchModel{DB: db}
+	return search.EnsureIndex()
+}
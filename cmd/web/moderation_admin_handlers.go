@@ -1,36 +1,24 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/aspandyar/forum/internal/validator"
+	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
 )
 
 func (app *application) userNotification(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session")
+	userID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	userID, _, err := app.sessions.GetSession(cookie.Value)
+	notifications, err := app.forumService.Notifications(userID)
 	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	role, err := app.forums.GetRoleByUserID(userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	if role != adminRole && role != moderatorRole {
-		app.clientError(w, http.StatusMethodNotAllowed)
-		return
-	}
-	notifications, err := app.forums.ShowUserNotification(role)
-	if err != nil {
-		app.serverError(w, err)
+		app.moderationError(w, err)
 		return
 	}
 	data := app.newTemplateData(r)
@@ -39,91 +27,38 @@ func (app *application) userNotification(w http.ResponseWriter, r *http.Request)
 }
 
 func (app *application) userNotificationRemove(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	adminID, _, err := app.sessions.GetSession(cookie.Value)
-	if err != nil {
-		app.serverError(w, err)
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
 		return
 	}
-	role, err := app.forums.GetRoleByUserID(adminID)
+
+	userID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	if role != adminRole && role != moderatorRole {
-		app.clientError(w, http.StatusMethodNotAllowed)
-		return
-	}
 	parts := strings.Split(r.URL.Path, "/")
 	id, err := strconv.Atoi(parts[4])
 	if err != nil || id < 1 {
 		http.NotFound(w, r)
 		return
 	}
-	err = app.forums.RemoveUserNotification(id)
+	err = app.forumService.DismissNotification(r.Context(), id, userID)
 	if err != nil {
-		app.serverError(w, err)
+		app.moderationError(w, err)
 		return
 	}
 	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
 }
 
-func (app *application) forumReportRemoveHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	adminID, _, err := app.sessions.GetSession(cookie.Value)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	role, err := app.forums.GetRoleByUserID(adminID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	if role != adminRole {
+func (app *application) userModerationDone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
 		app.clientError(w, http.StatusMethodNotAllowed)
 		return
 	}
-	parts := strings.Split(r.URL.Path, "/")
-	getUserID, err := strconv.Atoi(parts[6])
-	if err != nil || getUserID < 1 {
-		http.NotFound(w, r)
-		return
-	}
-	forumID, err := strconv.Atoi(parts[5])
-	if err != nil || forumID < 1 {
-		http.NotFound(w, r)
-		return
-	}
-	id, err := strconv.Atoi(parts[4])
-	if err != nil || id < 1 {
-		http.NotFound(w, r)
-		return
-	}
-	if err = app.forums.ChangeForumStatus(forumID, invisibleStatus); err != nil {
-		app.serverError(w, err)
-		return
-	}
-	if err = app.forums.AnswerFromAdmin(getUserID, "approved"); err != nil {
-		app.serverError(w, err)
-		return
-	}
-	if err = app.forums.RemoveUserNotification(id); err != nil {
-		app.serverError(w, err)
-		return
-	}
-	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
-}
 
-func (app *application) userModerationDone(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	userID, err := strconv.Atoi(parts[4])
 	if err != nil || userID < 1 {
@@ -135,18 +70,27 @@ func (app *application) userModerationDone(w http.ResponseWriter, r *http.Reques
 		http.NotFound(w, r)
 		return
 	}
-	if err = app.forums.ChangeUserRole(userID, moderatorRole); err != nil {
+	actorID, err := app.sessionUserID(r)
+	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	if err = app.forums.RemoveUserNotification(id); err != nil {
-		app.serverError(w, err)
+	if err = app.forumService.SetModerator(r.Context(), id, userID, true, actorID); err != nil {
+		app.moderationError(w, err)
 		return
 	}
 	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
 }
 
+// moderDenoteHandler demotes a moderator. The queue entry id may be 0 when
+// the demotion does not come from the notification queue.
 func (app *application) moderDenoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	parts := strings.Split(r.URL.Path, "/")
 	moderID, err := strconv.Atoi(parts[3])
 	if err != nil || moderID < 1 {
@@ -154,23 +98,40 @@ func (app *application) moderDenoteHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	id, err := strconv.Atoi(parts[4])
-	if err != nil || id < 1 {
+	if err != nil || id < 0 {
 		http.NotFound(w, r)
 		return
 	}
-	if err = app.forums.ChangeUserRole(moderID, userRole); err != nil {
+	actorID, err := app.sessionUserID(r)
+	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	if err = app.forums.RemoveUserNotification(id); err != nil {
-		app.serverError(w, err)
+	if err = app.forumService.SetModerator(r.Context(), id, moderID, false, actorID); err != nil {
+		app.moderationError(w, err)
 		return
 	}
 	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
 }
 
+// forumAcceptHandler handles POST /moderation/forum/{notID}/{forumID},
+// which approves a queued post, and POST /moderation/forum/{forumID}/reject.
 func (app *application) forumAcceptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 {
+		http.NotFound(w, r)
+		return
+	}
+	if parts[4] == "reject" {
+		app.forumRejectPost(w, r, parts[3])
+		return
+	}
 	notID, err := strconv.Atoi(parts[3])
 	if err != nil || notID < 1 {
 		http.NotFound(w, r)
@@ -181,12 +142,13 @@ func (app *application) forumAcceptHandler(w http.ResponseWriter, r *http.Reques
 		http.NotFound(w, r)
 		return
 	}
-	if err = app.forums.ChangeForumStatus(fourmID, visibleStatus); err != nil {
+	actorID, err := app.sessionUserID(r)
+	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	if err = app.forums.RemoveUserNotification(notID); err != nil {
-		app.serverError(w, err)
+	if err = app.forumService.ApprovePost(r.Context(), notID, fourmID, actorID); err != nil {
+		app.moderationError(w, err)
 		return
 	}
 	http.Redirect(w, r, "/user/notification", http.StatusSeeOther)
@@ -205,7 +167,13 @@ func (app *application) addTagsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) addTagsGet(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.forums.TagCounts()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data := app.newTemplateData(r)
+	data.Form = tags
 	app.render(w, http.StatusOK, "addTags.tmpl.html", data)
 }
 
@@ -241,6 +209,57 @@ func (app *application) addTagsPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+func (app *application) changeTagPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := tagChangeForm{
+		Action: r.PostForm.Get("action"),
+		From:   r.PostForm.Get("from"),
+		To:     strings.TrimSpace(r.PostForm.Get("to")),
+	}
+	form.validate()
+	if !form.Valid() {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if form.Action == "rename" {
+		err = app.forumService.RenameTag(form.From, form.To, userID)
+	} else {
+		err = app.forumService.MergeTags(form.From, form.To, userID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, forumsvc.ErrForbidden):
+			app.clientError(w, http.StatusForbidden)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrDuplicateTag):
+			app.clientError(w, http.StatusConflict)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/addTags", http.StatusSeeOther)
+}
+
 func (app *application) forumReportHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -255,6 +274,7 @@ func (app *application) forumReportHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) ForumReportGet(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
+	data.Form = forumReportForm{}
 	app.render(w, http.StatusOK, "report.tmpl.html", data)
 }
 
@@ -264,12 +284,11 @@ func (app *application) ForumReportPost(w http.ResponseWriter, r *http.Request)
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	types := r.PostForm["reportType"]
 	form := forumReportForm{
-		reportTypes:   strings.Join(types, ", "),
-		reportDetails: r.PostForm.Get("reportDetails"),
+		Reasons: r.PostForm["reportType"],
+		Details: strings.TrimSpace(r.PostForm.Get("reportDetails")),
 	}
-	form.CheckField(validator.MaxChars(form.reportTypes, 200), "tags", "This field cannot be more than 200 characters long")
+	form.validate()
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
@@ -282,19 +301,14 @@ func (app *application) ForumReportPost(w http.ResponseWriter, r *http.Request)
 		http.NotFound(w, r)
 		return
 	}
-	cookie, err := r.Cookie("session")
+	moderID, err := app.sessionUserID(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	moderID, _, err := app.sessions.GetSession(cookie.Value)
+	_, err = app.forumService.Report(forumID, moderID, form.Reasons, form.Details)
 	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	err = app.forums.ReportForum(forumID, moderID, form.reportTypes+" "+form.reportDetails)
-	if err != nil {
-		app.serverError(w, err)
+		app.moderationError(w, err)
 		return
 	}
 	http.Redirect(w, r, "/forum/view/"+strconv.Itoa(forumID), http.StatusSeeOther)
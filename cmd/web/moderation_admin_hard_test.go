@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/aspandyar/forum/internal/policy"
 )
 
 func TestModerationAdminHandlers_Matrix(t *testing.T) {
@@ -14,7 +20,7 @@ func TestModerationAdminHandlers_Matrix(t *testing.T) {
 
 	adminID := seedWebUser(t, app, "adminx", "adminx@example.com", 4)
 	userID := seedWebUser(t, app, "userx", "userx@example.com", 2)
-	forumID, err := app.forums.Insert("title", "body", "go", 7, userID, "")
+	forumID, err := app.forums.Insert(context.Background(), "title", "body", "go", 7, userID, "")
 	if err != nil {
 		t.Fatalf("insert forum: %v", err)
 	}
@@ -41,7 +47,7 @@ func TestModerationAdminHandlers_Matrix(t *testing.T) {
 		t.Fatalf("userNotification admin status=%d", rr.Code)
 	}
 
-	req, rr = newRequest(http.MethodGet, "/user/notification/remove/"+strconv.Itoa(notificationID), nil)
+	req, rr = newRequest(http.MethodPost, "/user/notification/remove/"+strconv.Itoa(notificationID), nil)
 	attachSessionCookie(t, app, req, adminID)
 	app.userNotificationRemove(rr, req)
 	if rr.Code != http.StatusSeeOther {
@@ -52,7 +58,8 @@ func TestModerationAdminHandlers_Matrix(t *testing.T) {
 	if err := db.QueryRow(`SELECT id FROM forum_notifications ORDER BY id DESC LIMIT 1`).Scan(&notificationID); err != nil {
 		t.Fatalf("notification id 2: %v", err)
 	}
-	req, rr = newRequest(http.MethodGet, "/moderation/accept/"+strconv.Itoa(notificationID)+"/"+strconv.Itoa(userID), nil)
+	req, rr = newRequest(http.MethodPost, "/moderation/accept/"+strconv.Itoa(notificationID)+"/"+strconv.Itoa(userID), nil)
+	attachSessionCookie(t, app, req, adminID)
 	app.userModerationDone(rr, req)
 	if rr.Code != http.StatusSeeOther {
 		t.Fatalf("userModerationDone status=%d", rr.Code)
@@ -62,7 +69,8 @@ func TestModerationAdminHandlers_Matrix(t *testing.T) {
 	if err := db.QueryRow(`SELECT id FROM forum_notifications ORDER BY id DESC LIMIT 1`).Scan(&notificationID); err != nil {
 		t.Fatalf("notification id 3: %v", err)
 	}
-	req, rr = newRequest(http.MethodGet, "/moderation/denote/"+strconv.Itoa(userID)+"/"+strconv.Itoa(notificationID), nil)
+	req, rr = newRequest(http.MethodPost, "/moderation/denote/"+strconv.Itoa(userID)+"/"+strconv.Itoa(notificationID), nil)
+	attachSessionCookie(t, app, req, adminID)
 	app.moderDenoteHandler(rr, req)
 	if rr.Code != http.StatusSeeOther {
 		t.Fatalf("moderDenoteHandler status=%d", rr.Code)
@@ -72,9 +80,192 @@ func TestModerationAdminHandlers_Matrix(t *testing.T) {
 	if err := db.QueryRow(`SELECT id FROM forum_notifications ORDER BY id DESC LIMIT 1`).Scan(&notificationID); err != nil {
 		t.Fatalf("notification id 4: %v", err)
 	}
-	req, rr = newRequest(http.MethodGet, "/moderation/forum/"+strconv.Itoa(notificationID)+"/"+strconv.Itoa(forumID), nil)
+	req, rr = newRequest(http.MethodPost, "/moderation/forum/"+strconv.Itoa(notificationID)+"/"+strconv.Itoa(forumID), nil)
+	attachSessionCookie(t, app, req, adminID)
 	app.forumAcceptHandler(rr, req)
 	if rr.Code != http.StatusSeeOther {
 		t.Fatalf("forumAcceptHandler status=%d", rr.Code)
 	}
 }
+
+func TestChangeTagPost(t *testing.T) {
+	app, db := newWebTestApp(t)
+	adminID := seedWebUser(t, app, "tagadmin", "tagadmin@example.com", policy.Admin)
+	userID := seedWebUser(t, app, "taguser", "taguser@example.com", policy.User)
+	if _, err := app.forums.Insert(context.Background(), "post", "body", "golang, web", 1, adminID, ""); err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+
+	post := func(userID int, body string) *httptest.ResponseRecorder {
+		req, rr := newRequest(http.MethodPost, "/admin/tags/change", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, userID)
+		app.changeTagPost(rr, req)
+		return rr
+	}
+
+	req, rr := newRequest(http.MethodGet, "/admin/tags/change", nil)
+	app.changeTagPost(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d", rr.Code)
+	}
+
+	if rr := post(userID, "action=rename&from=golang&to=go"); rr.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d", rr.Code)
+	}
+	if rr := post(adminID, "action=drop&from=golang&to=go"); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad action status = %d", rr.Code)
+	}
+	if rr := post(adminID, "action=rename&from=golang&to=web"); rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate rename status = %d", rr.Code)
+	}
+	if rr := post(adminID, "action=merge&from=missing&to=web"); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing merge status = %d", rr.Code)
+	}
+	if rr := post(adminID, "action=rename&from=golang&to=go"); rr.Code != http.StatusSeeOther {
+		t.Fatalf("rename status = %d", rr.Code)
+	}
+	if rr := post(adminID, "action=merge&from=web&to=go"); rr.Code != http.StatusSeeOther {
+		t.Fatalf("merge status = %d", rr.Code)
+	}
+
+	var names string
+	if err := db.QueryRow(`SELECT GROUP_CONCAT(tags, ',') FROM forum_tags`).Scan(&names); err != nil {
+		t.Fatal(err)
+	}
+	if names != "go" {
+		t.Fatalf("tags after rename and merge = %q, want %q", names, "go")
+	}
+}
+
+func TestReportQueueHandlers(t *testing.T) {
+	app, db := newWebTestApp(t)
+	addBaseTemplate(app, "reports.tmpl.html")
+	addBaseTemplate(app, "audit.tmpl.html")
+
+	adminID := seedWebUser(t, app, "admin", "admin@example.com", 4)
+	moderID := seedWebUser(t, app, "moder", "moder@example.com", 3)
+	userID := seedWebUser(t, app, "user", "user@example.com", 2)
+	forumID, err := app.forums.Insert(context.Background(), "title", "body", "go", 7, userID, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE forums SET status = 1 WHERE id = ?`, forumID); err != nil {
+		t.Fatalf("publish forum: %v", err)
+	}
+	reportID, err := app.forumService.Report(forumID, moderID, []string{"obscene"}, "")
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	get := func(target string, userID int, h http.HandlerFunc) int {
+		req, rr := newRequest(http.MethodGet, target, nil)
+		attachSessionCookie(t, app, req, userID)
+		h(rr, req)
+		return rr.Code
+	}
+	post := func(action string, userID int, body string) int {
+		req, rr := newRequest(http.MethodPost, "/moderation/reports/"+strconv.Itoa(reportID)+"/"+action, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, userID)
+		app.reportAction(rr, req)
+		return rr.Code
+	}
+
+	if code := get("/moderation/reports", userID, app.reportQueue); code != http.StatusForbidden {
+		t.Fatalf("user queue status = %d", code)
+	}
+	if code := get("/moderation/reports?state=stale", moderID, app.reportQueue); code != http.StatusBadRequest {
+		t.Fatalf("bad state status = %d", code)
+	}
+	if code := get("/moderation/reports", moderID, app.reportQueue); code != http.StatusOK {
+		t.Fatalf("queue status = %d", code)
+	}
+
+	if code := post("escalate", adminID, ""); code != http.StatusNotFound {
+		t.Fatalf("unknown action status = %d", code)
+	}
+	if code := post("assign", userID, ""); code != http.StatusForbidden {
+		t.Fatalf("user assign status = %d", code)
+	}
+	if code := post("assign", adminID, "assignee="+strconv.Itoa(moderID)); code != http.StatusSeeOther {
+		t.Fatalf("assign status = %d", code)
+	}
+	if code := post("resolve", moderID, ""); code != http.StatusForbidden {
+		t.Fatalf("moderator resolve status = %d", code)
+	}
+	if code := post("dismiss", moderID, "note="+strings.Repeat("x", 501)); code != http.StatusUnprocessableEntity {
+		t.Fatalf("long note status = %d", code)
+	}
+	if code := post("dismiss", moderID, "note=fine"); code != http.StatusSeeOther {
+		t.Fatalf("dismiss status = %d", code)
+	}
+	if code := post("resolve", adminID, ""); code != http.StatusConflict {
+		t.Fatalf("resolve closed report status = %d", code)
+	}
+
+	if code := get("/admin/audit", moderID, app.auditLog); code != http.StatusForbidden {
+		t.Fatalf("moderator audit status = %d", code)
+	}
+	if code := get("/admin/audit?before=x", adminID, app.auditLog); code != http.StatusBadRequest {
+		t.Fatalf("bad cursor status = %d", code)
+	}
+	if code := get("/admin/audit?report="+strconv.Itoa(reportID), adminID, app.auditLog); code != http.StatusOK {
+		t.Fatalf("audit status = %d", code)
+	}
+}
+
+func TestAdminPermissionsHandler(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "permissions.tmpl.html")
+
+	adminID := seedWebUser(t, app, "admin", "admin@example.com", policy.Admin)
+	moderID := seedWebUser(t, app, "moder", "moder@example.com", policy.Moderator)
+
+	req, rr := newRequest(http.MethodGet, "/admin/permissions", nil)
+	attachSessionCookie(t, app, req, moderID)
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("moderator status = %d", rr.Code)
+	}
+
+	req, rr = newRequest(http.MethodGet, "/admin/permissions", nil)
+	attachSessionCookie(t, app, req, adminID)
+	app.adminPermissions(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET status = %d", rr.Code)
+	}
+
+	post := func(grants ...string) int {
+		form := url.Values{"grant": grants}
+		req, rr := newRequest(http.MethodPost, "/admin/permissions", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, adminID)
+		app.adminPermissions(rr, req)
+		return rr.Code
+	}
+
+	if code := post("4:role.assign", "3:post.frobnicate"); code != http.StatusBadRequest {
+		t.Fatalf("unknown permission status = %d", code)
+	}
+	// The rejected matrix is rendered again rather than saved.
+	if code := post("3:tag.manage"); code == http.StatusSeeOther {
+		t.Fatal("lockout form was saved")
+	}
+	if ok, _ := app.policy.Can(policy.Moderator, policy.TagManage); ok {
+		t.Fatal("rejected form changed grants")
+	}
+
+	if code := post("4:role.assign", "3:tag.manage", "3:tag.manage"); code != http.StatusSeeOther {
+		t.Fatalf("save status = %d", code)
+	}
+	if ok, _ := app.policy.Can(policy.Moderator, policy.TagManage); !ok {
+		t.Fatal("moderator did not get tag.manage")
+	}
+	if ok, _ := app.policy.Can(policy.Admin, policy.TagManage); ok {
+		t.Fatal("admin kept tag.manage")
+	}
+	if ok, _ := app.policy.Can(policy.User, policy.ModerationRequest); ok {
+		t.Fatal("user kept moderation.request")
+	}
+}
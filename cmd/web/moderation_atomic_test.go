@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+	"github.com/aspandyar/forum/internal/testutil"
+)
+
+func TestModerationDecisionsAreAtomic(t *testing.T) {
+	app, db := newWebTestApp(t)
+
+	adminID := seedWebUser(t, app, "atomadmin", "atomadmin@example.com", policy.Admin)
+	moderID := seedWebUser(t, app, "atommoder", "atommoder@example.com", policy.Moderator)
+	userID := seedWebUser(t, app, "atomuser", "atomuser@example.com", policy.User)
+	forumID, err := app.forums.Insert(context.Background(), "title", "body", "go", 7, userID, "")
+	if err != nil {
+		t.Fatalf("insert forum: %v", err)
+	}
+
+	queue := func(status string) int {
+		t.Helper()
+		res, err := db.Exec(`INSERT INTO forum_notifications(user_name, body, status, forum_link, user_id, user_not_id) VALUES ('u', 'b', ?, ?, ?, ?)`,
+			status, forumID, adminID, userID)
+		if err != nil {
+			t.Fatalf("seed notification: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		return int(id)
+	}
+	audit := func() int {
+		return testutil.Count(t, db, `SELECT COUNT(*) FROM moderation_actions`)
+	}
+	queued := func(id int) int {
+		return testutil.Count(t, db, `SELECT COUNT(*) FROM forum_notifications WHERE id = ?`, id)
+	}
+
+	t.Run("promote", func(t *testing.T) {
+		notID := queue("admin")
+		before := audit()
+		restore := testutil.FailInserts(t, db, "moderation_actions")
+		defer restore()
+
+		req, rr := newRequest(http.MethodPost, "/moderation/accept/"+strconv.Itoa(notID)+"/"+strconv.Itoa(userID), nil)
+		attachSessionCookie(t, app, req, adminID)
+		app.userModerationDone(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", rr.Code)
+		}
+		if role, _ := app.forums.GetRoleByUserID(userID); role != models.UserRole {
+			t.Fatalf("role = %d, want %d", role, models.UserRole)
+		}
+		if queued(notID) != 1 || audit() != before {
+			t.Fatal("failed promotion left partial changes")
+		}
+	})
+
+	t.Run("approve", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE forums SET status = ? WHERE id = ?`, models.PostPending, forumID); err != nil {
+			t.Fatal(err)
+		}
+		notID := queue("moder")
+		before := audit()
+		restore := testutil.FailInserts(t, db, "moderation_actions")
+		defer restore()
+
+		req, rr := newRequest(http.MethodPost, "/moderation/forum/"+strconv.Itoa(notID)+"/"+strconv.Itoa(forumID), nil)
+		attachSessionCookie(t, app, req, adminID)
+		app.forumAcceptHandler(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", rr.Code)
+		}
+		if st, _ := app.forums.Status(forumID); st.State != models.PostPending {
+			t.Fatalf("state = %d, want pending", st.State)
+		}
+		if queued(notID) != 1 || audit() != before {
+			t.Fatal("failed approval left partial changes")
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE forums SET status = ? WHERE id = ?`, models.PostPublished, forumID); err != nil {
+			t.Fatal(err)
+		}
+		reports := &models.ReportModel{DB: db}
+		reportID, err := reports.File(forumID, moderID, []string{"obscene"}, "")
+		if err != nil {
+			t.Fatalf("File: %v", err)
+		}
+		before := audit()
+		// Telling the reporter is the last step.
+		restore := testutil.FailInserts(t, db, "forum_notifications")
+		defer restore()
+
+		req, rr := newRequest(http.MethodPost, "/moderation/reports/"+strconv.Itoa(reportID)+"/resolve", nil)
+		attachSessionCookie(t, app, req, adminID)
+		app.reportAction(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", rr.Code)
+		}
+		if r, _ := reports.Get(reportID); r.State != models.ReportOpen {
+			t.Fatalf("report state = %s, want open", r.State)
+		}
+		if st, _ := app.forums.Status(forumID); st.State != models.PostPublished {
+			t.Fatalf("post state = %d, want published", st.State)
+		}
+		if audit() != before {
+			t.Fatal("failed resolution left an audit entry")
+		}
+	})
+}
+
+func TestCreatePostIsAtomic(t *testing.T) {
+	app, db := newWebTestApp(t)
+	userID := seedWebUser(t, app, "atomauthor", "atomauthor@example.com", policy.User)
+
+	// Queueing the post for review is the last step.
+	restore := testutil.FailInserts(t, db, "forum_notifications")
+	defer restore()
+
+	fields := map[string]string{"title": "Half made", "content": "body", "expires": "7", "custom_tags": "go"}
+	body, ct := uploadBody(t, fields, "", nil)
+	req, rr := newRequest(http.MethodPost, "/forum/create", body)
+	req.Header.Set("Content-Type", ct)
+	attachSessionCookie(t, app, req, userID)
+	app.handleForumCreate(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rr.Code)
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM forums WHERE title = ?`, "Half made"); n != 0 {
+		t.Fatalf("failed create left %d posts", n)
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM forum_post_tags`); n != 0 {
+		t.Fatalf("failed create left %d tags", n)
+	}
+}
@@ -3,13 +3,19 @@ package main
 import "net/http"
 
 func (app *application) moderAskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	cookie, err := r.Cookie("session")
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	userID, _, err := app.sessions.GetSession(cookie.Value)
+	userID, _, err := app.sessions.GetSession(r.Context(), cookie.Value)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/oauth"
+)
+
+var oauthNameRX = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// newOAuthRegistry registers the providers configured in the environment.
+// Google and GitHub are enabled by GOOGLE_CLIENT_ID and GITHUB_CLIENT_ID;
+// any other provider is listed in OAUTH_PROVIDERS and described by
+// OAUTH_<NAME>_* variables. Callbacks default to
+// <baseURL>/login/<name>/callback, except Google's, which keeps /callback
+// (or OAUTH_REDIRECT_URI).
+func newOAuthRegistry(getenv func(string) string, baseURL string) (*oauth.Registry, error) {
+	reg := oauth.NewRegistry()
+	callback := func(name string) string { return baseURL + "/login/" + name + "/callback" }
+
+	if id := getenv("GOOGLE_CLIENT_ID"); id != "" {
+		redirect := getenv("OAUTH_REDIRECT_URI")
+		if redirect == "" {
+			redirect = baseURL + "/callback"
+		}
+		reg.Register(oauth.Google(oauth.Config{
+			ClientID:     id,
+			ClientSecret: getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  redirect,
+		}))
+	}
+	if id := getenv("GITHUB_CLIENT_ID"); id != "" {
+		reg.Register(oauth.GitHub(oauth.Config{
+			ClientID:     id,
+			ClientSecret: getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  callback("github"),
+		}))
+	}
+
+	for _, name := range strings.Split(getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !oauthNameRX.MatchString(name) {
+			return nil, fmt.Errorf("OAUTH_PROVIDERS: invalid provider name %q", name)
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		env := func(key string) string { return getenv(prefix + key) }
+		cfg := oauth.Config{
+			Name:         name,
+			Label:        env("LABEL"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  env("REDIRECT_URL"),
+			Scopes:       strings.Fields(env("SCOPES")),
+			Issuer:       env("ISSUER"),
+			AuthURL:      env("AUTH_URL"),
+			TokenURL:     env("TOKEN_URL"),
+			UserInfoURL:  env("USERINFO_URL"),
+		}
+		if cfg.ClientID == "" {
+			return nil, fmt.Errorf("%sCLIENT_ID is not set", prefix)
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = callback(name)
+		}
+		if v := env("TRUST_EMAIL"); v != "" {
+			trust, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%sTRUST_EMAIL: %v", prefix, err)
+			}
+			cfg.TrustEmail = trust
+		}
+
+		var (
+			p   oauth.Provider
+			err error
+		)
+		switch name {
+		case "google":
+			p = oauth.Google(cfg)
+		case "github":
+			p = oauth.GitHub(cfg)
+		default:
+			p, err = oauth.New(cfg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		reg.Register(p)
+	}
+	return reg, nil
+}
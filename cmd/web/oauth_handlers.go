@@ -1,189 +1,204 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
-	"encoding/json"
+	"crypto/subtle"
 	"errors"
-	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/aspandyar/forum/internal/models"
-	githuboauth "github.com/aspandyar/forum/internal/oauth/github"
-	googleoauth "github.com/aspandyar/forum/internal/oauth/google"
+	"github.com/aspandyar/forum/internal/oauth"
+	authsvc "github.com/aspandyar/forum/internal/service/auth"
 	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
-	"github.com/aspandyar/forum/internal/validator"
 )
 
-func (app *application) handleGoogleAuth(w http.ResponseWriter, r *http.Request) {
-	authURL := fmt.Sprintf("https://accounts.google.com/o/oauth2/auth?client_id=%s&redirect_uri=%s&response_type=code&scope=email profile", clientID, redirectURI)
-	http.Redirect(w, r, authURL, http.StatusFound)
-}
+// oauthStateCookie carries a flow from its start to the provider's
+// callback: "<mode>.<provider>.<state>.<PKCE verifier>.<nonce>", where mode
+// is "login" or "link".
+const oauthStateCookie = "oauth_state"
 
-func (app *application) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	info, err := googleoauth.FetchUserInfo(clientID, clientSecret, redirectURI, code)
-	if err != nil {
-		http.Error(w, "Failed to complete Google auth", http.StatusInternalServerError)
-		return
+// oauthProviders lists the configured providers for templates.
+func (app *application) oauthProviders() []oauthProvider {
+	var list []oauthProvider
+	for _, p := range app.oauth.All() {
+		list = append(list, oauthProvider{Name: p.Name(), Label: p.Label(), Start: "/login/" + p.Name() + "/"})
 	}
+	return list
+}
 
-	form := userSingupForm{Name: info.Name, Email: info.Email}
-
-	form.Role = userRole
-	form.Password, _ = generateRandomPassword(8)
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+func (app *application) oauthProviderLabel(name string) string {
+	if p, ok := app.oauth.Get(name); ok {
+		return p.Label()
+	}
+	return name
+}
 
-	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+// oauthLogin serves /login/{provider}/, which starts a flow, and
+// /login/{provider}/callback, where the provider sends the browser back.
+func (app *application) oauthLogin(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 || parts[1] != "login" || parts[2] == "" {
+		app.notFound(w)
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password, form.Role)
-	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			userID, _ := app.users.Authenticate(form.Email, form.Password)
-			session, err := app.sessions.CreateSession(userID)
-			if err != nil {
-				app.serverError(w, err)
-				return
-			}
-			sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
-			http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
-		} else {
-			app.serverError(w, err)
-		}
+	switch parts[3] {
+	case "":
+		app.beginOAuth(w, r, parts[2])
+	case "callback":
+		app.finishOAuth(w, r, parts[2])
+	default:
+		app.notFound(w)
+	}
+}
+
+// beginOAuth sends the browser to provider with a fresh state, nonce and
+// PKCE verifier, kept in a cookie until the callback. A linking flow
+// (?link=1) needs a signed-in user and redirects to the login page
+// otherwise.
+func (app *application) beginOAuth(w http.ResponseWriter, r *http.Request, provider string) {
+	p, ok := app.oauth.Get(provider)
+	if !ok {
+		app.notFound(w)
 		return
 	}
 
-	userID, err := app.users.Authenticate(form.Email, form.Password)
-	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
-		} else {
-			app.serverError(w, err)
+	mode := "login"
+	if r.URL.Query().Get("link") == "1" {
+		if _, err := app.currentUserID(r); err != nil {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
 		}
-		return
+		mode = "link"
 	}
 
-	session, err := app.sessions.CreateSession(userID)
-	if err != nil {
-		app.serverError(w, err)
+	state, verifier, nonce := oauth.RandomString(), oauth.RandomString(), oauth.RandomString()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    strings.Join([]string{mode, p.Name(), state, verifier, nonce}, "."),
+		Path:     "/",
+		MaxAge:   600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, p.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// finishOAuth checks a callback against the state cookie and clears it, so
+// a callback cannot be replayed, forged from another browser or swapped
+// between providers, then signs in as or links the user the provider
+// confirmed.
+func (app *application) finishOAuth(w http.ResponseWriter, r *http.Request, provider string) {
+	p, ok := app.oauth.Get(provider)
+	if !ok {
+		app.notFound(w)
 		return
 	}
-	sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
-	http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
-}
 
-func generateRandomPassword(length int) (string, error) {
-	bytes := make([]byte, length)
-	_, err := rand.Read(bytes)
+	cookie, err := r.Cookie(oauthStateCookie)
 	if err != nil {
-		return "", err
+		app.clientError(w, http.StatusBadRequest)
+		return
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
-}
-
-func (app *application) loggedinHandler(w http.ResponseWriter, r *http.Request, githubData string) {
-	if githubData == "" {
-		app.serverError(w, errors.New("github.com: unauthorized"))
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	fields := strings.Split(cookie.Value, ".")
+	query := r.URL.Query()
+	if len(fields) != 5 || fields[1] != p.Name() || fields[2] == "" ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(fields[2])) != 1 {
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
+	link, verifier, nonce := fields[0] == "link", fields[3], fields[4]
 
-	w.Header().Set("Content-type", "application/json")
-	form := userSingupForm{}
-	json.Unmarshal([]byte(githubData), &form)
-
-	form.Role = userRole
-	form.Password, _ = generateRandomPassword(8)
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-
-	if !form.Valid() {
+	if query.Get("error") != "" {
+		var form userLoginForm
+		form.AddNonFieldError("Signing in with " + p.Label() + " was cancelled or refused.")
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
-	err := app.users.Insert(form.Name, form.Email, form.Password, 2)
+	user, err := p.Authenticate(r.Context(), query.Get("code"), verifier, nonce)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			userID, err := app.users.Authenticate(form.Email, form.Password)
-			if err != nil {
-				if errors.Is(err, models.ErrInvalidCredentials) {
-					http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-				} else {
-					app.serverError(w, err)
-				}
-				return
-			}
-
-			session, err := app.sessions.CreateSession(userID)
-			if err != nil {
-				app.serverError(w, err)
-				return
-			}
-
-			sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
-			http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
-			return
-		}
 		app.serverError(w, err)
 		return
 	}
 
-	userID, err := app.users.Authenticate(form.Email, form.Password)
+	app.completeOAuth(w, r, link, authsvc.ExternalUser{
+		Provider: p.Name(),
+		Subject:  user.Subject,
+		Name:     user.Name,
+		Email:    user.Email,
+	})
+}
+
+// currentUserID returns the user of an unexpired session cookie.
+func (app *application) currentUserID(r *http.Request) (int, error) {
+	cookie, err := r.Cookie("session")
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		return 0, err
+	}
+	return app.authService.UserID(r.Context(), cookie.Value)
+}
+
+// completeOAuth signs in as, or links, the login the provider confirmed.
+func (app *application) completeOAuth(w http.ResponseWriter, r *http.Request, link bool, ext authsvc.ExternalUser) {
+	if ext.Subject == "" {
+		app.serverError(w, errors.New(ext.Provider+": no user id in profile"))
+		return
+	}
+
+	if link {
+		userID, err := app.currentUserID(r)
+		if err != nil {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-		} else {
+			return
+		}
+
+		err = app.authService.LinkIdentity(userID, ext)
+		switch {
+		case err == nil:
+			http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+		case errors.Is(err, models.ErrIdentityTaken):
+			app.renderSettings(w, r, userID, "That "+app.oauthProviderLabel(ext.Provider)+" account is already linked to another forum account.")
+		case errors.Is(err, models.ErrProviderLinked):
+			app.renderSettings(w, r, userID, "Unlink your current "+app.oauthProviderLabel(ext.Provider)+" account first.")
+		default:
 			app.serverError(w, err)
 		}
 		return
 	}
 
-	session, err := app.sessions.CreateSession(userID)
+	session, err := app.authService.OAuthLogin(r.Context(), ext, sessionDevice(r))
 	if err != nil {
-		app.serverError(w, err)
+		var form userLoginForm
+		switch {
+		case errors.Is(err, authsvc.ErrEmailInUse):
+			form.AddNonFieldError("An account already uses this email address. Sign in with your password, then link " +
+				app.oauthProviderLabel(ext.Provider) + " under Settings.")
+		case errors.Is(err, authsvc.ErrNoEmail):
+			form.AddNonFieldError(app.oauthProviderLabel(ext.Provider) + " did not share a verified email address, so we cannot create an account.")
+		default:
+			app.serverError(w, err)
+			return
+		}
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
 	sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
 	http.Redirect(w, r, "/forum/create", http.StatusSeeOther)
 }
-
-func (app *application) gitHubLoginHandler(w http.ResponseWriter, r *http.Request) {
-	redirectURL := fmt.Sprintf(
-		"https://github.com/login/oauth/authorize?client_id=%s&redirect_uri=%s",
-		clientGitID,
-		"https://localhost:4000/login/github/callback",
-	)
-
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
-}
-
-func (app *application) gitHubCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	gitHubAccessToken := app.getGitHubAccessToken(code)
-	gitHubData := getGitHubData(gitHubAccessToken)
-	app.loggedinHandler(w, r, gitHubData)
-}
-
-func (app *application) getGitHubAccessToken(code string) string {
-	token, err := githuboauth.AccessToken(clientGitID, clientGitSecret, code)
-	if err != nil {
-		panic(err)
-	}
-	return token
-}
-
-func getGitHubData(accessToken string) string {
-	data, _ := githuboauth.UserData(accessToken)
-	return data
-}
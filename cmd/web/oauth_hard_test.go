@@ -1,35 +1,334 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/aspandyar/forum/internal/oauth"
+	"github.com/aspandyar/forum/internal/policy"
 )
 
-type failingRoundTripper struct{}
+// fakeProvider stands in for an identity provider. It signs in as user,
+// after checking the verifier against the challenge of the last flow it
+// started.
+type fakeProvider struct {
+	name      string
+	user      oauth.User
+	err       error
+	challenge string
+	nonce     string
+}
+
+func (p *fakeProvider) Name() string  { return p.name }
+func (p *fakeProvider) Label() string { return strings.ToUpper(p.name[:1]) + p.name[1:] }
+
+func (p *fakeProvider) AuthCodeURL(state, nonce, verifier string) string {
+	p.challenge = oauth.Challenge(verifier)
+	return "https://idp.test/authorize?" + url.Values{
+		"state":          {state},
+		"nonce":          {nonce},
+		"code_challenge": {p.challenge},
+	}.Encode()
+}
+
+func (p *fakeProvider) Authenticate(ctx context.Context, code, verifier, nonce string) (oauth.User, error) {
+	if p.challenge != "" && oauth.Challenge(verifier) != p.challenge {
+		return oauth.User{}, oauth.ErrExchange
+	}
+	p.nonce = nonce
+	return p.user, p.err
+}
+
+// withFakeProviders registers stand-ins for google and github.
+func withFakeProviders(app *application) (google, github *fakeProvider) {
+	google, github = &fakeProvider{name: "google"}, &fakeProvider{name: "github"}
+	app.oauth = oauth.NewRegistry(google, github)
+	return google, github
+}
+
+// stateCookie returns the oauth_state cookie set by res.
+func stateCookie(res *http.Response) *http.Cookie {
+	for _, c := range res.Cookies() {
+		if c.Name == oauthStateCookie {
+			return c
+		}
+	}
+	return nil
+}
 
-func (f failingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
-	return nil, errors.New("forced transport error")
+// withOAuthState adds the state cookie of a flow with provider and echoes
+// state in the callback query.
+func withOAuthState(req *http.Request, mode, provider string) {
+	req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: mode + "." + provider + ".state123.verifier.nonce"})
+	q := req.URL.Query()
+	q.Set("state", "state123")
+	req.URL.RawQuery = q.Encode()
 }
 
 func TestOAuthCallbacks_ErrorBranches(t *testing.T) {
-	oldTransport := http.DefaultTransport
-	http.DefaultTransport = failingRoundTripper{}
-	t.Cleanup(func() { http.DefaultTransport = oldTransport })
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "login.tmpl.html")
+	google, github := withFakeProviders(app)
+	google.err = oauth.ErrExchange
+	github.err = oauth.ErrInvalidToken
+	h := app.routes()
 
+	for _, target := range []string{"/callback?code=abc", "/login/github/callback?code=abc"} {
+		provider := "google"
+		if strings.HasPrefix(target, "/login/github/") {
+			provider = "github"
+		}
+		req, rr := newRequest(http.MethodGet, target, nil)
+		withOAuthState(req, "login", provider)
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("%s forced error status=%d", target, rr.Code)
+		}
+	}
+
+	// The user said no at the provider.
+	req, rr := newRequest(http.MethodGet, "/login/github/callback?error=access_denied", nil)
+	withOAuthState(req, "login", "github")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity || strings.Contains(rr.Header().Get("Set-Cookie"), "session=") {
+		t.Fatalf("denied callback status=%d", rr.Code)
+	}
+
+	for _, target := range []string{"/login/gitlab/", "/login/gitlab/callback", "/login/github/other"} {
+		req, rr := newRequest(http.MethodGet, target, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s status=%d", target, rr.Code)
+		}
+	}
+}
+
+func TestOAuthStateMustMatch(t *testing.T) {
 	app, _ := newWebTestApp(t)
+	_, github := withFakeProviders(app)
+	github.user = oauth.User{Subject: "42", Name: "octo", Email: "octo@example.com"}
+	h := app.routes()
 
-	req, rr := newRequest(http.MethodGet, "/callback?code=abc", nil)
-	app.handleGoogleCallback(rr, req)
-	if rr.Code != http.StatusInternalServerError {
-		t.Fatalf("handleGoogleCallback forced error status=%d", rr.Code)
+	req, rr := newRequest(http.MethodGet, "/auth", nil)
+	h.ServeHTTP(rr, req)
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+	cookie := stateCookie(rr.Result())
+	if rr.Code != http.StatusFound || state == "" || cookie == nil || !cookie.HttpOnly ||
+		!strings.HasPrefix(cookie.Value, "login.google."+state+".") {
+		t.Fatalf("state %q, cookie %+v", state, cookie)
 	}
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatal("expected panic in gitHubCallbackHandler when transport fails")
+	for name, target := range map[string]string{
+		"missing":        "/callback?code=abc",
+		"forged":         "/callback?code=abc&state=forged",
+		"other provider": "/login/github/callback?code=abc&state=" + state,
+	} {
+		req, rr := newRequest(http.MethodGet, target, nil)
+		req.AddCookie(cookie)
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s state status=%d", name, rr.Code)
 		}
-	}()
-	req, rr = newRequest(http.MethodGet, "/login/github/callback?code=abc", nil)
-	app.gitHubCallbackHandler(rr, req)
+	}
+
+	// The callback hands the provider the verifier and nonce of its start.
+	req, rr = newRequest(http.MethodGet, "/login/github/", nil)
+	h.ServeHTTP(rr, req)
+	location, _ = url.Parse(rr.Header().Get("Location"))
+	start := stateCookie(rr.Result())
+
+	req, rr = newRequest(http.MethodGet, "/login/github/callback?code=abc&state="+location.Query().Get("state"), nil)
+	req.AddCookie(start)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || github.nonce != location.Query().Get("nonce") {
+		t.Fatalf("PKCE callback status=%d nonce=%q", rr.Code, github.nonce)
+	}
+
+	// Linking needs someone to link to.
+	req, rr = newRequest(http.MethodGet, "/login/github/?link=1", nil)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Fatalf("anonymous link status=%d location=%q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestOAuthLoginByProviderSubject(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "login.tmpl.html")
+	_, github := withFakeProviders(app)
+	h := app.routes()
+
+	callback := func(cookies ...*http.Cookie) *http.Response {
+		req, rr := newRequest(http.MethodGet, "/login/github/callback?code=abc", nil)
+		withOAuthState(req, "login", "github")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		h.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+	sessionCookie := func(res *http.Response) *http.Cookie {
+		for _, c := range res.Cookies() {
+			if c.Name == "session" && c.Value != "" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	github.user = oauth.User{Subject: "42", Name: "octo", Email: "octo@example.com"}
+	res := callback()
+	if res.StatusCode != http.StatusSeeOther || sessionCookie(res) == nil {
+		t.Fatalf("first login status=%d cookies=%v", res.StatusCode, res.Cookies())
+	}
+	first, err := app.authService.UserID(context.Background(), sessionCookie(res).Value)
+	if err != nil {
+		t.Fatalf("session of first login: %v", err)
+	}
+
+	// A returning user signs in to the same account.
+	res = callback()
+	again, err := app.authService.UserID(context.Background(), sessionCookie(res).Value)
+	if err != nil || again != first {
+		t.Fatalf("returning login user = %d, %v; want %d", again, err, first)
+	}
+
+	// Someone else's GitHub account with a registered email gets no session.
+	seedWebUser(t, app, "alice", "alice@example.com", policy.User)
+	github.user = oauth.User{Subject: "7", Name: "mallory", Email: "alice@example.com"}
+	if res = callback(); sessionCookie(res) != nil {
+		t.Fatal("login with a registered email signed in")
+	}
+	var n int
+	if err := app.users.DB.QueryRow(`SELECT COUNT(*) FROM oauth_identities WHERE subject = '7'`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("identity linked by email alone: %d, %v", n, err)
+	}
+
+	// A taken name gets a suffix.
+	github.user = oauth.User{Subject: "8", Name: "octo", Email: "octo2@example.com"}
+	res = callback()
+	if sessionCookie(res) == nil {
+		t.Fatalf("login with a taken name status=%d", res.StatusCode)
+	}
+	if u, err := app.users.ByEmail("octo2@example.com"); err != nil || u.Name != "octo 2" {
+		t.Fatalf("suffixed user = %+v, %v", u, err)
+	}
+}
+
+func TestOAuthLinkAndUnlink(t *testing.T) {
+	app, _ := newWebTestApp(t)
+	addBaseTemplate(app, "settings.tmpl.html")
+	_, github := withFakeProviders(app)
+	h := app.routes()
+	userID := seedWebUser(t, app, "alice", "alice@example.com", policy.User)
+
+	do := func(method, target, mode string) *http.Response {
+		req, rr := newRequest(method, target, nil)
+		attachSessionCookie(t, app, req, userID)
+		attachCSRF(req)
+		if mode != "" {
+			withOAuthState(req, mode, "github")
+		}
+		h.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	if res := do(http.MethodGet, "/login/github/?link=1", ""); res.StatusCode != http.StatusFound || !strings.HasPrefix(stateCookie(res).Value, "link.github.") {
+		t.Fatalf("start link status=%d cookies=%v", res.StatusCode, res.Cookies())
+	}
+
+	// The callbacks below come with a canned state cookie.
+	github.challenge = ""
+	github.user = oauth.User{Subject: "42", Name: "alice-gh", Email: "alice@users.example"}
+	if res := do(http.MethodGet, "/login/github/callback?code=abc", "link"); res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/user/settings" {
+		t.Fatalf("link callback status=%d location=%q", res.StatusCode, res.Header.Get("Location"))
+	}
+	identities, err := app.authService.ListIdentities(userID)
+	if err != nil || len(identities) != 1 || identities[0].Subject != "42" {
+		t.Fatalf("identities = %+v, %v", identities, err)
+	}
+
+	// The linked login now signs in as alice.
+	req, rr := newRequest(http.MethodGet, "/login/github/callback?code=abc", nil)
+	withOAuthState(req, "login", "github")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || len(rr.Result().Cookies()) < 2 {
+		t.Fatalf("login through linked account status=%d", rr.Code)
+	}
+
+	if res := do(http.MethodGet, "/user/settings", ""); res.StatusCode != http.StatusOK {
+		t.Fatalf("settings status=%d", res.StatusCode)
+	}
+	if res := do(http.MethodGet, "/user/settings/unlink/github", ""); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET unlink status=%d", res.StatusCode)
+	}
+	if res := do(http.MethodPost, "/user/settings/unlink/google", ""); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("unlink unlinked provider status=%d", res.StatusCode)
+	}
+	if res := do(http.MethodPost, "/user/settings/unlink/github", ""); res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("unlink status=%d", res.StatusCode)
+	}
+	if identities, _ := app.authService.ListIdentities(userID); len(identities) != 0 {
+		t.Fatalf("identities after unlink = %+v", identities)
+	}
+}
+
+func TestNewOAuthRegistry(t *testing.T) {
+	env := map[string]string{
+		"GOOGLE_CLIENT_ID":          "g-id",
+		"GITHUB_CLIENT_ID":          "gh-id",
+		"OAUTH_PROVIDERS":           "keycloak, GitLab",
+		"OAUTH_KEYCLOAK_CLIENT_ID":  "kc-id",
+		"OAUTH_KEYCLOAK_ISSUER":     "https://sso.example.com/realms/forum",
+		"OAUTH_KEYCLOAK_LABEL":      "Company SSO",
+		"OAUTH_GITLAB_CLIENT_ID":    "gl-id",
+		"OAUTH_GITLAB_AUTH_URL":     "https://gitlab.example.com/oauth/authorize",
+		"OAUTH_GITLAB_TOKEN_URL":    "https://gitlab.example.com/oauth/token",
+		"OAUTH_GITLAB_USERINFO_URL": "https://gitlab.example.com/oauth/userinfo",
+	}
+	reg, err := newOAuthRegistry(func(k string) string { return env[k] }, "https://forum.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range reg.All() {
+		names = append(names, p.Name()+"="+p.Label())
+	}
+	if got := strings.Join(names, ","); got != "google=Google,github=GitHub,keycloak=Company SSO,gitlab=gitlab" {
+		t.Fatalf("providers %s", got)
+	}
+
+	gitlab, _ := reg.Get("gitlab")
+	authURL, _ := url.Parse(gitlab.AuthCodeURL("s", "", "v"))
+	if authURL.Host != "gitlab.example.com" || authURL.Query().Get("redirect_uri") != "https://forum.test/login/gitlab/callback" {
+		t.Fatalf("gitlab auth URL %s", authURL)
+	}
+	github, _ := reg.Get("github")
+	authURL, _ = url.Parse(github.AuthCodeURL("s", "", "v"))
+	if authURL.Query().Get("redirect_uri") != "https://forum.test/login/github/callback" {
+		t.Fatalf("github auth URL %s", authURL)
+	}
+
+	for name, bad := range map[string]map[string]string{
+		"bad name":     {"OAUTH_PROVIDERS": "my_idp"},
+		"no client id": {"OAUTH_PROVIDERS": "gitlab"},
+		"no endpoints": {"OAUTH_PROVIDERS": "gitlab", "OAUTH_GITLAB_CLIENT_ID": "gl-id"},
+	} {
+		if _, err := newOAuthRegistry(func(k string) string { return bad[k] }, "https://forum.test"); err == nil {
+			t.Fatalf("%s: accepted", name)
+		}
+	}
+
+	reg, err = newOAuthRegistry(func(string) string { return "" }, "https://forum.test")
+	if err != nil || len(reg.All()) != 0 {
+		t.Fatalf("empty environment registered %v, %v", reg.All(), err)
+	}
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/policy"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type permissionRole struct {
+	ID   int
+	Name string
+}
+
+// permissionCell is one checkbox of the matrix; Value is "role:permission".
+type permissionCell struct {
+	Value   string
+	Granted bool
+}
+
+type permissionRow struct {
+	Name        policy.Permission
+	Description string
+	Cells       []permissionCell
+}
+
+type permissionsForm struct {
+	Roles []permissionRole
+	Rows  []permissionRow
+	validator.Validator
+}
+
+func newPermissionsForm(grants map[int][]policy.Permission) permissionsForm {
+	var form permissionsForm
+	for _, role := range policy.Roles {
+		form.Roles = append(form.Roles, permissionRole{ID: role, Name: policy.RoleName(role)})
+	}
+	for _, perm := range policy.Permissions {
+		row := permissionRow{Name: perm.Name, Description: perm.Description}
+		for _, role := range policy.Roles {
+			row.Cells = append(row.Cells, permissionCell{
+				Value:   strconv.Itoa(role) + ":" + string(perm.Name),
+				Granted: slices.Contains(grants[role], perm.Name),
+			})
+		}
+		form.Rows = append(form.Rows, row)
+	}
+	return form
+}
+
+// adminPermissions shows the role-permission matrix and saves it. Each
+// checked box is posted as grant=role:permission.
+func (app *application) adminPermissions(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/permissions" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	grants, err := app.forumService.Permissions(userID)
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		data := app.newTemplateData(r)
+		data.Form = newPermissionsForm(grants)
+		app.render(w, http.StatusOK, "permissions.tmpl.html", data)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	posted := make(map[int][]policy.Permission, len(policy.Roles))
+	for _, grant := range r.PostForm["grant"] {
+		roleStr, perm, _ := strings.Cut(grant, ":")
+		role, err := strconv.Atoi(roleStr)
+		if err != nil || policy.RoleName(role) == "unknown" || !policy.Valid(policy.Permission(perm)) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		posted[role] = append(posted[role], policy.Permission(perm))
+	}
+
+	// Check before saving anything, so a rejected matrix leaves every role
+	// as it was.
+	if !slices.Contains(posted[policy.Admin], policy.RoleAssign) {
+		form := newPermissionsForm(posted)
+		form.AddNonFieldError("Admins must keep role.assign, or nobody could change permissions again.")
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "permissions.tmpl.html", data)
+		return
+	}
+
+	for _, role := range policy.Roles {
+		slices.Sort(posted[role])
+		posted[role] = slices.Compact(posted[role])
+		if slices.Equal(posted[role], grants[role]) {
+			continue
+		}
+		if err := app.forumService.SetPermissions(r.Context(), role, posted[role], userID); err != nil {
+			app.moderationError(w, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/admin/permissions", http.StatusSeeOther)
+}
@@ -14,14 +14,14 @@ func TestAuthPostValidationBranches(t *testing.T) {
 	req, rr := newRequest(http.MethodPost, "/user/signup", strings.NewReader("name=&email=bad&password=1"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	app.userSignupPost(rr, req)
-	if rr.Code != http.StatusOK {
+	if rr.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("userSignupPost validation status=%d", rr.Code)
 	}
 
 	req, rr = newRequest(http.MethodPost, "/user/login", strings.NewReader("email=bad&password="))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	app.userLoginPost(rr, req)
-	if rr.Code != http.StatusOK {
+	if rr.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("userLoginPost validation status=%d", rr.Code)
 	}
 }
@@ -33,7 +33,7 @@ func TestModerationPostValidationBranches(t *testing.T) {
 	req, rr := newRequest(http.MethodPost, "/moderation/report/1", strings.NewReader("reportType="+strings.Repeat("x", 300)))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	app.ForumReportPost(rr, req)
-	if rr.Code != http.StatusOK {
+	if rr.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("ForumReportPost validation status=%d", rr.Code)
 	}
 
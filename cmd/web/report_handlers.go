@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+	"github.com/aspandyar/forum/internal/validator"
+)
+
+type reportQueueForm struct {
+	Reports []*models.Report
+	State   string
+	States  []string
+}
+
+type auditLogForm struct {
+	Actions  []*models.ModerationAction
+	ReportID int
+	// Next is the id to page back from, 0 on the last page.
+	Next int
+}
+
+// moderationError answers a failed moderation request.
+func (app *application) moderationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, forumsvc.ErrForbidden):
+		app.clientError(w, http.StatusForbidden)
+	case errors.Is(err, forumsvc.ErrNotFound), errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrInvalidState),
+		errors.Is(err, models.ErrDefaultExpiry):
+		app.clientError(w, http.StatusConflict)
+	case errors.Is(err, models.ErrUnknownExpiry):
+		app.clientError(w, http.StatusUnprocessableEntity)
+	default:
+		app.serverError(w, err)
+	}
+}
+
+// reportQueue lists reports for staff: the open queue by default, or every
+// report in one state with ?state=.
+func (app *application) reportQueue(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/moderation/reports" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	form := reportQueueForm{State: r.URL.Query().Get("state"), States: models.ReportStates}
+	if form.State != "" && !validator.PermittedValue(form.State, models.ReportStates...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form.Reports, err = app.forumService.ReportQueue(form.State, userID)
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "reports.tmpl.html", data)
+}
+
+// reportAction handles POST /moderation/reports/{id}/{assign|resolve|dismiss}.
+// assign takes an optional assignee (default: the caller); resolve and
+// dismiss take an optional note.
+func (app *application) reportAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[1] != "moderation" || parts[2] != "reports" {
+		app.notFound(w)
+		return
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	action := parts[4]
+	if action != "assign" && action != "resolve" && action != "dismiss" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	note := strings.TrimSpace(r.PostForm.Get("note"))
+	if !validator.MaxChars(note, 500) {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	switch action {
+	case "assign":
+		assigneeID := userID
+		if v := r.PostForm.Get("assignee"); v != "" {
+			assigneeID, err = strconv.Atoi(v)
+			if err != nil || assigneeID < 1 {
+				app.clientError(w, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		err = app.forumService.AssignReport(id, assigneeID, userID)
+	case "resolve":
+		err = app.forumService.ResolveReport(r.Context(), id, userID, note)
+	case "dismiss":
+		err = app.forumService.DismissReport(r.Context(), id, userID, note)
+	}
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/reports", http.StatusSeeOther)
+}
+
+// auditLog shows the moderation audit log to admins, newest first, one page
+// at a time. ?report= narrows it to one report.
+func (app *application) auditLog(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/audit" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter models.AuditFilter
+	for key, dst := range map[string]*int{"report": &filter.ReportID, "before": &filter.Before} {
+		if v := r.URL.Query().Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				app.clientError(w, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	actions, err := app.forumService.AuditLog(filter, userID)
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	form := auditLogForm{Actions: actions, ReportID: filter.ReportID}
+	if len(actions) == models.AuditPageSize {
+		form.Next = actions[len(actions)-1].ID
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "audit.tmpl.html", data)
+}
@@ -19,7 +19,7 @@ func (app *application) sessionUserID(r *http.Request) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	userID, _, err := app.sessions.GetSession(cookie.Value)
+	userID, _, err := app.sessions.GetSession(r.Context(), cookie.Value)
 	if err != nil {
 		return 0, err
 	}
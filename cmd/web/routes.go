@@ -1,6 +1,10 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/aspandyar/forum/internal/policy"
+)
 
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
@@ -17,43 +21,55 @@ func (app *application) routes() http.Handler {
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
+	// The disk backend serves uploads itself; other backends hand out URLs
+	// of their own.
+	if h, ok := app.images.Blobs.(http.Handler); ok {
+		mux.Handle(mediaURLPrefix+"/", http.StripPrefix(mediaURLPrefix, h))
+	}
+
+	mux.Handle("/api/v1/", app.apiRoutes())
 
 	mux.HandleFunc("/", app.home)
 	mux.HandleFunc("/showAll", app.allForum)
+	mux.HandleFunc("/search", app.forumSearch)
 
 	mux.HandleFunc("/forum/view/", app.forumView)
+	mux.HandleFunc("/forum/events/", app.forumEvents)
 
 	mux.HandleFunc("/forum/category", app.forumCategory)
 
 	mux.HandleFunc("/user/signup", app.userSignup)
-	mux.HandleFunc("/auth", app.handleGoogleAuth)
-	mux.HandleFunc("/callback", app.handleGoogleCallback)
-
-	mux.HandleFunc("/login/github/", app.gitHubLoginHandler)
-	mux.HandleFunc("/loggedin", func(w http.ResponseWriter, r *http.Request) {
-		app.loggedinHandler(w, r, "")
+	mux.HandleFunc("/login/", app.oauthLogin)
+	// Google's original start and callback paths.
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		app.beginOAuth(w, r, "google")
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		app.finishOAuth(w, r, "google")
 	})
-	mux.HandleFunc("/login/github/callback", app.gitHubCallbackHandler)
 
 	mux.HandleFunc("/user/login", app.userLogin)
+	mux.HandleFunc("/user/password/forgot", app.userPasswordForgot)
+	mux.HandleFunc("/user/password/reset", app.userPasswordReset)
+	mux.HandleFunc("/user/verify", app.userVerify)
 
 	userLogout := http.HandlerFunc(app.userLogoutPost)
 	mux.Handle("/user/logout", app.requireAuthentication(userLogout))
 
 	moderAsk := http.HandlerFunc(app.moderAskHandler)
-	mux.Handle("/moderation/ask", app.requireAuthentication(moderAsk))
+	mux.Handle("/moderation/ask", app.requireAuthentication(app.requirePermission(policy.ModerationRequest, moderAsk)))
 
 	moderAccept := http.HandlerFunc(app.userModerationDone)
-	mux.Handle("/moderation/accept/", app.requireAuthentication(moderAccept))
+	mux.Handle("/moderation/accept/", app.requireAuthentication(app.requirePermission(policy.RoleAssign, moderAccept)))
 
 	forumAccept := http.HandlerFunc(app.forumAcceptHandler)
-	mux.Handle("/moderation/forum/", app.requireAuthentication(forumAccept))
+	mux.Handle("/moderation/forum/", app.requireAuthentication(app.requirePermission(policy.PostApprove, forumAccept)))
 
 	forumReport := http.HandlerFunc(app.forumReportHandler)
-	mux.Handle("/moderation/report/", app.requireAuthentication(forumReport))
+	mux.Handle("/moderation/report/", app.requireAuthentication(app.requirePermission(policy.ReportFile, forumReport)))
 
 	moderDenote := http.HandlerFunc(app.moderDenoteHandler)
-	mux.Handle("/moderation/denote/", app.requireAuthentication(moderDenote))
+	mux.Handle("/moderation/denote/", app.requireAuthentication(app.requirePermission(policy.RoleAssign, moderDenote)))
 
 	forumCreate := http.HandlerFunc(app.handleForumCreate)
 	mux.Handle("/forum/create", app.requireAuthentication(forumCreate))
@@ -61,15 +77,31 @@ func (app *application) routes() http.Handler {
 	forumEdit := http.HandlerFunc(app.handleForumEdit)
 	mux.Handle("/forum/edit/", app.requireAuthentication(forumEdit))
 
+	forumPreview := http.HandlerFunc(app.forumPreview)
+	mux.Handle("/forum/preview", app.requireAuthentication(forumPreview))
+
+	forumState := http.HandlerFunc(app.forumState)
+	mux.Handle("/forum/state/", app.requireAuthentication(forumState))
+
+	forumHistory := http.HandlerFunc(app.forumHistory)
+	mux.Handle("/forum/history/", app.requireAuthentication(forumHistory))
+
 	forumRemove := http.HandlerFunc(app.handleForumRemove)
 	mux.Handle("/forum/remove/", app.requireAuthentication(forumRemove))
 
+	forumTrash := http.HandlerFunc(app.forumTrash)
+	mux.Handle("/forum/trash", app.requireAuthentication(forumTrash))
+	mux.Handle("/forum/trash/", app.requireAuthentication(forumTrash))
+
 	forumLikeStatus := http.HandlerFunc(app.forumIsLike)
 	mux.Handle("/forum/like/", app.requireAuthentication(forumLikeStatus))
 
 	forumCommentStatus := http.HandlerFunc(app.handleForumComment)
 	mux.Handle("/forum/comment/", app.requireAuthentication(forumCommentStatus))
 
+	forumReplyComment := http.HandlerFunc(app.ForumReplyCommentPost)
+	mux.Handle("/forum/comment/reply/", app.requireAuthentication(forumReplyComment))
+
 	forumCommentEditStatus := http.HandlerFunc(app.handleForumEditComment)
 	mux.Handle("/forum/comment/edit/", app.requireAuthentication(forumCommentEditStatus))
 
@@ -88,17 +120,48 @@ func (app *application) routes() http.Handler {
 	forumAllPosts := http.HandlerFunc(app.forumAllUserPosts)
 	mux.Handle("/forum/allPosts", app.requireAuthentication(forumAllPosts))
 
+	userInbox := http.HandlerFunc(app.userInbox)
+	mux.Handle("/user/inbox", app.requireAuthentication(userInbox))
+	userInboxRead := http.HandlerFunc(app.userInboxRead)
+	mux.Handle("/user/inbox/read/", app.requireAuthentication(userInboxRead))
+	userInboxReadAll := http.HandlerFunc(app.userInboxReadAll)
+	mux.Handle("/user/inbox/read-all", app.requireAuthentication(userInboxReadAll))
+	userInboxEvents := http.HandlerFunc(app.userInboxEvents)
+	mux.Handle("/user/inbox/events", app.requireAuthentication(userInboxEvents))
+
 	userNotificationSection := http.HandlerFunc(app.userNotification)
-	mux.Handle("/user/notification", app.requireAuthentication(userNotificationSection))
+	mux.Handle("/user/notification", app.requireAuthentication(app.requirePermission(policy.ModerationQueue, userNotificationSection)))
 
 	addTags := http.HandlerFunc(app.addTagsHandler)
-	mux.Handle("/admin/addTags", app.requireAuthentication(addTags))
+	mux.Handle("/admin/addTags", app.requireAuthentication(app.requirePermission(policy.TagManage, addTags)))
+	changeTag := http.HandlerFunc(app.changeTagPost)
+	mux.Handle("/admin/tags/change", app.requireAuthentication(app.requirePermission(policy.TagManage, changeTag)))
 
 	userNotificationSectionRemove := http.HandlerFunc(app.userNotificationRemove)
-	mux.Handle("/user/notification/remove/", app.requireAuthentication(userNotificationSectionRemove))
-
-	forumReportRemove := http.HandlerFunc(app.forumReportRemoveHandler)
-	mux.Handle("/user/report/remove/", app.requireAuthentication(forumReportRemove))
-
-	return app.recoverPanic(app.logRequest(secureHeaders(rateLimitMiddleware(mux))))
+	mux.Handle("/user/notification/remove/", app.requireAuthentication(app.requirePermission(policy.ModerationQueue, userNotificationSectionRemove)))
+
+	reportQueue := http.HandlerFunc(app.reportQueue)
+	mux.Handle("/moderation/reports", app.requireAuthentication(app.requirePermission(policy.ReportReview, reportQueue)))
+	reportAction := http.HandlerFunc(app.reportAction)
+	mux.Handle("/moderation/reports/", app.requireAuthentication(app.requirePermission(policy.ReportReview, reportAction)))
+	auditLog := http.HandlerFunc(app.auditLog)
+	mux.Handle("/admin/audit", app.requireAuthentication(app.requirePermission(policy.AuditRead, auditLog)))
+	permissions := http.HandlerFunc(app.adminPermissions)
+	mux.Handle("/admin/permissions", app.requireAuthentication(app.requirePermission(policy.RoleAssign, permissions)))
+	expiry := http.HandlerFunc(app.adminExpiry)
+	mux.Handle("/admin/expiry", app.requireAuthentication(app.requirePermission(policy.ExpiryManage, expiry)))
+
+	userSessions := http.HandlerFunc(app.userSessions)
+	mux.Handle("/user/sessions", app.requireAuthentication(userSessions))
+	userSessionRevoke := http.HandlerFunc(app.userSessionRevoke)
+	mux.Handle("/user/sessions/revoke/", app.requireAuthentication(userSessionRevoke))
+	userSessionsRevokeOthers := http.HandlerFunc(app.userSessionsRevokeOthers)
+	mux.Handle("/user/sessions/revoke-others", app.requireAuthentication(userSessionsRevokeOthers))
+
+	userSettings := http.HandlerFunc(app.userSettings)
+	mux.Handle("/user/settings", app.requireAuthentication(userSettings))
+	userSettingsUnlink := http.HandlerFunc(app.userSettingsUnlink)
+	mux.Handle("/user/settings/unlink/", app.requireAuthentication(userSettingsUnlink))
+
+	return app.recoverPanic(app.logRequest(secureHeaders(rateLimitMiddleware(limitBody(app.csrf(app.renewSession(mux)))))))
 }
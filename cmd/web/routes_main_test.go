@@ -1,9 +1,10 @@
 package main
 
 import (
+	"errors"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +19,7 @@ func TestRoutesBasicEndpoints(t *testing.T) {
 	}
 
 	req, rr = newRequest(http.MethodPost, "/swagger", nil)
+	attachCSRF(req)
 	h.ServeHTTP(rr, req)
 	if rr.Code != http.StatusMethodNotAllowed {
 		t.Fatalf("/swagger POST status=%d, want 405", rr.Code)
@@ -30,7 +32,7 @@ func TestRoutesBasicEndpoints(t *testing.T) {
 	}
 }
 
-func TestOpenDBAndReadDB(t *testing.T) {
+func TestOpenDBAndMigrateDB(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "db.sqlite")
 	db, err := openDB(dbPath)
 	if err != nil {
@@ -38,19 +40,49 @@ func TestOpenDBAndReadDB(t *testing.T) {
 	}
 	t.Cleanup(func() { _ = db.Close() })
 
-	sqlPath := filepath.Join(t.TempDir(), "init.sql")
-	sqlText := "CREATE TABLE x(id INTEGER); INSERT INTO x(id) VALUES (1);"
-	if err := os.WriteFile(sqlPath, []byte(sqlText), 0600); err != nil {
-		t.Fatalf("write sql file: %v", err)
+	if err := migrateDB(db); err != nil {
+		t.Fatalf("migrateDB: %v", err)
+	}
+	if err := migrateDB(db); err != nil {
+		t.Fatalf("second migrateDB should be a no-op: %v", err)
 	}
-
-	readDB(sqlPath, db)
 	var count int
-	if err := db.QueryRow(`SELECT COUNT(*) FROM x`).Scan(&count); err != nil {
-		t.Fatalf("query readDB-created table: %v", err)
+	if err := db.QueryRow(`SELECT COUNT(*) FROM roles WHERE id > 0`).Scan(&count); err != nil {
+		t.Fatalf("query migrated roles table: %v", err)
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	var out strings.Builder
+	if err := runMigrate(db, []string{"status"}, &out); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !strings.Contains(out.String(), "pending") {
+		t.Fatalf("expected pending migrations, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrate(db, []string{"up"}, &out); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	out.Reset()
+	if err := runMigrate(db, []string{"down", "1"}, &out); err != nil {
+		t.Fatalf("down: %v", err)
 	}
-	if count != 1 {
-		t.Fatalf("count=%d, want 1", count)
+	if out.String() != "reverted 1 migration(s)\n" {
+		t.Fatalf("unexpected down output %q", out.String())
+	}
+
+	for _, args := range [][]string{nil, {"sideways"}, {"up", "x"}, {"down", "0"}, {"status", "1"}} {
+		if err := runMigrate(db, args, &out); !errors.Is(err, errMigrateUsage) {
+			t.Fatalf("args %v: expected usage error, got %v", args, err)
+		}
 	}
 }
 
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/aspandyar/forum/internal/mail"
+	"github.com/aspandyar/forum/internal/media"
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+	"github.com/aspandyar/forum/internal/repository/sqlite"
+	authsvc "github.com/aspandyar/forum/internal/service/auth"
+	forumsvc "github.com/aspandyar/forum/internal/service/forum"
+)
+
+// newServices wires the forum and auth services over the sqlite repositories.
+// Writes that other users should see immediately publish to live; account
+// emails go out through mailer and uploaded images are kept in images.
+func newServices(db *sql.DB, live models.Publisher, mailer mail.Mailer, images *media.Store) (*forumsvc.Service, *authsvc.Service) {
+	forums := &models.ForumModel{DB: db, Live: live}
+	users := &models.UserModel{DB: db}
+
+	forumService := &forumsvc.Service{
+		Tx:   &sqlite.Transactor{DB: db},
+		Repo: &sqlite.ForumRepository{Model: forums},
+		Comments: &sqlite.CommentRepository{
+			CommentModel: &models.ForumCommentModel{DB: db, Live: live},
+			ForumModel:   forums,
+		},
+		Likes:      &sqlite.LikeRepository{Model: &models.ForumLikesModel{DB: db, Live: live}},
+		Moderation: &sqlite.ModerationRepository{Model: forums},
+		Reports:    &sqlite.ReportRepository{Model: &models.ReportModel{DB: db}},
+		Tags:       &sqlite.TagRepository{ForumModel: forums, UserModel: users},
+		Search:     &sqlite.SearchRepository{Model: &models.SearchModel{DB: db}},
+		Inboxes:    &sqlite.InboxRepository{Model: &models.InboxModel{DB: db, Live: live}},
+		Revisions:  &sqlite.RevisionRepository{Model: &models.RevisionModel{DB: db}},
+		Trash:      &sqlite.TrashRepository{Model: &models.TrashModel{DB: db}},
+		Expiry:     &sqlite.ExpiryRepository{Model: &models.ExpiryModel{DB: db, Live: live}},
+		Images:     images,
+		Policy:     policy.New(&sqlite.PermissionRepository{Model: &models.PermissionModel{DB: db}}),
+	}
+
+	authService := &authsvc.Service{
+		Tx:         &sqlite.Transactor{DB: db},
+		Users:      &sqlite.UserRepository{Model: users},
+		Sessions:   &sqlite.SessionRepository{Model: &models.SessionModel{DB: db}},
+		Tokens:     &sqlite.TokenRepository{Model: &models.AccountTokenModel{DB: db}},
+		Identities: &sqlite.IdentityRepository{Model: &models.OAuthIdentityModel{DB: db}},
+		Mailer:     mailer,
+	}
+
+	return forumService, authService
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	sessioncookie "github.com/aspandyar/forum/internal/transport/http/sessioncookie"
+)
+
+type sessionsForm struct {
+	Sessions []*models.Session
+}
+
+// sessionDevice records the browser and address a session is opened from.
+func sessionDevice(r *http.Request) models.SessionDevice {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return models.SessionDevice{UserAgent: r.UserAgent(), IP: host}
+}
+
+// startSession signs userID in on this device and sets the session cookie.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	session, err := app.authService.StartSession(r.Context(), userID, sessionDevice(r))
+	if err != nil {
+		return err
+	}
+	sessioncookie.SetSessionCookie(w, session.Token, session.Expiry)
+	return nil
+}
+
+// userSessions lists the devices the user is signed in on.
+func (app *application) userSessions(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/sessions" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var form sessionsForm
+	form.Sessions, err = app.authService.ListSessions(r.Context(), userID, cookie.Value)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "sessions.tmpl.html", data)
+}
+
+// userSessionRevoke signs out one device. Revoking the current session is
+// a logout.
+func (app *application) userSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[1] != "user" || parts[2] != "sessions" || parts[3] != "revoke" {
+		app.notFound(w)
+		return
+	}
+	id, err := strconv.Atoi(parts[4])
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	sessions, err := app.authService.ListSessions(r.Context(), userID, cookie.Value)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	current := false
+	for _, s := range sessions {
+		if s.ID == id && s.Current {
+			current = true
+		}
+	}
+
+	if err := app.authService.RevokeSession(r.Context(), userID, id); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if current {
+		sessioncookie.ClearSessionCookie(w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/user/sessions", http.StatusSeeOther)
+}
+
+// userSessionsRevokeOthers signs out every device but this one.
+func (app *application) userSessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/user/sessions/revoke-others" {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if _, err := app.authService.RevokeOtherSessions(r.Context(), userID, cookie.Value); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/user/sessions", http.StatusSeeOther)
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
-	"text/template"
+	"html/template"
 
 	renderpkg "github.com/aspandyar/forum/internal/transport/http/render"
 )
 
 type templateData = renderpkg.TemplateData
 
+type oauthProvider = renderpkg.SignInProvider
+
 func newTemplateCache() (map[string]*template.Template, error) {
 	return renderpkg.NewTemplateCache()
 }
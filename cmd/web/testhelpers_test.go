@@ -1,19 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"html/template"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"path/filepath"
 	"strings"
 	"testing"
-	"text/template"
 	"time"
 
+	"github.com/aspandyar/forum/internal/live"
+	"github.com/aspandyar/forum/internal/mail"
+	"github.com/aspandyar/forum/internal/media"
 	"github.com/aspandyar/forum/internal/models"
+	mw "github.com/aspandyar/forum/internal/transport/http/middleware"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -26,19 +30,8 @@ func newWebTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("open sqlite: %v", err)
 	}
 
-	schemaBytes, err := os.ReadFile(filepath.Join("..", "..", "init-up.sql"))
-	if err != nil {
-		t.Fatalf("read schema: %v", err)
-	}
-
-	for _, stmt := range strings.Split(string(schemaBytes), ";") {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		if _, err := db.Exec(stmt); err != nil {
-			t.Fatalf("exec schema statement %q: %v", stmt, err)
-		}
+	if err := migrateDB(db); err != nil {
+		t.Fatalf("apply migrations: %v", err)
 	}
 
 	t.Cleanup(func() {
@@ -52,6 +45,13 @@ func newWebTestApp(t *testing.T) (*application, *sql.DB) {
 	t.Helper()
 
 	db := newWebTestDB(t)
+	hub := live.NewHub()
+	images := &media.Store{
+		Blobs:     &media.Disk{Dir: t.TempDir(), URL: mediaURLPrefix, Secret: []byte("test")},
+		URLPrefix: mediaURLPrefix,
+		URLTTL:    time.Hour,
+	}
+	forumService, authService := newServices(db, hub, &mail.FileMailer{Dir: t.TempDir()}, images)
 	app := &application{
 		errorLog: log.New(io.Discard, "", 0),
 		infoLog:  log.New(io.Discard, "", 0),
@@ -59,11 +59,18 @@ func newWebTestApp(t *testing.T) (*application, *sql.DB) {
 		users:    &models.UserModel{DB: db},
 		sessions: &models.SessionModel{DB: db},
 		forumLike: &models.ForumLikesModel{
-			DB: db,
+			DB:   db,
+			Live: hub,
 		},
 		forumComment: &models.ForumCommentModel{
-			DB: db,
+			DB:   db,
+			Live: hub,
 		},
+		live:          hub,
+		policy:        forumService.Policy,
+		forumService:  forumService,
+		authService:   authService,
+		images:        images,
 		tempalteCache: map[string]*template.Template{},
 	}
 	return app, db
@@ -71,7 +78,7 @@ func newWebTestApp(t *testing.T) (*application, *sql.DB) {
 
 func seedWebUser(t *testing.T, app *application, name, email string, role int) int {
 	t.Helper()
-	if err := app.users.Insert(name, email, "password123", role); err != nil {
+	if err := app.users.Insert(context.Background(), name, email, "password123", role); err != nil {
 		t.Fatalf("insert user: %v", err)
 	}
 	var id int
@@ -83,13 +90,23 @@ func seedWebUser(t *testing.T, app *application, name, email string, role int) i
 
 func attachSessionCookie(t *testing.T, app *application, req *http.Request, userID int) {
 	t.Helper()
-	session, err := app.sessions.CreateSession(userID)
+	session, err := app.sessions.CreateSession(context.Background(), userID, models.SessionDevice{})
 	if err != nil {
 		t.Fatalf("create session: %v", err)
 	}
 	req.AddCookie(&http.Cookie{Name: "session", Value: session.Token, Expires: session.Expiry})
 }
 
+// testCSRFToken is accepted by the csrf middleware when sent by attachCSRF.
+var testCSRFToken = strings.Repeat("c", 43)
+
+// attachCSRF adds a matching CSRF cookie and header so unsafe requests pass
+// app.routes().
+func attachCSRF(req *http.Request) {
+	req.AddCookie(&http.Cookie{Name: mw.CSRFCookie, Value: testCSRFToken})
+	req.Header.Set(mw.CSRFHeader, testCSRFToken)
+}
+
 func newRequest(method, target string, body io.Reader) (*http.Request, *httptest.ResponseRecorder) {
 	req := httptest.NewRequest(method, target, body)
 	rr := httptest.NewRecorder()
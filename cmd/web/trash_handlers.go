@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// forumTrash handles GET /forum/trash, the deleted posts and comments the
+// user may see, and POST /forum/trash/{post|comment}/{id}/restore.
+func (app *application) forumTrash(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	switch {
+	case len(parts) == 3 && parts[1] == "forum" && parts[2] == "trash":
+		app.forumTrashGet(w, r)
+	case len(parts) == 6 && parts[1] == "forum" && parts[2] == "trash" && parts[5] == "restore" &&
+		(parts[3] == "post" || parts[3] == "comment"):
+		app.forumTrashRestore(w, r, parts[3], parts[4])
+	default:
+		app.notFound(w)
+	}
+}
+
+func (app *application) forumTrashGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	trash, err := app.forumService.ListTrash(userID)
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = trash
+	app.render(w, http.StatusOK, "trash.tmpl.html", data)
+}
+
+func (app *application) forumTrashRestore(w http.ResponseWriter, r *http.Request, kind, idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := app.sessionUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	target := fmt.Sprintf("/forum/view/%d", id)
+	if kind == "post" {
+		err = app.forumService.RestorePost(r.Context(), id, userID)
+	} else {
+		var forumID int
+		forumID, err = app.forumService.RestoreComment(id, userID)
+		target = fmt.Sprintf("/forum/view/%d#comment-%d", forumID, id)
+	}
+	if err != nil {
+		app.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+func TestForumTrash(t *testing.T) {
+	app, db := newWebTestApp(t)
+	app.tempalteCache["trash.tmpl.html"] = mustTemplate(`{{define "base"}}{{with .Form}}{{.AllPosts}}|` +
+		`{{range .Posts}}p{{.ForumID}}:{{.DeletedByName}};{{end}}|` +
+		`{{range .Comments}}c{{.CommentID}}:{{.Content}};{{end}}{{end}}{{end}}`)
+	h := app.routes()
+
+	authorID := seedWebUser(t, app, "author", "author@example.com", models.UserRole)
+	otherID := seedWebUser(t, app, "other", "other@example.com", models.UserRole)
+	adminID := seedWebUser(t, app, "admin", "admin@example.com", models.AdminRole)
+
+	newPost := func(title string) int {
+		id, err := app.forums.Insert(context.Background(), title, "body", "go", 7, authorID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.forums.ChangeForumStatus(id, models.VisibleStatus); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	ownID := newPost("own")
+	staffID := newPost("staff")
+	if _, err := app.forumComment.CommentPost(ownID, authorID, "regret"); err != nil {
+		t.Fatal(err)
+	}
+	var commentID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE forum_id = ?`, ownID).Scan(&commentID); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, target string, userID int) (int, string) {
+		req, rr := newRequest(method, target, strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		attachSessionCookie(t, app, req, userID)
+		attachCSRF(req)
+		h.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+
+	if code, _ := do(http.MethodPost, "/forum/remove/"+strconv.Itoa(ownID), authorID); code != http.StatusSeeOther {
+		t.Fatalf("removing own post = %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/forum/remove/"+strconv.Itoa(staffID), adminID); code != http.StatusSeeOther {
+		t.Fatalf("admin removing a post = %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/forum/view/"+strconv.Itoa(ownID), authorID); code != http.StatusNotFound {
+		t.Fatalf("viewing a trashed post = %d, want 404", code)
+	}
+
+	code, body := do(http.MethodGet, "/forum/trash", authorID)
+	want := "false|p" + strconv.Itoa(staffID) + ":admin;p" + strconv.Itoa(ownID) + ":author;|"
+	if code != http.StatusOK || body != want {
+		t.Fatalf("author trash = %d %q, want %q", code, body, want)
+	}
+	if code, body := do(http.MethodGet, "/forum/trash", otherID); code != http.StatusOK || body != "false||" {
+		t.Fatalf("other user's trash = %d %q", code, body)
+	}
+
+	// Authors restore what they deleted, not what staff removed.
+	restore := func(kind string, id int) string { return "/forum/trash/" + kind + "/" + strconv.Itoa(id) + "/restore" }
+	if code, _ := do(http.MethodPost, restore("post", staffID), authorID); code != http.StatusForbidden {
+		t.Fatalf("author restoring a staff removal = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodPost, restore("post", ownID), otherID); code != http.StatusForbidden {
+		t.Fatalf("other user restoring = %d, want 403", code)
+	}
+	if code, _ := do(http.MethodPost, restore("post", ownID), authorID); code != http.StatusSeeOther {
+		t.Fatalf("author restoring own post = %d", code)
+	}
+	if code, _ := do(http.MethodPost, restore("post", ownID), authorID); code != http.StatusNotFound {
+		t.Fatalf("restoring a live post = %d, want 404", code)
+	}
+	if code, _ := do(http.MethodGet, restore("post", staffID), adminID); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET restore = %d, want 405", code)
+	}
+	if code, _ := do(http.MethodPost, restore("post", staffID), adminID); code != http.StatusSeeOther {
+		t.Fatalf("admin restoring = %d", code)
+	}
+
+	removeComment := "/forum/comment/remove/" + strconv.Itoa(ownID) + "/" + strconv.Itoa(commentID)
+	if code, _ := do(http.MethodPost, removeComment, authorID); code != http.StatusSeeOther {
+		t.Fatalf("removing own comment = %d", code)
+	}
+	if code, body := do(http.MethodGet, "/forum/trash", adminID); code != http.StatusOK || body != "true||c"+strconv.Itoa(commentID)+":regret;" {
+		t.Fatalf("admin trash = %d %q", code, body)
+	}
+	req, rr := newRequest(http.MethodPost, restore("comment", commentID), strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	attachSessionCookie(t, app, req, authorID)
+	attachCSRF(req)
+	h.ServeHTTP(rr, req)
+	wantTarget := "/forum/view/" + strconv.Itoa(ownID) + "#comment-" + strconv.Itoa(commentID)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != wantTarget {
+		t.Fatalf("restoring own comment = %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	if code, _ := do(http.MethodGet, "/forum/trash/bogus", authorID); code != http.StatusNotFound {
+		t.Fatalf("unknown trash path = %d, want 404", code)
+	}
+}
@@ -3,7 +3,7 @@ module github.com/aspandyar/forum
 go 1.26.0
 
 require (
-	github.com/google/uuid v1.6.0
 	github.com/mattn/go-sqlite3 v1.14.42
 	golang.org/x/crypto v0.50.0
+	golang.org/x/image v0.46.0
 )
@@ -0,0 +1,132 @@
+// Package diff compares texts line by line.
+package diff
+
+import "strings"
+
+// Op says what happened to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the table of the longest common subsequence. Past it the
+// differing middle of two texts is shown as deleted and inserted whole.
+const maxCells = 1 << 20
+
+// Lines returns the lines that turn a into b: a shortest edit script, with
+// deletions before insertions where they meet.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Lines the texts share at either end need no table.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	var out []Line
+	for _, s := range x[:pre] {
+		out = append(out, Line{Equal, s})
+	}
+	out = append(out, middle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, s := range x[len(x)-suf:] {
+		out = append(out, Line{Equal, s})
+	}
+	return out
+}
+
+// middle diffs the part of two texts between their common ends.
+func middle(x, y []string) []Line {
+	var out []Line
+	if len(x)*len(y) > maxCells {
+		for _, s := range x {
+			out = append(out, Line{Delete, s})
+		}
+		for _, s := range y {
+			out = append(out, Line{Insert, s})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, x[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, Line{Insert, y[j]})
+	}
+	return out
+}
+
+// split cuts s into lines. An empty text has none, and a final newline does
+// not start another.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+// Changed reports whether a diff holds any insertion or deletion.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
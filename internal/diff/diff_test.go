@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name, a, b string
+		want       []Line
+	}{
+		{"same", "a\nb", "a\nb\n", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"empty", "", "", nil},
+		{"added", "", "a", []Line{{Insert, "a"}}},
+		{"removed", "a\nb", "", []Line{{Delete, "a"}, {Delete, "b"}}},
+		{"changed line", "a\nb\nc", "a\nB\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}}},
+		{"inserted", "a\nc", "a\nb\nc", []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"moved", "a\nb\nc", "b\nc\na", []Line{{Delete, "a"}, {Equal, "b"}, {Equal, "c"}, {Insert, "a"}}},
+		{"crlf", "a\r\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+	}
+	for _, tt := range tests {
+		got := Lines(tt.a, tt.b)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Lines(%q, %q) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+		if Changed(got) != (tt.name != "same" && tt.name != "empty" && tt.name != "crlf") {
+			t.Errorf("%s: Changed = %v", tt.name, Changed(got))
+		}
+	}
+}
+
+// apply rebuilds both texts from a diff.
+func apply(lines []Line) (a, b []string) {
+	for _, l := range lines {
+		if l.Op != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Op != Delete {
+			b = append(b, l.Text)
+		}
+	}
+	return a, b
+}
+
+func TestLinesLargeInputs(t *testing.T) {
+	var x, y strings.Builder
+	for i := 0; i < 5000; i++ {
+		x.WriteString("old " + string(rune('a'+i%26)) + "\n")
+		y.WriteString("new " + string(rune('a'+i%26)) + "\n")
+	}
+
+	start := time.Now()
+	lines := Lines(x.String(), y.String())
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Lines took %v", d)
+	}
+	a, b := apply(lines)
+	if strings.Join(a, "\n")+"\n" != x.String() || strings.Join(b, "\n")+"\n" != y.String() {
+		t.Fatal("diff does not rebuild its inputs")
+	}
+}
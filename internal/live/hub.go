@@ -0,0 +1,217 @@
+// Package live is an in-process publish/subscribe hub feeding the
+// Server-Sent Events streams. Events are kept in a short per-topic backlog
+// so a reconnecting client can resume from its Last-Event-ID.
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrTooManyStreams = errors.New("live: too many open streams for client")
+
+// Event is one message on a topic. IDs increase across the whole hub, so a
+// client can resume any topic from the last ID it saw.
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+type topic struct {
+	backlog []Event
+	// evicted is the highest ID dropped from the backlog; resuming from an
+	// older ID would miss events.
+	evicted uint64
+	subs    map[*Subscription]struct{}
+	// last is when the topic last had an event or lost its last
+	// subscriber.
+	last time.Time
+}
+
+// Hub fans published events out to subscribers. The zero value is not
+// usable; create hubs with NewHub.
+type Hub struct {
+	// Backlog is how many recent events each topic keeps for resume.
+	Backlog int
+	// MaxPerClient caps the concurrent subscriptions of one client key.
+	MaxPerClient int
+	// Buffer is the channel size of a subscription. A subscriber that falls
+	// further behind is closed and has to reconnect.
+	Buffer int
+	// Retention is how long a topic without subscribers keeps its backlog.
+	// After that it is forgotten, and a client resuming it is told to
+	// reload.
+	Retention time.Duration
+
+	mu      sync.Mutex
+	lastID  uint64
+	topics  map[string]*topic
+	clients map[string]int
+	// forgotten is the highest ID any forgotten topic held.
+	forgotten uint64
+	swept     time.Time
+	now       func() time.Time
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		Backlog:      64,
+		MaxPerClient: 4,
+		Buffer:       16,
+		Retention:    10 * time.Minute,
+		topics:       make(map[string]*topic),
+		clients:      make(map[string]int),
+		now:          time.Now,
+	}
+}
+
+// Subscription receives the events of one topic. Backlog holds the events
+// published after the requested Last-Event-ID and must be sent before
+// anything read from Events. Reset is set when the requested ID is no
+// longer covered by the backlog and the client should reload instead.
+type Subscription struct {
+	Events  <-chan Event
+	Backlog []Event
+	Reset   bool
+
+	ch     chan Event
+	hub    *Hub
+	topic  string
+	client string
+	once   sync.Once
+}
+
+// Close unsubscribes and releases the client's stream slot. It is safe to
+// call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		defer s.hub.mu.Unlock()
+
+		s.hub.clients[s.client]--
+		if s.hub.clients[s.client] <= 0 {
+			delete(s.hub.clients, s.client)
+		}
+		s.hub.drop(s)
+	})
+}
+
+// drop removes a subscription from its topic and closes its channel. The
+// caller holds h.mu.
+func (h *Hub) drop(s *Subscription) {
+	t := h.topics[s.topic]
+	if t == nil {
+		return
+	}
+	if _, ok := t.subs[s]; !ok {
+		return
+	}
+	delete(t.subs, s)
+	close(s.ch)
+	if len(t.subs) == 0 {
+		if len(t.backlog) == 0 {
+			delete(h.topics, s.topic)
+		}
+		t.last = h.now()
+	}
+}
+
+// topicLocked returns the topic called name, creating it if needed. A new
+// topic may have been forgotten before, so it cannot resume IDs older than
+// the forgotten ones. The caller holds h.mu.
+func (h *Hub) topicLocked(name string) *topic {
+	t := h.topics[name]
+	if t == nil {
+		t = &topic{subs: make(map[*Subscription]struct{}), evicted: h.forgotten}
+		h.topics[name] = t
+	}
+	return t
+}
+
+// sweepLocked forgets the topics nobody has subscribed to for longer than
+// Retention. It runs at most once per Retention; the caller holds h.mu.
+func (h *Hub) sweepLocked() {
+	now := h.now()
+	if h.Retention <= 0 || now.Sub(h.swept) < h.Retention {
+		return
+	}
+	h.swept = now
+
+	for name, t := range h.topics {
+		if len(t.subs) > 0 || now.Sub(t.last) <= h.Retention {
+			continue
+		}
+		if n := len(t.backlog); n > 0 && t.backlog[n-1].ID > h.forgotten {
+			h.forgotten = t.backlog[n-1].ID
+		}
+		delete(h.topics, name)
+	}
+}
+
+// Subscribe opens a subscription to name for client, replaying events after
+// lastID (0 for a fresh stream).
+func (h *Hub) Subscribe(name, client string, lastID uint64) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.MaxPerClient > 0 && h.clients[client] >= h.MaxPerClient {
+		return nil, ErrTooManyStreams
+	}
+	h.clients[client]++
+	h.sweepLocked()
+
+	ch := make(chan Event, h.Buffer)
+	s := &Subscription{Events: ch, ch: ch, hub: h, topic: name, client: client}
+
+	t := h.topicLocked(name)
+	if lastID > 0 {
+		if lastID > h.lastID || lastID < t.evicted {
+			s.Reset = true
+		} else {
+			for _, e := range t.backlog {
+				if e.ID > lastID {
+					s.Backlog = append(s.Backlog, e)
+				}
+			}
+		}
+	}
+	t.subs[s] = struct{}{}
+
+	return s, nil
+}
+
+// Publish sends an event of type typ with data encoded as JSON to every
+// subscriber of name.
+func (h *Hub) Publish(name, typ string, data interface{}) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sweepLocked()
+	h.lastID++
+	e := Event{ID: h.lastID, Topic: name, Type: typ, Data: js}
+
+	t := h.topicLocked(name)
+	t.last = h.now()
+	t.backlog = append(t.backlog, e)
+	if over := len(t.backlog) - h.Backlog; over > 0 {
+		t.evicted = t.backlog[over-1].ID
+		t.backlog = append(t.backlog[:0:0], t.backlog[over:]...)
+	}
+
+	for s := range t.subs {
+		select {
+		case s.ch <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package live
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-s.Events:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	default:
+		t.Fatal("no event delivered")
+	}
+	return Event{}
+}
+
+func TestPublishFansOutPerTopic(t *testing.T) {
+	h := NewHub()
+
+	a, err := h.Subscribe("forum:1", "alice", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer a.Close()
+	b, err := h.Subscribe("forum:2", "bob", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer b.Close()
+
+	h.Publish("forum:1", "comment", map[string]int{"id": 7})
+
+	e := receive(t, a)
+	if e.ID != 1 || e.Type != "comment" || string(e.Data) != `{"id":7}` {
+		t.Fatalf("event = %+v (%s)", e, e.Data)
+	}
+	select {
+	case e := <-b.Events:
+		t.Fatalf("other topic received %+v", e)
+	default:
+	}
+}
+
+func TestSubscribeResumesFromLastID(t *testing.T) {
+	h := NewHub()
+	h.Backlog = 3
+
+	for i := 0; i < 4; i++ {
+		h.Publish("inbox:1", "unread", i)
+	}
+	h.Publish("inbox:2", "unread", 0)
+
+	s, err := h.Subscribe("inbox:1", "u1", 2)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer s.Close()
+	if s.Reset || len(s.Backlog) != 2 || s.Backlog[0].ID != 3 || s.Backlog[1].ID != 4 {
+		t.Fatalf("resume from 2: reset=%v backlog=%+v", s.Reset, s.Backlog)
+	}
+
+	old, err := h.Subscribe("inbox:1", "u1", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer old.Close()
+	if old.Reset || len(old.Backlog) != 0 {
+		t.Fatalf("fresh stream should not replay: %+v", old)
+	}
+
+	future, err := h.Subscribe("inbox:1", "u2", 99)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if !future.Reset {
+		t.Fatal("resume from an ID the hub never issued should reset")
+	}
+	future.Close()
+
+	h.Publish("inbox:1", "unread", 4)
+	evicted, err := h.Subscribe("inbox:1", "u2", 1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer evicted.Close()
+	if !evicted.Reset {
+		t.Fatal("resume from an evicted ID should reset")
+	}
+}
+
+func TestSubscribeLimitsStreamsPerClient(t *testing.T) {
+	h := NewHub()
+	h.MaxPerClient = 2
+
+	first, err := h.Subscribe("forum:1", "carol", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	second, err := h.Subscribe("inbox:3", "carol", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer second.Close()
+
+	if _, err := h.Subscribe("forum:1", "carol", 0); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("third stream err = %v, want ErrTooManyStreams", err)
+	}
+	if s, err := h.Subscribe("forum:1", "dave", 0); err != nil {
+		t.Fatalf("other client: %v", err)
+	} else {
+		s.Close()
+	}
+
+	first.Close()
+	first.Close()
+	third, err := h.Subscribe("forum:1", "carol", 0)
+	if err != nil {
+		t.Fatalf("Subscribe after Close: %v", err)
+	}
+	third.Close()
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub()
+	h.Buffer = 1
+
+	s, err := h.Subscribe("forum:1", "erin", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer s.Close()
+
+	h.Publish("forum:1", "comment", 1)
+	h.Publish("forum:1", "comment", 2)
+
+	if e := receive(t, s); e.ID != 1 {
+		t.Fatalf("first event = %+v", e)
+	}
+	if _, ok := <-s.Events; ok {
+		t.Fatal("slow subscriber should be closed")
+	}
+}
+
+func TestIdleTopicsAreForgotten(t *testing.T) {
+	h := NewHub()
+	now := time.Unix(1000, 0)
+	h.now = func() time.Time { return now }
+
+	watched, err := h.Subscribe("forum:1", "frank", 0)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer watched.Close()
+	for i := 1; i <= 3; i++ {
+		h.Publish("forum:"+strconv.Itoa(i), "comment", i)
+	}
+	topics := func() int {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.topics)
+	}
+	if n := topics(); n != 3 {
+		t.Fatalf("%d topics, want 3", n)
+	}
+
+	// Within the window the backlogs stay for resume.
+	now = now.Add(h.Retention / 2)
+	h.Publish("forum:4", "comment", 4)
+	if n := topics(); n != 4 {
+		t.Fatalf("%d topics within the window, want 4", n)
+	}
+
+	now = now.Add(h.Retention)
+	h.Publish("forum:4", "comment", 5)
+	if n := topics(); n != 2 {
+		t.Fatalf("%d topics after the window, want the watched and the fresh one", n)
+	}
+
+	// Resuming a forgotten topic cannot replay what it held.
+	s, err := h.Subscribe("forum:2", "grace", 1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer s.Close()
+	if !s.Reset || len(s.Backlog) != 0 {
+		t.Fatalf("resume of a forgotten topic: reset=%v backlog=%d", s.Reset, len(s.Backlog))
+	}
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer writes each message to Dir as a numbered .eml file instead of
+// sending it, and logs where it went if Log is set. With no Dir the whole
+// message is logged instead. Either way the link can be followed locally.
+type FileMailer struct {
+	Dir  string
+	From string
+	Log  *log.Logger
+
+	mu sync.Mutex
+	n  int
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := check(msg); err != nil {
+		return err
+	}
+	if m.Dir == "" {
+		if m.Log == nil {
+			return fmt.Errorf("mail: FileMailer needs a Dir or a Log")
+		}
+		m.Log.Printf("mail to %s\n%s", msg.To, format(m.From, msg, time.Now()))
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.n++
+	now := time.Now()
+	name := fmt.Sprintf("%s-%03d-%s.eml", now.UTC().Format("20060102T150405"), m.n, safeName(msg.To))
+	m.mu.Unlock()
+
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg, now), 0o600); err != nil {
+		return err
+	}
+	if m.Log != nil {
+		m.Log.Printf("mail to %s (%q) written to %s", msg.To, msg.Subject, path)
+	}
+	return nil
+}
+
+// safeName keeps the recipient readable in a file name.
+func safeName(to string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		}
+		return '_'
+	}, to)
+}
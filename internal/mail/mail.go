@@ -0,0 +1,50 @@
+// Package mail sends the transactional emails of the forum: password reset
+// and address verification links. SMTPMailer talks to a real server;
+// FileMailer stands in for one during development and tests.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format renders msg as an RFC 5322 message from from.
+func format(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// validHeader rejects values that would start a new header line.
+func validHeader(v string) bool {
+	return v != "" && !strings.ContainsAny(v, "\r\n")
+}
+
+func check(msg Message) error {
+	if !validHeader(msg.To) || !validHeader(msg.Subject) {
+		return fmt.Errorf("mail: invalid recipient or subject %q", msg.To)
+	}
+	return nil
+}
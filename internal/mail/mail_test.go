@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	got := string(format("forum@example.com", Message{
+		To:      "alice@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two\n",
+	}, now))
+
+	for _, want := range []string{
+		"From: forum@example.com\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Date: Fri, 01 Mar 2024 10:00:00 +0000\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRejectsHeaderInjection(t *testing.T) {
+	m := &FileMailer{Dir: t.TempDir()}
+	for _, msg := range []Message{
+		{To: "a@example.com\r\nBcc: b@example.com", Subject: "hi"},
+		{To: "a@example.com", Subject: "hi\nBcc: b@example.com"},
+		{To: "", Subject: "hi"},
+	} {
+		if err := m.Send(msg); err == nil {
+			t.Errorf("Send(%q) succeeded", msg)
+		}
+	}
+}
+
+func TestFileMailerWritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	var logged bytes.Buffer
+	m := &FileMailer{Dir: dir, From: "forum@example.com", Log: log.New(&logged, "", 0)}
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if err := m.Send(Message{To: to, Subject: "hello", Body: "link"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("files = %v, %v; want 2", files, err)
+	}
+	body, err := os.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "To: b@example.com") {
+		t.Fatalf("second file is not the second message:\n%s", body)
+	}
+	if !strings.Contains(logged.String(), files[0]) {
+		t.Fatalf("log %q does not name %s", logged.String(), files[0])
+	}
+}
+
+func TestFileMailerLogsWithoutDir(t *testing.T) {
+	var logged bytes.Buffer
+	m := &FileMailer{Log: log.New(&logged, "", 0)}
+	if err := m.Send(Message{To: "a@example.com", Subject: "hello", Body: "https://x/verify?token=abc"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.Contains(logged.String(), "token=abc") {
+		t.Fatalf("log missing body: %q", logged.String())
+	}
+
+	if err := (&FileMailer{}).Send(Message{To: "a@example.com", Subject: "hello"}); err == nil {
+		t.Fatal("FileMailer with neither Dir nor Log succeeded")
+	}
+}
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends through an SMTP server at Addr (host:port), upgrading
+// to TLS when the server offers STARTTLS. Username and Password are
+// optional.
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := check(msg); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, format(m.From, msg, time.Now()))
+}
@@ -0,0 +1,241 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// spans renders the inline markup of s: backslash escapes, code spans,
+// emphasis, links and autolinks. Link text cannot hold another link.
+func spans(b *strings.Builder, s string, depth int, inLink bool) {
+	// none remembers, for each delimiter, the position from which it has no
+	// closer, so that a text full of unmatched delimiters is not searched
+	// again and again.
+	none := map[string]int{}
+	closer := func(delim string, from int, ok func(int) bool) int {
+		if p, seen := none[delim]; seen && from >= p {
+			return -1
+		}
+		for i := from; i < len(s); {
+			j := strings.Index(s[i:], delim)
+			if j < 0 {
+				break
+			}
+			if ok(i + j) {
+				return i + j
+			}
+			i += j + 1
+		}
+		none[delim] = from
+		return -1
+	}
+
+	// pairs matches brackets once the first "[" is seen.
+	var pairs map[int]int
+
+	text := 0
+	flush := func(end int) {
+		escape(b, s[text:end], true)
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			flush(i)
+			text = i + 1
+			i += 2
+			continue
+
+		case c == '`':
+			n := run(s[i:], '`')
+			end := closer(s[i:i+n], i+n, func(k int) bool {
+				return s[k-1] != '`' && run(s[k:], '`') == n
+			})
+			if end < 0 {
+				i += n
+				continue
+			}
+			flush(i)
+			code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+			if code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>")
+			escape(b, code, false)
+			b.WriteString("</code>")
+			i = end + n
+			text = i
+			continue
+
+		case (c == '*' || c == '_') && depth < maxDepth:
+			n := run(s[i:], c)
+			// An opener is followed by text; "_" inside a word, as in
+			// snake_case, is not one.
+			if n > 3 || i+n == len(s) || isSpace(s[i+n]) || (c == '_' && i > 0 && isAlnum(s[i-1])) {
+				i += n
+				continue
+			}
+			end := closer(s[i:i+n], i+n, func(k int) bool {
+				return !isSpace(s[k-1]) && s[k-1] != c && run(s[k:], c) == n &&
+					(c != '_' || k+n == len(s) || !isAlnum(s[k+n]))
+			})
+			if end < 0 {
+				i += n
+				continue
+			}
+			flush(i)
+			open, close := emphasis(n)
+			b.WriteString(open)
+			spans(b, s[i+n:end], depth+1, inLink)
+			b.WriteString(close)
+			i = end + n
+			text = i
+			continue
+
+		case (c == '[' || c == '!' && i+1 < len(s) && s[i+1] == '[') && !inLink && depth < maxDepth:
+			// An image is shown as a link to it.
+			open := i
+			if c == '!' {
+				open++
+			}
+			if pairs == nil {
+				pairs = brackets(s)
+			}
+			textEnd, dest, end, ok := link(s, open, pairs)
+			if !ok {
+				i++
+				continue
+			}
+			flush(i)
+			if safeURL(dest) {
+				writeLinkStart(b, dest)
+				spans(b, s[open+1:textEnd], depth+1, true)
+				b.WriteString("</a>")
+			} else {
+				spans(b, s[open+1:textEnd], depth+1, true)
+			}
+			i = end
+			text = i
+			continue
+
+		case c == '<' && !inLink:
+			n := strings.IndexAny(s[i+1:], " \t\n<>")
+			if n < 0 || s[i+1+n] != '>' || !autolink(s[i+1:i+1+n]) {
+				break
+			}
+			flush(i)
+			url := s[i+1 : i+1+n]
+			writeLinkStart(b, url)
+			escape(b, url, false)
+			b.WriteString("</a>")
+			i += n + 2
+			text = i
+			continue
+		}
+		i++
+	}
+	flush(len(s))
+}
+
+func emphasis(n int) (open, close string) {
+	switch n {
+	case 1:
+		return "<em>", "</em>"
+	case 2:
+		return "<strong>", "</strong>"
+	default:
+		return "<em><strong>", "</strong></em>"
+	}
+}
+
+// brackets pairs each "[" in s with its "]".
+func brackets(s string) map[int]int {
+	pairs := map[int]int{}
+	var open []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			open = append(open, i)
+		case ']':
+			if len(open) > 0 {
+				pairs[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return pairs
+}
+
+// link parses "[text](destination)" at s[i], returning where the text ends,
+// the destination and where the link ends.
+func link(s string, i int, pairs map[int]int) (textEnd int, dest string, end int, ok bool) {
+	j, ok := pairs[i]
+	if !ok || j+1 == len(s) || s[j+1] != '(' {
+		return 0, "", 0, false
+	}
+	start := j + 2
+	k := start
+	for k < len(s) && s[k] > ' ' && !strings.ContainsRune("()<>", rune(s[k])) {
+		k++
+	}
+	if k == len(s) || s[k] != ')' {
+		return 0, "", 0, false
+	}
+	return j, s[start:k], k + 1, true
+}
+
+// safeURL reports whether a link may point at u: an http, https or mailto
+// URL, or a relative one.
+func safeURL(u string) bool {
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// autolink reports whether u, written as <u>, is a link.
+func autolink(u string) bool {
+	u = strings.ToLower(u)
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "mailto:")
+}
+
+func writeLinkStart(b *strings.Builder, href string) {
+	b.WriteString(`<a href="`)
+	escape(b, href, false)
+	b.WriteString(`" rel="nofollow noopener">`)
+}
+
+// escape writes s as HTML text. With breaks, newlines become <br>.
+func escape(b *strings.Builder, s string, breaks bool) {
+	for {
+		line, rest, more := strings.Cut(s, "\n")
+		b.WriteString(html.EscapeString(line))
+		if !more {
+			return
+		}
+		if breaks {
+			b.WriteString("<br>")
+		}
+		b.WriteString("\n")
+		s = rest
+	}
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
@@ -0,0 +1,384 @@
+// Package markdown renders the Markdown used in posts and comments to HTML
+// that is safe to put on a page.
+//
+// Nothing from the source reaches the output unescaped: raw HTML shows up
+// as text, and the only markup produced is this allowlist:
+//
+//	p br hr h1-h6 blockquote ul ol[start] li pre code[class=language-*]
+//	em strong a[href rel=nofollow]
+//
+// Links keep their target only for http, https, mailto and relative URLs.
+// A newline inside a paragraph is kept as a line break, since posts used to
+// be shown as preformatted text.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds how deeply quotes, lists, emphasis and links nest. Deeper
+// markup is rendered as text, so hostile input cannot exhaust the stack.
+const maxDepth = 16
+
+// Render converts src to HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var b strings.Builder
+	blocks(&b, lines, 0, false)
+	return b.String()
+}
+
+// expandTabs turns tabs in the indentation of line into four spaces each.
+func expandTabs(line string) string {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	if !strings.Contains(line[:n], "\t") {
+		return line
+	}
+	return strings.ReplaceAll(line[:n], "\t", "    ") + line[n:]
+}
+
+// blocks renders lines as a sequence of blocks. In a tight list item
+// paragraphs are written without <p>.
+func blocks(b *strings.Builder, lines []string, depth int, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if blank(line) {
+			i++
+			continue
+		}
+		if marker, _ := fence(line); marker != "" {
+			i = fenced(b, lines, i)
+			continue
+		}
+		if level, text := heading(line); level > 0 {
+			tag := "h" + strconv.Itoa(level)
+			b.WriteString("<" + tag + ">")
+			spans(b, text, 0, false)
+			b.WriteString("</" + tag + ">\n")
+			i++
+			continue
+		}
+		if thematicBreak(line) {
+			b.WriteString("<hr>\n")
+			i++
+			continue
+		}
+		if depth < maxDepth {
+			if _, ok := quoteLine(line); ok {
+				i = quote(b, lines, i, depth)
+				continue
+			}
+			if _, ok := listItem(line); ok {
+				i = list(b, lines, i, depth)
+				continue
+			}
+		}
+		i = paragraph(b, lines, i, tight)
+	}
+}
+
+// startsBlock reports whether line ends a paragraph above it.
+func startsBlock(line string) bool {
+	if marker, _ := fence(line); marker != "" {
+		return true
+	}
+	if level, _ := heading(line); level > 0 {
+		return true
+	}
+	if _, ok := quoteLine(line); ok || thematicBreak(line) {
+		return true
+	}
+	// As in CommonMark, only a list that looks deliberate interrupts a
+	// paragraph, so a line such as "2024. What a year" does not.
+	m, ok := listItem(line)
+	return ok && m.rest != "" && (!m.ordered || m.start == 1)
+}
+
+func paragraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if blank(line) || (len(text) > 0 && startsBlock(line)) {
+			break
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	if !tight {
+		b.WriteString("<p>")
+	}
+	spans(b, strings.Join(text, "\n"), 0, false)
+	if !tight {
+		b.WriteString("</p>\n")
+	}
+	return i
+}
+
+// fence returns the opening code fence of line, such as "```", and its info
+// string, or "" if line does not open a fenced block.
+func fence(line string) (marker, info string) {
+	s, indent := trimIndent(line)
+	if indent > 3 || len(s) < 3 || (s[0] != '`' && s[0] != '~') {
+		return "", ""
+	}
+	n := run(s, s[0])
+	if n < 3 {
+		return "", ""
+	}
+	info = strings.TrimSpace(s[n:])
+	if s[0] == '`' && strings.Contains(info, "`") {
+		return "", ""
+	}
+	return s[:n], info
+}
+
+// fenced renders the fenced code block opening at lines[i]. A block that is
+// never closed runs to the end of the text.
+func fenced(b *strings.Builder, lines []string, i int) int {
+	marker, info := fence(lines[i])
+	_, indent := trimIndent(lines[i])
+	i++
+
+	var code []string
+	for ; i < len(lines); i++ {
+		s, n := trimIndent(lines[i])
+		if n <= 3 && run(s, marker[0]) >= len(marker) && strings.TrimSpace(strings.TrimLeft(s, marker[:1])) == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		if n > indent {
+			n = indent
+		}
+		code = append(code, line[n:])
+	}
+
+	b.WriteString("<pre><code")
+	if lang, _, _ := strings.Cut(info, " "); validLanguage(lang) {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		escape(b, line, false)
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// validLanguage reports whether lang, the first word of a fence's info
+// string, is safe to use in a class name.
+func validLanguage(lang string) bool {
+	if lang == "" || len(lang) > 32 {
+		return false
+	}
+	for i := 0; i < len(lang); i++ {
+		c := lang[i]
+		if !isAlnum(c) && c != '-' && c != '_' && c != '+' {
+			return false
+		}
+	}
+	return true
+}
+
+// heading returns the level and text of an ATX heading such as "## Title",
+// or a level of 0.
+func heading(line string) (level int, text string) {
+	s, indent := trimIndent(line)
+	n := run(s, '#')
+	if indent > 3 || n == 0 || n > 6 || (n < len(s) && s[n] != ' ' && s[n] != '\t') {
+		return 0, ""
+	}
+	text = strings.TrimSpace(s[n:])
+	// Drop an optional closing sequence: "## Title ##".
+	if t := strings.TrimRight(text, "#"); t == "" || strings.HasSuffix(t, " ") {
+		text = strings.TrimSpace(t)
+	}
+	return n, text
+}
+
+// thematicBreak reports whether line is a rule such as "---" or "* * *".
+func thematicBreak(line string) bool {
+	s, indent := trimIndent(line)
+	if indent > 3 || s == "" || (s[0] != '-' && s[0] != '*' && s[0] != '_') {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case s[0]:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// quoteLine strips the "> " of a block quote line.
+func quoteLine(line string) (string, bool) {
+	s, indent := trimIndent(line)
+	if indent > 3 || !strings.HasPrefix(s, ">") {
+		return "", false
+	}
+	return strings.TrimPrefix(s[1:], " "), true
+}
+
+func quote(b *strings.Builder, lines []string, i, depth int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		s, ok := quoteLine(lines[i])
+		if !ok {
+			// A paragraph may carry on without the ">".
+			if blank(lines[i]) || blank(inner[len(inner)-1]) || startsBlock(lines[i]) {
+				break
+			}
+			s = lines[i]
+		}
+		inner = append(inner, s)
+	}
+	b.WriteString("<blockquote>\n")
+	blocks(b, inner, depth+1, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// item is the marker line of a list item.
+type item struct {
+	ordered bool
+	// delim is the bullet, or the "." or ")" after the number.
+	delim byte
+	start int
+	// width is the indentation of the item's content; lines indented at
+	// least as far belong to it.
+	width int
+	rest  string
+}
+
+func listItem(line string) (m item, ok bool) {
+	s, indent := trimIndent(line)
+	if indent > 3 || s == "" {
+		return m, false
+	}
+	n := 0
+	switch s[0] {
+	case '-', '*', '+':
+		m.delim, n = s[0], 1
+	default:
+		for n < len(s) && n < 9 && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if n == 0 || n == len(s) || (s[n] != '.' && s[n] != ')') {
+			return m, false
+		}
+		m.ordered = true
+		m.start, _ = strconv.Atoi(s[:n])
+		m.delim = s[n]
+		n++
+	}
+	if n < len(s) && s[n] != ' ' {
+		return m, false
+	}
+	rest := s[n:]
+	spaces := len(rest) - len(strings.TrimLeft(rest, " "))
+	if spaces == 0 || spaces > 4 || strings.TrimSpace(rest) == "" {
+		// Content indented further than that is indented within the item.
+		spaces = 1
+	}
+	m.width = indent + n + spaces
+	if len(rest) > spaces {
+		m.rest = rest[spaces:]
+	}
+	return m, true
+}
+
+func list(b *strings.Builder, lines []string, i, depth int) int {
+	first, _ := listItem(lines[i])
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		m, ok := listItem(lines[i])
+		if !ok || m.ordered != first.ordered || m.delim != first.delim || thematicBreak(lines[i]) {
+			break
+		}
+		if len(items) > 0 && blank(lines[i-1]) {
+			loose = true
+		}
+		content := []string{m.rest}
+	itemLines:
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			_, indent := trimIndent(line)
+			_, isItem := listItem(line)
+			switch {
+			case blank(line):
+				content = append(content, "")
+			case indent >= m.width:
+				content = append(content, line[m.width:])
+			case !isItem && !blank(content[len(content)-1]) && !startsBlock(line):
+				// A paragraph may carry on without the indentation.
+				content = append(content, line)
+			default:
+				break itemLines
+			}
+		}
+		for len(content) > 1 && blank(content[len(content)-1]) {
+			content = content[:len(content)-1]
+		}
+		for j := 1; j < len(content); j++ {
+			if blank(content[j]) {
+				loose = true
+			}
+		}
+		items = append(items, content)
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+	for _, content := range items {
+		b.WriteString("<li>")
+		blocks(b, content, depth+1, !loose)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// trimIndent strips the leading spaces of line and returns how many there
+// were.
+func trimIndent(line string) (string, int) {
+	s := strings.TrimLeft(line, " ")
+	return s, len(line) - len(s)
+}
+
+// run returns how many times c repeats at the start of s.
+func run(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,108 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"paragraph", "Hello\nworld", "<p>Hello<br>\nworld</p>\n"},
+		{"headings", "# One\n### Three ###", "<h1>One</h1>\n<h3>Three</h3>\n"},
+		{"not a heading", "#hashtag", "<p>#hashtag</p>\n"},
+		{"emphasis", "*em* **strong** ***both***", "<p><em>em</em> <strong>strong</strong> <em><strong>both</strong></em></p>\n"},
+		{"snake case", "a_b_c and _em_", "<p>a_b_c and <em>em</em></p>\n"},
+		{"unmatched", "2 * 3 * 4 and **open", "<p>2 * 3 * 4 and **open</p>\n"},
+		{"escapes", `\*literal\* \[x\]`, "<p>*literal* [x]</p>\n"},
+		{"code span", "run `a < b` or ``x ` y``", "<p>run <code>a &lt; b</code> or <code>x ` y</code></p>\n"},
+		{"fenced", "```go\nif a < b {\n}\n```", "<pre><code class=\"language-go\">if a &lt; b {\n}\n</code></pre>\n"},
+		{"tilde fence", "~~~\n*not em*\n~~~", "<pre><code>*not em*\n</code></pre>\n"},
+		{"unclosed fence", "```\ncode", "<pre><code>code\n</code></pre>\n"},
+		{"bad language", "```js\" onclick=\"x\nx\n```", "<pre><code>x\n</code></pre>\n"},
+		{"link", "[docs](https://example.com/?a=1&b=2)", "<p><a href=\"https://example.com/?a=1&amp;b=2\" rel=\"nofollow noopener\">docs</a></p>\n"},
+		{"relative link", "[post](/forum/view/1)", "<p><a href=\"/forum/view/1\" rel=\"nofollow noopener\">post</a></p>\n"},
+		{"autolink", "<https://example.com>", "<p><a href=\"https://example.com\" rel=\"nofollow noopener\">https://example.com</a></p>\n"},
+		{"image", "![cat](/media/cat.png)", "<p><a href=\"/media/cat.png\" rel=\"nofollow noopener\">cat</a></p>\n"},
+		{"no nested links", "[a [b](/b)](/a)", "<p><a href=\"/a\" rel=\"nofollow noopener\">a [b](/b)</a></p>\n"},
+		{"list", "- a\n- b\n  - c\n- d", "<ul>\n<li>a</li>\n<li>b<ul>\n<li>c</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+		{"loose list", "- a\n\n- b", "<ul>\n<li><p>a</p>\n</li>\n<li><p>b</p>\n</li>\n</ul>\n"},
+		{"ordered", "3. c\n4. d", "<ol start=\"3\">\n<li>c</li>\n<li>d</li>\n</ol>\n"},
+		{"year is not a list", "It was\n2024. A good year", "<p>It was<br>\n2024. A good year</p>\n"},
+		{"quote", "> a\nb\n> > c", "<blockquote>\n<p>a<br>\nb</p>\n<blockquote>\n<p>c</p>\n</blockquote>\n</blockquote>\n"},
+		{"rule", "a\n\n* * *", "<p>a</p>\n<hr>\n"},
+		{"raw html", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"attribute quote", `[x](/a"onmouseover="y)`, "<p><a href=\"/a&#34;onmouseover=&#34;y\" rel=\"nofollow noopener\">x</a></p>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src); got != tt.want {
+			t.Errorf("%s: Render(%q) =\n%q\nwant\n%q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDropsUnsafeLinks(t *testing.T) {
+	for _, src := range []string{
+		"[x](javascript:alert)",
+		"[x](JavaScript:alert)",
+		"[x](data:text/html,hi)",
+		"[x](vbscript:msgbox)",
+		"[x](\x01javascript:alert)",
+		"<javascript:alert>",
+	} {
+		if got := Render(src); strings.Contains(got, "<a") {
+			t.Errorf("Render(%q) = %q, want no link", src, got)
+		}
+	}
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// allowed are the tags, with their attributes, that the package promises
+// to produce.
+var allowed = regexp.MustCompile(`^<(/?(p|br|hr|h[1-6]|blockquote|ul|li|pre|code|em|strong|a|ol)|ol start="\d+"|code class="language-[\w+-]+"|a href="[^"<>]*" rel="nofollow noopener")>$`)
+
+func TestRenderOnlyAllowedMarkup(t *testing.T) {
+	hostile := []string{
+		`<img src=x onerror=alert(1)>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		"```\"><script>\nx\n```",
+		`[<b>bold</b>](/x "title") *<i>*`,
+		"> <iframe>\n- <style>\n1. `</code><script>`",
+		`<https://x.y/"onclick="a> <mailto:x@y.z>`,
+		"# <h1>\n\n\\<p\\>",
+	}
+	for _, src := range hostile {
+		for _, tag := range tagPattern.FindAllString(Render(src), -1) {
+			if !allowed.MatchString(tag) {
+				t.Errorf("Render(%q) produced %s", src, tag)
+			}
+		}
+	}
+}
+
+func TestRenderHostileNesting(t *testing.T) {
+	var nested strings.Builder
+	for i := 0; i < 200; i++ {
+		nested.WriteString(strings.Repeat("  ", i) + "- x\n")
+	}
+	// Each must finish quickly; a quadratic or unbounded recursion shows up
+	// as a timeout.
+	for name, src := range map[string]string{
+		"quotes":      strings.Repeat(">", 100000) + " x",
+		"lists":       strings.Repeat("- ", 100000) + "x",
+		"nested list": nested.String(),
+		"stars":       strings.Repeat("*a ", 100000),
+		"emphasis":    strings.Repeat("*a ", 50000) + strings.Repeat("a* ", 50000),
+		"brackets":    strings.Repeat("[", 100000) + strings.Repeat("]", 100000),
+		"links":       strings.Repeat("[a](", 100000),
+		"backticks":   strings.Repeat("` ``", 100000),
+		"autolinks":   strings.Repeat("<http://", 100000),
+	} {
+		if Render(src) == "" {
+			t.Errorf("%s: empty output", name)
+		}
+	}
+}
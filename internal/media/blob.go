@@ -0,0 +1,264 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("media: blob not found")
+
+// BlobStore keeps uploaded files under slash-separated keys such as
+// "ab/ab…ef.jpg". Delete of a missing key is not an error.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Open(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+	// List calls fn with every key in the store.
+	List(fn func(key string) error) error
+	// SignedURL returns a URL that serves key until ttl has passed.
+	SignedURL(key string, ttl time.Duration) string
+}
+
+// ContentType is the media type a blob with key is served as.
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Disk keeps blobs as files under Dir and serves them itself: SignedURL
+// points below URL and carries an HMAC of the key and expiry made with
+// Secret, which ServeHTTP checks.
+type Disk struct {
+	Dir    string
+	URL    string
+	Secret []byte
+
+	now func() time.Time
+}
+
+func (d *Disk) file(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(d.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes data through a temporary file, so a reader never sees half a
+// file.
+func (d *Disk) Put(key string, data []byte) error {
+	name, err := d.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (d *Disk) Open(key string) (io.ReadCloser, error) {
+	name, err := d.file(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d *Disk) Exists(key string) (bool, error) {
+	name, err := d.file(key)
+	if err != nil {
+		return false, nil
+	}
+	_, err = os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *Disk) Delete(key string) error {
+	name, err := d.file(key)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks Dir, skipping temporary files of writes in progress.
+func (d *Disk) List(fn func(key string) error) error {
+	err := filepath.WalkDir(d.Dir, func(name string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Dir, name)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d *Disk) SignedURL(key string, ttl time.Duration) string {
+	expires := strconv.FormatInt(d.clock().Add(ttl).Unix(), 10)
+	return d.URL + "/" + key + "?expires=" + expires + "&sig=" + d.sign(key, expires)
+}
+
+// ServeHTTP serves the blob named by the request path, which must have
+// had URL stripped, if its signature is valid and has not expired.
+func (d *Disk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get("expires")
+	left, err := d.check(key, expires, r.URL.Query().Get("sig"))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	name, err := d.file(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(key))
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(left.Seconds())))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+var errBadSignature = errors.New("media: bad or expired signature")
+
+// check verifies a signed URL and returns how long it stays valid.
+func (d *Disk) check(key, expires, sig string) (time.Duration, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0, errBadSignature
+	}
+	left := time.Unix(unix, 0).Sub(d.clock())
+	if left <= 0 || !hmac.Equal([]byte(sig), []byte(d.sign(key, expires))) {
+		return 0, errBadSignature
+	}
+	return left, nil
+}
+
+func (d *Disk) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, d.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Disk) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+// Migrate copies every blob of src that dst does not have yet. With move
+// it then deletes the blob from src, copied or not, so src ends up empty.
+func Migrate(dst, src BlobStore, move bool) (copied, skipped int, err error) {
+	var keys []string
+	if err := src.List(func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	for _, key := range keys {
+		ok, err := dst.Exists(key)
+		if err != nil {
+			return copied, skipped, err
+		}
+		if ok {
+			skipped++
+		} else {
+			if err := copyBlob(dst, src, key); err != nil {
+				return copied, skipped, fmt.Errorf("media: copy %s: %w", key, err)
+			}
+			copied++
+		}
+		if move {
+			if err := src.Delete(key); err != nil {
+				return copied, skipped, err
+			}
+		}
+	}
+	return copied, skipped, nil
+}
+
+func copyBlob(dst, src BlobStore, key string) error {
+	rc, err := src.Open(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	return dst.Put(key, data)
+}
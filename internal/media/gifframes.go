@@ -0,0 +1,61 @@
+package media
+
+// gifFrames counts the image descriptors in a GIF by walking its block
+// stream, without decompressing any pixels. It stops once the count
+// passes limit and returns ok=false for a stream it cannot follow.
+func gifFrames(data []byte, limit int) (n int, ok bool) {
+	// Header and logical screen descriptor.
+	if len(data) < 13 {
+		return 0, false
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+
+	// skipSubBlocks moves past a run of data sub-blocks and its terminator.
+	skipSubBlocks := func() bool {
+		for i < len(data) {
+			size := int(data[i])
+			i++
+			if size == 0 {
+				return true
+			}
+			i += size
+		}
+		return false
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension: introducer, label, sub-blocks.
+			i += 2
+			if !skipSubBlocks() {
+				return n, false
+			}
+		case 0x2C: // Image descriptor, optional local table, LZW data.
+			if i+10 > len(data) {
+				return n, false
+			}
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&7 + 1)
+			}
+			// LZW minimum code size.
+			i++
+			if !skipSubBlocks() {
+				return n, false
+			}
+			n++
+			if n > limit {
+				return n, true
+			}
+		case 0x3B: // Trailer.
+			return n, true
+		default:
+			return n, false
+		}
+	}
+	return n, false
+}
@@ -0,0 +1,224 @@
+// Package media turns uploaded images into files that are safe to serve.
+// An upload is identified by sniffing its content, never by its name, and
+// only JPEG, PNG, GIF and WebP are accepted. It is decoded and encoded
+// again, which drops EXIF and every other piece of metadata, in a display
+// size and a thumbnail. Files are named by the hash of their content.
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxUploadSize is the largest upload accepted, in bytes.
+	MaxUploadSize = 20 << 20
+	// MaxPixels bounds width × height (times frames for an animated GIF),
+	// so a small file cannot decode into gigabytes.
+	MaxPixels = 40_000_000
+	// DisplaySize and ThumbSize bound the longest side of the two
+	// versions stored for an upload.
+	DisplaySize = 1280
+	ThumbSize   = 320
+)
+
+var (
+	ErrUnsupported = errors.New("media: not a JPEG, PNG, GIF or WebP image")
+	ErrTooLarge    = errors.New("media: image is too large")
+)
+
+// Image is a processed upload. Display is JPEG, PNG or GIF as given by Ext;
+// Thumb is encoded as ThumbExt(Ext).
+type Image struct {
+	Hash          string
+	Ext           string
+	Width, Height int
+	Display       []byte
+	Thumb         []byte
+}
+
+// ThumbExt is the extension of the thumbnail of a display image with ext.
+// Thumbnails of GIFs are still PNGs.
+func ThumbExt(ext string) string {
+	if ext == ".gif" {
+		return ".png"
+	}
+	return ext
+}
+
+// Process reads an upload of at most MaxUploadSize bytes and returns its
+// display and thumbnail versions. It returns ErrUnsupported for anything
+// that is not a well-formed image of an accepted type and ErrTooLarge for
+// uploads over the size or pixel limits.
+func Process(r io.Reader) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+
+	sniffed := map[string]string{
+		"image/jpeg": "jpeg",
+		"image/png":  "png",
+		"image/gif":  "gif",
+		"image/webp": "webp",
+	}[http.DetectContentType(data)]
+	if sniffed == "" {
+		return nil, ErrUnsupported
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != sniffed {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	if format == "gif" {
+		return processGIF(data, cfg)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	// Pixels lose the EXIF orientation, so apply it first.
+	display := fit(src, DisplaySize)
+	if format == "jpeg" {
+		display = orient(display, jpegOrientation(data))
+	}
+
+	ext := ".png"
+	if format == "jpeg" || (format == "webp" && opaque(display)) {
+		ext = ".jpg"
+	}
+	img := &Image{Ext: ext, Width: display.Bounds().Dx(), Height: display.Bounds().Dy()}
+	if img.Display, err = encode(display, ext); err != nil {
+		return nil, err
+	}
+	if img.Thumb, err = encode(fit(display, ThumbSize), ext); err != nil {
+		return nil, err
+	}
+	img.Hash = hash(img.Display)
+	return img, nil
+}
+
+// processGIF keeps an animation that already fits the display size and
+// otherwise shows its first frame.
+func processGIF(data []byte, cfg image.Config) (*Image, error) {
+	// Count frames before decoding: every frame may be as large as the
+	// canvas, and DecodeAll allocates them all.
+	limit := MaxPixels / (cfg.Width * cfg.Height)
+	frames, ok := gifFrames(data, limit)
+	if !ok || frames == 0 {
+		return nil, ErrUnsupported
+	}
+	if frames > limit {
+		return nil, ErrTooLarge
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(g.Image) == 0 {
+		return nil, ErrUnsupported
+	}
+
+	// The first frame may cover only part of the canvas.
+	first := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	draw.Draw(first, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+
+	img := &Image{Ext: ".gif"}
+	var buf bytes.Buffer
+	if len(g.Image) > 1 && cfg.Width <= DisplaySize && cfg.Height <= DisplaySize {
+		// Encoding again keeps frames, delays and the loop count but
+		// drops comments and application extensions.
+		err = gif.EncodeAll(&buf, &gif.GIF{
+			Image:           g.Image,
+			Delay:           g.Delay,
+			LoopCount:       g.LoopCount,
+			Disposal:        g.Disposal,
+			Config:          g.Config,
+			BackgroundIndex: g.BackgroundIndex,
+		})
+		img.Width, img.Height = cfg.Width, cfg.Height
+	} else {
+		display := fit(first, DisplaySize)
+		err = gif.Encode(&buf, display, nil)
+		img.Width, img.Height = display.Bounds().Dx(), display.Bounds().Dy()
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Display = buf.Bytes()
+
+	if img.Thumb, err = encode(fit(first, ThumbSize), ThumbExt(".gif")); err != nil {
+		return nil, err
+	}
+	img.Hash = hash(img.Display)
+	return img, nil
+}
+
+// fit scales src down so its longest side is at most max.
+func fit(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	if w >= h {
+		w, h = max, h*max/w
+	} else {
+		w, h = w*max/h, max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+func opaque(img image.Image) bool {
+	o, ok := img.(interface{ Opaque() bool })
+	return ok && o.Opaque()
+}
+
+func encode(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ext {
+	case ".jpg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	case ".gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,334 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// halves returns a w×h image whose left half is red and right half blue.
+func halves(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 segment holding an orientation tag and a camera
+// serial number right after the start of image marker.
+func withExif(data []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	// Orientation, SHORT, count 1.
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	// Body serial number, ASCII, count 4, stored inline.
+	binary.Write(&tiff, binary.BigEndian, []uint16{0xA431, 2})
+	binary.Write(&tiff, binary.BigEndian, uint32(4))
+	tiff.WriteString("SN1\x00")
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	valid := encodePNG(t, halves(8, 8))
+	tests := map[string][]byte{
+		"text":      []byte("just some text, named cat.png by the client"),
+		"html":      []byte("<!DOCTYPE html><script>alert(1)</script>"),
+		"svg":       []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+		"truncated": valid[:len(valid)/2],
+		"empty":     nil,
+		"bmp":       append([]byte("BM"), make([]byte, 64)...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Process(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("Process err = %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestProcessTooLarge(t *testing.T) {
+	big := bytes.NewReader(make([]byte, MaxUploadSize+1))
+	if _, err := Process(big); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized upload err = %v, want ErrTooLarge", err)
+	}
+
+	// A tiny PNG can claim an enormous canvas.
+	data := encodePNG(t, halves(2, 2))
+	binary.BigEndian.PutUint32(data[16:], 100_000)
+	binary.BigEndian.PutUint32(data[20:], 100_000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := Process(bytes.NewReader(data)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("pixel bomb err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestProcessJPEGAppliesOrientationAndStripsExif(t *testing.T) {
+	data := withExif(encodeJPEG(t, halves(40, 20)), 6)
+	if o := jpegOrientation(data); o != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", o)
+	}
+
+	img, err := Process(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Ext != ".jpg" || img.Width != 20 || img.Height != 40 {
+		t.Fatalf("got %s %dx%d, want .jpg 20x40", img.Ext, img.Width, img.Height)
+	}
+	if bytes.Contains(img.Display, []byte("Exif")) || bytes.Contains(img.Display, []byte("SN1")) {
+		t.Fatal("display image still carries EXIF")
+	}
+
+	out, err := jpeg.Decode(bytes.NewReader(img.Display))
+	if err != nil {
+		t.Fatalf("decode display: %v", err)
+	}
+	// Turned clockwise, the red left half ends up on top.
+	if !isRed(out.At(10, 5)) || isRed(out.At(10, 35)) {
+		t.Fatal("image was not rotated clockwise")
+	}
+}
+
+func TestProcessResizes(t *testing.T) {
+	img, err := Process(bytes.NewReader(encodePNG(t, halves(2*DisplaySize, DisplaySize/2))))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Ext != ".png" || img.Width != DisplaySize || img.Height != DisplaySize/4 {
+		t.Fatalf("display = %s %dx%d", img.Ext, img.Width, img.Height)
+	}
+	thumb, err := png.DecodeConfig(bytes.NewReader(img.Thumb))
+	if err != nil {
+		t.Fatalf("decode thumb: %v", err)
+	}
+	if thumb.Width != ThumbSize || thumb.Height != ThumbSize/4 {
+		t.Fatalf("thumb = %dx%d", thumb.Width, thumb.Height)
+	}
+
+	// Small images are kept at their size.
+	img, err = Process(bytes.NewReader(encodePNG(t, halves(10, 6))))
+	if err != nil {
+		t.Fatalf("Process small: %v", err)
+	}
+	if img.Width != 10 || img.Height != 6 {
+		t.Fatalf("small display = %dx%d", img.Width, img.Height)
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	frame := func() *image.Paletted {
+		return image.NewPaletted(image.Rect(0, 0, 16, 8), palette)
+	}
+	anim := &gif.GIF{
+		Image:     []*image.Paletted{frame(), frame(), frame()},
+		Delay:     []int{10, 20, 30},
+		LoopCount: 0,
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(&buf)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Ext != ".gif" || img.Width != 16 || img.Height != 8 {
+		t.Fatalf("got %s %dx%d", img.Ext, img.Width, img.Height)
+	}
+	out, err := gif.DecodeAll(bytes.NewReader(img.Display))
+	if err != nil {
+		t.Fatalf("decode display: %v", err)
+	}
+	if len(out.Image) != 3 || out.Delay[2] != 30 {
+		t.Fatalf("animation lost: %d frames, delays %v", len(out.Image), out.Delay)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(img.Thumb)); err != nil {
+		t.Fatalf("thumbnail is not a PNG: %v", err)
+	}
+}
+
+// gifBomb returns a GIF whose canvas is w×h with the given number of
+// full-canvas frames. The frames hold no pixel data, so decoding any of
+// them fails; only counting them succeeds.
+func gifBomb(w, h, frames int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	binary.Write(&buf, binary.LittleEndian, []uint16{uint16(w), uint16(h)})
+	// Global table of two colours.
+	buf.Write([]byte{0x80, 0, 0})
+	buf.Write([]byte{0, 0, 0, 255, 255, 255})
+	for i := 0; i < frames; i++ {
+		buf.WriteByte(0x2C)
+		binary.Write(&buf, binary.LittleEndian, []uint16{0, 0, uint16(w), uint16(h)})
+		buf.Write([]byte{0, 2, 0})
+	}
+	buf.WriteByte(0x3B)
+	return buf.Bytes()
+}
+
+func TestProcessGIFFrameBomb(t *testing.T) {
+	data := gifBomb(6000, 6000, 40)
+	if len(data) > 1024 {
+		t.Fatalf("bomb is %d bytes", len(data))
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := Process(bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+	// Decoding would fail with ErrUnsupported after allocating a canvas.
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("frame bomb err = %v, want ErrTooLarge", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 8<<20 {
+		t.Fatalf("Process allocated %d bytes before rejecting", alloc)
+	}
+
+	// One such frame is within the limit and is decoded as usual.
+	if _, err := Process(bytes.NewReader(gifBomb(6000, 6000, 1))); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("single frame err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestProcessHashIsStable(t *testing.T) {
+	data := encodePNG(t, halves(12, 12))
+	a, err := Process(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Process(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Hash != b.Hash || len(a.Hash) != 64 {
+		t.Fatalf("hashes %q and %q", a.Hash, b.Hash)
+	}
+}
+
+func TestStore(t *testing.T) {
+	disk := &Disk{Dir: t.TempDir(), URL: "/media", Secret: []byte("secret")}
+	s := &Store{Blobs: disk, URLPrefix: "/media"}
+	img, err := Process(bytes.NewReader(encodePNG(t, halves(12, 12))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := s.Save(img)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	want := "/media/" + img.Hash[:2] + "/" + img.Hash + ".png"
+	if path != want {
+		t.Fatalf("Save = %q, want %q", path, want)
+	}
+	if again, err := s.Save(img); err != nil || again != path {
+		t.Fatalf("second Save = %q, %v", again, err)
+	}
+
+	display := filepath.Join(disk.Dir, img.Hash[:2], img.Hash+".png")
+	thumb := filepath.Join(disk.Dir, img.Hash[:2], img.Hash+"-thumb.png")
+	for _, name := range []string{display, thumb} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Mode().Perm() != 0o644 {
+			t.Fatalf("%s mode = %v", name, info.Mode().Perm())
+		}
+	}
+
+	full, small := s.URLs(path)
+	if !strings.HasPrefix(full, path+"?expires=") || !strings.HasPrefix(small, strings.TrimSuffix(path, ".png")+"-thumb.png?") {
+		t.Fatalf("URLs = %q, %q", full, small)
+	}
+	if full, small := s.URLs("/static/images/123.png"); full != "/static/images/123.png" || small != full {
+		t.Fatalf("URLs of a legacy path = %q, %q", full, small)
+	}
+
+	if !s.Owns(path) {
+		t.Fatal("store does not own its own path")
+	}
+	for _, p := range []string{
+		"/static/images/123.png",
+		"/media/../" + img.Hash[:2] + "/" + img.Hash + ".png",
+		"/media/00/" + img.Hash + ".png",
+		"/other/" + img.Hash[:2] + "/" + img.Hash + ".png",
+	} {
+		if s.Owns(p) {
+			t.Fatalf("store owns %q", p)
+		}
+		if err := s.Delete(p); err != nil {
+			t.Fatalf("Delete(%q): %v", p, err)
+		}
+	}
+
+	if err := s.Delete(path); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for _, name := range []string{display, thumb} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists: %v", name, err)
+		}
+	}
+	if err := s.Delete(path); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package media
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 keeps blobs in a bucket of an S3-compatible service such as AWS S3 or
+// MinIO, signing requests with AWS Signature Version 4. Endpoint is the
+// service's base URL. With PathStyle the bucket goes in the path
+// (http://host/bucket/key), which most self-hosted services expect;
+// otherwise it goes in the host name (http://bucket.host/key).
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Client    *http.Client
+
+	now func() time.Time
+}
+
+// unsignedPayload stands in for the body hash of presigned URLs.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// MaxURLTTL is the longest a presigned URL may last; S3 rejects longer
+// ones.
+const MaxURLTTL = 7 * 24 * time.Hour
+
+func (s *S3) Put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, data, http.Header{"Content-Type": {ContentType(key)}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Delete succeeds for missing keys, as S3 itself does.
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List pages through the bucket with ListObjectsV2.
+func (s *S3) List(fn func(key string) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("media: s3 list: %w", err)
+		}
+
+		for _, c := range page.Contents {
+			if err := fn(c.Key); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// SignedURL presigns a GET of key. ttl is kept between a second and
+// MaxURLTTL, the range S3 accepts.
+func (s *S3) SignedURL(key string, ttl time.Duration) string {
+	ttl = min(max(ttl, time.Second), MaxURLTTL)
+
+	u := s.objectURL(key)
+	now := s.clock().UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.AccessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(ttl.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u.RawQuery = canonicalQuery(query)
+	header := http.Header{"Host": {u.Host}}
+	sig := s.signature(http.MethodGet, u, header, unsignedPayload, now)
+	u.RawQuery += "&X-Amz-Signature=" + sig
+	return u.String()
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+	u, err := url.Parse(strings.TrimRight(s.Endpoint, "/"))
+	if err != nil {
+		u = &url.URL{}
+	}
+	if s.PathStyle {
+		u.Path += "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+	return u
+}
+
+// do sends a signed request and turns a 404 into ErrNotFound and any other
+// failure into an error carrying S3's message.
+func (s *S3) do(method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := s.objectURL(key)
+	if key == "" && s.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]))
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("media: s3 %s %s: %s %s", method, u.Path, resp.Status, bytes.TrimSpace(msg))
+}
+
+// sign adds the SigV4 Authorization header to req, covering the host, the
+// payload hash and the date.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.clock().UTC()
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+
+	signed := http.Header{}
+	for _, k := range []string{"Host", "X-Amz-Content-Sha256", "X-Amz-Date"} {
+		signed[k] = req.Header[k]
+	}
+	sig := s.signature(req.Method, req.URL, signed, payloadHash, now)
+	req.Header.Del("Host")
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(now), signedHeaders(signed), sig,
+	))
+}
+
+// signature computes the SigV4 signature of a request over header.
+func (s *S3) signature(method string, u *url.URL, header http.Header, payloadHash string, now time.Time) string {
+	var names []string
+	for k := range header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(header.Get(name)) + "\n")
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(path, false),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		s.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+}
+
+func (s *S3) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+func (s *S3) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func signedHeaders(header http.Header) string {
+	var names []string
+	for k := range header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+// canonicalQuery encodes query sorted by name, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters and,
+// unless encodeSlash, '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package media
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mediaRX matches the path of a stored display image: <prefix>/ab/ab…(64
+// hex digits).<ext>.
+var mediaRX = regexp.MustCompile(`/([0-9a-f]{2})/([0-9a-f]{64})(\.jpg|\.png|\.gif)$`)
+
+// Store keeps processed images in Blobs, two blobs per image, under a
+// directory named after the first two hex digits of its hash. Posts record
+// an image by its path, URLPrefix followed by the key of its display
+// version; URLs hands out short-lived URLs of both versions.
+type Store struct {
+	Blobs     BlobStore
+	URLPrefix string
+	// URLTTL is how long a URL from URLs stays valid.
+	URLTTL time.Duration
+}
+
+// Save stores img and returns the path of its display version. An image
+// that is already stored is not written again.
+func (s *Store) Save(img *Image) (string, error) {
+	key := img.Hash[:2] + "/" + img.Hash + img.Ext
+	for k, data := range map[string][]byte{key: img.Display, thumbKey(key): img.Thumb} {
+		ok, err := s.Blobs.Exists(k)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			continue
+		}
+		if err := s.Blobs.Put(k, data); err != nil {
+			return "", err
+		}
+	}
+	return s.URLPrefix + "/" + key, nil
+}
+
+// Owns reports whether path is a display image of this store.
+func (s *Store) Owns(path string) bool {
+	_, ok := s.key(path)
+	return ok
+}
+
+func (s *Store) key(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, s.URLPrefix)
+	if !ok {
+		return "", false
+	}
+	m := mediaRX.FindStringSubmatch(rest)
+	if m == nil || len(m[0]) != len(rest) || m[2][:2] != m[1] {
+		return "", false
+	}
+	return rest[1:], true
+}
+
+// Delete removes the image at path and its thumbnail. Paths the store does
+// not own, such as uploads from before it existed, are left alone.
+func (s *Store) Delete(path string) error {
+	key, ok := s.key(path)
+	if !ok {
+		return nil
+	}
+	for _, k := range []string{key, thumbKey(key)} {
+		if err := s.Blobs.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// URLs returns signed URLs of the image at path and its thumbnail. Paths
+// the store does not own are returned as they are.
+func (s *Store) URLs(path string) (image, thumb string) {
+	key, ok := s.key(path)
+	if !ok {
+		return path, path
+	}
+	ttl := s.URLTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return s.Blobs.SignedURL(key, ttl), s.Blobs.SignedURL(thumbKey(key), ttl)
+}
+
+// thumbKey turns the key or path of a display image into its thumbnail's.
+func thumbKey(key string) string {
+	dot := strings.LastIndexByte(key, '.')
+	return key[:dot] + "-thumb" + ThumbExt(key[dot:])
+}
@@ -0,0 +1,342 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var (
+	ErrChecksumMismatch = errors.New("migrations: applied migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("migrations: applied version has no migration file")
+	ErrNoDownStep       = errors.New("migrations: migration has no down step")
+)
+
+var fileNameRX = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool
+	Missing   bool
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded in this package.
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: list}, nil
+}
+
+// Load reads NNNN_name.up.sql / NNNN_name.down.sql pairs from the root of fsys
+// and returns them ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNameRX.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: unexpected file name %q", entry.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: invalid version in %q", entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d has conflicting names %q and %q", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrations: version %d has no up step", mig.Version)
+		}
+		mig.Checksum = checksum(mig.Up)
+		list = append(list, *mig)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list, nil
+}
+
+func checksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) ensureTable() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`
+
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, checksum, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Verify checks that every applied migration still has a file and that its
+// up step has not been modified since it ran.
+func (m *Migrator) Verify() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	return m.verify(applied)
+}
+
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		known[mig.Version] = mig
+	}
+
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+		if mig.Checksum != a.checksum {
+			return fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+
+	return nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	return m.UpSteps(0)
+}
+
+// UpSteps applies at most steps pending migrations; steps <= 0 means all.
+func (m *Migrator) UpSteps(steps int) (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.Migrations {
+		if steps > 0 && count == steps {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.apply(mig); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) apply(mig Migration) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(mig.Up); err != nil {
+		return fmt.Errorf("migrations: apply %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	stmt := `INSERT INTO schema_migrations (version, name, checksum, applied_at)
+	VALUES (?, ?, ?, ?);`
+
+	if _, err := tx.Exec(stmt, mig.Version, mig.Name, mig.Checksum, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Down reverts the latest steps applied migrations (at least one), each in
+// its own transaction, and returns how many were reverted.
+func (m *Migrator) Down(steps int) (int, error) {
+	if steps < 1 {
+		steps = 1
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.Migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.Migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if err := m.revert(mig); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("%w: %04d_%s", ErrNoDownStep, mig.Version, mig.Name)
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(mig.Down); err != nil {
+		return fmt.Errorf("migrations: revert %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?;`, mig.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Status lists every known migration with its applied state, followed by any
+// applied versions that no longer have a file.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	known := make(map[int]bool, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt
+			s.Modified = a.checksum != mig.Checksum
+		}
+		statuses = append(statuses, s)
+	}
+
+	var missing []Status
+	for version, a := range applied {
+		if !known[version] {
+			missing = append(missing, Status{Version: version, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Version < missing[j].Version
+	})
+
+	return append(statuses, missing...), nil
+}
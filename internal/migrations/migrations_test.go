@@ -0,0 +1,196 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrations_test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER); INSERT INTO b VALUES (1);")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		t.Fatalf("query sqlite_master: %v", err)
+	}
+	return count == 1
+}
+
+func TestLoad(t *testing.T) {
+	list, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(list) != 2 || list[0].Version != 1 || list[1].Name != "create_b" {
+		t.Fatalf("unexpected migrations: %#v", list)
+	}
+	if list[0].Checksum == "" || list[0].Checksum == list[1].Checksum {
+		t.Fatalf("unexpected checksums %q %q", list[0].Checksum, list[1].Checksum)
+	}
+
+	bad := []fstest.MapFS{
+		{"readme.md": {Data: []byte("x")}},
+		{"0001_a.down.sql": {Data: []byte("DROP TABLE a;")}},
+		{"0001_a.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("y")}},
+	}
+	for i, fsys := range bad {
+		if _, err := Load(fsys); err == nil {
+			t.Fatalf("case %d: expected load error", i)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsApplyAndRevert(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	n, err := m.Up()
+	if err != nil || n != len(m.Migrations) {
+		t.Fatalf("Up: n=%d err=%v", n, err)
+	}
+	if _, err := db.Exec(`INSERT INTO roles (id, role, user_id) VALUES (1, 2, 1)`); err != nil {
+		t.Fatalf("roles.id column missing after migrations: %v", err)
+	}
+
+	n, err = m.Down(len(m.Migrations))
+	if err != nil || n != len(m.Migrations) {
+		t.Fatalf("Down: n=%d err=%v", n, err)
+	}
+	if tableExists(t, db, "forums") {
+		t.Fatal("expected forums table to be dropped")
+	}
+}
+
+func TestUpDownAndStatus(t *testing.T) {
+	db := newTestDB(t)
+	list, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	m := &Migrator{DB: db, Migrations: list}
+
+	n, err := m.UpSteps(1)
+	if err != nil || n != 1 {
+		t.Fatalf("UpSteps(1): n=%d err=%v", n, err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied || statuses[0].AppliedAt.IsZero() {
+		t.Fatalf("unexpected statuses: %#v", statuses)
+	}
+
+	n, err = m.Up()
+	if err != nil || n != 1 || !tableExists(t, db, "b") {
+		t.Fatalf("Up: n=%d err=%v", n, err)
+	}
+	n, err = m.Up()
+	if err != nil || n != 0 {
+		t.Fatalf("second Up should be a no-op: n=%d err=%v", n, err)
+	}
+
+	n, err = m.Down(0)
+	if err != nil || n != 1 || tableExists(t, db, "b") || !tableExists(t, db, "a") {
+		t.Fatalf("Down(0): n=%d err=%v", n, err)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	fsys := testFS()
+	fsys["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE c (id INTEGER); INSERT INTO missing VALUES (1);")}
+	list, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	m := &Migrator{DB: db, Migrations: list}
+
+	n, err := m.Up()
+	if err == nil || n != 2 {
+		t.Fatalf("expected failure on third migration: n=%d err=%v", n, err)
+	}
+	if tableExists(t, db, "c") {
+		t.Fatal("expected partial migration to be rolled back")
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if statuses[2].Applied {
+		t.Fatal("failed migration must not be recorded")
+	}
+
+	if _, err := m.Down(1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	m.Migrations[0].Down = ""
+	if _, err := m.Down(1); !errors.Is(err, ErrNoDownStep) {
+		t.Fatalf("expected ErrNoDownStep, got %v", err)
+	}
+}
+
+func TestChecksumVerification(t *testing.T) {
+	db := newTestDB(t)
+	list, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	m := &Migrator{DB: db, Migrations: list}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	edited := testFS()
+	edited["0001_create_a.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER, name TEXT);")}
+	list, err = Load(edited)
+	if err != nil {
+		t.Fatalf("Load edited: %v", err)
+	}
+	m.Migrations = list
+	if _, err := m.Up(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	statuses, err := m.Status()
+	if err != nil || !statuses[0].Modified {
+		t.Fatalf("expected modified status: %#v err=%v", statuses, err)
+	}
+
+	m.Migrations = list[1:]
+	if err := m.Verify(); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion, got %v", err)
+	}
+	statuses, err = m.Status()
+	if err != nil || len(statuses) != 2 || !statuses[1].Missing {
+		t.Fatalf("expected missing status: %#v err=%v", statuses, err)
+	}
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS forum_tags;
+DROP TABLE IF EXISTS forum_notifications;
+DROP TABLE IF EXISTS forum_likes;
+DROP TABLE IF EXISTS forum_comments;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS forums;
+DROP TABLE IF EXISTS users;
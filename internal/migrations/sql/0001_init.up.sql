@@ -3,7 +3,7 @@ CREATE TABLE IF NOT EXISTS forums (
     title TEXT NOT NULL,
     content TEXT NOT NULL,
     tags TEXT NOT NULL,
-    user_id INTEGER NOT NULL, 
+    user_id INTEGER NOT NULL,
     created DATETIME NOT NULL,
     expires DATETIME NOT NULL,
     image_path TEXT,
@@ -65,4 +65,4 @@ CREATE TABLE IF NOT EXISTS forum_notifications (
 CREATE TABLE IF NOT EXISTS forum_tags (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     tags TEXT UNIQUE
-)
\ No newline at end of file
+);
@@ -0,0 +1 @@
+ALTER TABLE roles RENAME COLUMN id TO "if";
@@ -0,0 +1 @@
+ALTER TABLE roles RENAME COLUMN "if" TO id;
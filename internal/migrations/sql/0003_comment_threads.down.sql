@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS forum_comments_forum_path_idx;
+
+ALTER TABLE forum_comments DROP COLUMN path;
+ALTER TABLE forum_comments DROP COLUMN depth;
+ALTER TABLE forum_comments DROP COLUMN parent_id;
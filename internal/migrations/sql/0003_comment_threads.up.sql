@@ -0,0 +1,7 @@
+ALTER TABLE forum_comments ADD COLUMN parent_id INTEGER;
+ALTER TABLE forum_comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE forum_comments ADD COLUMN path TEXT NOT NULL DEFAULT '';
+
+UPDATE forum_comments SET path = printf('%010d', id);
+
+CREATE INDEX IF NOT EXISTS forum_comments_forum_path_idx ON forum_comments (forum_id, path);
@@ -0,0 +1,11 @@
+ALTER TABLE forums ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+
+UPDATE forums SET tags = COALESCE((
+    SELECT GROUP_CONCAT(t.tags, ', ' ORDER BY t.tags)
+    FROM forum_post_tags pt
+    JOIN forum_tags t ON t.id = pt.tag_id
+    WHERE pt.forum_id = forums.id
+), '');
+
+DROP INDEX IF EXISTS forum_post_tags_tag_idx;
+DROP TABLE IF EXISTS forum_post_tags;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS forum_post_tags (
+    forum_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (forum_id, tag_id),
+    FOREIGN KEY (forum_id) REFERENCES forums (id),
+    FOREIGN KEY (tag_id) REFERENCES forum_tags (id)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS forum_post_tags_tag_idx ON forum_post_tags (tag_id, forum_id);
+
+CREATE TEMP TABLE forum_tags_split AS
+WITH RECURSIVE split (forum_id, tag, rest) AS (
+    SELECT id, '', tags || ',' FROM forums
+    UNION ALL
+    SELECT forum_id,
+           trim(substr(rest, 1, instr(rest, ',') - 1)),
+           substr(rest, instr(rest, ',') + 1)
+    FROM split
+    WHERE rest <> ''
+)
+SELECT DISTINCT forum_id, tag FROM split WHERE tag <> '';
+
+INSERT OR IGNORE INTO forum_tags (tags)
+SELECT DISTINCT tag FROM forum_tags_split;
+
+INSERT OR IGNORE INTO forum_post_tags (forum_id, tag_id)
+SELECT s.forum_id, t.id
+FROM forum_tags_split s
+JOIN forum_tags t ON t.tags = s.tag;
+
+DROP TABLE forum_tags_split;
+
+ALTER TABLE forums DROP COLUMN tags;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS forums_activity_idx;
+
+ALTER TABLE forums DROP COLUMN last_activity;
@@ -0,0 +1,5 @@
+ALTER TABLE forums ADD COLUMN last_activity DATETIME;
+
+UPDATE forums SET last_activity = created;
+
+CREATE INDEX IF NOT EXISTS forums_activity_idx ON forums (COALESCE(last_activity, created), id);
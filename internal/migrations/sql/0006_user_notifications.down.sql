@@ -0,0 +1,10 @@
+INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
+SELECT COALESCE(u.name, ''), n.body, 'reply', n.forum_id, n.recipient_id, n.actor_id
+FROM user_notifications n
+LEFT JOIN users u ON u.id = n.actor_id
+WHERE n.event = 'comment-reply'
+ORDER BY n.id;
+
+DROP INDEX IF EXISTS user_notifications_recipient_idx;
+
+DROP TABLE IF EXISTS user_notifications;
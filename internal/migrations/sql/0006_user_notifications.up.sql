@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS user_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient_id INTEGER NOT NULL,
+    actor_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    forum_id INTEGER NOT NULL,
+    comment_id INTEGER NOT NULL DEFAULT 0,
+    body TEXT NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    read_at DATETIME,
+    FOREIGN KEY (recipient_id) REFERENCES users (id),
+    FOREIGN KEY (actor_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS user_notifications_recipient_idx
+    ON user_notifications (recipient_id, read_at, id);
+
+-- Reply notifications used to share the moderation queue; move them into
+-- the recipient's inbox.
+INSERT INTO user_notifications (recipient_id, actor_id, event, forum_id, body, created)
+SELECT user_id, user_not_id, 'comment-reply', CAST(forum_link AS INTEGER), body,
+    strftime('%Y-%m-%d %H:%M:%S', 'now')
+FROM forum_notifications
+WHERE status = 'reply'
+ORDER BY id;
+
+DELETE FROM forum_notifications WHERE status = 'reply';
@@ -0,0 +1,16 @@
+INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
+SELECT COALESCE(u.name, ''), TRIM(REPLACE(r.reasons, ',', ', ') || ' ' || r.details), 'admin',
+    r.forum_id, r.reporter_id, COALESCE(f.user_id, 0)
+FROM reports r
+LEFT JOIN forums f ON f.id = r.forum_id
+LEFT JOIN users u ON u.id = f.user_id
+WHERE r.state IN ('open', 'in-review')
+ORDER BY r.id;
+
+DROP TRIGGER IF EXISTS moderation_actions_no_delete;
+DROP TRIGGER IF EXISTS moderation_actions_no_update;
+DROP INDEX IF EXISTS moderation_actions_report_idx;
+DROP TABLE IF EXISTS moderation_actions;
+
+DROP INDEX IF EXISTS reports_state_idx;
+DROP TABLE IF EXISTS reports;
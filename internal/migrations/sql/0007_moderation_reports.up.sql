@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    forum_id INTEGER NOT NULL,
+    reporter_id INTEGER NOT NULL,
+    reasons TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL DEFAULT 'open'
+        CHECK (state IN ('open', 'in-review', 'resolved', 'dismissed')),
+    assignee_id INTEGER NOT NULL DEFAULT 0,
+    resolution TEXT NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    FOREIGN KEY (forum_id) REFERENCES forums (id),
+    FOREIGN KEY (reporter_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS reports_state_idx ON reports (state, id);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    report_id INTEGER NOT NULL DEFAULT 0,
+    forum_id INTEGER NOT NULL DEFAULT 0,
+    target_user_id INTEGER NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT '',
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_report_idx ON moderation_actions (report_id, id);
+
+-- The audit log is append-only.
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_update
+BEFORE UPDATE ON moderation_actions
+BEGIN
+    SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_delete
+BEFORE DELETE ON moderation_actions
+BEGIN
+    SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
+
+-- Reports used to be free text in the admin queue; carry the open ones over
+-- with the text as details.
+INSERT INTO reports (forum_id, reporter_id, details, created, updated)
+SELECT CAST(forum_link AS INTEGER), user_id, TRIM(body),
+    strftime('%Y-%m-%d %H:%M:%S', 'now'), strftime('%Y-%m-%d %H:%M:%S', 'now')
+FROM forum_notifications
+WHERE status = 'admin' AND CAST(forum_link AS INTEGER) > 0
+ORDER BY id;
+
+DELETE FROM forum_notifications WHERE status = 'admin' AND CAST(forum_link AS INTEGER) > 0;
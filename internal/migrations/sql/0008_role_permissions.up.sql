@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role INTEGER NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+-- The grants the hard-coded role checks used to make; see policy.Defaults.
+INSERT OR IGNORE INTO role_permissions (role, permission) VALUES
+    (2, 'moderation.request'),
+    (3, 'post.approve'),
+    (3, 'report.file'),
+    (3, 'report.review'),
+    (3, 'moderation.queue'),
+    (4, 'post.publish'),
+    (4, 'post.edit.any'),
+    (4, 'post.delete.any'),
+    (4, 'post.approve'),
+    (4, 'comment.edit.any'),
+    (4, 'comment.delete.any'),
+    (4, 'report.file'),
+    (4, 'report.review'),
+    (4, 'report.assign'),
+    (4, 'report.resolve'),
+    (4, 'moderation.queue'),
+    (4, 'role.assign'),
+    (4, 'tag.manage'),
+    (4, 'audit.read');
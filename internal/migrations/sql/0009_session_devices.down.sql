@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS sessions_user_idx;
+DROP TABLE IF EXISTS sessions;
+
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry TEXT NOT NUll
+);
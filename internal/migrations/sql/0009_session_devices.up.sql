@@ -0,0 +1,19 @@
+-- Sessions are now one row per device. The cookie token is only kept as a
+-- SHA-256 hash, so the old plaintext sessions cannot be carried over and
+-- everyone signs in again.
+DROP TABLE IF EXISTS sessions;
+
+CREATE TABLE sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    max_expiry DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id, expiry);
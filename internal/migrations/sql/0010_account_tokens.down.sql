@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS account_tokens_user_idx;
+DROP TABLE IF EXISTS account_tokens;
+
+ALTER TABLE users DROP COLUMN email_verified;
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN email_verified DATETIME;
+
+-- Single-use links mailed to users: password resets and email
+-- verification. Only the SHA-256 of the token is kept.
+CREATE TABLE IF NOT EXISTS account_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    purpose TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    used DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS account_tokens_user_idx ON account_tokens (user_id, purpose);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_identities;
+
+ALTER TABLE users DROP COLUMN has_password;
@@ -0,0 +1,17 @@
+-- Accounts created through Google or GitHub never had a password the user
+-- knew; has_password keeps them from unlinking their only way in.
+ALTER TABLE users ADD COLUMN has_password INTEGER NOT NULL DEFAULT 1;
+
+-- One row per external login: the provider's stable user id (subject)
+-- signs in as user_id. A user links each provider at most once.
+CREATE TABLE IF NOT EXISTS oauth_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
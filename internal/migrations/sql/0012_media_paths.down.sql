@@ -0,0 +1,3 @@
+UPDATE forums
+SET image_path = '/static/media/' || substr(image_path, length('/media/') + 1)
+WHERE image_path LIKE '/media/%';
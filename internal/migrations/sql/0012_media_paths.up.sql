@@ -0,0 +1,5 @@
+-- Uploads moved out of the static file server into a blob store served at
+-- /media. Keys are unchanged; move the files with `forum media migrate`.
+UPDATE forums
+SET image_path = '/media/' || substr(image_path, length('/static/media/') + 1)
+WHERE image_path LIKE '/static/media/%';
@@ -0,0 +1,12 @@
+DELETE FROM role_permissions WHERE permission IN ('history.read', 'history.restore');
+
+DROP INDEX IF EXISTS comment_revisions_comment_idx;
+DROP TABLE IF EXISTS comment_revisions;
+DROP INDEX IF EXISTS forum_revisions_image_idx;
+DROP INDEX IF EXISTS forum_revisions_forum_idx;
+DROP TABLE IF EXISTS forum_revisions;
+
+ALTER TABLE forum_comments DROP COLUMN edited_by;
+ALTER TABLE forum_comments DROP COLUMN edited;
+ALTER TABLE forums DROP COLUMN edited_by;
+ALTER TABLE forums DROP COLUMN edited;
@@ -0,0 +1,41 @@
+-- When a post or comment was last edited, and by whom; NULL if never.
+ALTER TABLE forums ADD COLUMN edited DATETIME;
+ALTER TABLE forums ADD COLUMN edited_by INTEGER;
+ALTER TABLE forum_comments ADD COLUMN edited DATETIME;
+ALTER TABLE forum_comments ADD COLUMN edited_by INTEGER;
+
+-- Every version of a post an edit replaced: who wrote it and when (NULL
+-- for comments written before edits were tracked).
+CREATE TABLE IF NOT EXISTS forum_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    forum_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    tags TEXT NOT NULL,
+    image_path TEXT NOT NULL DEFAULT '',
+    author_id INTEGER NOT NULL,
+    created DATETIME,
+    FOREIGN KEY (forum_id) REFERENCES forums (id),
+    FOREIGN KEY (author_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS forum_revisions_forum_idx ON forum_revisions (forum_id, id);
+CREATE INDEX IF NOT EXISTS forum_revisions_image_idx ON forum_revisions (image_path);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    comment TEXT NOT NULL,
+    author_id INTEGER NOT NULL,
+    created DATETIME,
+    FOREIGN KEY (comment_id) REFERENCES forum_comments (id),
+    FOREIGN KEY (author_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS comment_revisions_comment_idx ON comment_revisions (comment_id, id);
+
+-- See policy.Defaults.
+INSERT OR IGNORE INTO role_permissions (role, permission) VALUES
+    (3, 'history.read'),
+    (4, 'history.read'),
+    (4, 'history.restore');
@@ -0,0 +1,23 @@
+-- Nothing can be left in the trash, or it would come back to life: posts
+-- in it go for good and deleted comments keep only a placeholder.
+DELETE FROM comment_revisions WHERE comment_id IN (
+    SELECT c.id FROM forum_comments c JOIN forums f ON f.id = c.forum_id
+    WHERE c.deleted IS NOT NULL OR f.deleted IS NOT NULL);
+DELETE FROM forum_revisions WHERE forum_id IN (SELECT id FROM forums WHERE deleted IS NOT NULL);
+DELETE FROM forum_likes WHERE forum_id IN (SELECT id FROM forums WHERE deleted IS NOT NULL)
+    OR comment_id IN (SELECT c.id FROM forum_comments c JOIN forums f ON f.id = c.forum_id WHERE f.deleted IS NOT NULL);
+DELETE FROM user_notifications WHERE forum_id IN (SELECT id FROM forums WHERE deleted IS NOT NULL);
+DELETE FROM forum_notifications WHERE forum_link IN (SELECT id FROM forums WHERE deleted IS NOT NULL);
+DELETE FROM forum_comments WHERE forum_id IN (SELECT id FROM forums WHERE deleted IS NOT NULL);
+DELETE FROM forum_post_tags WHERE forum_id IN (SELECT id FROM forums WHERE deleted IS NOT NULL);
+DELETE FROM forums WHERE deleted IS NOT NULL;
+UPDATE forum_comments SET comment = '[deleted]' WHERE deleted IS NOT NULL;
+
+DROP INDEX IF EXISTS forum_comments_deleted_idx;
+DROP INDEX IF EXISTS forums_deleted_idx;
+
+ALTER TABLE forum_comments DROP COLUMN purged;
+ALTER TABLE forum_comments DROP COLUMN deleted_by;
+ALTER TABLE forum_comments DROP COLUMN deleted;
+ALTER TABLE forums DROP COLUMN deleted_by;
+ALTER TABLE forums DROP COLUMN deleted;
@@ -0,0 +1,12 @@
+-- Deleted posts and comments stay in the trash until they are restored or
+-- purged: when and by whom they were deleted; NULL while they are live.
+ALTER TABLE forums ADD COLUMN deleted DATETIME;
+ALTER TABLE forums ADD COLUMN deleted_by INTEGER;
+ALTER TABLE forum_comments ADD COLUMN deleted DATETIME;
+ALTER TABLE forum_comments ADD COLUMN deleted_by INTEGER;
+-- A purged comment that still has replies keeps its row, emptied, as the
+-- "[deleted]" placeholder of its thread.
+ALTER TABLE forum_comments ADD COLUMN purged DATETIME;
+
+CREATE INDEX IF NOT EXISTS forums_deleted_idx ON forums (deleted) WHERE deleted IS NOT NULL;
+CREATE INDEX IF NOT EXISTS forum_comments_deleted_idx ON forum_comments (deleted) WHERE deleted IS NOT NULL;
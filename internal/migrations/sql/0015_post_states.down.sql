@@ -0,0 +1,7 @@
+-- Only pending and published existed before; everything else is hidden.
+UPDATE forums SET status = 0 WHERE status NOT IN (0, 1);
+
+DROP INDEX IF EXISTS forums_user_status_idx;
+
+ALTER TABLE forums DROP COLUMN status_changed;
+ALTER TABLE forums DROP COLUMN status_reason;
@@ -0,0 +1,11 @@
+-- forums.status becomes a lifecycle state: 0 pending and 1 published as
+-- before, 2 draft, 3 rejected, 4 archived. status_reason is shown to the
+-- author of a rejected post; status_changed is when the state last moved.
+ALTER TABLE forums ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE forums ADD COLUMN status_changed DATETIME;
+
+-- Posts hidden by an upheld report were left pending; they were rejected.
+UPDATE forums SET status = 3, status_reason = 'Removed after a report.'
+WHERE status = 0 AND id IN (SELECT forum_id FROM reports WHERE state = 'resolved');
+
+CREATE INDEX IF NOT EXISTS forums_user_status_idx ON forums (user_id, status);
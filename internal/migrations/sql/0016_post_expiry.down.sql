@@ -0,0 +1,7 @@
+DELETE FROM role_permissions WHERE permission = 'expiry.manage';
+
+DROP INDEX IF EXISTS forums_status_expires_idx;
+
+ALTER TABLE forums DROP COLUMN expiry_reminded;
+
+DROP TABLE IF EXISTS expiry_policies;
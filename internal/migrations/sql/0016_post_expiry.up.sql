@@ -0,0 +1,22 @@
+-- The lifetimes offered when writing or renewing a post; days = 0 never
+-- expires. Exactly one is the default.
+CREATE TABLE IF NOT EXISTS expiry_policies (
+    days INTEGER PRIMARY KEY,
+    label TEXT NOT NULL,
+    is_default INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO expiry_policies (days, label, is_default) VALUES
+    (1, 'One Day', 0),
+    (7, 'One Week', 0),
+    (365, 'One Year', 1);
+
+-- When the author was reminded that the post is about to expire; cleared
+-- by a renewal.
+ALTER TABLE forums ADD COLUMN expiry_reminded DATETIME;
+
+CREATE INDEX IF NOT EXISTS forums_status_expires_idx ON forums (status, expires);
+
+-- See policy.Defaults.
+INSERT OR IGNORE INTO role_permissions (role, permission) VALUES
+    (4, 'expiry.manage');
@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS forum_comments_count_delete;
+DROP TRIGGER IF EXISTS forum_comments_count_trash;
+DROP TRIGGER IF EXISTS forum_comments_count_insert;
+DROP TRIGGER IF EXISTS forum_likes_count_delete;
+DROP TRIGGER IF EXISTS forum_likes_count_update;
+DROP TRIGGER IF EXISTS forum_likes_count_insert;
+
+ALTER TABLE forum_comments DROP COLUMN dislikes_count;
+ALTER TABLE forum_comments DROP COLUMN likes_count;
+ALTER TABLE forums DROP COLUMN comments_count;
+ALTER TABLE forums DROP COLUMN dislikes_count;
+ALTER TABLE forums DROP COLUMN likes_count;
@@ -0,0 +1,87 @@
+-- Vote and comment counts kept on the rows they count, so pages and
+-- listings read them instead of aggregating forum_likes each time. The
+-- triggers below keep them in step; `forum counters repair` recomputes them.
+ALTER TABLE forums ADD COLUMN likes_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE forums ADD COLUMN dislikes_count INTEGER NOT NULL DEFAULT 0;
+-- Comments not in the trash.
+ALTER TABLE forums ADD COLUMN comments_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE forum_comments ADD COLUMN likes_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE forum_comments ADD COLUMN dislikes_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE forums SET
+    likes_count = (SELECT COUNT(*) FROM forum_likes l
+        WHERE l.forum_id = forums.id AND l.comment_id IS NULL AND l.like_status = 1),
+    dislikes_count = (SELECT COUNT(*) FROM forum_likes l
+        WHERE l.forum_id = forums.id AND l.comment_id IS NULL AND l.like_status = -1),
+    comments_count = (SELECT COUNT(*) FROM forum_comments c
+        WHERE c.forum_id = forums.id AND c.deleted IS NULL);
+
+UPDATE forum_comments SET
+    likes_count = (SELECT COUNT(*) FROM forum_likes l
+        WHERE l.comment_id = forum_comments.id AND l.like_status = 1),
+    dislikes_count = (SELECT COUNT(*) FROM forum_likes l
+        WHERE l.comment_id = forum_comments.id AND l.like_status = -1);
+
+-- A vote on a post has forum_id set and comment_id NULL; a vote on a
+-- comment has comment_id set.
+CREATE TRIGGER IF NOT EXISTS forum_likes_count_insert
+AFTER INSERT ON forum_likes
+BEGIN
+    UPDATE forums SET
+        likes_count = likes_count + (NEW.like_status = 1),
+        dislikes_count = dislikes_count + (NEW.like_status = -1)
+    WHERE id = NEW.forum_id AND NEW.comment_id IS NULL;
+    UPDATE forum_comments SET
+        likes_count = likes_count + (NEW.like_status = 1),
+        dislikes_count = dislikes_count + (NEW.like_status = -1)
+    WHERE id = NEW.comment_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_likes_count_update
+AFTER UPDATE OF like_status ON forum_likes
+BEGIN
+    UPDATE forums SET
+        likes_count = likes_count - (OLD.like_status = 1) + (NEW.like_status = 1),
+        dislikes_count = dislikes_count - (OLD.like_status = -1) + (NEW.like_status = -1)
+    WHERE id = NEW.forum_id AND NEW.comment_id IS NULL;
+    UPDATE forum_comments SET
+        likes_count = likes_count - (OLD.like_status = 1) + (NEW.like_status = 1),
+        dislikes_count = dislikes_count - (OLD.like_status = -1) + (NEW.like_status = -1)
+    WHERE id = NEW.comment_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_likes_count_delete
+AFTER DELETE ON forum_likes
+BEGIN
+    UPDATE forums SET
+        likes_count = likes_count - (OLD.like_status = 1),
+        dislikes_count = dislikes_count - (OLD.like_status = -1)
+    WHERE id = OLD.forum_id AND OLD.comment_id IS NULL;
+    UPDATE forum_comments SET
+        likes_count = likes_count - (OLD.like_status = 1),
+        dislikes_count = dislikes_count - (OLD.like_status = -1)
+    WHERE id = OLD.comment_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_comments_count_insert
+AFTER INSERT ON forum_comments
+WHEN NEW.deleted IS NULL
+BEGIN
+    UPDATE forums SET comments_count = comments_count + 1 WHERE id = NEW.forum_id;
+END;
+
+-- Moving a comment to the trash or restoring it.
+CREATE TRIGGER IF NOT EXISTS forum_comments_count_trash
+AFTER UPDATE OF deleted ON forum_comments
+WHEN (OLD.deleted IS NULL) <> (NEW.deleted IS NULL)
+BEGIN
+    UPDATE forums SET comments_count = comments_count + (NEW.deleted IS NULL) - (OLD.deleted IS NULL)
+    WHERE id = NEW.forum_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_comments_count_delete
+AFTER DELETE ON forum_comments
+WHEN OLD.deleted IS NULL
+BEGIN
+    UPDATE forums SET comments_count = comments_count - 1 WHERE id = OLD.forum_id;
+END;
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Purposes of an account token. A token only redeems for the purpose it
+// was issued for.
+const (
+	TokenPasswordReset = "password-reset"
+	TokenEmailVerify   = "email-verify"
+)
+
+// How long a mailed link stays valid.
+var (
+	PasswordResetTTL = time.Hour
+	EmailVerifyTTL   = 48 * time.Hour
+)
+
+// AccountTokenModel stores the single-use tokens behind password reset and
+// email verification links. Like sessions, only the token hash is stored.
+type AccountTokenModel struct {
+	DB *sql.DB
+}
+
+// Issue creates a token for userID valid for ttl. Earlier unused tokens of
+// the same purpose stop working, so only the newest link in the inbox does.
+func (m *AccountTokenModel) Issue(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := generateSessionID()
+
+	err := inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `UPDATE account_tokens SET used = ? WHERE user_id = ? AND purpose = ? AND used IS NULL`
+		if _, err := tx.ExecContext(ctx, stmt, sessionTime(now), userID, purpose); err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO account_tokens (token_hash, user_id, purpose, created, expiry)
+		VALUES (?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, stmt, hashSessionToken(token), userID, purpose, sessionTime(now), sessionTime(now.Add(ttl)))
+		return err
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume redeems token for purpose and returns its user. A token works
+// once; unknown, used, expired or wrong-purpose tokens give ErrInvalidToken.
+func (m *AccountTokenModel) Consume(ctx context.Context, token, purpose string) (int, error) {
+	now := sessionTime(time.Now())
+
+	stmt := `UPDATE account_tokens SET used = ?
+	WHERE token_hash = ? AND purpose = ? AND used IS NULL AND expiry > ?
+	RETURNING user_id`
+
+	var userID int
+	err := conn(ctx, m.DB).QueryRowContext(ctx, stmt, now, hashSessionToken(token), purpose, now).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Prune deletes tokens that were used or expired before now.
+func (m *AccountTokenModel) Prune(now time.Time) (int64, error) {
+	t := sessionTime(now)
+	result, err := m.DB.Exec("DELETE FROM account_tokens WHERE expiry <= ? OR used <= ?", t, t)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
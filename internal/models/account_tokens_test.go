@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccountTokensAreSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	model := &AccountTokenModel{DB: db}
+	u := seedUser(t, db, "token-user")
+
+	token, err := model.Issue(context.Background(), u, TokenPasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM account_tokens WHERE token_hash = ?`, token).Scan(&stored); err != nil {
+		t.Fatalf("query token: %v", err)
+	}
+	if stored != 0 {
+		t.Fatal("account token stored in plain text")
+	}
+
+	if _, err := model.Consume(context.Background(), token, TokenEmailVerify); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Consume with wrong purpose err = %v, want ErrInvalidToken", err)
+	}
+	got, err := model.Consume(context.Background(), token, TokenPasswordReset)
+	if err != nil || got != u {
+		t.Fatalf("Consume = %d, %v; want %d", got, err, u)
+	}
+	if _, err := model.Consume(context.Background(), token, TokenPasswordReset); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second Consume err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := model.Consume(context.Background(), "unknown", TokenPasswordReset); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Consume unknown err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAccountTokenReissueAndExpiry(t *testing.T) {
+	db := newTestDB(t)
+	model := &AccountTokenModel{DB: db}
+	u := seedUser(t, db, "reissue-user")
+
+	old, err := model.Issue(context.Background(), u, TokenEmailVerify, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	other, err := model.Issue(context.Background(), u, TokenPasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue reset: %v", err)
+	}
+	newer, err := model.Issue(context.Background(), u, TokenEmailVerify, time.Hour)
+	if err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+
+	if _, err := model.Consume(context.Background(), old, TokenEmailVerify); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("superseded token err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := model.Consume(context.Background(), newer, TokenEmailVerify); err != nil {
+		t.Fatalf("newest token: %v", err)
+	}
+	if _, err := model.Consume(context.Background(), other, TokenPasswordReset); err != nil {
+		t.Fatalf("token of another purpose was revoked: %v", err)
+	}
+
+	expired, err := model.Issue(context.Background(), u, TokenPasswordReset, -time.Minute)
+	if err != nil {
+		t.Fatalf("Issue expired: %v", err)
+	}
+	if _, err := model.Consume(context.Background(), expired, TokenPasswordReset); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token err = %v, want ErrInvalidToken", err)
+	}
+
+	live, err := model.Issue(context.Background(), u, TokenEmailVerify, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue live: %v", err)
+	}
+	removed, err := model.Prune(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 4 {
+		t.Fatalf("Prune removed %d, want 4", removed)
+	}
+	if _, err := model.Consume(context.Background(), live, TokenEmailVerify); err != nil {
+		t.Fatalf("Prune removed a live token: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+// forumCountColumns selects a post's counters, for listings to show with
+// no further queries.
+const forumCountColumns = `f.likes_count, f.dislikes_count, f.comments_count`
+
+// The counts the vote and comment counters on forums and forum_comments
+// should hold; triggers keep them in step as votes and comments change.
+const (
+	forumLikesCount    = `(SELECT COUNT(*) FROM forum_likes l WHERE l.forum_id = forums.id AND l.comment_id IS NULL AND l.like_status = 1)`
+	forumDislikesCount = `(SELECT COUNT(*) FROM forum_likes l WHERE l.forum_id = forums.id AND l.comment_id IS NULL AND l.like_status = -1)`
+	forumCommentsCount = `(SELECT COUNT(*) FROM forum_comments c WHERE c.forum_id = forums.id AND c.deleted IS NULL)`
+
+	commentLikesCount    = `(SELECT COUNT(*) FROM forum_likes l WHERE l.comment_id = forum_comments.id AND l.like_status = 1)`
+	commentDislikesCount = `(SELECT COUNT(*) FROM forum_likes l WHERE l.comment_id = forum_comments.id AND l.like_status = -1)`
+)
+
+// RepairCounters recomputes the vote and comment counters of every post and
+// comment whose counters drifted from the rows they count, in one
+// transaction. It returns how many posts and comments were corrected.
+func (m *ForumModel) RepairCounters() (posts, comments int, err error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE forums SET
+		likes_count = ` + forumLikesCount + `,
+		dislikes_count = ` + forumDislikesCount + `,
+		comments_count = ` + forumCommentsCount + `
+	WHERE likes_count <> ` + forumLikesCount + `
+		OR dislikes_count <> ` + forumDislikesCount + `
+		OR comments_count <> ` + forumCommentsCount
+	res, err := tx.Exec(stmt)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	posts = int(n)
+
+	stmt = `UPDATE forum_comments SET
+		likes_count = ` + commentLikesCount + `,
+		dislikes_count = ` + commentDislikesCount + `
+	WHERE likes_count <> ` + commentLikesCount + `
+		OR dislikes_count <> ` + commentDislikesCount
+	if res, err = tx.Exec(stmt); err != nil {
+		return 0, 0, err
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	comments = int(n)
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return posts, comments, nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import "testing"
+
+func TestVoteCounters(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	likes := &ForumLikesModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+
+	author := seedUser(t, db, "ola")
+	voters := []int{seedUser(t, db, "pia"), seedUser(t, db, "rui"), seedUser(t, db, "sam")}
+	forumID := seedForum(t, db, author, "counted", PostPublished, "go")
+	first := seedComment(t, db, forumID, author, "first")
+	second, err := comments.CommentPost(forumID, voters[0], "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vote := func(userID, status int) {
+		t.Helper()
+		if _, err := likes.LikeOrDislike(forumID, userID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vote(voters[0], 1)
+	vote(voters[1], 1)
+	vote(voters[2], -1)
+	vote(voters[1], -1) // changed
+	vote(voters[2], -1) // taken back
+	if _, err := likes.LikeOrDislikeComment(first, voters[0], -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := likes.LikeOrDislikeComment(first, voters[1], 1); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := forums.Get(forumID, voters[1], false)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if f.LikesCount != 1 || f.DislikesCount != 1 || f.CommentsCount != 2 {
+		t.Fatalf("post counters = %d/%d/%d, want 1/1/2", f.LikesCount, f.DislikesCount, f.CommentsCount)
+	}
+	if !f.Reacted || f.Liked {
+		t.Fatalf("viewer's vote = reacted %v liked %v, want a dislike", f.Reacted, f.Liked)
+	}
+	if len(f.Comment) != 2 {
+		t.Fatalf("%d comments", len(f.Comment))
+	}
+	c := f.Comment[0]
+	if c.CommentID != first || c.LikesCount != 1 || c.DislikesCount != 1 || !c.Reacted || !c.Liked {
+		t.Fatalf("first comment = %+v", c)
+	}
+	if c := f.Comment[1]; c.LikesCount != 0 || c.Reacted {
+		t.Fatalf("second comment = %+v", c)
+	}
+
+	// Comments in the trash are not counted; restored ones are again.
+	if err := comments.RemoveCommentPost(second, voters[0]); err != nil {
+		t.Fatal(err)
+	}
+	page, err := forums.List(ForumFilter{}, PageQuery{Sort: SortMostCommented, Limit: 10})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Forums) != 1 || page.Forums[0].CommentsCount != 1 || page.Forums[0].LikesCount != 1 {
+		t.Fatalf("listed = %+v", page.Forums)
+	}
+	if err := comments.RestoreCommentPost(second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Repair puts drifted counters back and leaves correct ones alone.
+	if _, err := db.Exec(`UPDATE forums SET likes_count = 40, comments_count = 0 WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE forum_comments SET dislikes_count = 7 WHERE id = ?`, first); err != nil {
+		t.Fatal(err)
+	}
+	posts, fixed, err := forums.RepairCounters()
+	if err != nil || posts != 1 || fixed != 1 {
+		t.Fatalf("RepairCounters = %d, %d, %v, want 1, 1", posts, fixed, err)
+	}
+	f, err = forums.Get(forumID, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.LikesCount != 1 || f.CommentsCount != 2 || f.Comment[0].DislikesCount != 1 {
+		t.Fatalf("repaired counters = %d/%d, comment %d", f.LikesCount, f.CommentsCount, f.Comment[0].DislikesCount)
+	}
+	if posts, fixed, _ := forums.RepairCounters(); posts != 0 || fixed != 0 {
+		t.Fatalf("second repair fixed %d, %d", posts, fixed)
+	}
+}
@@ -12,4 +12,14 @@ var (
 	ErrDuplicateEmail = errors.New("models: duplicate email")
 
 	ErrDuplicateName = errors.New("models: duplicate name")
+
+	ErrDuplicateTag = errors.New("models: duplicate tag")
+
+	ErrMaxDepth = errors.New("models: comment thread is too deep")
+
+	ErrInvalidToken = errors.New("models: invalid or expired token")
+
+	ErrIdentityTaken  = errors.New("models: login is linked to another account")
+	ErrProviderLinked = errors.New("models: a login of this provider is already linked")
+	ErrLastSignIn     = errors.New("models: cannot remove the only way to sign in")
 )
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// NeverExpires is the expiry stored for posts written with a lifetime of
+// 0 days, which never ends.
+var NeverExpires = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// expiresAfter is the expiry of a post written now to live for the days
+// given as its two arguments.
+const expiresAfter = `CASE WHEN ? = 0 THEN '9999-12-31 23:59:59'
+	ELSE strftime('%Y-%m-%d %H:%M:%S', 'now', '+' || ? || ' day') END`
+
+// ExpiryReminder is how long before a post expires its author is told.
+// ArchiveRetention is how long archived posts are kept before they are
+// purged.
+var (
+	ExpiryReminder   = 3 * 24 * time.Hour
+	ArchiveRetention = 365 * 24 * time.Hour
+)
+
+var (
+	ErrUnknownExpiry = errors.New("models: no such expiry policy")
+	ErrDefaultExpiry = errors.New("models: the default expiry policy cannot be removed")
+)
+
+// NeverExpires reports whether the post was written to live for ever.
+func (f *Forum) NeverExpires() bool {
+	return !f.Expires.Before(NeverExpires)
+}
+
+// Expired reports whether the post's lifetime has run out; expired posts
+// are read-only and, once the sweep has run, archived.
+func (f *Forum) Expired() bool {
+	return !f.Expires.IsZero() && !f.Expires.After(time.Now())
+}
+
+// Open reports whether the post takes comments and votes: it is published
+// and has not expired.
+func (f *Forum) Open() bool {
+	return f.State == postStates[PostPublished] && !f.Expired()
+}
+
+// Gone reports whether the post is in the read-only archive, or on its way
+// there once the next sweep runs.
+func (f *Forum) Gone() bool {
+	return f.State == postStates[PostArchived] || (f.State == postStates[PostPublished] && f.Expired())
+}
+
+// ExpiryPolicy is one lifetime offered for posts. Days is 0 for posts that
+// never expire.
+type ExpiryPolicy struct {
+	Days    int
+	Label   string
+	Default bool
+}
+
+type ExpiryModel struct {
+	DB   *sql.DB
+	Live Publisher
+}
+
+// Policies returns the offered lifetimes, shortest first and "never" last.
+func (m *ExpiryModel) Policies() ([]*ExpiryPolicy, error) {
+	stmt := `SELECT days, label, is_default FROM expiry_policies
+	ORDER BY days = 0, days`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*ExpiryPolicy{}
+	for rows.Next() {
+		p := &ExpiryPolicy{}
+		if err := rows.Scan(&p.Days, &p.Label, &p.Default); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// Policy returns the policy for days, or ErrUnknownExpiry.
+func (m *ExpiryModel) Policy(days int) (*ExpiryPolicy, error) {
+	stmt := `SELECT days, label, is_default FROM expiry_policies WHERE days = ?`
+
+	p := &ExpiryPolicy{}
+	err := m.DB.QueryRow(stmt, days).Scan(&p.Days, &p.Label, &p.Default)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnknownExpiry
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetPolicy offers a lifetime of days under label, or relabels it.
+func (m *ExpiryModel) SetPolicy(days int, label string) error {
+	stmt := `INSERT INTO expiry_policies (days, label) VALUES (?, ?)
+	ON CONFLICT (days) DO UPDATE SET label = excluded.label`
+
+	_, err := m.DB.Exec(stmt, days, label)
+	return err
+}
+
+// RemovePolicy stops offering a lifetime. Posts written with it keep their
+// expiry. The default cannot be removed.
+func (m *ExpiryModel) RemovePolicy(days int) error {
+	p, err := m.Policy(days)
+	if err != nil {
+		return err
+	}
+	if p.Default {
+		return ErrDefaultExpiry
+	}
+
+	_, err = m.DB.Exec(`DELETE FROM expiry_policies WHERE days = ?`, days)
+	return err
+}
+
+// SetDefault makes days the lifetime preselected on the post forms.
+func (m *ExpiryModel) SetDefault(days int) error {
+	if _, err := m.Policy(days); err != nil {
+		return err
+	}
+
+	_, err := m.DB.Exec(`UPDATE expiry_policies SET is_default = (days = ?)`, days)
+	return err
+}
+
+// Remind tells the author of every published post expiring by before, and
+// not yet reminded, in their inbox. It returns how many were told.
+func (m *ExpiryModel) Remind(before time.Time) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT id, user_id, expires FROM forums
+	WHERE status = ? AND deleted IS NULL AND expiry_reminded IS NULL
+		AND expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND expires <= ?`
+
+	rows, err := tx.Query(stmt, PostPublished, before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		forumID, ownerID int
+		expires          time.Time
+	}
+	var posts []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.forumID, &d.ownerID, &d.expires); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		posts = append(posts, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	live := &liveBatch{}
+	for _, d := range posts {
+		body := "Expires " + d.expires.UTC().Format("02 Jan 2006 at 15:04") + " UTC. Renew it to keep it listed."
+		if err := notify(tx, live, d.ownerID, 0, EventPostExpiring, d.forumID, 0, body); err != nil {
+			return 0, err
+		}
+		stmt := `UPDATE forums SET expiry_reminded = strftime('%Y-%m-%d %H:%M:%S', 'now') WHERE id = ?`
+		if _, err := tx.Exec(stmt, d.forumID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	live.publish(m.DB, m.Live)
+	return len(posts), nil
+}
+
+// Archive moves every published post that expired by now to the archive,
+// where it stays readable by URL but takes no comments or votes. It
+// returns how many were archived.
+func (m *ExpiryModel) Archive(now time.Time) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT id FROM forums WHERE status = ? AND deleted IS NULL AND expires <= ?`
+	rows, err := tx.Query(stmt, PostPublished, now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	live := &liveBatch{}
+	for _, id := range ids {
+		if err := setPostState(tx, live, id, PostArchived, 0, ""); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	live.publish(m.DB, m.Live)
+	return len(ids), nil
+}
+
+// Archived returns the posts archived before cutoff, with their images,
+// for purging.
+func (m *ExpiryModel) Archived(cutoff time.Time) ([]*Forum, error) {
+	stmt := `SELECT id, COALESCE(image_path, '') FROM forums
+	WHERE status = ? AND deleted IS NULL AND status_changed <= ?
+	ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, PostArchived, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := []*Forum{}
+	for rows.Next() {
+		f := &Forum{}
+		if err := rows.Scan(&f.ID, &f.ImagePath); err != nil {
+			return nil, err
+		}
+		forums = append(forums, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return forums, nil
+}
+
+// Renew gives a post a fresh lifetime of days from now on behalf of
+// actorID; an archived post is published again.
+func (m *ForumModel) Renew(forumID, days, actorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var state int
+	err = tx.QueryRow(`SELECT status FROM forums WHERE id = ? AND deleted IS NULL`, forumID).Scan(&state)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	stmt := `UPDATE forums SET expires = ` + expiresAfter + `, expiry_reminded = NULL WHERE id = ?`
+	if _, err := tx.Exec(stmt, days, days, forumID); err != nil {
+		return err
+	}
+
+	live := &liveBatch{}
+	if state == PostArchived {
+		if err := setPostState(tx, live, forumID, PostPublished, actorID, ""); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	live.publish(m.DB, m.Live)
+	return nil
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExpiryPolicies(t *testing.T) {
+	db := newTestDB(t)
+	expiry := &ExpiryModel{DB: db}
+
+	days := func() []int {
+		t.Helper()
+		policies, err := expiry.Policies()
+		if err != nil {
+			t.Fatalf("Policies: %v", err)
+		}
+		var out []int
+		for _, p := range policies {
+			out = append(out, p.Days)
+		}
+		return out
+	}
+
+	if got := days(); len(got) != 3 || got[0] != 1 || got[2] != 365 {
+		t.Fatalf("seeded policies = %v", got)
+	}
+	if p, err := expiry.Policy(365); err != nil || !p.Default || p.Label != "One Year" {
+		t.Fatalf("Policy(365) = %+v, %v", p, err)
+	}
+	if _, err := expiry.Policy(30); !errors.Is(err, ErrUnknownExpiry) {
+		t.Fatalf("Policy(30) err = %v, want ErrUnknownExpiry", err)
+	}
+
+	if err := expiry.SetPolicy(0, "Never"); err != nil {
+		t.Fatalf("SetPolicy(0): %v", err)
+	}
+	if err := expiry.SetPolicy(30, "A month"); err != nil {
+		t.Fatalf("SetPolicy(30): %v", err)
+	}
+	if got := days(); len(got) != 5 || got[3] != 365 || got[4] != 0 {
+		t.Fatalf("policies = %v, want never last", got)
+	}
+	if err := expiry.SetPolicy(30, "Thirty days"); err != nil {
+		t.Fatalf("relabelling: %v", err)
+	}
+	if p, _ := expiry.Policy(30); p.Label != "Thirty days" {
+		t.Fatalf("relabelled = %q", p.Label)
+	}
+
+	if err := expiry.RemovePolicy(365); !errors.Is(err, ErrDefaultExpiry) {
+		t.Fatalf("removing the default err = %v, want ErrDefaultExpiry", err)
+	}
+	if err := expiry.SetDefault(0); err != nil {
+		t.Fatalf("SetDefault(0): %v", err)
+	}
+	if err := expiry.SetDefault(12); !errors.Is(err, ErrUnknownExpiry) {
+		t.Fatalf("SetDefault(12) err = %v, want ErrUnknownExpiry", err)
+	}
+	if err := expiry.RemovePolicy(365); err != nil {
+		t.Fatalf("RemovePolicy(365): %v", err)
+	}
+	if p, _ := expiry.Policy(0); !p.Default {
+		t.Fatalf("never is not the default: %+v", p)
+	}
+	if got := days(); len(got) != 4 {
+		t.Fatalf("policies after removal = %v", got)
+	}
+}
+
+func TestExpirySweep(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	expiry := &ExpiryModel{DB: db}
+	inbox := &InboxModel{DB: db}
+
+	author := seedUser(t, db, "ola")
+	soon := seedForum(t, db, author, "expires in five days", PostPublished, "go")
+	later, err := forums.Insert(context.Background(), "lives a year", "content", "go", 365, author, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	never, err := forums.Insert(context.Background(), "lives for ever", "content", "go", 0, author, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{later, never} {
+		if err := forums.SetState(context.Background(), id, PostPublished, author, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	n, err := expiry.Remind(now.Add(6 * 24 * time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("Remind = %d, %v, want 1", n, err)
+	}
+	if n, _ := expiry.Remind(now.Add(6 * 24 * time.Hour)); n != 0 {
+		t.Fatalf("reminded again %d times", n)
+	}
+	list, err := inbox.List(author, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Event != EventPostExpiring || list[0].ForumID != soon || list[0].ActorName != "" {
+		t.Fatalf("author inbox = %+v", list)
+	}
+
+	if _, err := db.Exec(`UPDATE forums SET expires = datetime('now', '-1 hour') WHERE id = ?`, soon); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := expiry.Archive(now); err != nil || n != 1 {
+		t.Fatalf("Archive = %d, %v, want 1", n, err)
+	}
+	if st, _ := forums.Status(soon); st.State != PostArchived {
+		t.Fatalf("expired post state = %s", PostStateName(st.State))
+	}
+	// Archived posts stay readable but take no comments or votes.
+	f, err := forums.Get(soon, 0, false)
+	if err != nil || f.State != "archived" || !f.Gone() || f.Open() {
+		t.Fatalf("Get(archived) = %+v, %v", f, err)
+	}
+	if _, err := forums.GetUserIDFromForum(soon); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetUserIDFromForum(archived) err = %v, want sql.ErrNoRows", err)
+	}
+
+	archived, err := expiry.Archived(now.Add(time.Hour))
+	if err != nil || len(archived) != 1 || archived[0].ID != soon {
+		t.Fatalf("Archived = %+v, %v", archived, err)
+	}
+	if archived, _ := expiry.Archived(now.Add(-time.Hour)); len(archived) != 0 {
+		t.Fatalf("archived before the cutoff = %+v", archived)
+	}
+
+	if err := forums.Renew(soon, 7, author); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	st, err := forums.Status(soon)
+	if err != nil || st.State != PostPublished || !st.Expires.After(now.Add(6*24*time.Hour)) {
+		t.Fatalf("renewed status = %+v, %v", st, err)
+	}
+	// A renewed post is reminded again before its new expiry.
+	if n, _ := expiry.Remind(now.Add(8 * 24 * time.Hour)); n != 1 {
+		t.Fatalf("reminded %d posts after renewal, want 1", n)
+	}
+
+	f, err = forums.Get(never, 0, false)
+	if err != nil || !f.NeverExpires() || f.Expired() {
+		t.Fatalf("Get(never) expires %v, %v", f.Expires, err)
+	}
+	if n, _ := expiry.Archive(now.Add(100 * 365 * 24 * time.Hour)); n != 2 {
+		t.Fatalf("archived %d posts a century on, want 2", n)
+	}
+	if st, _ := forums.Status(never); st.State != PostPublished {
+		t.Fatalf("a post that never expires was archived")
+	}
+
+	if err := forums.Renew(12345, 7, author); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Renew(missing) err = %v, want ErrNoRecord", err)
+	}
+}
@@ -2,8 +2,6 @@ package models
 
 import (
 	"database/sql"
-	"io/ioutil"
-	"log"
 	"time"
 )
 
@@ -17,15 +15,28 @@ type Forum struct {
 	Liked         bool
 	LikesCount    int
 	DislikesCount int
-	Comment       []userComment
+	// CommentsCount counts the post's comments that are not in the trash.
+	CommentsCount int
+	Comment       []UserComment
 	Created       time.Time
 	Expires       time.Time
 	ImagePath     string
-	IsOwnForum    bool
-	EditComment   userComment
+	// Edited is when the post was last edited; zero if it never was.
+	Edited time.Time
+	// State names the post's lifecycle state ("published", "draft", ...);
+	// empty where the query did not load it. StateReason is why a rejected
+	// post was rejected.
+	State       string
+	StateReason string
+	IsOwnForum  bool
+	// IsAuthor is set when the viewer wrote the post; only the author
+	// submits or withdraws it.
+	IsAuthor    bool
+	EditComment UserComment
 }
 
-type userComment struct {
+// UserComment is a comment as shown to a particular viewer of a forum page.
+type UserComment struct {
 	CommentID     int
 	ForumID       int
 	User          string
@@ -35,21 +46,33 @@ type userComment struct {
 	LikesCount    int
 	DislikesCount int
 	IsOwnComment  bool
+	// Edited is when the comment was last edited; zero if it never was.
+	Edited time.Time
+	// Deleted marks the placeholder of a deleted comment that still has
+	// replies; it carries no author or text.
+	Deleted    bool
+	ParentID   int
+	Depth      int
+	ReplyCount int
+	Replies    []UserComment
 }
 
-type ForumModel struct {
-	DB *sql.DB
+// collapseCommentDepth is the depth from which replies are folded away in
+// the forum view.
+const collapseCommentDepth = 3
+
+// CanReply reports whether a reply to this comment would stay within
+// MaxCommentDepth. Deleted comments take no replies.
+func (c UserComment) CanReply() bool {
+	return !c.Deleted && c.Depth < MaxCommentDepth
 }
 
-func (m *ForumModel) Init(initSqlFileName string) error {
-	file, err := ioutil.ReadFile(initSqlFileName)
-	if err != nil {
-		log.Fatalf("Can't read SQL file %v", err)
-	}
+// Collapsed reports whether the comment's replies start folded.
+func (c UserComment) Collapsed() bool {
+	return c.Depth+1 >= collapseCommentDepth
+}
 
-	_, err = m.DB.Exec(string(file))
-	if err != nil {
-		log.Fatalf("DB init error: %v", err)
-	}
-	return nil
+type ForumModel struct {
+	DB   *sql.DB
+	Live Publisher
 }
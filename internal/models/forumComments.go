@@ -2,53 +2,205 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 )
 
 type ForumComment struct {
-	ID      int
-	ForumID int
-	UserID  int
-	Comment string
+	ID       int
+	ForumID  int
+	UserID   int
+	ParentID int
+	Depth    int
+	Comment  string
 }
 
 type ForumCommentModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	Live Publisher
+}
+
+// MaxCommentDepth is the deepest reply level accepted below a top-level
+// comment (depth 0). It may be overridden at startup.
+var MaxCommentDepth = 5
+
+// commentPathSegment is one element of a comment's materialized path. Ids are
+// zero-padded so that ordering by path yields depth-first thread order.
+func commentPathSegment(id int64) string {
+	return fmt.Sprintf("%010d", id)
+}
+
+// touchForum marks a post as active now, for the recently-active sort.
+func touchForum(tx *sql.Tx, forumID int) error {
+	stmt := `UPDATE forums SET last_activity = strftime('%Y-%m-%d %H:%M:%S', 'now') WHERE id = ?`
+	_, err := tx.Exec(stmt, forumID)
+	return err
+}
+
+// postAuthor returns the author of forumID, or 0 if the post is gone.
+func postAuthor(tx *sql.Tx, forumID int) (int, error) {
+	var authorID int
+	err := tx.QueryRow(`SELECT user_id FROM forums WHERE id = ?`, forumID).Scan(&authorID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return authorID, nil
 }
 
 func (m *ForumCommentModel) CommentPost(forumID, userID int, comment string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	stmt := `INSERT INTO forum_comments (forum_id, user_id, comment) 
 	VALUES (?, ?, ?);`
 
-	_, err := m.DB.Exec(stmt, forumID, userID, comment)
+	result, err := tx.Exec(stmt, forumID, userID, comment)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE forum_comments SET path = ? WHERE id = ?`, commentPathSegment(id), id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = touchForum(tx, forumID); err != nil {
+		return 0, err
+	}
+
+	live := &liveBatch{}
+	if err = live.addComment(tx, forumID, int(id), 0, 0, userID, comment); err != nil {
+		return 0, err
+	}
+
+	authorID, err := postAuthor(tx, forumID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = notify(tx, live, authorID, userID, EventPostComment, forumID, int(id), comment)
 	if err != nil {
 		return 0, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	live.publish(m.DB, m.Live)
+
 	return forumID, nil
 }
 
-func (m *ForumCommentModel) CommentPostNotification(forumID, userID int, comment, userName string) error {
-	stmt := `INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id) 
+// ReplyPost stores a reply to parentID, which must belong to forumID, and
+// notifies the parent comment's author and the post author. It returns the
+// new comment id.
+func (m *ForumCommentModel) ReplyPost(forumID, userID, parentID int, comment string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var parent ForumComment
+	var parentPath string
+	stmt := `SELECT forum_id, user_id, depth, path FROM forum_comments WHERE id = ? AND deleted IS NULL`
+	err = tx.QueryRow(stmt, parentID).Scan(&parent.ForumID, &parent.UserID, &parent.Depth, &parentPath)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	if parent.ForumID != forumID {
+		return 0, ErrNoRecord
+	}
+	if parent.Depth+1 > MaxCommentDepth {
+		return 0, ErrMaxDepth
+	}
+
+	stmt = `INSERT INTO forum_comments (forum_id, user_id, comment, parent_id, depth)
 	VALUES (?, ?, ?, ?, ?);`
 
-	_, err := m.DB.Exec(stmt, userName, comment, "commented", forumID, userID)
+	result, err := tx.Exec(stmt, forumID, userID, comment, parentID, parent.Depth+1)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE forum_comments SET path = ? WHERE id = ?`, parentPath+"/"+commentPathSegment(id), id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = touchForum(tx, forumID); err != nil {
+		return 0, err
+	}
+
+	live := &liveBatch{}
+	err = live.addComment(tx, forumID, int(id), parentID, parent.Depth+1, userID, comment)
+	if err != nil {
+		return 0, err
+	}
+
+	err = notify(tx, live, parent.UserID, userID, EventCommentReply, forumID, int(id), comment)
+	if err != nil {
+		return 0, err
+	}
+
+	// The post author hears about replies too, unless the reply is to them.
+	authorID, err := postAuthor(tx, forumID)
+	if err != nil {
+		return 0, err
+	}
+	if authorID != parent.UserID {
+		err = notify(tx, live, authorID, userID, EventPostComment, forumID, int(id), comment)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	live.publish(m.DB, m.Live)
+
+	return int(id), nil
 }
 
-func (m *ForumCommentModel) EditCommentPost(forumID, userID int, comment string, commentID int) error {
+// EditCommentPost replaces the text of a comment on behalf of editorID. The
+// text it replaces is kept in comment_revisions.
+func (m *ForumCommentModel) EditCommentPost(forumID, userID int, comment string, commentID, editorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = reviseComment(tx, commentID, editorID, comment); err != nil {
+		return err
+	}
+
 	stmt := `UPDATE forum_comments 
-	SET forum_id = ?, user_id = ?, comment = ?
+	SET forum_id = ?, user_id = ?
 	WHERE id = ? `
 
-	_, err := m.DB.Exec(stmt, forumID, userID, comment, commentID)
+	_, err = tx.Exec(stmt, forumID, userID, commentID)
 	if err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
 // func (m *ForumCommentModel) EditCommentPostNotification(body string, notID int) error {
@@ -63,21 +215,38 @@ func (m *ForumCommentModel) EditCommentPost(forumID, userID int, comment string,
 // 	return nil
 // } TODO: edit comment should eddit also notification... but how to get id from notification???
 
-func (m *ForumCommentModel) RemoveCommentPost(commentID int) error {
-	stmt := `DELETE FROM forum_comments
-	WHERE id = ?`
+// RemoveCommentPost moves a comment to the trash on behalf of userID. Its
+// replies stay; the thread shows a placeholder where it was.
+func (m *ForumCommentModel) RemoveCommentPost(commentID, userID int) error {
+	stmt := `UPDATE forum_comments
+	SET deleted = strftime('%Y-%m-%d %H:%M:%S', 'now'), deleted_by = ?
+	WHERE id = ? AND deleted IS NULL;`
+
+	_, err := m.DB.Exec(stmt, userID, commentID)
+	return err
+}
+
+// RestoreCommentPost takes a comment back out of the trash.
+func (m *ForumCommentModel) RestoreCommentPost(commentID int) error {
+	stmt := `UPDATE forum_comments SET deleted = NULL, deleted_by = NULL
+	WHERE id = ? AND deleted IS NOT NULL AND purged IS NULL;`
 
-	_, err := m.DB.Exec(stmt, commentID)
+	result, err := m.DB.Exec(stmt, commentID)
 	if err != nil {
 		return err
 	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoRecord
+	}
 	return nil
 }
 
 func (m *ForumModel) GetUserIDFromComment(forumCommentID int) (int, error) {
 	stmt := `SELECT user_id
 	FROM forum_comments 
-	WHERE id = ?;`
+	WHERE id = ? AND deleted IS NULL;`
 
 	row := m.DB.QueryRow(stmt, forumCommentID)
 
@@ -91,9 +260,26 @@ func (m *ForumModel) GetUserIDFromComment(forumCommentID int) (int, error) {
 	return userID, nil
 }
 
+func (m *ForumModel) GetForumIDFromComment(forumCommentID int) (int, error) {
+	stmt := `SELECT forum_id
+	FROM forum_comments
+	WHERE id = ?;`
+
+	row := m.DB.QueryRow(stmt, forumCommentID)
+
+	var forumID int
+
+	err := row.Scan(&forumID)
+	if err != nil {
+		return 0, err
+	}
+
+	return forumID, nil
+}
+
 func (m *ForumModel) ShowAllUserComments(userID int) ([]*ForumComment, error) {
-	stmt := `SELECT forum_id, comment FROM forum_comments
-	WHERE user_id = ?`
+	stmt := `SELECT id, forum_id, parent_id, depth, comment FROM forum_comments
+	WHERE user_id = ? AND deleted IS NULL`
 
 	rows, err := m.DB.Query(stmt, userID)
 	if err != nil {
@@ -106,11 +292,13 @@ func (m *ForumModel) ShowAllUserComments(userID int) ([]*ForumComment, error) {
 
 	for rows.Next() {
 		f := &ForumComment{}
+		var parentID sql.NullInt64
 
-		err := rows.Scan(&f.ForumID, &f.Comment)
+		err := rows.Scan(&f.ID, &f.ForumID, &parentID, &f.Depth, &f.Comment)
 		if err != nil {
 			return nil, err
 		}
+		f.ParentID = int(parentID.Int64)
 
 		forums = append(forums, f)
 	}
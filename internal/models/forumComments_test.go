@@ -1,6 +1,9 @@
 package models
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestForumCommentCRUD(t *testing.T) {
 	db := newTestDB(t)
@@ -23,7 +26,7 @@ func TestForumCommentCRUD(t *testing.T) {
 		t.Fatalf("query inserted comment: %v", err)
 	}
 
-	if err := commentModel.EditCommentPost(forumID, u1, "edited", commentID); err != nil {
+	if err := commentModel.EditCommentPost(forumID, u1, "edited", commentID, u1); err != nil {
 		t.Fatalf("EditCommentPost: %v", err)
 	}
 
@@ -43,16 +46,26 @@ func TestForumCommentCRUD(t *testing.T) {
 		t.Fatalf("GetUserIDFromComment = %d, want %d", userIDFromComment, u1)
 	}
 
-	if err := commentModel.RemoveCommentPost(commentID); err != nil {
+	if err := commentModel.RemoveCommentPost(commentID, u1); err != nil {
 		t.Fatalf("RemoveCommentPost: %v", err)
 	}
 
 	var count int
-	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_comments WHERE id = ?`, commentID).Scan(&count); err != nil {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_comments WHERE id = ? AND deleted_by = ?`, commentID, u1).Scan(&count); err != nil {
 		t.Fatal(err)
 	}
-	if count != 0 {
-		t.Fatalf("expected comment to be removed, count=%d", count)
+	if count != 1 {
+		t.Fatalf("expected comment to be in the trash, count=%d", count)
+	}
+	if _, err := forumModel.GetUserIDFromComment(commentID); err == nil {
+		t.Fatal("GetUserIDFromComment found a deleted comment")
+	}
+
+	if err := commentModel.RestoreCommentPost(commentID); err != nil {
+		t.Fatalf("RestoreCommentPost: %v", err)
+	}
+	if err := commentModel.RestoreCommentPost(commentID); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("RestoreCommentPost(live) err = %v, want ErrNoRecord", err)
 	}
 }
 
@@ -84,3 +97,116 @@ func TestShowAllUserComments(t *testing.T) {
 		t.Fatalf("expected 2 comments for user %d, got %d", u2, len(comments))
 	}
 }
+
+func TestCommentReplyThreads(t *testing.T) {
+	db := newTestDB(t)
+	commentModel := &ForumCommentModel{DB: db}
+	forumModel := &ForumModel{DB: db}
+
+	u1 := seedUser(t, db, "ann")
+	u2 := seedUser(t, db, "ben")
+	forumID := seedForum(t, db, u1, "forum", 1, "go")
+	otherForumID := seedForum(t, db, u1, "other", 1, "go")
+
+	if _, err := commentModel.CommentPost(forumID, u1, "root"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+	var rootID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE comment = 'root'`).Scan(&rootID); err != nil {
+		t.Fatal(err)
+	}
+	secondRootID := seedComment(t, db, forumID, u2, "second root")
+
+	replyID, err := commentModel.ReplyPost(forumID, u2, rootID, "reply")
+	if err != nil {
+		t.Fatalf("ReplyPost: %v", err)
+	}
+	nestedID, err := commentModel.ReplyPost(forumID, u1, replyID, "nested")
+	if err != nil {
+		t.Fatalf("ReplyPost nested: %v", err)
+	}
+	if _, err := commentModel.ReplyPost(forumID, u1, rootID, "own reply"); err != nil {
+		t.Fatalf("ReplyPost own: %v", err)
+	}
+
+	var notifications int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_notifications WHERE event = ? AND recipient_id = ?`, EventCommentReply, u1).Scan(&notifications); err != nil {
+		t.Fatal(err)
+	}
+	if notifications != 1 {
+		t.Fatalf("reply notifications for root author = %d, want 1", notifications)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_notifications WHERE event = ? AND recipient_id = ?`, EventCommentReply, u2).Scan(&notifications); err != nil {
+		t.Fatal(err)
+	}
+	if notifications != 1 {
+		t.Fatalf("reply notifications for reply author = %d, want 1", notifications)
+	}
+
+	if _, err := commentModel.ReplyPost(otherForumID, u1, rootID, "wrong forum"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("ReplyPost across forums err = %v, want ErrNoRecord", err)
+	}
+	if _, err := commentModel.ReplyPost(forumID, u1, 9999, "missing"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("ReplyPost missing parent err = %v, want ErrNoRecord", err)
+	}
+
+	origDepth := MaxCommentDepth
+	MaxCommentDepth = 2
+	t.Cleanup(func() { MaxCommentDepth = origDepth })
+	if _, err := commentModel.ReplyPost(forumID, u2, nestedID, "too deep"); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("ReplyPost beyond max depth err = %v, want ErrMaxDepth", err)
+	}
+
+	f, err := forumModel.Get(forumID, u1, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(f.Comment) != 2 || f.Comment[0].CommentID != rootID || f.Comment[1].CommentID != secondRootID {
+		t.Fatalf("top-level comments = %+v", f.Comment)
+	}
+	root := f.Comment[0]
+	if root.ReplyCount != 3 || len(root.Replies) != 2 {
+		t.Fatalf("root replies = %d (direct %d), want 3 (direct 2)", root.ReplyCount, len(root.Replies))
+	}
+	reply := root.Replies[0]
+	if reply.CommentID != replyID || reply.ParentID != rootID || reply.Depth != 1 {
+		t.Fatalf("first reply = %+v", reply)
+	}
+	if len(reply.Replies) != 1 || reply.Replies[0].CommentID != nestedID || reply.Replies[0].Depth != 2 {
+		t.Fatalf("nested replies = %+v", reply.Replies)
+	}
+	if !reply.CanReply() || reply.Replies[0].CanReply() {
+		t.Fatalf("CanReply at depth 1/2 with max 2 = %v/%v", reply.CanReply(), reply.Replies[0].CanReply())
+	}
+	if root.Collapsed() || !reply.Replies[0].Collapsed() {
+		t.Fatalf("Collapsed at depth 0/2 = %v/%v", root.Collapsed(), reply.Replies[0].Collapsed())
+	}
+
+	// A deleted comment with a live reply stays as a placeholder.
+	if err := commentModel.RemoveCommentPost(replyID, u1); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+	f, err = forumModel.Get(forumID, u1, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	reply = f.Comment[0].Replies[0]
+	if !reply.Deleted || reply.Comment != "" || reply.User != "" || reply.CanReply() {
+		t.Fatalf("placeholder = %+v", reply)
+	}
+	if len(reply.Replies) != 1 || reply.Replies[0].CommentID != nestedID {
+		t.Fatalf("replies under placeholder = %+v", reply.Replies)
+	}
+
+	// Once the reply goes too, so does the placeholder.
+	if err := commentModel.RemoveCommentPost(nestedID, u1); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+	f, err = forumModel.Get(forumID, u1, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if f.Comment[0].ReplyCount != 1 {
+		t.Fatalf("root replies after deleting the subtree = %d, want 1", f.Comment[0].ReplyCount)
+	}
+}
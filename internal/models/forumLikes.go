@@ -20,7 +20,21 @@ type ForumLikesComment struct {
 }
 
 type ForumLikesModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	Live Publisher
+}
+
+// publishReaction sends the new vote totals of the post, or of commentID,
+// followed by the inbox changes the vote caused.
+func (m *ForumLikesModel) publishReaction(live *liveBatch, forumID, commentID int) {
+	if m.Live == nil {
+		return
+	}
+	totals, err := reactionTotals(m.DB, forumID, commentID)
+	if err == nil {
+		m.Live.Publish(ForumTopic(forumID), LiveReaction, totals)
+	}
+	live.publish(m.DB, m.Live)
 }
 
 func (m *ForumLikesModel) LikeOrDislike(forumID, userID, likeStatus int) (int, error) {
@@ -37,8 +51,10 @@ func (m *ForumLikesModel) LikeOrDislike(forumID, userID, likeStatus int) (int, e
 		return 0, err
 	}
 
+	current := likeStatus
 	if f.ID != 0 {
 		if f.LikeStatus == likeStatus {
+			current = 0
 			stmt = `DELETE FROM forum_likes WHERE forum_id = ? AND user_id = ?`
 			_, err = m.DB.Exec(stmt, forumID, userID)
 			if err != nil {
@@ -65,6 +81,12 @@ func (m *ForumLikesModel) LikeOrDislike(forumID, userID, likeStatus int) (int, e
 		}
 	}
 
+	live := &liveBatch{}
+	if err = notifyReaction(m.DB, live, userID, forumID, 0, current); err != nil {
+		return 0, err
+	}
+	m.publishReaction(live, forumID, 0)
+
 	return forumID, nil
 }
 
@@ -82,8 +104,10 @@ func (m *ForumLikesModel) LikeOrDislikeComment(commentID, userID, likeStatus int
 		return 0, err
 	}
 
+	current := likeStatus
 	if f.ID != 0 {
 		if f.LikeStatus == likeStatus {
+			current = 0
 			stmt = `DELETE FROM forum_likes WHERE comment_id = ? AND user_id = ?`
 			_, err = m.DB.Exec(stmt, commentID, userID)
 			if err != nil {
@@ -123,5 +147,11 @@ func (m *ForumLikesModel) LikeOrDislikeComment(commentID, userID, likeStatus int
 		return 0, err
 	}
 
+	live := &liveBatch{}
+	if err = notifyReaction(m.DB, live, userID, forumID, commentID, current); err != nil {
+		return 0, err
+	}
+	m.publishReaction(live, forumID, commentID)
+
 	return forumID, nil
 }
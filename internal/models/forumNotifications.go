@@ -1,8 +1,11 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+
+	"github.com/aspandyar/forum/internal/policy"
 )
 
 type Notification struct {
@@ -16,11 +19,15 @@ type Notification struct {
 }
 
 const (
-	ModeratorRole = 3
-	AdminRole     = 4
+	UserRole      = policy.User
+	ModeratorRole = policy.Moderator
+	AdminRole     = policy.Admin
 	AdminStatus   = "admin"
 	ModerStatus   = "moder"
 	AdminID       = 1
+
+	InvisibleStatus = 0
+	VisibleStatus   = 1
 )
 
 func (m *ForumModel) AskForModeration(userID int) error {
@@ -46,36 +53,7 @@ func (m *ForumModel) AskForModeration(userID int) error {
 	return nil
 }
 
-func (m *ForumModel) ReportForum(forumID, moderID int, body string) error {
-	stmt := `INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
-	VALUES(?, ?, ?, ?, ?, ?)`
-	not := Notification{}
-
-	var user User
-	user, err := m.GetUserByForumID(forumID)
-	if err != nil {
-		return err
-	}
-
-	not.Body = body
-	not.Status = AdminStatus
-	not.ForumID = forumID
-	not.UserID = moderID
-	not.UserCommentedID = user.ID
-	not.UserCommented, err = m.GetUserByUserID(user.ID)
-	if err != nil {
-		return err
-	}
-
-	_, err = m.DB.Exec(stmt, not.UserCommented, not.Body, not.Status, not.ForumID, not.UserID, not.UserCommentedID)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (m *ForumModel) AnswerFromAdmin(getUserID int, body string) error {
+func (m *ForumModel) AnswerFromAdmin(ctx context.Context, getUserID int, body string) error {
 	stmt := `INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
 	VALUES(?, ?, ?, ?, ?, ?)`
 	not := Notification{}
@@ -87,12 +65,13 @@ func (m *ForumModel) AnswerFromAdmin(getUserID int, body string) error {
 	not.ForumID = -1 // not needed (cuz it would hidden)
 	not.UserID = getUserID
 	not.UserCommentedID = AdminID
-	not.UserCommented, err = m.GetUserByUserID(AdminID)
+	db := conn(ctx, m.DB)
+	err = db.QueryRowContext(ctx, `SELECT name FROM users WHERE id = ?`, AdminID).Scan(&not.UserCommented)
 	if err != nil {
 		return err
 	}
 
-	_, err = m.DB.Exec(stmt, not.UserCommented, not.Body, not.Status, not.ForumID, not.UserID, not.UserCommentedID)
+	_, err = db.ExecContext(ctx, stmt, not.UserCommented, not.Body, not.Status, not.ForumID, not.UserID, not.UserCommentedID)
 	if err != nil {
 		return err
 	}
@@ -100,9 +79,14 @@ func (m *ForumModel) AnswerFromAdmin(getUserID int, body string) error {
 	return nil
 }
 
-func (m *ForumModel) AskForNewForum(forumID, userID int, body string) error {
-	var err error
+func (m *ForumModel) AskForNewForum(ctx context.Context, forumID, userID int, body string) error {
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return queueForReview(tx, forumID, userID, body)
+	}, nil)
+}
 
+// queueForReview adds a pending post to the moderators' queue.
+func queueForReview(db dbtx, forumID, userID int, body string) error {
 	stmt := `INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
 	VALUES(?, ?, ?, ?, ?, ?)`
 
@@ -115,12 +99,8 @@ func (m *ForumModel) AskForNewForum(forumID, userID int, body string) error {
 	not.UserCommented = "user"
 	not.UserID = -1 // not needed  here
 
-	_, err = m.DB.Exec(stmt, not.UserCommented, not.Body, not.Status, not.ForumID, not.UserID, not.UserCommentedID)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err := db.Exec(stmt, not.UserCommented, not.Body, not.Status, not.ForumID, not.UserID, not.UserCommentedID)
+	return err
 }
 
 func (m *ForumModel) ShowUserNotification(role int) ([]*Notification, error) {
@@ -201,10 +181,10 @@ func (m *ForumModel) ShowUserNotification(role int) ([]*Notification, error) {
 // 	return notifications, nil
 // }
 
-func (m *ForumModel) RemoveUserNotification(id int) error {
+func (m *ForumModel) RemoveUserNotification(ctx context.Context, id int) error {
 	stmt := `DELETE FROM forum_notifications WHERE id = ?;`
 
-	_, err := m.DB.Exec(stmt, id)
+	_, err := conn(ctx, m.DB).ExecContext(ctx, stmt, id)
 	if err != nil {
 		return err
 	}
@@ -212,11 +192,11 @@ func (m *ForumModel) RemoveUserNotification(id int) error {
 	return nil
 }
 
-func (m *ForumModel) ChangeUserRole(userID, role int) error {
+func (m *ForumModel) ChangeUserRole(ctx context.Context, userID, role int) error {
 	stmt := `UPDATE roles
 	SET role = ? WHERE user_id = ?`
 
-	_, err := m.DB.Exec(stmt, role, userID)
+	_, err := conn(ctx, m.DB).ExecContext(ctx, stmt, role, userID)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,14 @@
 package models
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+)
 
 func TestForumGetEditAndLookupHelpers(t *testing.T) {
 	db := newTestDB(t)
 	m := &ForumModel{DB: db}
-	cm := &ForumCommentModel{DB: db}
 
 	u1 := seedUser(t, db, "owner")
 	u2 := seedUser(t, db, "reader")
@@ -47,10 +50,6 @@ func TestForumGetEditAndLookupHelpers(t *testing.T) {
 		t.Fatalf("GetUserByForumID ID = %d, want %d", userByForum.ID, u1)
 	}
 
-	if err := cm.CommentPostNotification(forumID, u2, "commented", "reader"); err == nil {
-		t.Fatal("expected CommentPostNotification to fail with current schema")
-	}
-
 	if err := m.ChangeForumStatus(forumID, 0); err != nil {
 		t.Fatalf("ChangeForumStatus hidden: %v", err)
 	}
@@ -83,3 +82,39 @@ func TestGetAllTagsAndCommentUserLookup(t *testing.T) {
 		t.Fatal("expected username from comment lookup helper")
 	}
 }
+
+func TestForumImagePathAndInUse(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	userID := seedUser(t, db, "owner")
+	forumID := seedForum(t, db, userID, "title", 1, "go")
+
+	path, err := m.ImagePath(forumID)
+	if err != nil || path != "" {
+		t.Fatalf("ImagePath without image = %q, %v", path, err)
+	}
+
+	const image = "/static/media/ab/abc.jpg"
+	if err := m.Edit(context.Background(), "title", "content", "go", 7, userID, image, forumID, userID); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	if path, err := m.ImagePath(forumID); err != nil || path != image {
+		t.Fatalf("ImagePath = %q, %v", path, err)
+	}
+
+	// Hidden posts still hold on to their image.
+	if err := m.ChangeForumStatus(forumID, 0); err != nil {
+		t.Fatalf("ChangeForumStatus: %v", err)
+	}
+	if used, err := m.ImageInUse(context.Background(), image); err != nil || !used {
+		t.Fatalf("ImageInUse = %v, %v; want true", used, err)
+	}
+	if used, err := m.ImageInUse(context.Background(), "/static/media/cd/cde.png"); err != nil || used {
+		t.Fatalf("ImageInUse unknown = %v, %v; want false", used, err)
+	}
+
+	if _, err := m.ImagePath(forumID + 100); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("ImagePath missing forum err = %v, want ErrNoRecord", err)
+	}
+}
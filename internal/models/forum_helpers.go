@@ -1,12 +1,51 @@
 package models
 
-func containsAny(source []string, target []string) bool {
-	for _, s := range source {
-		for _, t := range target {
-			if s == t {
-				return true
+// threadComments nests comments, given in path order, under their parents.
+// Comments whose parent is not in the list are kept at the top level.
+func threadComments(flat []UserComment) []UserComment {
+	present := make(map[int]bool, len(flat))
+	for _, c := range flat {
+		present[c.CommentID] = true
+	}
+
+	var roots []UserComment
+	children := make(map[int][]UserComment)
+	for _, c := range flat {
+		if c.ParentID != 0 && present[c.ParentID] {
+			children[c.ParentID] = append(children[c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var attach func(list []UserComment) ([]UserComment, int)
+	attach = func(list []UserComment) ([]UserComment, int) {
+		total := 0
+		for i := range list {
+			replies, count := attach(children[list[i].CommentID])
+			list[i].Replies = replies
+			list[i].ReplyCount = count
+			total += 1 + count
+		}
+		return list, total
+	}
+
+	roots, _ = attach(roots)
+	return roots
+}
+
+// ManageComments marks every comment of f as the viewer's own, for viewers
+// whose role may edit and delete any comment.
+func (f *Forum) ManageComments() {
+	var mark func(list []UserComment)
+	mark = func(list []UserComment) {
+		for i := range list {
+			if !list[i].Deleted {
+				list[i].IsOwnComment = true
+				list[i].ForumID = f.ID
 			}
+			mark(list[i].Replies)
 		}
 	}
-	return false
+	mark(f.Comment)
 }
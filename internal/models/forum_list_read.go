@@ -2,39 +2,20 @@ package models
 
 import "strings"
 
+// Latest returns the first page of the newest visible posts.
 func (m *ForumModel) Latest() ([]*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires
-	FROM forums
-	WHERE expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND status = 1
-	ORDER BY id DESC
-	LIMIT 10;`
-
-	rows, err := m.DB.Query(stmt)
+	page, err := m.List(ForumFilter{}, PageQuery{Limit: LatestPageSize})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	forums := []*Forum{}
-	for rows.Next() {
-		f := &Forum{}
-		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires)
-		if err != nil {
-			return nil, err
-		}
-		forums = append(forums, f)
-	}
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-	return forums, nil
+	return page.Forums, nil
 }
 
 func (m *ForumModel) ShowAll() ([]*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires
-	FROM forums
-	WHERE expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND status = 1
-	ORDER BY id DESC;`
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, ` + forumCountColumns + `
+	FROM forums f
+	WHERE f.expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND f.status = 1 AND f.deleted IS NULL
+	ORDER BY f.id DESC;`
 
 	rows, err := m.DB.Query(stmt)
 	if err != nil {
@@ -45,7 +26,7 @@ func (m *ForumModel) ShowAll() ([]*Forum, error) {
 	forums := []*Forum{}
 	for rows.Next() {
 		f := &Forum{}
-		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires)
+		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.LikesCount, &f.DislikesCount, &f.CommentsCount)
 		if err != nil {
 			return nil, err
 		}
@@ -57,29 +38,44 @@ func (m *ForumModel) ShowAll() ([]*Forum, error) {
 	return forums, nil
 }
 
+// ShowCategory returns visible posts carrying any of the given tags.
 func (m *ForumModel) ShowCategory(tags []string) ([]*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires
-	FROM forums
-	WHERE expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND status = 1
-	ORDER BY id DESC;`
+	forums := []*Forum{}
+	if len(tags) == 0 {
+		return forums, nil
+	}
 
-	rows, err := m.DB.Query(stmt)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, ` + forumCountColumns + `
+	FROM forums f
+	WHERE f.expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND f.status = 1 AND f.deleted IS NULL
+		AND f.id IN (
+			SELECT pt.forum_id
+			FROM forum_post_tags pt
+			JOIN forum_tags t ON t.id = pt.tag_id
+			WHERE t.tags IN (` + placeholders + `)
+		)
+	ORDER BY f.id DESC;`
+
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	forums := []*Forum{}
 	for rows.Next() {
 		f := &Forum{}
-		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires)
+		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.LikesCount, &f.DislikesCount, &f.CommentsCount)
 		if err != nil {
 			return nil, err
 		}
-		f.TagsOutput = strings.Split(f.Tags, ", ")
-		if containsAny(tags, f.TagsOutput) {
-			forums = append(forums, f)
-		}
+		f.TagsOutput = parseTags(f.Tags)
+		forums = append(forums, f)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
@@ -1,6 +1,9 @@
 package models
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestNotificationFlowsAndRoleQueries(t *testing.T) {
 	db := newTestDB(t)
@@ -17,13 +20,10 @@ func TestNotificationFlowsAndRoleQueries(t *testing.T) {
 	if err := model.AskForModeration(moderID); err != nil {
 		t.Fatalf("AskForModeration: %v", err)
 	}
-	if err := model.ReportForum(forumID, moderID, "spam"); err != nil {
-		t.Fatalf("ReportForum: %v", err)
-	}
-	if err := model.AnswerFromAdmin(moderID, "approved"); err != nil {
+	if err := model.AnswerFromAdmin(context.Background(), moderID, "approved"); err != nil {
 		t.Fatalf("AnswerFromAdmin: %v", err)
 	}
-	if err := model.AskForNewForum(forumID, userID, "new forum body"); err != nil {
+	if err := model.AskForNewForum(context.Background(), forumID, userID, "new forum body"); err != nil {
 		t.Fatalf("AskForNewForum: %v", err)
 	}
 
@@ -46,10 +46,10 @@ func TestNotificationFlowsAndRoleQueries(t *testing.T) {
 		t.Fatal("expected invalid role error")
 	}
 
-	if err := model.RemoveUserNotification(adminNotifications[0].ID); err != nil {
+	if err := model.RemoveUserNotification(context.Background(), adminNotifications[0].ID); err != nil {
 		t.Fatalf("RemoveUserNotification: %v", err)
 	}
-	if err := model.ChangeUserRole(moderID, ModeratorRole); err != nil {
+	if err := model.ChangeUserRole(context.Background(), moderID, ModeratorRole); err != nil {
 		t.Fatalf("ChangeUserRole: %v", err)
 	}
 	role, err := model.GetRoleByUserID(moderID)
@@ -0,0 +1,291 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sort modes accepted by ForumModel.List.
+const (
+	SortNewest         = "newest"
+	SortOldest         = "oldest"
+	SortMostLiked      = "most-liked"
+	SortMostCommented  = "most-commented"
+	SortRecentlyActive = "recently-active"
+)
+
+// Sorts lists the sort modes in the order they are offered to users.
+var Sorts = []string{SortNewest, SortOldest, SortMostLiked, SortMostCommented, SortRecentlyActive}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	LatestPageSize  = 10
+)
+
+var (
+	ErrInvalidSort   = errors.New("models: invalid sort mode")
+	ErrInvalidCursor = errors.New("models: invalid page cursor")
+)
+
+// sortKey is the SQL expression a sort mode orders by, with f.id as the
+// tie-breaker. Numeric keys are compared as integers, the rest as text.
+type sortKey struct {
+	expr    string
+	desc    bool
+	numeric bool
+}
+
+var sortKeys = map[string]sortKey{
+	SortNewest: {expr: `f.id`, desc: true, numeric: true},
+	SortOldest: {expr: `f.id`, numeric: true},
+	SortMostLiked: {
+		expr:    `f.likes_count`,
+		desc:    true,
+		numeric: true,
+	},
+	SortMostCommented: {
+		expr:    `f.comments_count`,
+		desc:    true,
+		numeric: true,
+	},
+	SortRecentlyActive: {expr: `COALESCE(f.last_activity, f.created)`, desc: true},
+}
+
+// ForumFilter narrows ForumModel.List. The zero value lists every visible,
+// unexpired post.
+type ForumFilter struct {
+	// Tags keeps posts carrying any of the tags.
+	Tags []string
+	// AuthorID keeps posts written by the user, including expired ones.
+	AuthorID int
+	// AnyState keeps the author's drafts, pending, rejected and archived
+	// posts too. It is only honoured together with AuthorID.
+	AnyState bool
+	// ReactedBy keeps posts the user reacted to, directly or on a comment.
+	ReactedBy int
+}
+
+// PageQuery selects one page of a listing. Cursor is empty for the first
+// page, otherwise a value from ForumPage.Next or ForumPage.Prev.
+type PageQuery struct {
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+// ForumPage is one page of a listing. Next and Prev are empty at either end.
+type ForumPage struct {
+	Forums []*Forum
+	Sort   string
+	Next   string
+	Prev   string
+}
+
+// pageCursor is the decoded form of an opaque cursor: the sort key and id of
+// the row to continue from, and whether to read forwards or backwards.
+type pageCursor struct {
+	Sort     string `json:"s"`
+	Key      string `json:"k"`
+	ID       int    `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (c pageCursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(js, &c); err != nil || c.ID < 1 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// ValidSort reports whether sort is a known sort mode.
+func ValidSort(sort string) bool {
+	_, ok := sortKeys[sort]
+	return ok
+}
+
+// List returns one page of posts matching filter, ordered by q.Sort (newest
+// by default). Pages are addressed by keyset cursors, so rows inserted while
+// paging do not shift or repeat later pages.
+func (m *ForumModel) List(filter ForumFilter, q PageQuery) (*ForumPage, error) {
+	if q.Sort == "" {
+		q.Sort = SortNewest
+	}
+	key, ok := sortKeys[q.Sort]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	var cursor pageCursor
+	if q.Cursor != "" {
+		var err error
+		cursor, err = decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.Sort {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	var where []string
+	var args []interface{}
+
+	if filter.AuthorID != 0 && filter.AnyState {
+		where = append(where, `f.user_id = ? AND f.deleted IS NULL`)
+		args = append(args, filter.AuthorID)
+	} else if filter.AuthorID != 0 {
+		where = append(where, `f.user_id = ? AND f.status = 1 AND f.deleted IS NULL`)
+		args = append(args, filter.AuthorID)
+	} else {
+		where = append(where, `f.expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND f.status = 1 AND f.deleted IS NULL`)
+	}
+	if len(filter.Tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Tags)), ", ")
+		where = append(where, `f.id IN (
+			SELECT pt.forum_id
+			FROM forum_post_tags pt
+			JOIN forum_tags t ON t.id = pt.tag_id
+			WHERE t.tags IN (`+placeholders+`))`)
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+	}
+	if filter.ReactedBy != 0 {
+		where = append(where, `f.id IN (
+			SELECT forum_id FROM forum_likes WHERE user_id = ?
+			UNION
+			SELECT fc.forum_id
+			FROM forum_comments fc
+			JOIN forum_likes fl ON fl.comment_id = fc.id
+			WHERE fl.user_id = ?)`)
+		args = append(args, filter.ReactedBy, filter.ReactedBy)
+	}
+
+	// Reading backwards flips the order; the rows are reversed afterwards.
+	desc := key.desc != cursor.Backward
+	if q.Cursor != "" {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		where = append(where, `(`+key.expr+`, f.id) `+op+` (?, ?)`)
+		if key.numeric {
+			n, err := strconv.ParseInt(cursor.Key, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			args = append(args, n, cursor.ID)
+		} else {
+			args = append(args, cursor.Key, cursor.ID)
+		}
+	}
+
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, f.status, f.status_reason, ` + forumCountColumns + `, ` + key.expr + `
+	FROM forums f
+	WHERE ` + strings.Join(where, " AND ") + `
+	ORDER BY ` + key.expr + ` ` + order + `, f.id ` + order + `
+	LIMIT ?;`
+	args = append(args, limit+1)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := []*Forum{}
+	var keys []string
+	for rows.Next() {
+		f := &Forum{}
+		var k interface{}
+		var state int
+		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &state, &f.StateReason,
+			&f.LikesCount, &f.DislikesCount, &f.CommentsCount, &k)
+		if err != nil {
+			return nil, err
+		}
+		f.State = PostStateName(state)
+		forums = append(forums, f)
+		keys = append(keys, sortKeyString(k))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(forums) > limit
+	if more {
+		forums, keys = forums[:limit], keys[:limit]
+	}
+	if cursor.Backward {
+		for i, j := 0, len(forums)-1; i < j; i, j = i+1, j-1 {
+			forums[i], forums[j] = forums[j], forums[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	page := &ForumPage{Forums: forums, Sort: q.Sort}
+	if len(forums) == 0 {
+		return page, nil
+	}
+
+	first := pageCursor{Sort: q.Sort, Key: keys[0], ID: forums[0].ID, Backward: true}
+	last := pageCursor{Sort: q.Sort, Key: keys[len(keys)-1], ID: forums[len(forums)-1].ID}
+	if cursor.Backward {
+		page.Next = last.encode()
+		if more {
+			page.Prev = first.encode()
+		}
+	} else {
+		if more {
+			page.Next = last.encode()
+		}
+		if q.Cursor != "" {
+			page.Prev = first.encode()
+		}
+	}
+
+	return page, nil
+}
+
+func sortKeyString(v interface{}) string {
+	switch k := v.(type) {
+	case int64:
+		return strconv.FormatInt(k, 10)
+	case []byte:
+		return string(k)
+	case string:
+		return k
+	case time.Time:
+		return k.UTC().Format("2006-01-02 15:04:05")
+	default:
+		return ""
+	}
+}
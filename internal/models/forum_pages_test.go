@@ -0,0 +1,219 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func pageIDs(page *ForumPage) []int {
+	ids := make([]int, 0, len(page.Forums))
+	for _, f := range page.Forums {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, name string, got, want []int) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestListPagesForwardAndBack(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "sam")
+	var ids []int
+	for i := 0; i < 5; i++ {
+		ids = append(ids, seedForum(t, db, u, "post", 1, "go"))
+	}
+
+	first, err := m.List(ForumFilter{}, PageQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	assertIDs(t, "first page", pageIDs(first), []int{ids[4], ids[3]})
+	if first.Prev != "" || first.Next == "" {
+		t.Fatalf("first page cursors: prev=%q next=%q", first.Prev, first.Next)
+	}
+
+	// A post created while paging must not shift the following pages.
+	seedForum(t, db, u, "late", 1, "go")
+
+	second, err := m.List(ForumFilter{}, PageQuery{Cursor: first.Next, Limit: 2})
+	if err != nil {
+		t.Fatalf("List second: %v", err)
+	}
+	assertIDs(t, "second page", pageIDs(second), []int{ids[2], ids[1]})
+	if second.Prev == "" || second.Next == "" {
+		t.Fatalf("second page cursors: prev=%q next=%q", second.Prev, second.Next)
+	}
+
+	last, err := m.List(ForumFilter{}, PageQuery{Cursor: second.Next, Limit: 2})
+	if err != nil {
+		t.Fatalf("List last: %v", err)
+	}
+	assertIDs(t, "last page", pageIDs(last), []int{ids[0]})
+	if last.Next != "" {
+		t.Fatalf("last page should have no next cursor, got %q", last.Next)
+	}
+
+	back, err := m.List(ForumFilter{}, PageQuery{Cursor: last.Prev, Limit: 2})
+	if err != nil {
+		t.Fatalf("List back: %v", err)
+	}
+	assertIDs(t, "previous page", pageIDs(back), []int{ids[2], ids[1]})
+	if back.Next == "" || back.Prev == "" {
+		t.Fatalf("previous page cursors: prev=%q next=%q", back.Prev, back.Next)
+	}
+}
+
+func TestListSortModes(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+	likes := &ForumLikesModel{DB: db}
+
+	u := seedUser(t, db, "tess")
+	v := seedUser(t, db, "uma")
+	a := seedForum(t, db, u, "a", 1, "go")
+	b := seedForum(t, db, u, "b", 1, "go")
+	c := seedForum(t, db, u, "c", 1, "go")
+	if _, err := db.Exec(`UPDATE forums SET last_activity = datetime('now', '-1 hour')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{b, b, c} {
+		if _, err := comments.CommentPost(id, u, "reply"); err != nil {
+			t.Fatalf("CommentPost: %v", err)
+		}
+	}
+	for _, uid := range []int{u, v} {
+		if _, err := likes.LikeOrDislike(a, uid, 1); err != nil {
+			t.Fatalf("LikeOrDislike: %v", err)
+		}
+	}
+	if _, err := likes.LikeOrDislike(c, u, 1); err != nil {
+		t.Fatalf("LikeOrDislike: %v", err)
+	}
+	// Backdate b and c so the later comment on a makes it the most active.
+	if _, err := db.Exec(`UPDATE forums SET last_activity = datetime('now', '-30 minutes') WHERE id IN (?, ?)`, b, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.CommentPost(a, v, "late reply"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+
+	tests := []struct {
+		sort string
+		want []int
+	}{
+		{SortNewest, []int{c, b, a}},
+		{SortOldest, []int{a, b, c}},
+		{SortMostLiked, []int{a, c, b}},
+		{SortMostCommented, []int{b, c, a}},
+		{SortRecentlyActive, []int{a, c, b}},
+	}
+	for _, tt := range tests {
+		page, err := m.List(ForumFilter{}, PageQuery{Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("List(%s): %v", tt.sort, err)
+		}
+		assertIDs(t, tt.sort, pageIDs(page), tt.want)
+
+		// Paging one row at a time must visit the same order.
+		var walked []int
+		q := PageQuery{Sort: tt.sort, Limit: 1}
+		for {
+			page, err := m.List(ForumFilter{}, q)
+			if err != nil {
+				t.Fatalf("List(%s) paged: %v", tt.sort, err)
+			}
+			walked = append(walked, pageIDs(page)...)
+			if page.Next == "" {
+				break
+			}
+			q.Cursor = page.Next
+		}
+		assertIDs(t, tt.sort+" paged", walked, tt.want)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+	likes := &ForumLikesModel{DB: db}
+
+	u := seedUser(t, db, "vera")
+	v := seedUser(t, db, "walt")
+	goPost := seedForum(t, db, u, "go", 1, "go")
+	webPost := seedForum(t, db, v, "web", 1, "web")
+	seedForum(t, db, u, "hidden", 0, "go")
+	expired := seedForum(t, db, u, "expired", 1, "go")
+	if _, err := db.Exec(`UPDATE forums SET expires = datetime('now', '-1 day') WHERE id = ?`, expired); err != nil {
+		t.Fatal(err)
+	}
+	commentID := seedComment(t, db, webPost, u, "nice")
+	if _, err := likes.LikeOrDislikeComment(commentID, v, 1); err != nil {
+		t.Fatalf("LikeOrDislikeComment: %v", err)
+	}
+	if _, err := likes.LikeOrDislike(goPost, v, -1); err != nil {
+		t.Fatalf("LikeOrDislike: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter ForumFilter
+		want   []int
+	}{
+		{"all", ForumFilter{}, []int{webPost, goPost}},
+		{"tags", ForumFilter{Tags: []string{"go"}}, []int{goPost}},
+		{"author keeps expired", ForumFilter{AuthorID: u}, []int{expired, goPost}},
+		{"reacted", ForumFilter{ReactedBy: v}, []int{webPost, goPost}},
+		{"tags and author", ForumFilter{Tags: []string{"web"}, AuthorID: u}, []int{}},
+	}
+	for _, tt := range tests {
+		page, err := m.List(tt.filter, PageQuery{})
+		if err != nil {
+			t.Fatalf("List(%s): %v", tt.name, err)
+		}
+		assertIDs(t, tt.name, pageIDs(page), tt.want)
+	}
+}
+
+func TestListRejectsBadInput(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "xena")
+	seedForum(t, db, u, "one", 1, "go")
+	seedForum(t, db, u, "two", 1, "go")
+
+	if _, err := m.List(ForumFilter{}, PageQuery{Sort: "random"}); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("unknown sort: err = %v, want ErrInvalidSort", err)
+	}
+	if _, err := m.List(ForumFilter{}, PageQuery{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("garbage cursor: err = %v, want ErrInvalidCursor", err)
+	}
+
+	page, err := m.List(ForumFilter{}, PageQuery{Sort: SortMostLiked, Limit: 1})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if _, err := m.List(ForumFilter{}, PageQuery{Sort: SortOldest, Cursor: page.Next}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("cursor from another sort: err = %v, want ErrInvalidCursor", err)
+	}
+
+	bad := pageCursor{Sort: SortNewest, Key: "abc", ID: 1}.encode()
+	if _, err := m.List(ForumFilter{}, PageQuery{Cursor: bad}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("non-numeric key: err = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -3,317 +3,114 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"strings"
+	"time"
 )
 
-func (m *ForumModel) Get(id, userId int, isOwnForum bool) (*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires, image_path
-	FROM forums
-	WHERE expires > datetime('now') AND id = ?;`
-
-	row := m.DB.QueryRow(stmt, id)
-
-	f := &Forum{}
-
-	err := row.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.ImagePath)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		}
-		return nil, err
-	}
-
-	f.TagsOutput = strings.Split(f.Tags, ", ")
-	f.IsOwnForum = isOwnForum
-
-	var reacted, liked bool
-	stmt = `SELECT id, like_status
-	FROM forum_likes
-	WHERE forum_id = ? AND user_id = ?`
-	fl := &ForumLikes{}
-	rowL := m.DB.QueryRow(stmt, id, userId)
-
-	err = rowL.Scan(&fl.ID, &fl.LikeStatus)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			reacted = false
-			liked = false
-		} else {
-			return nil, err
-		}
-	}
-
-	if fl.ID == 0 {
-		reacted = false
-		liked = false
-	} else if fl.LikeStatus == 1 {
-		reacted = true
-		liked = true
-	} else if fl.LikeStatus == -1 {
-		reacted = true
-		liked = false
-	}
-
-	f.Reacted = reacted
-	f.Liked = liked
+// threadCommentVisible keeps a deleted comment in its thread, as a
+// placeholder, only while a reply under it is still there.
+const threadCommentVisible = `(fc.deleted IS NULL OR EXISTS (
+	SELECT 1 FROM forum_comments r
+	WHERE r.forum_id = fc.forum_id AND r.path LIKE fc.path || '/%' AND r.deleted IS NULL))`
 
-	stmt = `SELECT f.id,
-		SUM(CASE WHEN l.like_status = 1 THEN 1 ELSE 0 END) AS like_count,
-		SUM(CASE WHEN l.like_status = -1 THEN 1 ELSE 0 END) AS dislike_count
-	FROM forums f
-	LEFT JOIN forum_likes l ON f.id = l.forum_id
-	WHERE f.id = ?
-	GROUP BY f.id`
-
-	rows := m.DB.QueryRow(stmt, id)
-	fs := &Forum{}
-	err = rows.Scan(&fs.ID, &fs.LikesCount, &fs.DislikesCount)
+func (m *ForumModel) Get(id, userId int, isOwnForum bool) (*Forum, error) {
+	f, comments, err := m.load(id, userId, isOwnForum)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		}
 		return nil, err
 	}
+	f.Comment = threadComments(comments)
+	return f, nil
+}
 
-	f.LikesCount = fs.LikesCount
-	f.DislikesCount = fs.DislikesCount
-
-	stmt = `SELECT u.name, fc.comment, fc.id, u.id
-    FROM forum_comments fc
-    JOIN users u ON fc.user_id = u.id
-    WHERE fc.forum_id = ?`
-
-	rowsL, err := m.DB.Query(stmt, id)
+// GetEdit is Get with the comment being edited taken out of the thread and
+// set as f.EditComment.
+func (m *ForumModel) GetEdit(forumID, userID int, isOwnForum bool, commentID int) (*Forum, error) {
+	f, comments, err := m.load(forumID, userID, isOwnForum)
 	if err != nil {
 		return nil, err
 	}
-	defer rowsL.Close()
-
-	var userComments []userComment
-	for rowsL.Next() {
-		var userComment userComment
-		var givenUser int
-		err := rowsL.Scan(&userComment.User, &userComment.Comment, &userComment.CommentID, &givenUser)
-		if err != nil {
-			return nil, err
-		}
 
-		if givenUser == userId || userId == AdminID {
-			userComment.IsOwnComment = true
-			userComment.ForumID = f.ID
+	kept := comments[:0]
+	for _, c := range comments {
+		if c.CommentID == commentID {
+			f.EditComment = c
 		} else {
-			userComment.IsOwnComment = false
+			kept = append(kept, c)
 		}
-
-		var reacted, liked bool
-		stmt = `SELECT id, like_status
-		FROM forum_likes
-		WHERE comment_id = ? AND user_id = ?`
-
-		fl := &ForumLikesComment{}
-		rowTemp := m.DB.QueryRow(stmt, userComment.CommentID, userId)
-		err = rowTemp.Scan(&fl.ID, &fl.LikeStatus)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				reacted = false
-				liked = false
-			} else {
-				return nil, err
-			}
-		}
-
-		if fl.ID == 0 {
-			reacted = false
-			liked = false
-		} else if fl.LikeStatus == 1 {
-			reacted = true
-			liked = true
-		} else if fl.LikeStatus == -1 {
-			reacted = true
-			liked = false
-		}
-
-		userComment.Reacted = reacted
-		userComment.Liked = liked
-
-		stmt = `SELECT f.id,
-		SUM(CASE WHEN l.like_status = 1 THEN 1 ELSE 0 END) AS like_count,
-		SUM(CASE WHEN l.like_status = -1 THEN 1 ELSE 0 END) AS dislike_count
-		FROM forum_comments f
-		LEFT JOIN forum_likes l ON f.id = l.comment_id
-		WHERE f.id = ?
-		GROUP BY f.id`
-
-		rows := m.DB.QueryRow(stmt, userComment.CommentID)
-		fs := &ForumComment{}
-		err = rows.Scan(&fs.ID, &userComment.LikesCount, &userComment.DislikesCount)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, ErrNoRecord
-			}
-			return nil, err
-		}
-
-		userComments = append(userComments, userComment)
 	}
-
-	if err := rowsL.Err(); err != nil {
-		return nil, err
-	}
-
-	f.Comment = userComments
+	f.Comment = threadComments(kept)
 	return f, nil
 }
 
-func (m *ForumModel) GetEdit(forumID, userID int, isOwnForum bool, commentID int) (*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires, image_path
-	FROM forums
-	WHERE expires > datetime('now') AND id = ?;`
+// load reads a post and its visible comments, in thread order, with their
+// vote counters and userID's own votes. It takes two queries however many
+// comments there are.
+func (m *ForumModel) load(id, userID int, isOwnForum bool) (*Forum, []UserComment, error) {
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, f.image_path, f.edited,
+		f.status, f.status_reason, f.likes_count, f.dislikes_count, f.comments_count, COALESCE(v.like_status, 0)
+	FROM forums f
+	LEFT JOIN forum_likes v ON v.forum_id = f.id AND v.comment_id IS NULL AND v.user_id = ?
+	WHERE f.id = ? AND f.deleted IS NULL;`
 
-	row := m.DB.QueryRow(stmt, forumID)
 	f := &Forum{}
-	err := row.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.ImagePath)
+	var edited sql.NullTime
+	var state, vote int
+	err := m.DB.QueryRow(stmt, userID, id).Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.ImagePath, &edited,
+		&state, &f.StateReason, &f.LikesCount, &f.DislikesCount, &f.CommentsCount, &vote)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
+			return nil, nil, ErrNoRecord
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
-	f.TagsOutput = strings.Split(f.Tags, ", ")
+	f.Edited = edited.Time
+	f.State = PostStateName(state)
+	f.TagsOutput = parseTags(f.Tags)
 	f.IsOwnForum = isOwnForum
+	f.Reacted, f.Liked = vote != 0, vote == 1
 
-	var reacted, liked bool
-	stmt = `SELECT id, like_status
-	FROM forum_likes
-	WHERE forum_id = ? AND user_id = ?`
-	fl := &ForumLikes{}
-	rowL := m.DB.QueryRow(stmt, forumID, userID)
-	err = rowL.Scan(&fl.ID, &fl.LikeStatus)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			reacted = false
-			liked = false
-		} else {
-			return nil, err
-		}
-	}
-
-	if fl.ID == 0 {
-		reacted = false
-		liked = false
-	} else if fl.LikeStatus == 1 {
-		reacted = true
-		liked = true
-	} else if fl.LikeStatus == -1 {
-		reacted = true
-		liked = false
-	}
-
-	f.Reacted = reacted
-	f.Liked = liked
-
-	stmt = `SELECT f.id,
-		SUM(CASE WHEN l.like_status = 1 THEN 1 ELSE 0 END) AS like_count,
-		SUM(CASE WHEN l.like_status = -1 THEN 1 ELSE 0 END) AS dislike_count
-	FROM forums f
-	LEFT JOIN forum_likes l ON f.id = l.forum_id
-	WHERE f.id = ?
-	GROUP BY f.id`
-	rows := m.DB.QueryRow(stmt, forumID)
-	fs := &Forum{}
-	err = rows.Scan(&fs.ID, &fs.LikesCount, &fs.DislikesCount)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		}
-		return nil, err
-	}
-	f.LikesCount = fs.LikesCount
-	f.DislikesCount = fs.DislikesCount
-
-	stmt = `SELECT u.name, fc.comment, fc.id, u.id
+	stmt = `SELECT u.name, fc.comment, fc.id, u.id, fc.parent_id, fc.depth, fc.edited, fc.deleted IS NOT NULL,
+		fc.likes_count, fc.dislikes_count, COALESCE(v.like_status, 0)
     FROM forum_comments fc
     JOIN users u ON fc.user_id = u.id
-    WHERE fc.forum_id = ?`
+    LEFT JOIN forum_likes v ON v.comment_id = fc.id AND v.user_id = ?
+    WHERE fc.forum_id = ? AND ` + threadCommentVisible + `
+    ORDER BY fc.path, fc.id`
 
-	rowsL, err := m.DB.Query(stmt, forumID)
+	rows, err := m.DB.Query(stmt, userID, id)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer rowsL.Close()
-
-	var userComments []userComment
-	for rowsL.Next() {
-		var userComment userComment
-		var givenUser int
-		err := rowsL.Scan(&userComment.User, &userComment.Comment, &userComment.CommentID, &givenUser)
+	defer rows.Close()
+
+	var comments []UserComment
+	for rows.Next() {
+		var c UserComment
+		var author int
+		var parentID sql.NullInt64
+		var edited sql.NullTime
+		var vote int
+		err := rows.Scan(&c.User, &c.Comment, &c.CommentID, &author, &parentID, &c.Depth, &edited, &c.Deleted,
+			&c.LikesCount, &c.DislikesCount, &vote)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		if givenUser == userID {
-			userComment.IsOwnComment = true
-			userComment.ForumID = f.ID
-		} else {
-			userComment.IsOwnComment = false
+		c.ParentID = int(parentID.Int64)
+		c.Edited = edited.Time
+		if c.Deleted {
+			c.User, c.Comment, c.Edited = "", "", time.Time{}
+			author = 0
 		}
-
-		var reacted, liked bool
-		stmt = `SELECT id, like_status
-		FROM forum_likes
-		WHERE comment_id = ? AND user_id = ?`
-		fl := &ForumLikesComment{}
-		rowTemp := m.DB.QueryRow(stmt, userComment.CommentID, userID)
-		err = rowTemp.Scan(&fl.ID, &fl.LikeStatus)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				reacted = false
-				liked = false
-			} else {
-				return nil, err
-			}
-		}
-		if fl.ID == 0 {
-			reacted = false
-			liked = false
-		} else if fl.LikeStatus == 1 {
-			reacted = true
-			liked = true
-		} else if fl.LikeStatus == -1 {
-			reacted = true
-			liked = false
-		}
-
-		userComment.Reacted = reacted
-		userComment.Liked = liked
-
-		stmt = `SELECT f.id,
-		SUM(CASE WHEN l.like_status = 1 THEN 1 ELSE 0 END) AS like_count,
-		SUM(CASE WHEN l.like_status = -1 THEN 1 ELSE 0 END) AS dislike_count
-		FROM forum_comments f
-		LEFT JOIN forum_likes l ON f.id = l.comment_id
-		WHERE f.id = ?
-		GROUP BY f.id`
-		rows := m.DB.QueryRow(stmt, userComment.CommentID)
-		fs := &ForumComment{}
-		err = rows.Scan(&fs.ID, &userComment.LikesCount, &userComment.DislikesCount)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, ErrNoRecord
-			}
-			return nil, err
+		if author == userID {
+			c.IsOwnComment = true
+			c.ForumID = f.ID
 		}
+		c.Reacted, c.Liked = vote != 0, vote == 1
 
-		if userComment.CommentID == commentID {
-			f.EditComment = userComment
-		} else {
-			userComments = append(userComments, userComment)
-		}
+		comments = append(comments, c)
 	}
-	if err := rowsL.Err(); err != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	f.Comment = userComments
-	return f, nil
+	return f, comments, nil
 }
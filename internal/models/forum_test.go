@@ -1,16 +1,19 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"sort"
 	"testing"
 )
 
-func TestContainsAny(t *testing.T) {
-	if !containsAny([]string{"go", "web"}, []string{"db", "go"}) {
-		t.Fatal("expected overlap to return true")
+func TestParseTags(t *testing.T) {
+	got := parseTags(" go, web,,go ,  db ")
+	if len(got) != 3 || got[0] != "go" || got[1] != "web" || got[2] != "db" {
+		t.Fatalf("parseTags = %#v", got)
 	}
-	if containsAny([]string{"a"}, []string{"b"}) {
-		t.Fatal("expected non-overlap to return false")
+	if got := parseTags(""); len(got) != 0 {
+		t.Fatalf("parseTags(\"\") = %#v, want empty", got)
 	}
 }
 
@@ -19,7 +22,7 @@ func TestForumInsertEditAndRemove(t *testing.T) {
 	m := &ForumModel{DB: db}
 
 	u1 := seedUser(t, db, "harry")
-	forumID, err := m.Insert("title", "body", "go, web", 7, u1, "/img.png")
+	forumID, err := m.Insert(context.Background(), "title", "body", "go, web", 7, u1, "/img.png")
 	if err != nil {
 		t.Fatalf("Insert: %v", err)
 	}
@@ -27,7 +30,7 @@ func TestForumInsertEditAndRemove(t *testing.T) {
 		t.Fatalf("ChangeForumStatus: %v", err)
 	}
 
-	if err := m.Edit("new title", "new body", "go, api", 7, u1, "/new.png", forumID); err != nil {
+	if err := m.Edit(context.Background(), "new title", "new body", "go, api", 7, u1, "/new.png", forumID, u1); err != nil {
 		t.Fatalf("Edit: %v", err)
 	}
 
@@ -38,6 +41,9 @@ func TestForumInsertEditAndRemove(t *testing.T) {
 	if got.Title != "new title" || got.Content != "new body" {
 		t.Fatalf("unexpected edited forum: %#v", got)
 	}
+	if got.Tags != "api, go" || len(got.TagsOutput) != 2 {
+		t.Fatalf("edited tags = %q (%v), want %q", got.Tags, got.TagsOutput, "api, go")
+	}
 
 	liker := seedUser(t, db, "ian")
 	commenter := seedUser(t, db, "jane")
@@ -46,9 +52,24 @@ func TestForumInsertEditAndRemove(t *testing.T) {
 	_, _ = db.Exec(`INSERT INTO forum_likes(comment_id, user_id, like_status) VALUES(?, ?, 1)`, commentID, liker)
 	_, _ = db.Exec(`INSERT INTO forum_notifications(user_name, body, status, forum_link, user_id, user_not_id) VALUES('u','b','moder', ?, 1, 1)`, forumID)
 
-	if err := m.Remove(forumID); err != nil {
+	if err := m.Remove(context.Background(), forumID, u1); err != nil {
 		t.Fatalf("Remove: %v", err)
 	}
+	if _, err := m.Get(forumID, u1, true); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Get(removed) err = %v, want ErrNoRecord", err)
+	}
+	if err := m.Remove(context.Background(), forumID, u1); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Remove(removed) err = %v, want ErrNoRecord", err)
+	}
+	if err := m.Restore(context.Background(), forumID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := m.Get(forumID, u1, true); err != nil {
+		t.Fatalf("Get(restored): %v", err)
+	}
+	if err := m.Purge(context.Background(), forumID); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
 
 	var count int
 	if err := db.QueryRow(`SELECT COUNT(*) FROM forums WHERE id = ?`, forumID).Scan(&count); err != nil {
@@ -57,6 +78,12 @@ func TestForumInsertEditAndRemove(t *testing.T) {
 	if count != 0 {
 		t.Fatalf("expected forum row deleted, count=%d", count)
 	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_post_tags WHERE forum_id = ?`, forumID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected post tag links deleted, count=%d", count)
+	}
 }
 
 func TestForumListingAndFilters(t *testing.T) {
@@ -70,8 +97,8 @@ func TestForumListingAndFilters(t *testing.T) {
 	fDB := seedForum(t, db, u1, "db post", 1, "db, sql")
 	_ = seedForum(t, db, u1, "hidden", 0, "go")
 	_, _ = db.Exec(
-		`INSERT INTO forums(title, content, tags, user_id, created, expires, image_path, status)
-		 VALUES('expired', 'x', 'go', ?, datetime('now'), datetime('now', '-1 day'), '', 1)`,
+		`INSERT INTO forums(title, content, user_id, created, expires, image_path, status)
+		 VALUES('expired', 'x', ?, datetime('now'), datetime('now', '-1 day'), '', 1)`,
 		u1,
 	)
 
@@ -103,8 +130,8 @@ func TestForumListingAndFilters(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ShowAllUserPosts: %v", err)
 	}
-	if len(userPosts) != 3 { // status filter only, so expired forum is still included.
-		t.Fatalf("expected 3 user posts, got %d", len(userPosts))
+	if len(userPosts) != 4 { // the author sees expired and pending posts too.
+		t.Fatalf("expected 4 user posts, got %d", len(userPosts))
 	}
 
 	ids := make([]int, 0, len(userPosts))
@@ -1,9 +1,9 @@
 package models
 
 func (m *ForumModel) ShowAllUserPosts(userID int) ([]*Forum, error) {
-	stmt := `SELECT id, title, content, tags, created, expires
-	FROM forums 
-	WHERE user_id = ? AND status = 1;`
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, f.status, f.status_reason, ` + forumCountColumns + `
+	FROM forums f
+	WHERE f.user_id = ? AND f.deleted IS NULL;`
 
 	rows, err := m.DB.Query(stmt, userID)
 	if err != nil {
@@ -14,10 +14,13 @@ func (m *ForumModel) ShowAllUserPosts(userID int) ([]*Forum, error) {
 	forums := []*Forum{}
 	for rows.Next() {
 		f := &Forum{}
-		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires)
+		var state int
+		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &state, &f.StateReason,
+			&f.LikesCount, &f.DislikesCount, &f.CommentsCount)
 		if err != nil {
 			return nil, err
 		}
+		f.State = PostStateName(state)
 		forums = append(forums, f)
 	}
 	if err = rows.Err(); err != nil {
@@ -27,7 +30,7 @@ func (m *ForumModel) ShowAllUserPosts(userID int) ([]*Forum, error) {
 }
 
 func (m *ForumModel) ShowAllUserLikes(userID int) ([]*Forum, error) {
-	stmt := `SELECT f.id, f.title, f.content, f.tags, f.created, f.expires
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, f.created, f.expires, ` + forumCountColumns + `
 	FROM forums f
 	INNER JOIN (
 		SELECT forum_id
@@ -41,7 +44,7 @@ func (m *ForumModel) ShowAllUserLikes(userID int) ([]*Forum, error) {
 		JOIN forum_likes fl ON fl.comment_id = fc.id 
 		WHERE fl.user_id = ?
 	) AS relevant_forums ON f.id = relevant_forums.forum_id
-	WHERE expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND f.status = 1;`
+	WHERE expires > strftime('%Y-%m-%d %H:%M:%S', 'now') AND f.status = 1 AND f.deleted IS NULL;`
 
 	rows, err := m.DB.Query(stmt, userID, userID)
 	if err != nil {
@@ -52,7 +55,7 @@ func (m *ForumModel) ShowAllUserLikes(userID int) ([]*Forum, error) {
 	forums := []*Forum{}
 	for rows.Next() {
 		f := &Forum{}
-		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires)
+		err := rows.Scan(&f.ID, &f.Title, &f.Content, &f.Tags, &f.Created, &f.Expires, &f.LikesCount, &f.DislikesCount, &f.CommentsCount)
 		if err != nil {
 			return nil, err
 		}
@@ -64,10 +67,12 @@ func (m *ForumModel) ShowAllUserLikes(userID int) ([]*Forum, error) {
 	return forums, nil
 }
 
+// GetUserIDFromForum returns the author of a published post that has not
+// expired. Comments and votes check it, so other posts take neither.
 func (m *ForumModel) GetUserIDFromForum(forumID int) (int, error) {
 	stmt := `SELECT user_id
 	FROM forums 
-	WHERE id = ? AND status = 1;`
+	WHERE id = ? AND status = 1 AND deleted IS NULL AND expires > strftime('%Y-%m-%d %H:%M:%S', 'now');`
 
 	row := m.DB.QueryRow(stmt, forumID)
 	var userID int
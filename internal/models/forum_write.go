@@ -1,75 +1,118 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
 
-func (m *ForumModel) Insert(title, content, tags string, expires, userID int, imagePath string) (int, error) {
-	stmt := `INSERT INTO forums (title, content, tags, user_id, created, expires, image_path) 
-	VALUES (?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'), strftime('%Y-%m-%d %H:%M:%S', 'now', '+' || ? || ' day'), ?);`
+func (m *ForumModel) Insert(ctx context.Context, title, content, tags string, expires, userID int, imagePath string) (int, error) {
+	var id int64
+	err := inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO forums (title, content, user_id, created, last_activity, expires, image_path) 
+		VALUES (?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'), strftime('%Y-%m-%d %H:%M:%S', 'now'), ` + expiresAfter + `, ?);`
 
-	result, err := m.DB.Exec(stmt, title, content, tags, userID, expires, imagePath)
-	if err != nil {
-		return 0, err
-	}
-
-	id, err := result.LastInsertId()
+		result, err := tx.ExecContext(ctx, stmt, title, content, userID, expires, expires, imagePath)
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return setForumTags(tx, int(id), tags)
+	}, nil)
 	if err != nil {
 		return 0, err
 	}
-
 	return int(id), nil
 }
 
-func (m *ForumModel) Edit(title, content, tags string, expires, userID int, imagePath string, forumID int) error {
-	stmt := `UPDATE forums 
-	SET title = ?, content = ?, tags = ?, user_id = ?, expires = strftime('%Y-%m-%d %H:%M:%S', 'now', '+' || ? || ' day'), image_path = ?
-	WHERE id = ?`
+// Edit replaces a post on behalf of editorID. The version it replaces is
+// kept in forum_revisions.
+func (m *ForumModel) Edit(ctx context.Context, title, content, tags string, expires, userID int, imagePath string, forumID, editorID int) error {
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return reviseForum(tx, forumID, editorID, func() error {
+			stmt := `UPDATE forums 
+			SET title = ?, content = ?, user_id = ?, expires = ` + expiresAfter + `, expiry_reminded = NULL, image_path = ?
+			WHERE id = ?`
+
+			if _, err := tx.ExecContext(ctx, stmt, title, content, userID, expires, expires, imagePath, forumID); err != nil {
+				return err
+			}
+			return setForumTags(tx, forumID, tags)
+		})
+	}, nil)
+}
 
-	_, err := m.DB.Exec(stmt, title, content, tags, userID, expires, imagePath, forumID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+// Remove moves a post to the trash on behalf of userID. It disappears from
+// every page but keeps its comments, votes and history until Restore brings
+// it back or Purge deletes it. Open reports against it are dismissed.
+func (m *ForumModel) Remove(ctx context.Context, forumID, userID int) error {
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `UPDATE forums
+		SET deleted = strftime('%Y-%m-%d %H:%M:%S', 'now'), deleted_by = ?
+		WHERE id = ? AND deleted IS NULL;`
+
+		result, err := tx.ExecContext(ctx, stmt, userID, forumID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
 			return ErrNoRecord
 		}
-		return err
-	}
-
-	return nil
-}
-
-func (m *ForumModel) Remove(forumID int) error {
-	stmt := `DELETE FROM forums WHERE id = ?;`
-
-	_, err := m.DB.Exec(stmt, forumID)
-	if err != nil {
-		return err
-	}
 
-	stmt = `DELETE FROM forum_likes WHERE forum_id = ?;`
+		// Reports stay for the audit trail, but nothing is left to decide.
+		stmt = `UPDATE reports
+		SET state = ?, resolution = 'post removed', updated = strftime('%Y-%m-%d %H:%M:%S', 'now')
+		WHERE forum_id = ? AND state IN (?, ?);`
 
-	_, err = m.DB.Exec(stmt, forumID)
-	if err != nil {
+		_, err = tx.ExecContext(ctx, stmt, ReportDismissed, forumID, ReportOpen, ReportInReview)
 		return err
-	}
+	}, nil)
+}
 
-	stmt = `DELETE FROM forum_comments WHERE forum_id = ?;`
+// Restore takes a post back out of the trash.
+func (m *ForumModel) Restore(ctx context.Context, forumID int) error {
+	stmt := `UPDATE forums SET deleted = NULL, deleted_by = NULL
+	WHERE id = ? AND deleted IS NOT NULL;`
 
-	_, err = m.DB.Exec(stmt, forumID)
+	result, err := conn(ctx, m.DB).ExecContext(ctx, stmt, forumID)
 	if err != nil {
 		return err
 	}
-
-	stmt = `DELETE FROM forum_notifications WHERE forum_link = ?;`
-
-	_, err = m.DB.Exec(stmt, forumID)
-	if err != nil {
+	if n, err := result.RowsAffected(); err != nil {
 		return err
+	} else if n == 0 {
+		return ErrNoRecord
 	}
-
 	return nil
 }
 
+// Purge deletes a post for good, together with its comments, votes, tags,
+// history and notifications.
+func (m *ForumModel) Purge(ctx context.Context, forumID int) error {
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`DELETE FROM forum_likes WHERE comment_id IN (SELECT id FROM forum_comments WHERE forum_id = ?);`,
+			`DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM forum_comments WHERE forum_id = ?);`,
+			`DELETE FROM forum_likes WHERE forum_id = ?;`,
+			`DELETE FROM forum_revisions WHERE forum_id = ?;`,
+			`DELETE FROM forum_post_tags WHERE forum_id = ?;`,
+			`DELETE FROM forum_comments WHERE forum_id = ?;`,
+			`DELETE FROM forum_notifications WHERE forum_link = ?;`,
+			`DELETE FROM user_notifications WHERE forum_id = ?;`,
+			`DELETE FROM forums WHERE id = ?;`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, forumID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+}
+
 func (m *ForumModel) ChangeForumStatus(forumID int, status int) error {
 	stmt := `UPDATE forums
 	SET status = ? WHERE id = ?`
@@ -81,3 +124,25 @@ func (m *ForumModel) ChangeForumStatus(forumID int, status int) error {
 
 	return nil
 }
+
+// ImagePath returns the image of a post, whatever its status.
+func (m *ForumModel) ImagePath(forumID int) (string, error) {
+	var path sql.NullString
+	err := m.DB.QueryRow(`SELECT image_path FROM forums WHERE id = ?`, forumID).Scan(&path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+	return path.String, err
+}
+
+// ImageInUse reports whether any post, or any earlier version of one, still
+// shows the image at path. Uploads are stored by content, so two posts may
+// share one.
+func (m *ForumModel) ImageInUse(ctx context.Context, path string) (bool, error) {
+	stmt := `SELECT EXISTS (SELECT 1 FROM forums WHERE image_path = ?)
+		OR EXISTS (SELECT 1 FROM forum_revisions WHERE image_path = ?)`
+
+	var used bool
+	err := conn(ctx, m.DB).QueryRowContext(ctx, stmt, path, path).Scan(&used)
+	return used, err
+}
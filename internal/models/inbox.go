@@ -0,0 +1,280 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"unicode/utf8"
+)
+
+// Inbox events. Each names what happened to something the recipient wrote.
+const (
+	EventPostComment    = "post-comment"
+	EventCommentReply   = "comment-reply"
+	EventPostLike       = "post-like"
+	EventPostDislike    = "post-dislike"
+	EventCommentLike    = "comment-like"
+	EventCommentDislike = "comment-dislike"
+	EventPostRejected   = "post-rejected"
+	EventPostExpiring   = "post-expiring"
+)
+
+const (
+	InboxPageSize = 50
+	// inboxBodyLength caps the comment excerpt stored with a notification.
+	inboxBodyLength = 200
+)
+
+// Retention windows used by InboxModel.Prune. Read notifications go first;
+// unread ones are kept longer so nothing disappears before it is seen.
+var (
+	ReadNotificationRetention   = 30 * 24 * time.Hour
+	UnreadNotificationRetention = 180 * 24 * time.Hour
+)
+
+// InboxNotification is one entry in a user's inbox.
+type InboxNotification struct {
+	ID          int
+	RecipientID int
+	ActorID     int
+	ActorName   string
+	Event       string
+	ForumID     int
+	ForumTitle  string
+	CommentID   int
+	Body        string
+	Created     time.Time
+	Read        bool
+}
+
+// Summary describes the event as shown after the actor's name.
+func (n *InboxNotification) Summary() string {
+	switch n.Event {
+	case EventPostComment:
+		return "commented on your post"
+	case EventCommentReply:
+		return "replied to your comment on"
+	case EventPostLike:
+		return "liked your post"
+	case EventPostDislike:
+		return "disliked your post"
+	case EventCommentLike:
+		return "liked your comment on"
+	case EventCommentDislike:
+		return "disliked your comment on"
+	case EventPostRejected:
+		return "rejected your post"
+	case EventPostExpiring:
+		return "Your post is about to expire:"
+	default:
+		return "reacted to"
+	}
+}
+
+type InboxModel struct {
+	DB   *sql.DB
+	Live Publisher
+}
+
+// dbtx is the part of *sql.DB and *sql.Tx the notification helpers need, so
+// they can join the caller's transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// notify adds an unread notification to recipientID's inbox and queues it on
+// live for the recipient's stream. Users are never notified about their own
+// actions.
+func notify(db dbtx, live *liveBatch, recipientID, actorID int, event string, forumID, commentID int, body string) error {
+	if recipientID <= 0 || recipientID == actorID {
+		return nil
+	}
+
+	if utf8.RuneCountInString(body) > inboxBodyLength {
+		body = string([]rune(body)[:inboxBodyLength]) + "…"
+	}
+
+	stmt := `INSERT INTO user_notifications (recipient_id, actor_id, event, forum_id, comment_id, body, created)
+	VALUES (?, ?, ?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'));`
+
+	result, err := db.Exec(stmt, recipientID, actorID, event, forumID, commentID, body)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	live.add(InboxTopic(recipientID), LiveNotification, NotificationEvent{
+		ID:        int(id),
+		Event:     event,
+		ActorID:   actorID,
+		ForumID:   forumID,
+		CommentID: commentID,
+		Body:      body,
+	})
+	live.touchInbox(recipientID)
+	return nil
+}
+
+// notifyReaction keeps the inbox in step with a vote: an unread notification
+// for the actor's previous vote on the same post or comment is withdrawn,
+// and a new one is added when likeStatus is 1 or -1. commentID is 0 for
+// votes on the post itself.
+func notifyReaction(db dbtx, live *liveBatch, actorID, forumID, commentID, likeStatus int) error {
+	events := [2]string{EventPostLike, EventPostDislike}
+	owner := `SELECT user_id FROM forums WHERE id = ?`
+	target := forumID
+	if commentID != 0 {
+		events = [2]string{EventCommentLike, EventCommentDislike}
+		owner = `SELECT user_id FROM forum_comments WHERE id = ?`
+		target = commentID
+	}
+
+	var recipientID int
+	err := db.QueryRow(owner, target).Scan(&recipientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	stmt := `DELETE FROM user_notifications
+	WHERE actor_id = ? AND forum_id = ? AND comment_id = ? AND event IN (?, ?) AND read_at IS NULL;`
+
+	result, err := db.Exec(stmt, actorID, forumID, commentID, events[0], events[1])
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		live.touchInbox(recipientID)
+	}
+
+	switch likeStatus {
+	case 1:
+		return notify(db, live, recipientID, actorID, events[0], forumID, commentID, "")
+	case -1:
+		return notify(db, live, recipientID, actorID, events[1], forumID, commentID, "")
+	}
+	return nil
+}
+
+// inboxVisible hides notifications about posts and comments in the trash;
+// they come back with a restore and go for good with a purge.
+const inboxVisible = `NOT EXISTS (SELECT 1 FROM forums t WHERE t.id = n.forum_id AND t.deleted IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM forum_comments t WHERE t.id = n.comment_id AND t.deleted IS NOT NULL)`
+
+// List returns the newest notifications addressed to recipientID, only the
+// unread ones when unreadOnly is set.
+func (m *InboxModel) List(recipientID int, unreadOnly bool, limit int) ([]*InboxNotification, error) {
+	if limit <= 0 || limit > InboxPageSize {
+		limit = InboxPageSize
+	}
+
+	stmt := `SELECT n.id, n.recipient_id, n.actor_id, COALESCE(u.name, ''), n.event, n.forum_id,
+		COALESCE(f.title, ''), n.comment_id, n.body, n.created, n.read_at IS NOT NULL
+	FROM user_notifications n
+	LEFT JOIN users u ON u.id = n.actor_id
+	LEFT JOIN forums f ON f.id = n.forum_id
+	WHERE n.recipient_id = ? AND ` + inboxVisible
+	if unreadOnly {
+		stmt += ` AND n.read_at IS NULL`
+	}
+	stmt += `
+	ORDER BY n.id DESC
+	LIMIT ?;`
+
+	rows, err := m.DB.Query(stmt, recipientID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*InboxNotification{}
+	for rows.Next() {
+		n := &InboxNotification{}
+		err := rows.Scan(&n.ID, &n.RecipientID, &n.ActorID, &n.ActorName, &n.Event, &n.ForumID,
+			&n.ForumTitle, &n.CommentID, &n.Body, &n.Created, &n.Read)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// UnreadCount returns how many unread notifications recipientID has.
+func (m *InboxModel) UnreadCount(recipientID int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM user_notifications n
+	WHERE n.recipient_id = ? AND n.read_at IS NULL AND ` + inboxVisible
+
+	var n int
+	if err := m.DB.QueryRow(stmt, recipientID).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// MarkRead marks one of recipientID's notifications as read and returns it
+// with the post and comment it refers to. Notifications of other users give
+// ErrNoRecord.
+func (m *InboxModel) MarkRead(recipientID, id int) (*InboxNotification, error) {
+	stmt := `UPDATE user_notifications
+	SET read_at = COALESCE(read_at, strftime('%Y-%m-%d %H:%M:%S', 'now'))
+	WHERE id = ? AND recipient_id = ?
+	RETURNING event, forum_id, comment_id;`
+
+	n := &InboxNotification{ID: id, RecipientID: recipientID, Read: true}
+	err := m.DB.QueryRow(stmt, id, recipientID).Scan(&n.Event, &n.ForumID, &n.CommentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	live := &liveBatch{}
+	live.touchInbox(recipientID)
+	live.publish(m.DB, m.Live)
+
+	return n, nil
+}
+
+// MarkAllRead marks every unread notification of recipientID as read.
+func (m *InboxModel) MarkAllRead(recipientID int) error {
+	stmt := `UPDATE user_notifications
+	SET read_at = strftime('%Y-%m-%d %H:%M:%S', 'now')
+	WHERE recipient_id = ? AND read_at IS NULL;`
+
+	if _, err := m.DB.Exec(stmt, recipientID); err != nil {
+		return err
+	}
+
+	live := &liveBatch{}
+	live.touchInbox(recipientID)
+	live.publish(m.DB, m.Live)
+	return nil
+}
+
+// Prune deletes read notifications created before readBefore and unread
+// ones created before unreadBefore, returning how many were removed.
+func (m *InboxModel) Prune(readBefore, unreadBefore time.Time) (int64, error) {
+	const layout = "2006-01-02 15:04:05"
+
+	stmt := `DELETE FROM user_notifications
+	WHERE (read_at IS NOT NULL AND created < ?) OR created < ?;`
+
+	result, err := m.DB.Exec(stmt, readBefore.UTC().Format(layout), unreadBefore.UTC().Format(layout))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,310 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aspandyar/forum/internal/migrations"
+)
+
+func inboxEvents(t *testing.T, m *InboxModel, userID int) []string {
+	t.Helper()
+
+	list, err := m.List(userID, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	events := make([]string, 0, len(list))
+	for _, n := range list {
+		events = append(events, n.Event)
+	}
+	return events
+}
+
+func TestInboxCommentAndReplyEvents(t *testing.T) {
+	db := newTestDB(t)
+	inbox := &InboxModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+
+	author := seedUser(t, db, "yara")
+	reader := seedUser(t, db, "zack")
+	third := seedUser(t, db, "abby")
+	forumID := seedForum(t, db, author, "Inbox post", 1, "go")
+
+	if _, err := comments.CommentPost(forumID, author, "own comment"); err != nil {
+		t.Fatalf("CommentPost own: %v", err)
+	}
+	if got := inboxEvents(t, inbox, author); len(got) != 0 {
+		t.Fatalf("author notified about own comment: %v", got)
+	}
+
+	if _, err := comments.CommentPost(forumID, reader, "nice post"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+	var readerComment int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE comment = 'nice post'`).Scan(&readerComment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.ReplyPost(forumID, third, readerComment, "agreed"); err != nil {
+		t.Fatalf("ReplyPost: %v", err)
+	}
+
+	got := inboxEvents(t, inbox, author)
+	if len(got) != 2 || got[0] != EventPostComment || got[1] != EventPostComment {
+		t.Fatalf("author inbox = %v", got)
+	}
+	got = inboxEvents(t, inbox, reader)
+	if len(got) != 1 || got[0] != EventCommentReply {
+		t.Fatalf("reader inbox = %v", got)
+	}
+
+	list, err := inbox.List(reader, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	n := list[0]
+	if n.ActorName != "abby" || n.ForumTitle != "Inbox post" || n.Body != "agreed" || n.Read || n.CommentID == 0 {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+}
+
+func TestInboxReactionEvents(t *testing.T) {
+	db := newTestDB(t)
+	inbox := &InboxModel{DB: db}
+	likes := &ForumLikesModel{DB: db}
+
+	author := seedUser(t, db, "bea")
+	voter := seedUser(t, db, "cal")
+	forumID := seedForum(t, db, author, "post", 1, "go")
+	commentID := seedComment(t, db, forumID, author, "comment")
+
+	if _, err := likes.LikeOrDislike(forumID, voter, 1); err != nil {
+		t.Fatalf("LikeOrDislike: %v", err)
+	}
+	if _, err := likes.LikeOrDislike(forumID, voter, -1); err != nil {
+		t.Fatalf("LikeOrDislike switch: %v", err)
+	}
+	got := inboxEvents(t, inbox, author)
+	if len(got) != 1 || got[0] != EventPostDislike {
+		t.Fatalf("switching vote should replace the notification, got %v", got)
+	}
+
+	if _, err := likes.LikeOrDislike(forumID, voter, -1); err != nil {
+		t.Fatalf("LikeOrDislike withdraw: %v", err)
+	}
+	if got := inboxEvents(t, inbox, author); len(got) != 0 {
+		t.Fatalf("withdrawn vote should drop the notification, got %v", got)
+	}
+
+	if _, err := likes.LikeOrDislikeComment(commentID, voter, 1); err != nil {
+		t.Fatalf("LikeOrDislikeComment: %v", err)
+	}
+	if _, err := likes.LikeOrDislikeComment(commentID, author, 1); err != nil {
+		t.Fatalf("LikeOrDislikeComment own: %v", err)
+	}
+	list, err := inbox.List(author, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].Event != EventCommentLike || list[0].CommentID != commentID || list[0].ForumID != forumID {
+		t.Fatalf("comment like notifications = %+v", list)
+	}
+}
+
+func TestInboxReadState(t *testing.T) {
+	db := newTestDB(t)
+	inbox := &InboxModel{DB: db}
+
+	owner := seedUser(t, db, "dina")
+	other := seedUser(t, db, "eli")
+	forumID := seedForum(t, db, owner, "post", 1, "go")
+	for i := 0; i < 3; i++ {
+		if err := notify(db, &liveBatch{}, owner, other, EventPostLike, forumID, 0, ""); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+
+	list, err := inbox.List(owner, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	if _, err := inbox.MarkRead(other, list[0].ID); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("MarkRead by another user: err = %v, want ErrNoRecord", err)
+	}
+	got, err := inbox.MarkRead(owner, list[0].ID)
+	if err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got.ForumID != forumID || got.Event != EventPostLike {
+		t.Fatalf("MarkRead returned %+v", got)
+	}
+
+	count, err := inbox.UnreadCount(owner)
+	if err != nil {
+		t.Fatalf("UnreadCount: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("UnreadCount = %d, want 2", count)
+	}
+	unread, err := inbox.List(owner, true, 0)
+	if err != nil {
+		t.Fatalf("List unread: %v", err)
+	}
+	if len(unread) != 2 {
+		t.Fatalf("unread list has %d entries, want 2", len(unread))
+	}
+
+	if err := inbox.MarkAllRead(owner); err != nil {
+		t.Fatalf("MarkAllRead: %v", err)
+	}
+	if count, _ := inbox.UnreadCount(owner); count != 0 {
+		t.Fatalf("UnreadCount after MarkAllRead = %d", count)
+	}
+}
+
+func TestInboxPrune(t *testing.T) {
+	db := newTestDB(t)
+	inbox := &InboxModel{DB: db}
+
+	owner := seedUser(t, db, "finn")
+	other := seedUser(t, db, "gail")
+	forumID := seedForum(t, db, owner, "post", 1, "go")
+
+	rows := []struct {
+		age  string
+		read bool
+	}{
+		{"-40 days", true},
+		{"-40 days", false},
+		{"-200 days", false},
+		{"-1 day", true},
+	}
+	for _, r := range rows {
+		readAt := "NULL"
+		if r.read {
+			readAt = "created"
+		}
+		_, err := db.Exec(`INSERT INTO user_notifications (recipient_id, actor_id, event, forum_id, created)
+			VALUES (?, ?, ?, ?, datetime('now', ?))`, owner, other, EventPostLike, forumID, r.age)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`UPDATE user_notifications SET read_at = ` + readAt + ` WHERE id = last_insert_rowid()`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	removed, err := inbox.Prune(now.Add(-30*24*time.Hour), now.Add(-180*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("Prune removed %d, want 2", removed)
+	}
+
+	list, err := inbox.List(owner, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Read == list[1].Read {
+		t.Fatalf("kept notifications = %+v", list)
+	}
+}
+
+func TestInboxFollowsRemovals(t *testing.T) {
+	db := newTestDB(t)
+	inbox := &InboxModel{DB: db}
+	forums := &ForumModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+
+	owner := seedUser(t, db, "hana")
+	other := seedUser(t, db, "ivan")
+	forumID := seedForum(t, db, owner, "post", 1, "go")
+
+	if _, err := comments.CommentPost(forumID, other, "first"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+	var commentID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE comment = 'first'`).Scan(&commentID); err != nil {
+		t.Fatal(err)
+	}
+	if err := comments.RemoveCommentPost(commentID, other); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+	if got := inboxEvents(t, inbox, owner); len(got) != 0 {
+		t.Fatalf("notifications for a deleted comment kept: %v", got)
+	}
+
+	if err := notify(db, &liveBatch{}, owner, other, EventPostLike, forumID, 0, ""); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if err := forums.Remove(context.Background(), forumID, owner); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if got := inboxEvents(t, inbox, owner); len(got) != 0 {
+		t.Fatalf("notifications for a deleted post kept: %v", got)
+	}
+}
+
+func TestUserNotificationsMigrationMovesReplies(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.UpSteps(5); err != nil {
+		t.Fatalf("apply legacy migrations: %v", err)
+	}
+
+	owner := seedUser(t, db, "jade")
+	replier := seedUser(t, db, "kurt")
+	for _, status := range []string{"reply", "moder"} {
+		_, err := db.Exec(`INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
+			VALUES ('kurt', 'hi', ?, '7', ?, ?)`, status, owner, replier)
+		if err != nil {
+			t.Fatalf("insert legacy notification: %v", err)
+		}
+	}
+
+	if _, err := migrator.UpSteps(1); err != nil {
+		t.Fatalf("apply inbox migration: %v", err)
+	}
+
+	var recipient, actor, forumID int
+	var event string
+	err = db.QueryRow(`SELECT recipient_id, actor_id, event, forum_id FROM user_notifications`).Scan(&recipient, &actor, &event, &forumID)
+	if err != nil {
+		t.Fatalf("query moved notification: %v", err)
+	}
+	if recipient != owner || actor != replier || event != EventCommentReply || forumID != 7 {
+		t.Fatalf("moved notification = %d %d %q %d", recipient, actor, event, forumID)
+	}
+	var queued int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_notifications`).Scan(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 {
+		t.Fatalf("moderation queue has %d rows, want 1", queued)
+	}
+
+	if _, err := migrator.Down(1); err != nil {
+		t.Fatalf("revert inbox migration: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_notifications WHERE status = 'reply'`).Scan(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 {
+		t.Fatalf("restored reply notifications = %d, want 1", queued)
+	}
+}
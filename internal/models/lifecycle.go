@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Post states, kept in forums.status. Pending and published are the 0 and 1
+// InvisibleStatus and VisibleStatus always stored. Published posts are
+// listed and shown to everyone, and archived ones stay readable by URL; the
+// others are shown only to their author and reviewers.
+const (
+	PostPending   = InvisibleStatus
+	PostPublished = VisibleStatus
+	PostDraft     = 2
+	PostRejected  = 3
+	PostArchived  = 4
+)
+
+// postStates names each state, as shown to users and in the API.
+var postStates = map[int]string{
+	PostPending:   "pending",
+	PostPublished: "published",
+	PostDraft:     "draft",
+	PostRejected:  "rejected",
+	PostArchived:  "archived",
+}
+
+// postTransitions lists the states a post may move to from each state.
+// Drafts and rejected posts are submitted for review (or published at once
+// by roles that may publish); pending posts are approved, rejected or taken
+// back to drafts; published posts are archived, or rejected when a report
+// against them is upheld.
+var postTransitions = map[int][]int{
+	PostDraft:     {PostPending, PostPublished},
+	PostPending:   {PostPublished, PostRejected, PostDraft},
+	PostPublished: {PostArchived, PostRejected},
+	PostRejected:  {PostPending, PostPublished, PostDraft},
+	PostArchived:  {PostPublished},
+}
+
+var ErrInvalidState = errors.New("models: post cannot move to that state")
+
+// PostStateName returns the name of state, or "" if there is no such state.
+func PostStateName(state int) string {
+	return postStates[state]
+}
+
+// CanTransition reports whether a post may move from one state to another.
+func CanTransition(from, to int) bool {
+	for _, s := range postTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PostStatus is where a post stands in its lifecycle.
+type PostStatus struct {
+	OwnerID int
+	State   int
+	// Reason is why the post was rejected; empty in other states.
+	Reason  string
+	Changed time.Time
+	Expires time.Time
+}
+
+// Status returns the lifecycle status of a post in any state. Posts in the
+// trash give ErrNoRecord.
+func (m *ForumModel) Status(forumID int) (*PostStatus, error) {
+	stmt := `SELECT user_id, status, status_reason, status_changed, expires
+	FROM forums WHERE id = ? AND deleted IS NULL`
+
+	s := &PostStatus{}
+	var changed sql.NullTime
+	err := m.DB.QueryRow(stmt, forumID).Scan(&s.OwnerID, &s.State, &s.Reason, &changed, &s.Expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	s.Changed = changed.Time
+	return s, nil
+}
+
+// SetState moves a post to state on behalf of actorID; reason is kept for a
+// rejection and shown to the author. Moves the state machine does not allow
+// give ErrInvalidState.
+func (m *ForumModel) SetState(ctx context.Context, forumID, state, actorID int, reason string) error {
+	live := &liveBatch{}
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return setPostState(tx, live, forumID, state, actorID, reason)
+	}, func() { live.publish(m.DB, m.Live) })
+}
+
+// setPostState is SetState inside the caller's transaction. Entering
+// pending queues the post for review and leaving it closes its queue entry;
+// the author of a rejected post is told why in their inbox.
+func setPostState(tx *sql.Tx, live *liveBatch, forumID, state, actorID int, reason string) error {
+	var from, ownerID int
+	var title, content string
+	stmt := `SELECT status, user_id, title, content FROM forums WHERE id = ? AND deleted IS NULL`
+	err := tx.QueryRow(stmt, forumID).Scan(&from, &ownerID, &title, &content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	if !CanTransition(from, state) {
+		return ErrInvalidState
+	}
+	if state != PostRejected {
+		reason = ""
+	}
+
+	stmt = `UPDATE forums
+	SET status = ?, status_reason = ?, status_changed = strftime('%Y-%m-%d %H:%M:%S', 'now')
+	WHERE id = ? AND status = ?`
+
+	result, err := tx.Exec(stmt, state, reason, forumID, from)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrInvalidState
+	}
+
+	if from == PostPending {
+		stmt = `DELETE FROM forum_notifications WHERE forum_link = ? AND status = ? AND user_id = -1`
+		if _, err := tx.Exec(stmt, forumID, ModerStatus); err != nil {
+			return err
+		}
+	}
+	if state == PostPending {
+		if err := queueForReview(tx, forumID, ownerID, title+"\n"+content); err != nil {
+			return err
+		}
+	}
+	if state == PostRejected {
+		return notify(tx, live, ownerID, actorID, EventPostRejected, forumID, 0, reason)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     bool
+	}{
+		{PostDraft, PostPending, true},
+		{PostPending, PostRejected, true},
+		{PostRejected, PostPending, true},
+		{PostPublished, PostArchived, true},
+		{PostArchived, PostPublished, true},
+		{PostDraft, PostRejected, false},
+		{PostArchived, PostDraft, false},
+		{PostPublished, PostPublished, false},
+		{PostPublished, PostDraft, false},
+		{7, PostPublished, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", PostStateName(tt.from), PostStateName(tt.to), got, tt.want)
+		}
+	}
+}
+
+func TestPostLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	inbox := &InboxModel{DB: db}
+
+	author := seedUser(t, db, "ola")
+	moder := seedUser(t, db, "pia")
+	forumID := seedForum(t, db, author, "draft post", PostPending, "go")
+
+	queued := func() int {
+		t.Helper()
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM forum_notifications WHERE forum_link = ? AND status = ? AND user_id = -1`,
+			forumID, ModerStatus).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	state := func() *PostStatus {
+		t.Helper()
+		st, err := forums.Status(forumID)
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		return st
+	}
+
+	if err := forums.SetState(context.Background(), forumID, PostDraft, author, ""); err != nil {
+		t.Fatalf("SetState(draft): %v", err)
+	}
+	if st := state(); st.State != PostDraft || st.OwnerID != author || st.Changed.IsZero() {
+		t.Fatalf("status = %+v", st)
+	}
+	if err := forums.SetState(context.Background(), forumID, PostRejected, moder, "no"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("rejecting a draft err = %v, want ErrInvalidState", err)
+	}
+
+	if err := forums.SetState(context.Background(), forumID, PostPending, author, ""); err != nil {
+		t.Fatalf("SetState(pending): %v", err)
+	}
+	if n := queued(); n != 1 {
+		t.Fatalf("%d queue entries for a pending post, want 1", n)
+	}
+
+	if err := forums.SetState(context.Background(), forumID, PostRejected, moder, "off topic"); err != nil {
+		t.Fatalf("SetState(rejected): %v", err)
+	}
+	if st := state(); st.State != PostRejected || st.Reason != "off topic" {
+		t.Fatalf("rejected status = %+v", st)
+	}
+	if n := queued(); n != 0 {
+		t.Fatalf("%d queue entries left after rejection", n)
+	}
+	list, err := inbox.List(author, false, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].Event != EventPostRejected || list[0].Body != "off topic" || list[0].ForumID != forumID {
+		t.Fatalf("author inbox = %+v", list)
+	}
+
+	// The author sees the post and why it was turned down.
+	f, err := forums.Get(forumID, author, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if f.State != "rejected" || f.StateReason != "off topic" {
+		t.Fatalf("Get state = %q %q", f.State, f.StateReason)
+	}
+	posts, err := forums.ShowAllUserPosts(author)
+	if err != nil || len(posts) != 1 || posts[0].State != "rejected" {
+		t.Fatalf("ShowAllUserPosts = %+v, %v", posts, err)
+	}
+
+	// Published again, the reason goes away.
+	if err := forums.SetState(context.Background(), forumID, PostPublished, moder, "ignored"); err != nil {
+		t.Fatalf("SetState(published): %v", err)
+	}
+	if st := state(); st.State != PostPublished || st.Reason != "" {
+		t.Fatalf("published status = %+v", st)
+	}
+	if _, err := forums.GetUserIDFromForum(forumID); err != nil {
+		t.Fatalf("GetUserIDFromForum(published): %v", err)
+	}
+
+	if err := forums.Remove(context.Background(), forumID, author); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forums.Status(forumID); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Status(trashed) err = %v, want ErrNoRecord", err)
+	}
+	if err := forums.SetState(context.Background(), forumID, PostArchived, author, ""); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("SetState(trashed) err = %v, want ErrNoRecord", err)
+	}
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// Publisher receives live events once the write that caused them has
+// committed. Models with a nil Publisher publish nothing.
+type Publisher interface {
+	Publish(topic, event string, data interface{})
+}
+
+// Live event types.
+const (
+	LiveComment      = "comment"
+	LiveReaction     = "reaction"
+	LiveNotification = "notification"
+	LiveUnread       = "unread"
+)
+
+// ForumTopic is the topic carrying new comments and votes on a post.
+func ForumTopic(forumID int) string {
+	return "forum:" + strconv.Itoa(forumID)
+}
+
+// InboxTopic is the topic carrying a user's new notifications and unread
+// count.
+func InboxTopic(userID int) string {
+	return "inbox:" + strconv.Itoa(userID)
+}
+
+// CommentEvent announces a new comment or reply on a post.
+type CommentEvent struct {
+	ForumID   int    `json:"forum_id"`
+	CommentID int    `json:"comment_id"`
+	ParentID  int    `json:"parent_id,omitempty"`
+	Depth     int    `json:"depth"`
+	User      string `json:"user"`
+	Comment   string `json:"comment"`
+}
+
+// ReactionEvent carries the new vote totals of a post, or of one of its
+// comments when CommentID is set.
+type ReactionEvent struct {
+	ForumID   int `json:"forum_id"`
+	CommentID int `json:"comment_id,omitempty"`
+	Likes     int `json:"likes"`
+	Dislikes  int `json:"dislikes"`
+}
+
+// NotificationEvent mirrors a new inbox entry.
+type NotificationEvent struct {
+	ID        int    `json:"id"`
+	Event     string `json:"event"`
+	ActorID   int    `json:"actor_id"`
+	ForumID   int    `json:"forum_id"`
+	CommentID int    `json:"comment_id,omitempty"`
+	Body      string `json:"body,omitempty"`
+}
+
+// UnreadEvent carries a user's current unread notification count.
+type UnreadEvent struct {
+	Unread int `json:"unread"`
+}
+
+type liveEvent struct {
+	topic string
+	event string
+	data  interface{}
+}
+
+// liveBatch collects the events of a write so they are published only after
+// it commits.
+type liveBatch struct {
+	events  []liveEvent
+	inboxes []int
+}
+
+func (b *liveBatch) add(topic, event string, data interface{}) {
+	b.events = append(b.events, liveEvent{topic: topic, event: event, data: data})
+}
+
+// addComment queues a new comment for the post's stream, looking up the
+// author's name inside the writing transaction.
+func (b *liveBatch) addComment(db dbtx, forumID, commentID, parentID, depth, userID int, comment string) error {
+	e := CommentEvent{ForumID: forumID, CommentID: commentID, ParentID: parentID, Depth: depth, Comment: comment}
+	err := db.QueryRow(`SELECT name FROM users WHERE id = ?`, userID).Scan(&e.User)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	b.add(ForumTopic(forumID), LiveComment, e)
+	return nil
+}
+
+// touchInbox records that userID's unread count changed.
+func (b *liveBatch) touchInbox(userID int) {
+	for _, id := range b.inboxes {
+		if id == userID {
+			return
+		}
+	}
+	b.inboxes = append(b.inboxes, userID)
+}
+
+// publish sends the collected events, followed by the fresh unread count of
+// every inbox the write touched.
+func (b *liveBatch) publish(db *sql.DB, p Publisher) {
+	if p == nil {
+		return
+	}
+	for _, e := range b.events {
+		p.Publish(e.topic, e.event, e.data)
+	}
+	inbox := &InboxModel{DB: db}
+	for _, userID := range b.inboxes {
+		n, err := inbox.UnreadCount(userID)
+		if err != nil {
+			continue
+		}
+		p.Publish(InboxTopic(userID), LiveUnread, UnreadEvent{Unread: n})
+	}
+}
+
+// reactionTotals reads the vote counters of a post, or of a comment when
+// commentID is set.
+func reactionTotals(db *sql.DB, forumID, commentID int) (ReactionEvent, error) {
+	e := ReactionEvent{ForumID: forumID, CommentID: commentID}
+
+	stmt := `SELECT likes_count, dislikes_count FROM forums WHERE id = ?;`
+	target := forumID
+	if commentID != 0 {
+		stmt = `SELECT likes_count, dislikes_count FROM forum_comments WHERE id = ?;`
+		target = commentID
+	}
+
+	err := db.QueryRow(stmt, target).Scan(&e.Likes, &e.Dislikes)
+	return e, err
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+type published struct {
+	topic string
+	event string
+	data  interface{}
+}
+
+type recordingPublisher struct {
+	events []published
+}
+
+func (p *recordingPublisher) Publish(topic, event string, data interface{}) {
+	p.events = append(p.events, published{topic, event, data})
+}
+
+func (p *recordingPublisher) take() []published {
+	events := p.events
+	p.events = nil
+	return events
+}
+
+func TestLiveEventsFollowWrites(t *testing.T) {
+	db := newTestDB(t)
+	live := &recordingPublisher{}
+	comments := &ForumCommentModel{DB: db, Live: live}
+	likes := &ForumLikesModel{DB: db, Live: live}
+	inbox := &InboxModel{DB: db, Live: live}
+
+	author := seedUser(t, db, "lina")
+	reader := seedUser(t, db, "omar")
+	forumID := seedForum(t, db, author, "Live post", 1, "go")
+
+	if _, err := comments.CommentPost(forumID, reader, "first"); err != nil {
+		t.Fatalf("CommentPost: %v", err)
+	}
+	var commentID int
+	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE forum_id = ?`, forumID).Scan(&commentID); err != nil {
+		t.Fatalf("comment id: %v", err)
+	}
+	var notificationID int
+	if err := db.QueryRow(`SELECT id FROM user_notifications WHERE recipient_id = ?`, author).Scan(&notificationID); err != nil {
+		t.Fatalf("notification id: %v", err)
+	}
+
+	want := []published{
+		{ForumTopic(forumID), LiveComment, CommentEvent{ForumID: forumID, CommentID: commentID, User: "omar", Comment: "first"}},
+		{InboxTopic(author), LiveNotification, NotificationEvent{ID: notificationID, Event: EventPostComment, ActorID: reader, ForumID: forumID, CommentID: commentID, Body: "first"}},
+		{InboxTopic(author), LiveUnread, UnreadEvent{Unread: 1}},
+	}
+	if got := live.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("comment events = %+v, want %+v", got, want)
+	}
+
+	replyID, err := comments.ReplyPost(forumID, author, commentID, "thanks")
+	if err != nil {
+		t.Fatalf("ReplyPost: %v", err)
+	}
+	got := live.take()
+	if len(got) != 3 || got[0].data != (CommentEvent{ForumID: forumID, CommentID: replyID, ParentID: commentID, Depth: 1, User: "lina", Comment: "thanks"}) {
+		t.Fatalf("reply events = %+v", got)
+	}
+	if got[1].topic != InboxTopic(reader) || got[2].data != (UnreadEvent{Unread: 1}) {
+		t.Fatalf("reply should reach the parent author's inbox: %+v", got)
+	}
+
+	if _, err := likes.LikeOrDislike(forumID, reader, 1); err != nil {
+		t.Fatalf("LikeOrDislike: %v", err)
+	}
+	got = live.take()
+	if len(got) != 3 || got[0].data != (ReactionEvent{ForumID: forumID, Likes: 1}) || got[2].data != (UnreadEvent{Unread: 2}) {
+		t.Fatalf("like events = %+v", got)
+	}
+
+	// Withdrawing the vote removes its notification, so the unread count
+	// drops without a new notification event.
+	if _, err := likes.LikeOrDislike(forumID, reader, 1); err != nil {
+		t.Fatalf("LikeOrDislike withdraw: %v", err)
+	}
+	want = []published{
+		{ForumTopic(forumID), LiveReaction, ReactionEvent{ForumID: forumID}},
+		{InboxTopic(author), LiveUnread, UnreadEvent{Unread: 1}},
+	}
+	if got := live.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("withdraw events = %+v, want %+v", got, want)
+	}
+
+	if _, err := likes.LikeOrDislikeComment(replyID, reader, -1); err != nil {
+		t.Fatalf("LikeOrDislikeComment: %v", err)
+	}
+	got = live.take()
+	if len(got) != 3 || got[0].data != (ReactionEvent{ForumID: forumID, CommentID: replyID, Dislikes: 1}) || got[1].topic != InboxTopic(author) {
+		t.Fatalf("comment vote events = %+v", got)
+	}
+
+	if err := inbox.MarkAllRead(author); err != nil {
+		t.Fatalf("MarkAllRead: %v", err)
+	}
+	want = []published{{InboxTopic(author), LiveUnread, UnreadEvent{Unread: 0}}}
+	if got := live.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("read events = %+v, want %+v", got, want)
+	}
+}
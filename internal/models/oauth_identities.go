@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OAuthIdentity is an external login linked to a user: Subject is the
+// provider's stable id for the person, Email what the provider reported.
+type OAuthIdentity struct {
+	ID       int
+	Provider string
+	Subject  string
+	UserID   int
+	Email    string
+	Created  time.Time
+}
+
+// OAuthIdentityModel stores the Google, GitHub, ... logins of users.
+type OAuthIdentityModel struct {
+	DB *sql.DB
+}
+
+// UserID returns the user that provider's subject signs in as, or
+// ErrNoRecord.
+func (m *OAuthIdentityModel) UserID(provider, subject string) (int, error) {
+	var userID int
+	err := m.DB.QueryRow(`SELECT user_id FROM oauth_identities WHERE provider = ? AND subject = ?`,
+		provider, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// InsertUser signs up a new user through provider. The account gets no
+// usable password, its email counts as verified by the provider, and the
+// identity is linked in the same transaction. A taken email or name gives
+// ErrDuplicateEmail or ErrDuplicateName.
+func (m *OAuthIdentityModel) InsertUser(provider, subject, name, email string, role int) (int, error) {
+	// Nobody knows this password; it only keeps the column meaningful.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(generateSessionID()), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := sessionTime(time.Now())
+	result, err := tx.Exec(`INSERT INTO users (name, email, hashed_password, created, email_verified, has_password)
+	VALUES (?, ?, ?, ?, ?, 0)`, name, email, hashedPassword, now, now)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			switch {
+			case strings.Contains(sqliteErr.Error(), "users.name"):
+				return 0, ErrDuplicateName
+			case strings.Contains(sqliteErr.Error(), "users.email"):
+				return 0, ErrDuplicateEmail
+			}
+		}
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	userID := int(id)
+
+	if _, err := tx.Exec(`INSERT INTO roles (role, user_id) VALUES (?, ?)`, role, userID); err != nil {
+		return 0, err
+	}
+	if err := linkIdentity(tx, userID, provider, subject, email, now); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// Link connects provider's subject to userID. Linking the same identity
+// again is a no-op; an identity of another user gives ErrIdentityTaken, a
+// second identity of the same provider ErrProviderLinked.
+func (m *OAuthIdentityModel) Link(userID int, provider, subject, email string) error {
+	owner, err := m.UserID(provider, subject)
+	switch {
+	case err == nil && owner == userID:
+		return nil
+	case err == nil:
+		return ErrIdentityTaken
+	case !errors.Is(err, ErrNoRecord):
+		return err
+	}
+
+	return linkIdentity(m.DB, userID, provider, subject, email, sessionTime(time.Now()))
+}
+
+func linkIdentity(db dbtx, userID int, provider, subject, email, now string) error {
+	_, err := db.Exec(`INSERT INTO oauth_identities (provider, subject, user_id, email, created)
+	VALUES (?, ?, ?, ?, ?)`, provider, subject, userID, email, now)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			if strings.Contains(sqliteErr.Error(), "oauth_identities.user_id") {
+				return ErrProviderLinked
+			}
+			return ErrIdentityTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// Identities lists the logins linked to userID by provider name.
+func (m *OAuthIdentityModel) Identities(userID int) ([]*OAuthIdentity, error) {
+	rows, err := m.DB.Query(`SELECT id, provider, subject, email, created FROM oauth_identities
+	WHERE user_id = ? ORDER BY provider`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*OAuthIdentity{}
+	for rows.Next() {
+		i := &OAuthIdentity{UserID: userID}
+		if err := rows.Scan(&i.ID, &i.Provider, &i.Subject, &i.Email, &i.Created); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Unlink removes the provider login of userID. It returns ErrNoRecord when
+// none is linked and ErrLastSignIn when it is the only way into an account
+// without a password.
+func (m *OAuthIdentityModel) Unlink(userID int, provider string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasPassword bool
+	var others int
+	err = tx.QueryRow(`SELECT has_password,
+		(SELECT COUNT(*) FROM oauth_identities WHERE user_id = users.id AND provider <> ?)
+	FROM users WHERE id = ?`, provider, userID).Scan(&hasPassword, &others)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM oauth_identities WHERE user_id = ? AND provider = ?`, userID, provider)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	if !hasPassword && others == 0 {
+		return ErrLastSignIn
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOAuthIdentitySignupAndLink(t *testing.T) {
+	db := newTestDB(t)
+	model := &OAuthIdentityModel{DB: db}
+	users := &UserModel{DB: db}
+
+	userID, err := model.InsertUser("github", "42", "octo", "octo@example.com", UserRole)
+	if err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	if got, err := model.UserID("github", "42"); err != nil || got != userID {
+		t.Fatalf("UserID = %d, %v; want %d", got, err, userID)
+	}
+	if _, err := model.UserID("google", "42"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("UserID other provider err = %v, want ErrNoRecord", err)
+	}
+	if u, err := users.ByEmail("octo@example.com"); err != nil || !u.EmailVerified {
+		t.Fatalf("provider signup not verified: %+v, %v", u, err)
+	}
+	if role, err := users.GetUserRole(userID); err != nil || role != UserRole {
+		t.Fatalf("role = %d, %v", role, err)
+	}
+
+	if _, err := model.InsertUser("google", "g-1", "octo", "other@example.com", UserRole); !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("duplicate name err = %v", err)
+	}
+	if _, err := model.InsertUser("google", "g-1", "octo2", "octo@example.com", UserRole); !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("duplicate email err = %v", err)
+	}
+	if _, err := model.UserID("google", "g-1"); !errors.Is(err, ErrNoRecord) {
+		t.Fatal("failed signup left an identity behind")
+	}
+
+	other := seedUser(t, db, "other")
+	if err := model.Link(other, "github", "42", ""); !errors.Is(err, ErrIdentityTaken) {
+		t.Fatalf("Link taken identity err = %v", err)
+	}
+	if err := model.Link(userID, "github", "42", "octo@example.com"); err != nil {
+		t.Fatalf("relinking the same identity: %v", err)
+	}
+	if err := model.Link(userID, "github", "43", ""); !errors.Is(err, ErrProviderLinked) {
+		t.Fatalf("second github login err = %v", err)
+	}
+	if err := model.Link(userID, "google", "g-1", "octo@gmail.example"); err != nil {
+		t.Fatalf("Link google: %v", err)
+	}
+
+	identities, err := model.Identities(userID)
+	if err != nil || len(identities) != 2 || identities[0].Provider != "github" || identities[1].Provider != "google" {
+		t.Fatalf("Identities = %+v, %v", identities, err)
+	}
+}
+
+func TestOAuthIdentityUnlinkKeepsAWayIn(t *testing.T) {
+	db := newTestDB(t)
+	model := &OAuthIdentityModel{DB: db}
+	users := &UserModel{DB: db}
+
+	userID, err := model.InsertUser("github", "42", "octo", "octo@example.com", UserRole)
+	if err != nil {
+		t.Fatalf("InsertUser: %v", err)
+	}
+	if err := model.Link(userID, "google", "g-1", ""); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+
+	if err := model.Unlink(userID, "gitlab"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Unlink unknown provider err = %v", err)
+	}
+	if err := model.Unlink(userID, "google"); err != nil {
+		t.Fatalf("Unlink with another login left: %v", err)
+	}
+	if err := model.Unlink(userID, "github"); !errors.Is(err, ErrLastSignIn) {
+		t.Fatalf("Unlink last login err = %v, want ErrLastSignIn", err)
+	}
+	if got, err := model.UserID("github", "42"); err != nil || got != userID {
+		t.Fatalf("refused unlink still removed the login: %d, %v", got, err)
+	}
+
+	if err := users.SetPassword(context.Background(), userID, "finally123"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if err := model.Unlink(userID, "github"); err != nil {
+		t.Fatalf("Unlink once a password is set: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "database/sql"
+
+// PermissionModel stores the permissions granted to each role.
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// Grants returns every role's permissions.
+func (m *PermissionModel) Grants() (map[int][]string, error) {
+	rows, err := m.DB.Query(`SELECT role, permission FROM role_permissions ORDER BY role, permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := map[int][]string{}
+	for rows.Next() {
+		var role int
+		var permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		grants[role] = append(grants[role], permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// SetGrants replaces the permissions of role.
+func (m *PermissionModel) SetGrants(role int, permissions []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = ?`, role); err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		_, err := tx.Exec(`INSERT INTO role_permissions (role, permission) VALUES (?, ?)`, role, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+func TestPermissionMigrationSeedsDefaults(t *testing.T) {
+	db := newTestDB(t)
+	m := &PermissionModel{DB: db}
+
+	grants, err := m.Grants()
+	if err != nil {
+		t.Fatalf("Grants: %v", err)
+	}
+	for _, role := range policy.Roles {
+		want := make([]string, 0, len(policy.Defaults[role]))
+		for _, perm := range policy.Defaults[role] {
+			want = append(want, string(perm))
+		}
+		sort.Strings(want)
+		if got := grants[role]; !slices.Equal(got, want) {
+			t.Errorf("%s grants = %v, want %v", policy.RoleName(role), got, want)
+		}
+	}
+}
+
+func TestPermissionSetGrants(t *testing.T) {
+	db := newTestDB(t)
+	m := &PermissionModel{DB: db}
+
+	if err := m.SetGrants(policy.Moderator, []string{"tag.manage", "audit.read"}); err != nil {
+		t.Fatalf("SetGrants: %v", err)
+	}
+	if err := m.SetGrants(policy.User, nil); err != nil {
+		t.Fatalf("SetGrants empty: %v", err)
+	}
+
+	grants, err := m.Grants()
+	if err != nil {
+		t.Fatalf("Grants: %v", err)
+	}
+	if want := []string{"audit.read", "tag.manage"}; !reflect.DeepEqual(grants[policy.Moderator], want) {
+		t.Fatalf("moderator grants = %v, want %v", grants[policy.Moderator], want)
+	}
+	if got := grants[policy.User]; len(got) != 0 {
+		t.Fatalf("user grants = %v, want none", got)
+	}
+	if len(grants[policy.Admin]) != len(policy.Defaults[policy.Admin]) {
+		t.Fatalf("admin grants changed: %v", grants[policy.Admin])
+	}
+}
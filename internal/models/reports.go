@@ -0,0 +1,371 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Report states. A report starts open, moves to in-review once someone is
+// assigned, and ends resolved (the post was hidden) or dismissed.
+const (
+	ReportOpen      = "open"
+	ReportInReview  = "in-review"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// ReportStates lists every report state in queue order.
+var ReportStates = []string{ReportOpen, ReportInReview, ReportResolved, ReportDismissed}
+
+// ReportReasons are the reasons a post can be reported for.
+var ReportReasons = []string{"irrelevant", "obscene", "harassment", "illegal", "insulting", "other"}
+
+// Moderation actions recorded in the audit log.
+const (
+	ActionReportFiled         = "report-filed"
+	ActionReportAssigned      = "report-assigned"
+	ActionReportResolved      = "report-resolved"
+	ActionReportDismissed     = "report-dismissed"
+	ActionPostApproved        = "post-approved"
+	ActionPostRejected        = "post-rejected"
+	ActionModeratorPromoted   = "moderator-promoted"
+	ActionModeratorDemoted    = "moderator-demoted"
+	ActionNotificationRemoved = "notification-removed"
+	ActionPermissionsChanged  = "permissions-changed"
+	ActionRevisionRestored    = "revision-restored"
+	ActionExpiryChanged       = "expiry-changed"
+)
+
+const AuditPageSize = 100
+
+var ErrInvalidTransition = errors.New("models: report is already closed")
+
+type Report struct {
+	ID           int
+	ForumID      int
+	ForumTitle   string
+	ReporterID   int
+	ReporterName string
+	Reasons      []string
+	Details      string
+	State        string
+	AssigneeID   int
+	AssigneeName string
+	Resolution   string
+	Created      time.Time
+	Updated      time.Time
+}
+
+// Open reports whether the report still waits for a decision.
+func (r *Report) Open() bool {
+	return r.State == ReportOpen || r.State == ReportInReview
+}
+
+// ModerationAction is one immutable entry of the moderation audit log.
+// ReportID, ForumID and TargetUserID are 0 when the action has none.
+type ModerationAction struct {
+	ID           int
+	ActorID      int
+	ActorName    string
+	Action       string
+	ReportID     int
+	ForumID      int
+	TargetUserID int
+	TargetName   string
+	Note         string
+	Created      time.Time
+}
+
+// AuditFilter narrows the audit log. Before pages backwards from an action
+// id; zero values match everything.
+type AuditFilter struct {
+	ReportID int
+	Before   int
+}
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// record appends an action to the audit log.
+func record(db dbtx, a ModerationAction) error {
+	stmt := `INSERT INTO moderation_actions (actor_id, action, report_id, forum_id, target_user_id, note, created)
+	VALUES (?, ?, ?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'));`
+
+	_, err := db.Exec(stmt, a.ActorID, a.Action, a.ReportID, a.ForumID, a.TargetUserID, a.Note)
+	return err
+}
+
+// Record appends a moderation action taken outside the report workflow,
+// such as approving a post or promoting a moderator.
+func (m *ReportModel) Record(ctx context.Context, a ModerationAction) error {
+	stmt := `INSERT INTO moderation_actions (actor_id, action, report_id, forum_id, target_user_id, note, created)
+	VALUES (?, ?, ?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'));`
+
+	_, err := conn(ctx, m.DB).ExecContext(ctx, stmt, a.ActorID, a.Action, a.ReportID, a.ForumID, a.TargetUserID, a.Note)
+	return err
+}
+
+// File opens a report against forumID. reasons must be taken from
+// ReportReasons; they are stored in that order without duplicates.
+func (m *ReportModel) File(forumID, reporterID int, reasons []string, details string) (int, error) {
+	var stored []string
+	for _, reason := range ReportReasons {
+		for _, r := range reasons {
+			if r == reason {
+				stored = append(stored, reason)
+				break
+			}
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO reports (forum_id, reporter_id, reasons, details, created, updated)
+	VALUES (?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'), strftime('%Y-%m-%d %H:%M:%S', 'now'));`
+
+	result, err := tx.Exec(stmt, forumID, reporterID, strings.Join(stored, ","), details)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = record(tx, ModerationAction{ActorID: reporterID, Action: ActionReportFiled, ReportID: int(id), ForumID: forumID})
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+const reportSelect = `SELECT r.id, r.forum_id, COALESCE(f.title, ''), r.reporter_id, COALESCE(ru.name, ''),
+	r.reasons, r.details, r.state, r.assignee_id, COALESCE(au.name, ''), r.resolution, r.created, r.updated
+	FROM reports r
+	LEFT JOIN forums f ON f.id = r.forum_id
+	LEFT JOIN users ru ON ru.id = r.reporter_id
+	LEFT JOIN users au ON au.id = r.assignee_id`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	r := &Report{}
+	var reasons string
+	err := row.Scan(&r.ID, &r.ForumID, &r.ForumTitle, &r.ReporterID, &r.ReporterName,
+		&reasons, &r.Details, &r.State, &r.AssigneeID, &r.AssigneeName, &r.Resolution, &r.Created, &r.Updated)
+	if err != nil {
+		return nil, err
+	}
+	if reasons != "" {
+		r.Reasons = strings.Split(reasons, ",")
+	}
+	return r, nil
+}
+
+func (m *ReportModel) Get(id int) (*Report, error) {
+	r, err := scanReport(m.DB.QueryRow(reportSelect+` WHERE r.id = ?;`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns the reports in state, oldest first. An empty state lists the
+// queue: every open and in-review report.
+func (m *ReportModel) List(state string) ([]*Report, error) {
+	stmt := reportSelect + ` WHERE r.state = ? ORDER BY r.id;`
+	args := []interface{}{state}
+	if state == "" {
+		stmt = reportSelect + ` WHERE r.state IN (?, ?) ORDER BY r.id;`
+		args = []interface{}{ReportOpen, ReportInReview}
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// transition moves an open or in-review report to state inside tx, setting
+// assignee and resolution when they are given. It returns the report's post.
+func transition(tx *sql.Tx, id int, state string, assigneeID int, resolution string) (int, error) {
+	stmt := `UPDATE reports
+	SET state = ?,
+		assignee_id = CASE WHEN ? > 0 THEN ? ELSE assignee_id END,
+		resolution = ?,
+		updated = strftime('%Y-%m-%d %H:%M:%S', 'now')
+	WHERE id = ? AND state IN (?, ?)
+	RETURNING forum_id;`
+
+	var forumID int
+	err := tx.QueryRow(stmt, state, assigneeID, assigneeID, resolution, id, ReportOpen, ReportInReview).Scan(&forumID)
+	if err == nil {
+		return forumID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var exists int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM reports WHERE id = ?`, id).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, ErrNoRecord
+	}
+	return 0, ErrInvalidTransition
+}
+
+// Assign puts a report in review by assigneeID. Closed reports give
+// ErrInvalidTransition.
+func (m *ReportModel) Assign(id, assigneeID, actorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	forumID, err := transition(tx, id, ReportInReview, assigneeID, "")
+	if err != nil {
+		return err
+	}
+
+	err = record(tx, ModerationAction{
+		ActorID:      actorID,
+		Action:       ActionReportAssigned,
+		ReportID:     id,
+		ForumID:      forumID,
+		TargetUserID: assigneeID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Resolve upholds a report: the post is rejected and the report closed with
+// note as its resolution, which the author is shown as the reason.
+func (m *ReportModel) Resolve(ctx context.Context, id, actorID int, note string) (*Report, error) {
+	return m.close(ctx, id, actorID, ReportResolved, ActionReportResolved, note)
+}
+
+// Dismiss closes a report without acting on the post.
+func (m *ReportModel) Dismiss(ctx context.Context, id, actorID int, note string) (*Report, error) {
+	return m.close(ctx, id, actorID, ReportDismissed, ActionReportDismissed, note)
+}
+
+func (m *ReportModel) close(ctx context.Context, id, actorID int, state, action, note string) (*Report, error) {
+	var r *Report
+	err := inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		forumID, err := transition(tx, id, state, 0, note)
+		if err != nil {
+			return err
+		}
+
+		if state == ReportResolved {
+			reason := note
+			if reason == "" {
+				reason = "Removed after a report."
+			}
+			// A post that is no longer published has nothing left to hide.
+			err = setPostState(tx, &liveBatch{}, forumID, PostRejected, actorID, reason)
+			if err != nil && !errors.Is(err, ErrInvalidState) && !errors.Is(err, ErrNoRecord) {
+				return err
+			}
+		}
+
+		err = record(tx, ModerationAction{ActorID: actorID, Action: action, ReportID: id, ForumID: forumID, Note: note})
+		if err != nil {
+			return err
+		}
+
+		// Read back in the transaction, which may not have committed yet.
+		r, err = scanReport(tx.QueryRowContext(ctx, reportSelect+` WHERE r.id = ?;`, id))
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Actions returns audit log entries newest first.
+func (m *ReportModel) Actions(filter AuditFilter, limit int) ([]*ModerationAction, error) {
+	if limit <= 0 || limit > AuditPageSize {
+		limit = AuditPageSize
+	}
+
+	stmt := `SELECT a.id, a.actor_id, COALESCE(au.name, ''), a.action, a.report_id, a.forum_id,
+		a.target_user_id, COALESCE(tu.name, ''), a.note, a.created
+	FROM moderation_actions a
+	LEFT JOIN users au ON au.id = a.actor_id
+	LEFT JOIN users tu ON tu.id = a.target_user_id
+	WHERE 1 = 1`
+	var args []interface{}
+	if filter.ReportID > 0 {
+		stmt += ` AND a.report_id = ?`
+		args = append(args, filter.ReportID)
+	}
+	if filter.Before > 0 {
+		stmt += ` AND a.id < ?`
+		args = append(args, filter.Before)
+	}
+	stmt += `
+	ORDER BY a.id DESC
+	LIMIT ?;`
+	args = append(args, limit)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*ModerationAction{}
+	for rows.Next() {
+		a := &ModerationAction{}
+		err := rows.Scan(&a.ID, &a.ActorID, &a.ActorName, &a.Action, &a.ReportID, &a.ForumID,
+			&a.TargetUserID, &a.TargetName, &a.Note, &a.Created)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/migrations"
+)
+
+func auditActions(t *testing.T, m *ReportModel, filter AuditFilter) []string {
+	t.Helper()
+
+	actions, err := m.Actions(filter, 0)
+	if err != nil {
+		t.Fatalf("Actions: %v", err)
+	}
+	names := make([]string, 0, len(actions))
+	for _, a := range actions {
+		names = append(names, a.Action)
+	}
+	return names
+}
+
+func TestReportLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	reports := &ReportModel{DB: db}
+
+	admin := seedUser(t, db, "ruth")
+	moder := seedUser(t, db, "saul")
+	author := seedUser(t, db, "tess")
+	forumID := seedForum(t, db, author, "Reported post", 1, "go")
+	otherID := seedForum(t, db, author, "Other post", 1, "go")
+
+	id, err := reports.File(forumID, moder, []string{"other", "obscene", "bogus", "obscene"}, "rude")
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	otherReport, err := reports.File(otherID, moder, []string{"irrelevant"}, "")
+	if err != nil {
+		t.Fatalf("File other: %v", err)
+	}
+
+	r, err := reports.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(r.Reasons, []string{"obscene", "other"}) || r.State != ReportOpen ||
+		r.ForumTitle != "Reported post" || r.ReporterName != "saul" || r.Details != "rude" || !r.Open() {
+		t.Fatalf("filed report = %+v", r)
+	}
+
+	if err := reports.Assign(id, admin, admin); err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	if r, _ = reports.Get(id); r.State != ReportInReview || r.AssigneeName != "ruth" {
+		t.Fatalf("assigned report = %+v", r)
+	}
+
+	resolved, err := reports.Resolve(context.Background(), id, admin, "hidden for obscenity")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if resolved.State != ReportResolved || resolved.Resolution != "hidden for obscenity" || resolved.AssigneeID != admin {
+		t.Fatalf("resolved report = %+v", resolved)
+	}
+	var status int
+	var reason string
+	if err := db.QueryRow(`SELECT status, status_reason FROM forums WHERE id = ?`, forumID).Scan(&status, &reason); err != nil {
+		t.Fatal(err)
+	}
+	if status != PostRejected || reason != "hidden for obscenity" {
+		t.Fatalf("post status = %d %q, want rejected with the resolution", status, reason)
+	}
+
+	if _, err := reports.Dismiss(context.Background(), id, admin, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Dismiss closed report err = %v, want ErrInvalidTransition", err)
+	}
+	if err := reports.Assign(id, moder, admin); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Assign closed report err = %v, want ErrInvalidTransition", err)
+	}
+	if _, err := reports.Resolve(context.Background(), 999, admin, ""); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Resolve missing err = %v, want ErrNoRecord", err)
+	}
+
+	if _, err := reports.Dismiss(context.Background(), otherReport, moder, "fine"); err != nil {
+		t.Fatalf("Dismiss: %v", err)
+	}
+	if err := db.QueryRow(`SELECT status FROM forums WHERE id = ?`, otherID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != VisibleStatus {
+		t.Fatal("dismissing a report should leave the post alone")
+	}
+
+	queue, err := reports.List("")
+	if err != nil || len(queue) != 0 {
+		t.Fatalf("open queue = %v, %v", queue, err)
+	}
+	closed, err := reports.List(ReportDismissed)
+	if err != nil || len(closed) != 1 || closed[0].ID != otherReport {
+		t.Fatalf("dismissed reports = %v, %v", closed, err)
+	}
+
+	want := []string{ActionReportResolved, ActionReportAssigned, ActionReportFiled}
+	if got := auditActions(t, reports, AuditFilter{ReportID: id}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("report history = %v, want %v", got, want)
+	}
+	all, err := reports.Actions(AuditFilter{}, 0)
+	if err != nil || len(all) != 5 {
+		t.Fatalf("audit log = %v, %v", all, err)
+	}
+	older := auditActions(t, reports, AuditFilter{Before: all[1].ID})
+	if !reflect.DeepEqual(older, []string{ActionReportAssigned, ActionReportFiled, ActionReportFiled}) {
+		t.Fatalf("page before %d = %v", all[1].ID, older)
+	}
+}
+
+func TestModerationActionsAreAppendOnly(t *testing.T) {
+	db := newTestDB(t)
+	reports := &ReportModel{DB: db}
+
+	admin := seedUser(t, db, "uma")
+	target := seedUser(t, db, "vic")
+	err := reports.Record(context.Background(), ModerationAction{ActorID: admin, Action: ActionModeratorPromoted, TargetUserID: target})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	if _, err := db.Exec(`UPDATE moderation_actions SET note = 'edited'`); err == nil {
+		t.Fatal("audit entries must not be editable")
+	}
+	if _, err := db.Exec(`DELETE FROM moderation_actions`); err == nil {
+		t.Fatal("audit entries must not be deletable")
+	}
+
+	actions, err := reports.Actions(AuditFilter{}, 0)
+	if err != nil || len(actions) != 1 || actions[0].TargetName != "vic" || actions[0].ActorName != "uma" {
+		t.Fatalf("audit log = %+v, %v", actions, err)
+	}
+}
+
+func TestRemovingPostDismissesItsReports(t *testing.T) {
+	db := newTestDB(t)
+	reports := &ReportModel{DB: db}
+	forums := &ForumModel{DB: db}
+
+	moder := seedUser(t, db, "wes")
+	author := seedUser(t, db, "xena")
+	forumID := seedForum(t, db, author, "Doomed post", 1, "go")
+
+	id, err := reports.File(forumID, moder, []string{"illegal"}, "")
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	if err := forums.Remove(context.Background(), forumID, moder); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	r, err := reports.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if r.State != ReportDismissed || r.ForumTitle != "Doomed post" {
+		t.Fatalf("report of removed post = %+v", r)
+	}
+
+	if err := forums.Purge(context.Background(), forumID); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if r, err = reports.Get(id); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if r.ForumTitle != "" {
+		t.Fatalf("report of purged post = %+v", r)
+	}
+}
+
+func TestReportsMigrationMovesLegacyReports(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.UpSteps(6); err != nil {
+		t.Fatalf("apply legacy migrations: %v", err)
+	}
+
+	moder := seedUser(t, db, "yuri")
+	author := seedUser(t, db, "zoe")
+	forumID := seedForum(t, db, author, "Old report", 1, "go")
+	_, err = db.Exec(`INSERT INTO forum_notifications (user_name, body, status, forum_link, user_id, user_not_id)
+		VALUES ('zoe', 'obscene, illegal spam link', 'admin', ?, ?, ?), ('yuri', 'asked for moder', 'admin', '0', 1, ?)`,
+		forumID, moder, author, moder)
+	if err != nil {
+		t.Fatalf("insert legacy notifications: %v", err)
+	}
+
+	if _, err := migrator.UpSteps(1); err != nil {
+		t.Fatalf("apply reports migration: %v", err)
+	}
+
+	queue, err := (&ReportModel{DB: db}).List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(queue) != 1 || queue[0].ForumID != forumID || queue[0].ReporterID != moder ||
+		queue[0].Details != "obscene, illegal spam link" || queue[0].State != ReportOpen {
+		t.Fatalf("migrated reports = %+v", queue)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_notifications`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 1 {
+		t.Fatalf("moderation queue has %d rows, want the moderator request only", left)
+	}
+
+	if _, err := migrator.Down(1); err != nil {
+		t.Fatalf("revert reports migration: %v", err)
+	}
+	var body string
+	err = db.QueryRow(`SELECT body FROM forum_notifications WHERE forum_link = ?`, forumID).Scan(&body)
+	if err != nil {
+		t.Fatalf("restored report: %v", err)
+	}
+	if body != "obscene, illegal spam link" {
+		t.Fatalf("restored body = %q", body)
+	}
+}
@@ -0,0 +1,310 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revision is an earlier version of a post or comment, kept when an edit
+// replaced it. Title, Tags and ImagePath are empty for comments. In a
+// history the current version is included with an ID of 0.
+type Revision struct {
+	ID        int
+	ForumID   int
+	CommentID int
+	Title     string
+	Content   string
+	Tags      string
+	ImagePath string
+	AuthorID  int
+	Author    string
+	// Created is when this version was written; zero if that is unknown.
+	Created time.Time
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+// forumVersion reads the current version of a post.
+func forumVersion(tx *sql.Tx, forumID int) (*Revision, error) {
+	stmt := `SELECT f.id, f.title, f.content, ` + forumTagsColumn + `, COALESCE(f.image_path, ''),
+		COALESCE(f.edited_by, f.user_id), f.edited, f.created
+	FROM forums f
+	WHERE f.id = ?`
+
+	r := &Revision{}
+	var edited sql.NullTime
+	var created time.Time
+	err := tx.QueryRow(stmt, forumID).Scan(&r.ForumID, &r.Title, &r.Content, &r.Tags, &r.ImagePath, &r.AuthorID, &edited, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	r.Created = created
+	if edited.Valid {
+		r.Created = edited.Time
+	}
+	return r, nil
+}
+
+// reviseForum runs update on forumID inside tx. If that changed the title,
+// content, tags or image, the version it replaced is kept as a revision and
+// the post is marked edited by editorID.
+func reviseForum(tx *sql.Tx, forumID, editorID int, update func() error) error {
+	before, err := forumVersion(tx, forumID)
+	if err != nil {
+		return err
+	}
+	if err := update(); err != nil {
+		return err
+	}
+	after, err := forumVersion(tx, forumID)
+	if err != nil {
+		return err
+	}
+	if after.Title == before.Title && after.Content == before.Content && after.Tags == before.Tags && after.ImagePath == before.ImagePath {
+		return nil
+	}
+
+	stmt := `INSERT INTO forum_revisions (forum_id, title, content, tags, image_path, author_id, created)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.Exec(stmt, forumID, before.Title, before.Content, before.Tags, before.ImagePath, before.AuthorID, before.Created.UTC())
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE forums SET edited = strftime('%Y-%m-%d %H:%M:%S', 'now'), edited_by = ? WHERE id = ?`
+	_, err = tx.Exec(stmt, editorID, forumID)
+	return err
+}
+
+// reviseComment replaces the text of commentID inside tx, keeping the old
+// text as a revision if it changed.
+func reviseComment(tx *sql.Tx, commentID, editorID int, comment string) error {
+	var old string
+	var authorID int
+	var edited sql.NullTime
+	stmt := `SELECT comment, COALESCE(edited_by, user_id), edited FROM forum_comments WHERE id = ?`
+	err := tx.QueryRow(stmt, commentID).Scan(&old, &authorID, &edited)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	if old == comment {
+		return nil
+	}
+
+	var created interface{}
+	if edited.Valid {
+		created = edited.Time.UTC()
+	}
+	stmt = `INSERT INTO comment_revisions (comment_id, comment, author_id, created) VALUES (?, ?, ?, ?)`
+	if _, err = tx.Exec(stmt, commentID, old, authorID, created); err != nil {
+		return err
+	}
+
+	stmt = `UPDATE forum_comments
+	SET comment = ?, edited = strftime('%Y-%m-%d %H:%M:%S', 'now'), edited_by = ?
+	WHERE id = ?`
+	_, err = tx.Exec(stmt, comment, editorID, commentID)
+	return err
+}
+
+// ForumHistory returns the versions of a post, newest first: the current
+// one, then every revision.
+func (m *RevisionModel) ForumHistory(forumID int) ([]*Revision, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := forumVersion(tx, forumID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(`SELECT name FROM users WHERE id = ?`, current.AuthorID).Scan(&current.Author); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	stmt := `SELECT r.id, r.forum_id, r.title, r.content, r.tags, r.image_path, r.author_id, COALESCE(u.name, ''), r.created
+	FROM forum_revisions r
+	LEFT JOIN users u ON u.id = r.author_id
+	WHERE r.forum_id = ?
+	ORDER BY r.id DESC`
+
+	rows, err := tx.Query(stmt, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*Revision{current}
+	for rows.Next() {
+		r := &Revision{}
+		var created sql.NullTime
+		err := rows.Scan(&r.ID, &r.ForumID, &r.Title, &r.Content, &r.Tags, &r.ImagePath, &r.AuthorID, &r.Author, &created)
+		if err != nil {
+			return nil, err
+		}
+		r.Created = created.Time
+		history = append(history, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, tx.Commit()
+}
+
+// CommentHistory returns the versions of every edited comment of a post, in
+// thread order and newest first within each comment, the current text
+// included.
+func (m *RevisionModel) CommentHistory(forumID int) ([]*Revision, error) {
+	stmt := `SELECT r.id, r.comment_id, r.comment, r.author_id, COALESCE(u.name, ''), r.created
+	FROM comment_revisions r
+	JOIN forum_comments c ON c.id = r.comment_id
+	LEFT JOIN users u ON u.id = r.author_id
+	WHERE c.forum_id = ?
+	ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	older := map[int][]*Revision{}
+	for rows.Next() {
+		r := &Revision{ForumID: forumID}
+		var created sql.NullTime
+		err := rows.Scan(&r.ID, &r.CommentID, &r.Content, &r.AuthorID, &r.Author, &created)
+		if err != nil {
+			return nil, err
+		}
+		r.Created = created.Time
+		older[r.CommentID] = append(older[r.CommentID], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(older) == 0 {
+		return nil, nil
+	}
+
+	stmt = `SELECT c.id, c.comment, COALESCE(c.edited_by, c.user_id), COALESCE(u.name, ''), c.edited
+	FROM forum_comments c
+	LEFT JOIN users u ON u.id = COALESCE(c.edited_by, c.user_id)
+	WHERE c.forum_id = ? AND c.edited IS NOT NULL
+	ORDER BY c.path, c.id`
+
+	rows, err = m.DB.Query(stmt, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*Revision
+	for rows.Next() {
+		r := &Revision{ForumID: forumID}
+		var edited sql.NullTime
+		err := rows.Scan(&r.CommentID, &r.Content, &r.AuthorID, &r.Author, &edited)
+		if err != nil {
+			return nil, err
+		}
+		r.Created = edited.Time
+		history = append(history, r)
+		history = append(history, older[r.CommentID]...)
+	}
+	return history, rows.Err()
+}
+
+// ForumAuthor returns the author of a post in any state, the trash
+// included, so its history stays readable after moderation.
+func (m *RevisionModel) ForumAuthor(forumID int) (int, error) {
+	var userID int
+	err := m.DB.QueryRow(`SELECT user_id FROM forums WHERE id = ?`, forumID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// ForumRevision returns one revision of a post.
+func (m *RevisionModel) ForumRevision(id int) (*Revision, error) {
+	stmt := `SELECT id, forum_id, title, content, tags, image_path, author_id, created
+	FROM forum_revisions WHERE id = ?`
+
+	r := &Revision{}
+	var created sql.NullTime
+	err := m.DB.QueryRow(stmt, id).Scan(&r.ID, &r.ForumID, &r.Title, &r.Content, &r.Tags, &r.ImagePath, &r.AuthorID, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	r.Created = created.Time
+	return r, nil
+}
+
+// CommentRevision returns one revision of a comment.
+func (m *RevisionModel) CommentRevision(id int) (*Revision, error) {
+	stmt := `SELECT r.id, c.forum_id, r.comment_id, r.comment, r.author_id, r.created
+	FROM comment_revisions r
+	JOIN forum_comments c ON c.id = r.comment_id
+	WHERE r.id = ?`
+
+	r := &Revision{}
+	var created sql.NullTime
+	err := m.DB.QueryRow(stmt, id).Scan(&r.ID, &r.ForumID, &r.CommentID, &r.Content, &r.AuthorID, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	r.Created = created.Time
+	return r, nil
+}
+
+// RestoreForum makes revision id the current version of its post again.
+// The version it replaces becomes a revision, so a restore can be undone
+// like any edit. The expiry is left alone.
+func (m *RevisionModel) RestoreForum(ctx context.Context, id, editorID int) error {
+	r, err := m.ForumRevision(id)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return reviseForum(tx, r.ForumID, editorID, func() error {
+			stmt := `UPDATE forums SET title = ?, content = ?, image_path = ? WHERE id = ?`
+			if _, err := tx.Exec(stmt, r.Title, r.Content, r.ImagePath, r.ForumID); err != nil {
+				return err
+			}
+			return setForumTags(tx, r.ForumID, r.Tags)
+		})
+	}, nil)
+}
+
+// RestoreComment makes revision id the current text of its comment again.
+func (m *RevisionModel) RestoreComment(ctx context.Context, id, editorID int) error {
+	r, err := m.CommentRevision(id)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return reviseComment(tx, r.CommentID, editorID, r.Content)
+	}, nil)
+}
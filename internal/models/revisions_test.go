@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForumRevisions(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	revisions := &RevisionModel{DB: db}
+
+	author := seedUser(t, db, "author")
+	admin := seedUser(t, db, "admin")
+	forumID := seedForum(t, db, author, "first", VisibleStatus, "go")
+
+	// An edit that changes nothing is not a revision.
+	if err := forums.Edit(context.Background(), "first", "content", "go", 5, author, "", forumID, author); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	history, err := revisions.ForumHistory(forumID)
+	if err != nil {
+		t.Fatalf("ForumHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != 0 || history[0].Author != "author" {
+		t.Fatalf("history after no-op edit = %+v", history)
+	}
+
+	if err := forums.Edit(context.Background(), "second", "new content", "go, web", 5, author, "/media/a.png", forumID, author); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	if err := forums.Edit(context.Background(), "third", "new content", "go, web", 5, author, "", forumID, admin); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+
+	f, err := forums.Get(forumID, author, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if f.Edited.IsZero() {
+		t.Fatal("edited post has no Edited time")
+	}
+
+	history, err = revisions.ForumHistory(forumID)
+	if err != nil {
+		t.Fatalf("ForumHistory: %v", err)
+	}
+	var titles, authors []string
+	for _, r := range history {
+		titles = append(titles, r.Title)
+		authors = append(authors, r.Author)
+	}
+	if len(history) != 3 || titles[0] != "third" || titles[1] != "second" || titles[2] != "first" {
+		t.Fatalf("history titles = %v", titles)
+	}
+	if authors[0] != "admin" || authors[1] != "author" || authors[2] != "author" {
+		t.Fatalf("history authors = %v", authors)
+	}
+	if history[1].ImagePath != "/media/a.png" || history[2].Tags != "go" {
+		t.Fatalf("revisions = %+v %+v", history[1], history[2])
+	}
+
+	// The replaced image is still in use by the history.
+	if used, err := forums.ImageInUse(context.Background(), "/media/a.png"); err != nil || !used {
+		t.Fatalf("ImageInUse = %v, %v", used, err)
+	}
+
+	if err := revisions.RestoreForum(context.Background(), history[2].ID, admin); err != nil {
+		t.Fatalf("RestoreForum: %v", err)
+	}
+	f, err = forums.Get(forumID, author, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if f.Title != "first" || f.Content != "content" || f.Tags != "go" {
+		t.Fatalf("restored post = %q %q %q", f.Title, f.Content, f.Tags)
+	}
+	history, err = revisions.ForumHistory(forumID)
+	if err != nil {
+		t.Fatalf("ForumHistory: %v", err)
+	}
+	if len(history) != 4 || history[1].Title != "third" {
+		t.Fatalf("restore did not keep the replaced version: %+v", history)
+	}
+
+	if err := revisions.RestoreForum(context.Background(), 999, admin); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("RestoreForum(missing) err = %v, want ErrNoRecord", err)
+	}
+
+	if err := forums.Purge(context.Background(), forumID); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_revisions`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatalf("%d revisions left after Purge", left)
+	}
+	if used, err := forums.ImageInUse(context.Background(), "/media/a.png"); err != nil || used {
+		t.Fatalf("ImageInUse after Remove = %v, %v", used, err)
+	}
+}
+
+func TestCommentRevisions(t *testing.T) {
+	db := newTestDB(t)
+	comments := &ForumCommentModel{DB: db}
+	revisions := &RevisionModel{DB: db}
+
+	author := seedUser(t, db, "author")
+	moder := seedUser(t, db, "moder")
+	forumID := seedForum(t, db, author, "post", VisibleStatus, "go")
+	first := seedComment(t, db, forumID, author, "one")
+	seedComment(t, db, forumID, author, "untouched")
+
+	history, err := revisions.CommentHistory(forumID)
+	if err != nil || len(history) != 0 {
+		t.Fatalf("CommentHistory before edits = %v, %v", history, err)
+	}
+
+	if err := comments.EditCommentPost(forumID, author, "two", first, author); err != nil {
+		t.Fatalf("EditCommentPost: %v", err)
+	}
+	if err := comments.EditCommentPost(forumID, author, "three", first, moder); err != nil {
+		t.Fatalf("EditCommentPost: %v", err)
+	}
+
+	history, err = revisions.CommentHistory(forumID)
+	if err != nil {
+		t.Fatalf("CommentHistory: %v", err)
+	}
+	var texts []string
+	for _, r := range history {
+		if r.CommentID != first {
+			t.Fatalf("history holds comment %d", r.CommentID)
+		}
+		texts = append(texts, r.Content)
+	}
+	if len(texts) != 3 || texts[0] != "three" || texts[1] != "two" || texts[2] != "one" {
+		t.Fatalf("comment history = %v", texts)
+	}
+	if history[0].ID != 0 || history[0].Author != "moder" || history[2].Author != "author" || !history[2].Created.IsZero() {
+		t.Fatalf("comment history = %+v %+v", history[0], history[2])
+	}
+
+	r, err := revisions.CommentRevision(history[2].ID)
+	if err != nil || r.ForumID != forumID {
+		t.Fatalf("CommentRevision = %+v, %v", r, err)
+	}
+	if err := revisions.RestoreComment(context.Background(), history[2].ID, moder); err != nil {
+		t.Fatalf("RestoreComment: %v", err)
+	}
+	var body string
+	if err := db.QueryRow(`SELECT comment FROM forum_comments WHERE id = ?`, first).Scan(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body != "one" {
+		t.Fatalf("restored comment = %q", body)
+	}
+
+	if err := comments.RemoveCommentPost(first, moder); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+	if _, err := (&TrashModel{DB: db}).PurgeComments(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeComments: %v", err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM comment_revisions`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatalf("%d comment revisions left after purge", left)
+	}
+}
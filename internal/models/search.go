@@ -0,0 +1,309 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// The search index is an FTS5 table fed by triggers on forums and
+// forum_comments. Posts use rowid 2*id and comments 2*id+1, so both kinds
+// share one table without colliding. FTS5 is only compiled into the sqlite3
+// driver with the sqlite_fts5 build tag, so the index is created by
+// EnsureIndex at startup rather than by a migration.
+const searchIndexSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS forum_search USING fts5 (
+    forum_id UNINDEXED,
+    comment_id UNINDEXED,
+    title,
+    body,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS forum_search_forums_ai AFTER INSERT ON forums BEGIN
+    INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+    VALUES (new.id * 2, new.id, 0, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_search_forums_au AFTER UPDATE OF title, content ON forums BEGIN
+    DELETE FROM forum_search WHERE rowid = old.id * 2;
+    INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+    VALUES (new.id * 2, new.id, 0, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_search_forums_ad AFTER DELETE ON forums BEGIN
+    DELETE FROM forum_search WHERE rowid = old.id * 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_search_comments_ai AFTER INSERT ON forum_comments BEGIN
+    INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+    VALUES (new.id * 2 + 1, new.forum_id, new.id, '', new.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_search_comments_au AFTER UPDATE OF comment ON forum_comments BEGIN
+    DELETE FROM forum_search WHERE rowid = old.id * 2 + 1;
+    INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+    VALUES (new.id * 2 + 1, new.forum_id, new.id, '', new.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS forum_search_comments_ad AFTER DELETE ON forum_comments BEGIN
+    DELETE FROM forum_search WHERE rowid = old.id * 2 + 1;
+END;
+`
+
+const searchIndexBackfill = `
+DELETE FROM forum_search;
+
+INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+SELECT id * 2, id, 0, title, content FROM forums;
+
+INSERT INTO forum_search (rowid, forum_id, comment_id, title, body)
+SELECT id * 2 + 1, forum_id, id, '', comment FROM forum_comments;
+`
+
+// Snippet markers are control characters so they cannot collide with text
+// typed by users.
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 50
+)
+
+var ErrSearchUnavailable = errors.New("models: full-text search is not compiled in")
+
+type SearchModel struct {
+	DB *sql.DB
+}
+
+type SearchQuery struct {
+	Text   string
+	Tag    string
+	Author string
+	Limit  int
+}
+
+// SnippetPart is a run of snippet text; Match marks the highlighted terms.
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+type SearchResult struct {
+	ForumID   int
+	CommentID int
+	Title     string
+	Author    string
+	Snippet   []SnippetPart
+	Rank      float64
+}
+
+// IsComment reports whether the hit is a comment rather than the post body.
+func (r *SearchResult) IsComment() bool {
+	return r.CommentID != 0
+}
+
+// Available reports whether the sqlite3 driver was built with FTS5.
+func (m *SearchModel) Available() (bool, error) {
+	var used int
+	err := m.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used)
+	if err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// EnsureIndex creates the search index and its triggers when FTS5 is
+// available, filling it from existing rows the first time. Without FTS5 it
+// does nothing and Search returns ErrSearchUnavailable.
+func (m *SearchModel) EnsureIndex() error {
+	ok, err := m.Available()
+	if err != nil || !ok {
+		return err
+	}
+
+	var exists int
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'forum_search'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(searchIndexSchema); err != nil {
+		return err
+	}
+	if exists == 0 {
+		if _, err = tx.Exec(searchIndexBackfill); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Search ranks visible posts and their comments against q using BM25, with
+// title matches weighted above body matches.
+func (m *SearchModel) Search(q SearchQuery) ([]*SearchResult, error) {
+	ok, err := m.Available()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSearchUnavailable
+	}
+
+	match := MatchQuery(q.Text)
+	if match == "" {
+		return []*SearchResult{}, nil
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	stmt := `SELECT s.forum_id, s.comment_id, f.title, u.name,
+		snippet(forum_search, -1, ?, ?, '…', 16),
+		bm25(forum_search, 0, 0, 10.0, 1.0) AS rank
+	FROM forum_search s
+	JOIN forums f ON f.id = s.forum_id
+	LEFT JOIN forum_comments c ON c.id = s.comment_id
+	JOIN users u ON u.id = COALESCE(c.user_id, f.user_id)
+	WHERE forum_search MATCH ?
+		AND f.status = 1
+		AND f.deleted IS NULL
+		AND (c.id IS NULL OR c.deleted IS NULL)
+		AND f.expires > strftime('%Y-%m-%d %H:%M:%S', 'now')`
+	args := []interface{}{snippetOpen, snippetClose, match}
+
+	if tag := strings.TrimSpace(q.Tag); tag != "" {
+		stmt += ` AND EXISTS (
+			SELECT 1 FROM forum_post_tags pt
+			JOIN forum_tags t ON t.id = pt.tag_id
+			WHERE pt.forum_id = f.id AND t.tags = ?)`
+		args = append(args, tag)
+	}
+	if author := strings.TrimSpace(q.Author); author != "" {
+		stmt += ` AND u.name = ? COLLATE NOCASE`
+		args = append(args, author)
+	}
+
+	stmt += ` ORDER BY rank LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		r := &SearchResult{}
+		var snippet string
+		err := rows.Scan(&r.ForumID, &r.CommentID, &r.Title, &r.Author, &snippet, &r.Rank)
+		if err != nil {
+			return nil, err
+		}
+		r.Snippet = splitSnippet(snippet)
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// MatchQuery turns free text into an FTS5 query. Double-quoted runs become
+// phrases and a trailing * makes a prefix query; everything else is split
+// into bare terms that must all match. Operators and column filters typed by
+// the user are treated as plain words.
+func MatchQuery(text string) string {
+	var terms []string
+	rest := text
+	for rest != "" {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		var raw string
+		phrase := rest[0] == '"'
+		if phrase {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				raw, rest = rest, ""
+			} else {
+				raw, rest = rest[:end], rest[end:]
+			}
+		}
+
+		prefix := false
+		if phrase {
+			if strings.HasPrefix(rest, "*") {
+				prefix, rest = true, rest[1:]
+			}
+		} else if strings.HasSuffix(raw, "*") {
+			prefix = true
+		}
+
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+
+		term := `"` + strings.Join(words, " ") + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+func splitSnippet(s string) []SnippetPart {
+	parts := []SnippetPart{}
+	for s != "" {
+		open := strings.Index(s, snippetOpen)
+		if open < 0 {
+			parts = append(parts, SnippetPart{Text: s})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, SnippetPart{Text: s[:open]})
+		}
+		s = s[open+len(snippetOpen):]
+
+		end := strings.Index(s, snippetClose)
+		if end < 0 {
+			parts = append(parts, SnippetPart{Text: s, Match: true})
+			break
+		}
+		parts = append(parts, SnippetPart{Text: s[:end], Match: true})
+		s = s[end+len(snippetClose):]
+	}
+	return parts
+}
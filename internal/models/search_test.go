@@ -0,0 +1,232 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newSearchTestDB(t *testing.T) (*sql.DB, *SearchModel) {
+	t.Helper()
+
+	db := newTestDB(t)
+	m := &SearchModel{DB: db}
+	ok, err := m.Available()
+	if err != nil {
+		t.Fatalf("Available: %v", err)
+	}
+	if !ok {
+		t.Skip("sqlite3 driver built without FTS5; run with -tags sqlite_fts5")
+	}
+	if err := m.EnsureIndex(); err != nil {
+		t.Fatalf("EnsureIndex: %v", err)
+	}
+	return db, m
+}
+
+func TestMatchQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"golang", `"golang"`},
+		{"go  web", `"go" "web"`},
+		{"gopher*", `"gopher"*`},
+		{`"net http" server`, `"net http" "server"`},
+		{`"half open`, `"half open"`},
+		{`"some phrase"*`, `"some phrase"*`},
+		{"title:secret OR NOT", `"title secret" "OR" "NOT"`},
+		{`a"b`, `"a" "b"`},
+		{"---", ""},
+		{"café", `"café"`},
+	}
+
+	for _, tt := range tests {
+		if got := MatchQuery(tt.text); got != tt.want {
+			t.Errorf("MatchQuery(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSnippet(t *testing.T) {
+	got := splitSnippet("before \x02hit\x03 after \x02tail")
+	want := []SnippetPart{
+		{Text: "before "},
+		{Text: "hit", Match: true},
+		{Text: " after "},
+		{Text: "tail", Match: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitSnippet = %#v, want %#v", got, want)
+	}
+
+	if got := splitSnippet(""); len(got) != 0 {
+		t.Fatalf("splitSnippet(\"\") = %#v, want empty", got)
+	}
+}
+
+func TestSearchUnavailableWithoutIndex(t *testing.T) {
+	db := newTestDB(t)
+	m := &SearchModel{DB: db}
+	ok, err := m.Available()
+	if err != nil {
+		t.Fatalf("Available: %v", err)
+	}
+	if ok {
+		t.Skip("sqlite3 driver built with FTS5")
+	}
+
+	if err := m.EnsureIndex(); err != nil {
+		t.Fatalf("EnsureIndex without FTS5 should be a no-op, got %v", err)
+	}
+	if _, err := m.Search(SearchQuery{Text: "anything"}); !errors.Is(err, ErrSearchUnavailable) {
+		t.Fatalf("Search err = %v, want ErrSearchUnavailable", err)
+	}
+}
+
+func TestSearchRanksAndFilters(t *testing.T) {
+	db, m := newSearchTestDB(t)
+
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+
+	titled := seedForum(t, db, alice, "Gopher meetup", 1, "go, events")
+	bodied := seedForum(t, db, bob, "Weekend plans", 1, "life")
+	if _, err := db.Exec(`UPDATE forums SET content = 'bring your gopher plush' WHERE id = ?`, bodied); err != nil {
+		t.Fatal(err)
+	}
+	hidden := seedForum(t, db, alice, "Gopher drafts", 0, "go")
+	comment := seedComment(t, db, bodied, alice, "my gopher is bigger")
+
+	results, err := m.Search(SearchQuery{Text: "gopher"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Search returned %d results, want 3: %+v", len(results), results)
+	}
+	if results[0].ForumID != titled || results[0].IsComment() {
+		t.Fatalf("title match should rank first, got %+v", results[0])
+	}
+	for _, r := range results {
+		if r.ForumID == hidden {
+			t.Fatalf("invisible post %d returned in results", hidden)
+		}
+		if r.IsComment() && (r.CommentID != comment || r.Author != "alice" || r.Title != "Weekend plans") {
+			t.Fatalf("unexpected comment hit %+v", r)
+		}
+	}
+
+	var highlighted bool
+	for _, part := range results[1].Snippet {
+		if part.Match && part.Text == "gopher" {
+			highlighted = true
+		}
+	}
+	if !highlighted {
+		t.Fatalf("snippet missing highlighted term: %+v", results[1].Snippet)
+	}
+
+	byTag, err := m.Search(SearchQuery{Text: "gopher", Tag: "events"})
+	if err != nil {
+		t.Fatalf("Search by tag: %v", err)
+	}
+	if len(byTag) != 1 || byTag[0].ForumID != titled {
+		t.Fatalf("tag filter returned %+v", byTag)
+	}
+
+	byAuthor, err := m.Search(SearchQuery{Text: "gopher", Author: "BOB"})
+	if err != nil {
+		t.Fatalf("Search by author: %v", err)
+	}
+	if len(byAuthor) != 1 || byAuthor[0].ForumID != bodied || byAuthor[0].IsComment() {
+		t.Fatalf("author filter returned %+v", byAuthor)
+	}
+
+	prefix, err := m.Search(SearchQuery{Text: "meet*"})
+	if err != nil {
+		t.Fatalf("prefix Search: %v", err)
+	}
+	if len(prefix) != 1 || prefix[0].ForumID != titled {
+		t.Fatalf("prefix search returned %+v", prefix)
+	}
+
+	limited, err := m.Search(SearchQuery{Text: "gopher", Limit: 1})
+	if err != nil {
+		t.Fatalf("limited Search: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Fatalf("limit 1 returned %d results", len(limited))
+	}
+}
+
+func TestSearchIndexFollowsWrites(t *testing.T) {
+	db, m := newSearchTestDB(t)
+
+	u := seedUser(t, db, "carol")
+	forumID := seedForum(t, db, u, "Original title", 1, "go")
+	commentID := seedComment(t, db, forumID, u, "first draft")
+
+	if _, err := db.Exec(`UPDATE forums SET title = 'Renamed heading' WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE forum_comments SET comment = 'final wording' WHERE id = ?`, commentID); err != nil {
+		t.Fatal(err)
+	}
+
+	assertHits := func(text string, want int) {
+		t.Helper()
+		results, err := m.Search(SearchQuery{Text: text})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", text, err)
+		}
+		if len(results) != want {
+			t.Fatalf("Search(%q) returned %d results, want %d", text, len(results), want)
+		}
+	}
+
+	assertHits("original", 0)
+	assertHits("renamed", 1)
+	assertHits("draft", 0)
+	assertHits("wording", 1)
+
+	if _, err := db.Exec(`DELETE FROM forum_comments WHERE id = ?`, commentID); err != nil {
+		t.Fatal(err)
+	}
+	assertHits("wording", 0)
+
+	if _, err := db.Exec(`DELETE FROM forums WHERE id = ?`, forumID); err != nil {
+		t.Fatal(err)
+	}
+	assertHits("renamed", 0)
+}
+
+func TestEnsureIndexBackfillsExistingRows(t *testing.T) {
+	db := newTestDB(t)
+	m := &SearchModel{DB: db}
+	if ok, err := m.Available(); err != nil || !ok {
+		t.Skip("sqlite3 driver built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	u := seedUser(t, db, "dave")
+	forumID := seedForum(t, db, u, "Legacy post", 1, "go")
+	seedComment(t, db, forumID, u, "legacy comment")
+
+	if err := m.EnsureIndex(); err != nil {
+		t.Fatalf("EnsureIndex: %v", err)
+	}
+	if err := m.EnsureIndex(); err != nil {
+		t.Fatalf("second EnsureIndex: %v", err)
+	}
+
+	results, err := m.Search(SearchQuery{Text: "legacy"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("backfilled search returned %d results, want 2", len(results))
+	}
+}
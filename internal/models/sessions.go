@@ -1,87 +1,225 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
+)
 
-	"github.com/google/uuid"
+// Session lifetimes. A session ends after SessionIdleTimeout without use,
+// and never lives longer than SessionMaxLifetime however often it is used.
+var (
+	SessionIdleTimeout = 24 * time.Hour
+	SessionMaxLifetime = 30 * 24 * time.Hour
 )
 
+// sessionRenewInterval keeps Renew from writing on every request.
+const sessionRenewInterval = time.Minute
+
+const sessionTimeLayout = "2006-01-02 15:04:05"
+
+// Session is one signed-in device. Token is only known when the session is
+// created; the database stores its hash.
 type Session struct {
-	Token  string
-	UserID int
-	Expiry time.Time
+	ID        int
+	Token     string
+	UserID    int
+	UserAgent string
+	IP        string
+	Created   time.Time
+	LastSeen  time.Time
+	Expiry    time.Time
+	MaxExpiry time.Time
+	// Current marks the session the list was requested with.
+	Current bool
+}
+
+// SessionDevice describes where a session was opened from.
+type SessionDevice struct {
+	UserAgent string
+	IP        string
 }
 
 type SessionModel struct {
 	DB *sql.DB
 }
 
+// generateSessionID returns 32 random bytes, URL-safe encoded.
 func generateSessionID() string {
-	return uuid.New().String()
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func (m *SessionModel) CreateSession(userID int) (*Session, error) {
-	sessionToken := generateSessionID()
-	expiration := time.Now().Add(15 * time.Minute)
+func sessionTime(t time.Time) string {
+	return t.UTC().Format(sessionTimeLayout)
+}
 
-	existingSession, err := m.GetActiveSession(userID)
+// CreateSession opens a new session for userID. Other sessions of the user
+// stay signed in.
+func (m *SessionModel) CreateSession(ctx context.Context, userID int, device SessionDevice) (*Session, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	session := &Session{
+		Token:     generateSessionID(),
+		UserID:    userID,
+		UserAgent: device.UserAgent,
+		IP:        device.IP,
+		Created:   now,
+		LastSeen:  now,
+		Expiry:    now.Add(SessionIdleTimeout),
+		MaxExpiry: now.Add(SessionMaxLifetime),
+	}
+	if len(session.UserAgent) > 255 {
+		session.UserAgent = session.UserAgent[:255]
+	}
+	if session.Expiry.After(session.MaxExpiry) {
+		session.Expiry = session.MaxExpiry
+	}
+
+	stmt := `INSERT INTO sessions (token_hash, user_id, user_agent, ip, created, last_seen, expiry, max_expiry)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := conn(ctx, m.DB).ExecContext(ctx, stmt, hashSessionToken(session.Token), userID, session.UserAgent, session.IP,
+		sessionTime(now), sessionTime(now), sessionTime(session.Expiry), sessionTime(session.MaxExpiry))
 	if err != nil {
 		return nil, err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	session.ID = int(id)
 
-	if existingSession != nil {
-		err = m.InvalidateSession(existingSession.Token)
-		if err != nil {
-			return nil, err
-		}
+	return session, nil
+}
+
+// GetSession returns the user and expiry of token. Expired sessions are
+// returned too; callers compare the expiry.
+func (m *SessionModel) GetSession(ctx context.Context, token string) (int, time.Time, error) {
+	var userID int
+	var expiry time.Time
+	err := conn(ctx, m.DB).QueryRowContext(ctx, "SELECT user_id, expiry FROM sessions WHERE token_hash = ?", hashSessionToken(token)).Scan(&userID, &expiry)
+	if err != nil {
+		return 0, time.Time{}, err
 	}
+	return userID, expiry, nil
+}
+
+// Renew slides the expiry of an active session forward by
+// SessionIdleTimeout, capped at its maximum lifetime. Sessions used within
+// the last minute are left alone; renewed reports whether expiry moved.
+func (m *SessionModel) Renew(ctx context.Context, token string) (expiry time.Time, renewed bool, err error) {
+	now := time.Now()
+
+	stmt := `UPDATE sessions SET last_seen = ?, expiry = MIN(?, max_expiry)
+	WHERE token_hash = ? AND expiry > ? AND last_seen <= ?`
 
-	_, err = m.DB.Exec("INSERT INTO sessions (token, user_id, expiry) VALUES (?, ?, ?)", sessionToken, userID, expiration)
+	hash := hashSessionToken(token)
+	result, err := conn(ctx, m.DB).ExecContext(ctx, stmt, sessionTime(now), sessionTime(now.Add(SessionIdleTimeout)),
+		hash, sessionTime(now), sessionTime(now.Add(-sessionRenewInterval)))
 	if err != nil {
-		return nil, err
+		return time.Time{}, false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return time.Time{}, false, err
 	}
 
-	session := &Session{
-		Token:  sessionToken,
-		UserID: userID,
-		Expiry: expiration,
+	err = conn(ctx, m.DB).QueryRowContext(ctx, "SELECT expiry FROM sessions WHERE token_hash = ?", hash).Scan(&expiry)
+	if err != nil {
+		return time.Time{}, false, err
 	}
+	return expiry, true, nil
+}
 
-	return session, nil
+// InvalidateSession signs token out.
+func (m *SessionModel) InvalidateSession(ctx context.Context, token string) error {
+	_, err := conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", hashSessionToken(token))
+	return err
 }
 
-func (m *SessionModel) GetActiveSession(userID int) (*Session, error) {
-	var token string
-	var expiry string
+// Sessions lists the active sessions of userID, most recently used first,
+// marking the one opened with currentToken.
+func (m *SessionModel) Sessions(ctx context.Context, userID int, currentToken string) ([]*Session, error) {
+	stmt := `SELECT id, token_hash, user_agent, ip, created, last_seen, expiry, max_expiry
+	FROM sessions WHERE user_id = ? AND expiry > ?
+	ORDER BY last_seen DESC, id DESC`
 
-	err := m.DB.QueryRow("SELECT token, expiry FROM sessions WHERE user_id = ? AND expiry > ?", userID, time.Now()).Scan(&token, &expiry)
+	rows, err := conn(ctx, m.DB).QueryContext(ctx, stmt, userID, sessionTime(time.Now()))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No active session found
+		return nil, err
+	}
+	defer rows.Close()
+
+	current := hashSessionToken(currentToken)
+	sessions := []*Session{}
+	for rows.Next() {
+		s := &Session{UserID: userID}
+		var hash string
+		err := rows.Scan(&s.ID, &hash, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen, &s.Expiry, &s.MaxExpiry)
+		if err != nil {
+			return nil, err
 		}
+		s.Current = hash == current
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	parsedTime, _ := time.Parse("2006-01-02 15:04:05.999999999-07:00", expiry)
-	return &Session{Token: token, UserID: userID, Expiry: parsedTime}, nil
+	return sessions, nil
 }
 
-func (m *SessionModel) GetSession(token string) (int, time.Time, error) {
-	var userID int
-	var expiry string
-	err := m.DB.QueryRow("SELECT user_id, expiry FROM sessions WHERE token = ?", token).Scan(&userID, &expiry)
+// Revoke signs out session id of userID. It returns ErrNoRecord when the
+// user has no such session.
+func (m *SessionModel) Revoke(ctx context.Context, userID, id int) error {
+	result, err := conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
 	if err != nil {
-		return 0, time.Time{}, err
+		return err
 	}
-	parsedTime, _ := time.Parse("2006-01-02 15:04:05.999999999-07:00", expiry)
-	return userID, parsedTime, nil
-}
-
-func (m *SessionModel) InvalidateSession(token string) error {
-	_, err := m.DB.Exec("UPDATE sessions SET expiry = ? WHERE token = ?", time.Now(), token)
+	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return ErrNoRecord
+	}
 	return nil
 }
+
+// RevokeOthers signs userID out everywhere except the session of keepToken,
+// returning how many sessions ended.
+func (m *SessionModel) RevokeOthers(ctx context.Context, userID int, keepToken string) (int64, error) {
+	result, err := conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ? AND token_hash <> ?", userID, hashSessionToken(keepToken))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeAll signs userID out on every device, returning how many sessions
+// ended.
+func (m *SessionModel) RevokeAll(ctx context.Context, userID int) (int64, error) {
+	result, err := conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Prune deletes sessions that expired before now.
+func (m *SessionModel) Prune(ctx context.Context, now time.Time) (int64, error) {
+	result, err := conn(ctx, m.DB).ExecContext(ctx, "DELETE FROM sessions WHERE expiry <= ?", sessionTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
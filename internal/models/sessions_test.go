@@ -1,6 +1,9 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -18,70 +21,165 @@ func TestGenerateSessionID(t *testing.T) {
 	}
 }
 
-func TestCreateSessionReplacesExistingSession(t *testing.T) {
+func TestCreateSessionKeepsOtherDevices(t *testing.T) {
 	db := newTestDB(t)
 	model := &SessionModel{DB: db}
 	u := seedUser(t, db, "sess-user")
 
-	first, err := model.CreateSession(u)
+	first, err := model.CreateSession(context.Background(), u, SessionDevice{UserAgent: "laptop", IP: "10.0.0.1"})
 	if err != nil {
 		t.Fatalf("first CreateSession: %v", err)
 	}
-	second, err := model.CreateSession(u)
+	second, err := model.CreateSession(context.Background(), u, SessionDevice{UserAgent: "phone", IP: "10.0.0.2"})
 	if err != nil {
 		t.Fatalf("second CreateSession: %v", err)
 	}
-	if first.Token == second.Token {
-		t.Fatal("expected new session token when replacing active session")
+	if first.Token == second.Token || len(first.Token) != 43 {
+		t.Fatalf("tokens %q and %q", first.Token, second.Token)
 	}
 
-	var oldExpiryRaw string
-	if err := db.QueryRow(`SELECT expiry FROM sessions WHERE token = ?`, first.Token).Scan(&oldExpiryRaw); err != nil {
-		t.Fatalf("query old session expiry: %v", err)
+	for _, s := range []*Session{first, second} {
+		gotUser, expiry, err := model.GetSession(context.Background(), s.Token)
+		if err != nil || gotUser != u || !expiry.After(time.Now()) {
+			t.Fatalf("GetSession(%s) = %d, %v, %v", s.UserAgent, gotUser, expiry, err)
+		}
 	}
-	oldExpiry, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", oldExpiryRaw)
+
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE token_hash = ?`, first.Token).Scan(&stored); err != nil {
+		t.Fatalf("query token: %v", err)
+	}
+	if stored != 0 {
+		t.Fatal("session token stored in plain text")
+	}
+
+	sessions, err := model.Sessions(context.Background(), u, second.Token)
 	if err != nil {
-		t.Fatalf("parse old session expiry: %v", err)
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions = %d, want 2", len(sessions))
 	}
-	if oldExpiry.After(time.Now().Add(2 * time.Second)) {
-		t.Fatalf("expected old session invalidated, got expiry %v", oldExpiry)
+	for _, s := range sessions {
+		if s.Current != (s.UserAgent == "phone") || s.IP == "" || s.Created.IsZero() {
+			t.Fatalf("session %+v", s)
+		}
 	}
 }
 
-func TestGetSessionAndActiveSession(t *testing.T) {
+func TestRenewSession(t *testing.T) {
 	db := newTestDB(t)
 	model := &SessionModel{DB: db}
-	u := seedUser(t, db, "active-user")
+	u := seedUser(t, db, "renew-user")
 
-	active, err := model.GetActiveSession(u)
+	s, err := model.CreateSession(context.Background(), u, SessionDevice{})
 	if err != nil {
-		t.Fatalf("GetActiveSession without session: %v", err)
+		t.Fatalf("CreateSession: %v", err)
 	}
-	if active != nil {
-		t.Fatal("expected nil active session initially")
+	if _, renewed, err := model.Renew(context.Background(), s.Token); err != nil || renewed {
+		t.Fatalf("Renew right after login: renewed=%v err=%v", renewed, err)
 	}
 
-	created, err := model.CreateSession(u)
-	if err != nil {
-		t.Fatalf("CreateSession: %v", err)
+	// Pretend the session was last used ten minutes ago and is about to lapse.
+	ago := sessionTime(time.Now().Add(-10 * time.Minute))
+	soon := sessionTime(time.Now().Add(time.Minute))
+	if _, err := db.Exec(`UPDATE sessions SET last_seen = ?, expiry = ?`, ago, soon); err != nil {
+		t.Fatalf("age session: %v", err)
 	}
-	gotUser, expiry, err := model.GetSession(created.Token)
-	if err != nil {
-		t.Fatalf("GetSession: %v", err)
+	expiry, renewed, err := model.Renew(context.Background(), s.Token)
+	if err != nil || !renewed {
+		t.Fatalf("Renew: renewed=%v err=%v", renewed, err)
+	}
+	if expiry.Before(time.Now().Add(SessionIdleTimeout - time.Minute)) {
+		t.Fatalf("renewed expiry %v too early", expiry)
 	}
-	if gotUser != u {
-		t.Fatalf("GetSession user = %d, want %d", gotUser, u)
+
+	// Renewal never passes the absolute lifetime.
+	maxExpiry := time.Now().Add(5 * time.Minute)
+	if _, err := db.Exec(`UPDATE sessions SET last_seen = ?, max_expiry = ?`, ago, sessionTime(maxExpiry)); err != nil {
+		t.Fatalf("cap session: %v", err)
 	}
-	if expiry.IsZero() {
-		t.Fatal("expected non-zero session expiry")
+	if expiry, _, _ = model.Renew(context.Background(), s.Token); expiry.After(maxExpiry) {
+		t.Fatalf("renewed expiry %v past max %v", expiry, maxExpiry)
 	}
 
-	active, err = model.GetActiveSession(u)
-	if err != nil {
-		t.Fatalf("GetActiveSession with session: %v", err)
+	// Expired sessions stay expired.
+	if _, err := db.Exec(`UPDATE sessions SET last_seen = ?, expiry = ?`, ago, ago); err != nil {
+		t.Fatalf("expire session: %v", err)
+	}
+	if _, renewed, err := model.Renew(context.Background(), s.Token); err != nil || renewed {
+		t.Fatalf("Renew expired: renewed=%v err=%v", renewed, err)
+	}
+	if _, renewed, err := model.Renew(context.Background(), "unknown"); err != nil || renewed {
+		t.Fatalf("Renew unknown: renewed=%v err=%v", renewed, err)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	db := newTestDB(t)
+	model := &SessionModel{DB: db}
+	u := seedUser(t, db, "revoke-user")
+	other := seedUser(t, db, "other-user")
+
+	keep, _ := model.CreateSession(context.Background(), u, SessionDevice{UserAgent: "keep"})
+	drop, _ := model.CreateSession(context.Background(), u, SessionDevice{UserAgent: "drop"})
+	third, _ := model.CreateSession(context.Background(), u, SessionDevice{UserAgent: "third"})
+	foreign, _ := model.CreateSession(context.Background(), other, SessionDevice{})
+
+	if err := model.Revoke(context.Background(), u, foreign.ID); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Revoke someone else's session: %v", err)
+	}
+	if err := model.Revoke(context.Background(), u, drop.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err := model.GetSession(context.Background(), drop.Token); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("revoked session still found: %v", err)
+	}
+
+	n, err := model.RevokeOthers(context.Background(), u, keep.Token)
+	if err != nil || n != 1 {
+		t.Fatalf("RevokeOthers = %d, %v", n, err)
+	}
+	if _, _, err := model.GetSession(context.Background(), third.Token); err == nil {
+		t.Fatal("other session survived RevokeOthers")
+	}
+	if _, _, err := model.GetSession(context.Background(), foreign.Token); err != nil {
+		t.Fatalf("RevokeOthers touched another user: %v", err)
+	}
+
+	if err := model.InvalidateSession(context.Background(), keep.Token); err != nil {
+		t.Fatalf("InvalidateSession: %v", err)
+	}
+	if sessions, _ := model.Sessions(context.Background(), u, ""); len(sessions) != 0 {
+		t.Fatalf("sessions left after logout: %d", len(sessions))
+	}
+	model.CreateSession(context.Background(), u, SessionDevice{})
+	model.CreateSession(context.Background(), u, SessionDevice{})
+	if n, err := model.RevokeAll(context.Background(), u); err != nil || n != 2 {
+		t.Fatalf("RevokeAll = %d, %v", n, err)
+	}
+	if _, _, err := model.GetSession(context.Background(), foreign.Token); err != nil {
+		t.Fatalf("RevokeAll touched another user: %v", err)
+	}
+}
+
+func TestPruneSessions(t *testing.T) {
+	db := newTestDB(t)
+	model := &SessionModel{DB: db}
+	u := seedUser(t, db, "prune-user")
+
+	old, _ := model.CreateSession(context.Background(), u, SessionDevice{})
+	fresh, _ := model.CreateSession(context.Background(), u, SessionDevice{})
+	if _, err := db.Exec(`UPDATE sessions SET expiry = ? WHERE id = ?`, sessionTime(time.Now().Add(-time.Hour)), old.ID); err != nil {
+		t.Fatalf("expire session: %v", err)
+	}
+
+	if sessions, _ := model.Sessions(context.Background(), u, ""); len(sessions) != 1 || sessions[0].ID != fresh.ID {
+		t.Fatalf("Sessions lists expired session: %+v", sessions)
 	}
-	if active == nil || active.Token != created.Token {
-		t.Fatalf("unexpected active session: %#v", active)
+	n, err := model.Prune(context.Background(), time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v", n, err)
 	}
 }
 
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// forumTagsColumn selects a post's tag names joined with ", " for
+// Forum.Tags. Queries using it must alias forums as f.
+const forumTagsColumn = `COALESCE((
+		SELECT GROUP_CONCAT(t.tags, ', ' ORDER BY t.tags)
+		FROM forum_post_tags pt
+		JOIN forum_tags t ON t.id = pt.tag_id
+		WHERE pt.forum_id = f.id
+	), '')`
+
+// TagCount is a tag together with the number of visible posts carrying it.
+type TagCount struct {
+	ID    int
+	Name  string
+	Count int
+}
+
+// parseTags splits a comma-separated tag list, dropping blanks and
+// duplicates while keeping the first-seen order.
+func parseTags(tags string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// setForumTags replaces the tags linked to a post, creating any tag that does
+// not exist yet.
+func setForumTags(tx *sql.Tx, forumID int, tags string) error {
+	_, err := tx.Exec(`DELETE FROM forum_post_tags WHERE forum_id = ?;`, forumID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range parseTags(tags) {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO forum_tags (tags) VALUES (?);`, tag)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT OR IGNORE INTO forum_post_tags (forum_id, tag_id)
+		SELECT ?, id FROM forum_tags WHERE tags = ?;`
+		if _, err = tx.Exec(stmt, forumID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tagIDByName(tx *sql.Tx, name string) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM forum_tags WHERE tags = ?;`, name).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// TagCounts lists every tag with the number of visible, unexpired posts that
+// use it, most used first.
+func (m *ForumModel) TagCounts() ([]*TagCount, error) {
+	stmt := `SELECT t.id, t.tags, COUNT(f.id)
+	FROM forum_tags t
+	LEFT JOIN forum_post_tags pt ON pt.tag_id = t.id
+	LEFT JOIN forums f ON f.id = pt.forum_id
+		AND f.status = 1
+		AND f.deleted IS NULL
+		AND f.expires > strftime('%Y-%m-%d %H:%M:%S', 'now')
+	WHERE t.tags IS NOT NULL
+	GROUP BY t.id
+	ORDER BY COUNT(f.id) DESC, t.tags;`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*TagCount{}
+	for rows.Next() {
+		c := &TagCount{}
+		if err := rows.Scan(&c.ID, &c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// RenameTag renames a tag on every post that carries it. Renaming onto an
+// existing tag returns ErrDuplicateTag; use MergeTags for that.
+func (m *ForumModel) RenameTag(oldName, newName string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := tagIDByName(tx, oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	if _, err := tagIDByName(tx, newName); err == nil {
+		return ErrDuplicateTag
+	} else if !errors.Is(err, ErrNoRecord) {
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE forum_tags SET tags = ? WHERE id = ?;`, newName, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeTags moves every post tagged with source onto target and deletes
+// source. Posts that already carry both keep a single target tag.
+func (m *ForumModel) MergeTags(source, target string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sourceID, err := tagIDByName(tx, source)
+	if err != nil {
+		return err
+	}
+	targetID, err := tagIDByName(tx, target)
+	if err != nil {
+		return err
+	}
+	if sourceID == targetID {
+		return nil
+	}
+
+	stmt := `INSERT OR IGNORE INTO forum_post_tags (forum_id, tag_id)
+	SELECT forum_id, ? FROM forum_post_tags WHERE tag_id = ?;`
+	if _, err = tx.Exec(stmt, targetID, sourceID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM forum_post_tags WHERE tag_id = ?;`, sourceID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM forum_tags WHERE id = ?;`, sourceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,246 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/migrations"
+)
+
+func TestForumTagsUseJoinTable(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "mia")
+	forumID, err := m.Insert(context.Background(), "tagged", "body", "web, go, web", 7, u, "")
+	if err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := m.ChangeForumStatus(forumID, 1); err != nil {
+		t.Fatalf("ChangeForumStatus: %v", err)
+	}
+
+	var links int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_post_tags WHERE forum_id = ?`, forumID).Scan(&links); err != nil {
+		t.Fatal(err)
+	}
+	if links != 2 {
+		t.Fatalf("expected 2 post tag links, got %d", links)
+	}
+
+	got, err := m.Get(forumID, u, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Tags != "go, web" {
+		t.Fatalf("Tags = %q, want %q", got.Tags, "go, web")
+	}
+
+	filtered, err := m.ShowCategory([]string{"go", "web"})
+	if err != nil {
+		t.Fatalf("ShowCategory: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].ID != forumID {
+		t.Fatalf("post matching two tags should be listed once, got %+v", filtered)
+	}
+
+	none, err := m.ShowCategory(nil)
+	if err != nil {
+		t.Fatalf("ShowCategory(nil): %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("expected no posts without tags, got %d", len(none))
+	}
+}
+
+func TestTagCounts(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "noah")
+	seedForum(t, db, u, "one", 1, "go, web")
+	seedForum(t, db, u, "two", 1, "go")
+	seedForum(t, db, u, "hidden", 0, "web")
+	if _, err := db.Exec(`INSERT INTO forum_tags(tags) VALUES ('unused')`); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := m.TagCounts()
+	if err != nil {
+		t.Fatalf("TagCounts: %v", err)
+	}
+
+	got := make(map[string]int)
+	for _, c := range counts {
+		got[c.Name] = c.Count
+	}
+	want := map[string]int{"go": 2, "web": 1, "unused": 0}
+	if len(got) != len(want) {
+		t.Fatalf("TagCounts = %v, want %v", got, want)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Fatalf("count[%q] = %d, want %d", name, got[name], n)
+		}
+	}
+	if counts[0].Name != "go" {
+		t.Fatalf("most used tag should be first, got %q", counts[0].Name)
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "olga")
+	forumID := seedForum(t, db, u, "post", 1, "golang, web")
+
+	if err := m.RenameTag("golang", "go"); err != nil {
+		t.Fatalf("RenameTag: %v", err)
+	}
+	got, err := m.Get(forumID, u, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Tags != "go, web" {
+		t.Fatalf("Tags after rename = %q", got.Tags)
+	}
+
+	if err := m.RenameTag("go", "web"); !errors.Is(err, ErrDuplicateTag) {
+		t.Fatalf("rename onto existing tag: err = %v, want ErrDuplicateTag", err)
+	}
+	if err := m.RenameTag("missing", "other"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("rename missing tag: err = %v, want ErrNoRecord", err)
+	}
+	if err := m.RenameTag("go", "go"); err != nil {
+		t.Fatalf("rename onto itself: %v", err)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	db := newTestDB(t)
+	m := &ForumModel{DB: db}
+
+	u := seedUser(t, db, "pete")
+	both := seedForum(t, db, u, "both", 1, "golang, go")
+	only := seedForum(t, db, u, "only", 1, "golang")
+
+	if err := m.MergeTags("golang", "go"); err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+
+	for _, id := range []int{both, only} {
+		got, err := m.Get(id, u, true)
+		if err != nil {
+			t.Fatalf("Get(%d): %v", id, err)
+		}
+		if got.Tags != "go" {
+			t.Fatalf("post %d tags after merge = %q, want %q", id, got.Tags, "go")
+		}
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_tags WHERE tags = 'golang'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("merged source tag should be deleted")
+	}
+
+	if err := m.MergeTags("golang", "go"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("merge missing source: err = %v, want ErrNoRecord", err)
+	}
+	if err := m.MergeTags("go", "missing"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("merge into missing target: err = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestRemoveTagUnlinksPosts(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	users := &UserModel{DB: db}
+
+	u := seedUser(t, db, "quinn")
+	forumID := seedForum(t, db, u, "post", 1, "go, web")
+
+	if err := users.RemoveTag("web"); err != nil {
+		t.Fatalf("RemoveTag: %v", err)
+	}
+	got, err := forums.Get(forumID, u, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Tags != "go" {
+		t.Fatalf("Tags after removal = %q, want %q", got.Tags, "go")
+	}
+}
+
+func TestPostTagsMigrationSplitsLegacyTags(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.UpSteps(3); err != nil {
+		t.Fatalf("apply legacy migrations: %v", err)
+	}
+
+	u := seedUser(t, db, "rita")
+	if _, err := db.Exec(`INSERT INTO forum_tags(tags) VALUES ('go')`); err != nil {
+		t.Fatal(err)
+	}
+	for _, tags := range []string{"go, web", "web,sql , go", ""} {
+		_, err := db.Exec(
+			`INSERT INTO forums(title, content, tags, user_id, created, expires, image_path, status)
+			 VALUES('legacy', 'x', ?, ?, datetime('now'), datetime('now', '+1 day'), '', 1)`,
+			tags,
+			u,
+		)
+		if err != nil {
+			t.Fatalf("insert legacy forum: %v", err)
+		}
+	}
+
+	if _, err := migrator.UpSteps(1); err != nil {
+		t.Fatalf("apply tag migration: %v", err)
+	}
+
+	want := map[int]string{1: "go, web", 2: "go, sql, web", 3: ""}
+	for id, tags := range want {
+		var got string
+		err := db.QueryRow(`SELECT `+forumTagsColumn+` FROM forums f WHERE f.id = ?`, id).Scan(&got)
+		if err != nil {
+			t.Fatalf("query forum %d tags: %v", id, err)
+		}
+		if got != tags {
+			t.Fatalf("forum %d tags = %q, want %q", id, got, tags)
+		}
+	}
+
+	var tagRows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_tags`).Scan(&tagRows); err != nil {
+		t.Fatal(err)
+	}
+	if tagRows != 3 {
+		t.Fatalf("expected 3 distinct tags, got %d", tagRows)
+	}
+
+	if _, err := migrator.Down(1); err != nil {
+		t.Fatalf("revert tag migration: %v", err)
+	}
+	var restored string
+	if err := db.QueryRow(`SELECT tags FROM forums WHERE id = 2`).Scan(&restored); err != nil {
+		t.Fatalf("query restored tags: %v", err)
+	}
+	if restored != "go, sql, web" {
+		t.Fatalf("restored tags = %q", restored)
+	}
+}
@@ -2,11 +2,11 @@ package models
 
 import (
 	"database/sql"
-	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/aspandyar/forum/internal/migrations"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -19,20 +19,12 @@ func newTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("open sqlite: %v", err)
 	}
 
-	schemaBytes, err := os.ReadFile(filepath.Join("..", "..", "init-up.sql"))
+	migrator, err := migrations.New(db)
 	if err != nil {
-		t.Fatalf("read schema: %v", err)
+		t.Fatalf("load migrations: %v", err)
 	}
-
-	statements := strings.Split(string(schemaBytes), ";")
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		if _, err := db.Exec(stmt); err != nil {
-			t.Fatalf("exec schema statement %q: %v", stmt, err)
-		}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("apply migrations: %v", err)
 	}
 
 	t.Cleanup(func() {
@@ -77,10 +69,9 @@ func seedForum(t *testing.T, db *sql.DB, userID int, title string, status int, t
 	}
 
 	res, err := db.Exec(
-		`INSERT INTO forums(title, content, tags, user_id, created, expires, image_path, status)
-		 VALUES(?, 'content', ?, ?, datetime('now'), datetime('now', '+5 day'), '', ?)`,
+		`INSERT INTO forums(title, content, user_id, created, expires, image_path, status)
+		 VALUES(?, 'content', ?, datetime('now'), datetime('now', '+5 day'), '', ?)`,
 		title,
-		tags,
 		userID,
 		status,
 	)
@@ -92,6 +83,19 @@ func seedForum(t *testing.T, db *sql.DB, userID int, title string, status int, t
 	if err != nil {
 		t.Fatalf("seed forum id: %v", err)
 	}
+
+	for _, tag := range parseTags(tags) {
+		if _, err := db.Exec(`INSERT OR IGNORE INTO forum_tags(tags) VALUES(?)`, tag); err != nil {
+			t.Fatalf("seed tag: %v", err)
+		}
+		if _, err := db.Exec(
+			`INSERT INTO forum_post_tags(forum_id, tag_id) SELECT ?, id FROM forum_tags WHERE tags = ?`,
+			id,
+			tag,
+		); err != nil {
+			t.Fatalf("seed post tag: %v", err)
+		}
+	}
 	return int(id)
 }
 
@@ -112,5 +116,8 @@ func seedComment(t *testing.T, db *sql.DB, forumID, userID int, body string) int
 	if err != nil {
 		t.Fatalf("seed comment id: %v", err)
 	}
+	if _, err := db.Exec(`UPDATE forum_comments SET path = printf('%010d', id) WHERE id = ?`, id); err != nil {
+		t.Fatalf("seed comment path: %v", err)
+	}
 	return int(id)
 }
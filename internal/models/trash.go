@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TrashRetention is how long deleted posts and comments stay in the trash
+// before they are purged.
+var TrashRetention = 30 * 24 * time.Hour
+
+// TrashItem is a deleted post, or a deleted comment when CommentID is set.
+// Title is always the post's.
+type TrashItem struct {
+	ForumID       int
+	CommentID     int
+	Title         string
+	Content       string
+	ImagePath     string
+	OwnerID       int
+	Owner         string
+	DeletedByID   int
+	DeletedByName string
+	Deleted       time.Time
+}
+
+// PurgeAt is when the item leaves the trash for good.
+func (t *TrashItem) PurgeAt() time.Time {
+	return t.Deleted.Add(TrashRetention)
+}
+
+type TrashModel struct {
+	DB *sql.DB
+}
+
+const trashPostColumns = `f.id, 0, f.title, f.content, COALESCE(f.image_path, ''), f.user_id, COALESCE(u.name, ''),
+	COALESCE(f.deleted_by, 0), COALESCE(d.name, ''), f.deleted
+	FROM forums f
+	LEFT JOIN users u ON u.id = f.user_id
+	LEFT JOIN users d ON d.id = f.deleted_by`
+
+// Comments of a post in the trash come back with it, so they are not
+// listed on their own.
+const trashCommentColumns = `c.forum_id, c.id, f.title, c.comment, '', c.user_id, COALESCE(u.name, ''),
+	COALESCE(c.deleted_by, 0), COALESCE(d.name, ''), c.deleted
+	FROM forum_comments c
+	JOIN forums f ON f.id = c.forum_id AND f.deleted IS NULL
+	LEFT JOIN users u ON u.id = c.user_id
+	LEFT JOIN users d ON d.id = c.deleted_by`
+
+func scanTrash(rows *sql.Rows) ([]*TrashItem, error) {
+	defer rows.Close()
+
+	items := []*TrashItem{}
+	for rows.Next() {
+		t := &TrashItem{}
+		err := rows.Scan(&t.ForumID, &t.CommentID, &t.Title, &t.Content, &t.ImagePath, &t.OwnerID, &t.Owner,
+			&t.DeletedByID, &t.DeletedByName, &t.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, t)
+	}
+	return items, rows.Err()
+}
+
+// Posts lists the posts in the trash written by ownerID, or by anyone if
+// ownerID is 0, most recently deleted first.
+func (m *TrashModel) Posts(ownerID int) ([]*TrashItem, error) {
+	stmt := `SELECT ` + trashPostColumns + `
+	WHERE f.deleted IS NOT NULL AND (? = 0 OR f.user_id = ?)
+	ORDER BY f.deleted DESC, f.id DESC`
+
+	rows, err := m.DB.Query(stmt, ownerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTrash(rows)
+}
+
+// Comments lists the comments in the trash written by ownerID, or by anyone
+// if ownerID is 0, most recently deleted first.
+func (m *TrashModel) Comments(ownerID int) ([]*TrashItem, error) {
+	stmt := `SELECT ` + trashCommentColumns + `
+	WHERE c.deleted IS NOT NULL AND c.purged IS NULL AND (? = 0 OR c.user_id = ?)
+	ORDER BY c.deleted DESC, c.id DESC`
+
+	rows, err := m.DB.Query(stmt, ownerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTrash(rows)
+}
+
+func (m *TrashModel) one(stmt string, id int) (*TrashItem, error) {
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanTrash(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNoRecord
+	}
+	return items[0], nil
+}
+
+// Post returns a post in the trash.
+func (m *TrashModel) Post(forumID int) (*TrashItem, error) {
+	return m.one(`SELECT `+trashPostColumns+` WHERE f.id = ? AND f.deleted IS NOT NULL`, forumID)
+}
+
+// Comment returns a comment in the trash.
+func (m *TrashModel) Comment(commentID int) (*TrashItem, error) {
+	return m.one(`SELECT `+trashCommentColumns+` WHERE c.id = ? AND c.deleted IS NOT NULL AND c.purged IS NULL`, commentID)
+}
+
+// ExpiredPosts lists the posts deleted before cutoff, due to be purged.
+func (m *TrashModel) ExpiredPosts(cutoff time.Time) ([]*TrashItem, error) {
+	stmt := `SELECT ` + trashPostColumns + `
+	WHERE f.deleted IS NOT NULL AND f.deleted < ?
+	ORDER BY f.id`
+
+	rows, err := m.DB.Query(stmt, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return scanTrash(rows)
+}
+
+// PurgeComments deletes for good the comments deleted before cutoff and
+// returns how many went. A comment that still has replies is emptied
+// instead and stays as the placeholder of its thread.
+func (m *TrashModel) PurgeComments(ctx context.Context, cutoff time.Time) (int64, error) {
+	before := cutoff.UTC().Format("2006-01-02 15:04:05")
+
+	var purged int64
+	err := inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		// Removing a leaf may leave its parent a leaf, so repeat until no
+		// expired leaf is left; threads are at most MaxCommentDepth deep.
+		leaves := `SELECT id FROM forum_comments c
+		WHERE c.deleted IS NOT NULL AND c.deleted < ?
+			AND NOT EXISTS (SELECT 1 FROM forum_comments r WHERE r.parent_id = c.id)`
+
+		for {
+			for _, stmt := range []string{
+				`DELETE FROM forum_likes WHERE comment_id IN (` + leaves + `)`,
+				`DELETE FROM comment_revisions WHERE comment_id IN (` + leaves + `)`,
+				`DELETE FROM user_notifications WHERE comment_id IN (` + leaves + `)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt, before); err != nil {
+					return err
+				}
+			}
+			result, err := tx.ExecContext(ctx, `DELETE FROM forum_comments WHERE id IN (`+leaves+`)`, before)
+			if err != nil {
+				return err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			purged += n
+		}
+
+		placeholders := `SELECT id FROM forum_comments
+		WHERE deleted IS NOT NULL AND deleted < ? AND purged IS NULL`
+
+		for _, stmt := range []string{
+			`DELETE FROM forum_likes WHERE comment_id IN (` + placeholders + `)`,
+			`DELETE FROM comment_revisions WHERE comment_id IN (` + placeholders + `)`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, before); err != nil {
+				return err
+			}
+		}
+		stmt := `UPDATE forum_comments SET comment = '', purged = strftime('%Y-%m-%d %H:%M:%S', 'now')
+		WHERE id IN (` + placeholders + `)`
+
+		result, err := tx.ExecContext(ctx, stmt, before)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		purged += n
+		return nil
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
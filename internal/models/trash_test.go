@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrashPostsAndComments(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+	trash := &TrashModel{DB: db}
+
+	author := seedUser(t, db, "kim")
+	admin := seedUser(t, db, "lee")
+	kept := seedForum(t, db, author, "kept", VisibleStatus, "go")
+	gone := seedForum(t, db, author, "gone", VisibleStatus, "go")
+	commentID := seedComment(t, db, kept, author, "oops")
+	onGone := seedComment(t, db, gone, author, "with the post")
+
+	if err := forums.Remove(context.Background(), gone, admin); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := comments.RemoveCommentPost(commentID, author); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+	if err := comments.RemoveCommentPost(onGone, author); err != nil {
+		t.Fatalf("RemoveCommentPost: %v", err)
+	}
+
+	posts, err := trash.Posts(author)
+	if err != nil {
+		t.Fatalf("Posts: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ForumID != gone || posts[0].DeletedByID != admin || posts[0].DeletedByName != "lee" {
+		t.Fatalf("trashed posts = %+v", posts)
+	}
+	if want := posts[0].Deleted.Add(TrashRetention); !posts[0].PurgeAt().Equal(want) {
+		t.Fatalf("PurgeAt = %v, want %v", posts[0].PurgeAt(), want)
+	}
+	if posts, err := trash.Posts(admin); err != nil || len(posts) != 0 {
+		t.Fatalf("Posts(admin) = %+v, %v", posts, err)
+	}
+
+	// A comment on a trashed post comes back with the post, so it is not
+	// listed on its own.
+	all, err := trash.Comments(0)
+	if err != nil {
+		t.Fatalf("Comments: %v", err)
+	}
+	if len(all) != 1 || all[0].CommentID != commentID || all[0].Content != "oops" || all[0].Title != "kept" {
+		t.Fatalf("trashed comments = %+v", all)
+	}
+
+	if _, err := trash.Post(kept); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Post(live) err = %v, want ErrNoRecord", err)
+	}
+	if item, err := trash.Comment(commentID); err != nil || item.OwnerID != author {
+		t.Fatalf("Comment = %+v, %v", item, err)
+	}
+
+	// Nothing is old enough to purge yet.
+	expired, err := trash.ExpiredPosts(time.Now().Add(-time.Minute))
+	if err != nil || len(expired) != 0 {
+		t.Fatalf("ExpiredPosts = %+v, %v", expired, err)
+	}
+	if n, err := trash.PurgeComments(context.Background(), time.Now().Add(-time.Minute)); err != nil || n != 0 {
+		t.Fatalf("PurgeComments = %d, %v", n, err)
+	}
+
+	expired, err = trash.ExpiredPosts(time.Now().Add(time.Minute))
+	if err != nil || len(expired) != 1 || expired[0].ForumID != gone {
+		t.Fatalf("ExpiredPosts = %+v, %v", expired, err)
+	}
+	if err := forums.Purge(context.Background(), gone); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_comments WHERE forum_id = ?`, gone).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatalf("%d comments left on a purged post", left)
+	}
+	if err := forums.Restore(context.Background(), gone); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("Restore(purged) err = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestTrashPurgeCommentsKeepsThreads(t *testing.T) {
+	db := newTestDB(t)
+	forums := &ForumModel{DB: db}
+	comments := &ForumCommentModel{DB: db}
+	trash := &TrashModel{DB: db}
+
+	author := seedUser(t, db, "mia")
+	other := seedUser(t, db, "ned")
+	forumID := seedForum(t, db, author, "thread", VisibleStatus, "go")
+
+	rootID := seedComment(t, db, forumID, author, "root")
+	replyID, err := comments.ReplyPost(forumID, other, rootID, "reply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafID := seedComment(t, db, forumID, other, "leaf")
+	nestedID, err := comments.ReplyPost(forumID, other, leafID, "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{rootID, leafID, nestedID} {
+		if err := comments.RemoveCommentPost(id, author); err != nil {
+			t.Fatalf("RemoveCommentPost(%d): %v", id, err)
+		}
+	}
+
+	// root is emptied but stays over its live reply; leaf and nested go.
+	n, err := trash.PurgeComments(context.Background(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeComments: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("PurgeComments = %d, want 3", n)
+	}
+
+	var body string
+	var purged bool
+	err = db.QueryRow(`SELECT comment, purged IS NOT NULL FROM forum_comments WHERE id = ?`, rootID).Scan(&body, &purged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" || !purged {
+		t.Fatalf("purged root = %q, purged %v", body, purged)
+	}
+	if err := comments.RestoreCommentPost(rootID); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("RestoreCommentPost(purged) err = %v, want ErrNoRecord", err)
+	}
+
+	f, err := forums.Get(forumID, author, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(f.Comment) != 1 || !f.Comment[0].Deleted || len(f.Comment[0].Replies) != 1 || f.Comment[0].Replies[0].CommentID != replyID {
+		t.Fatalf("thread after purge = %+v", f.Comment)
+	}
+
+	// The reply goes too; now the placeholder has nothing left to hold.
+	if err := comments.RemoveCommentPost(replyID, other); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := trash.PurgeComments(context.Background(), time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Fatalf("PurgeComments = %d, %v, want 2", n, err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM forum_comments WHERE forum_id = ?`, forumID).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatalf("%d comments left", left)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier runs statements on the database or inside a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// txState is the transaction a context carries, with the work to do once
+// it commits.
+type txState struct {
+	tx          *sql.Tx
+	afterCommit []func()
+}
+
+// WithTx runs fn as one unit of work: every model method given the context
+// fn receives runs its statements in the same transaction, which commits
+// when fn returns nil and rolls back when it returns an error or panics.
+// Called with a context already inside WithTx, fn joins that transaction.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	st := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, st)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, f := range st.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// inTx runs fn in the transaction ctx carries or, outside WithTx, in a new
+// one on db. after runs once the transaction has committed, for live
+// updates that must not announce work that is rolled back.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error, after func()) error {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		if err := fn(st.tx); err != nil {
+			return err
+		}
+		if after != nil {
+			st.afterCommit = append(st.afterCommit, after)
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if after != nil {
+		after()
+	}
+	return nil
+}
+
+// AfterCommit runs fn once the transaction ctx carries has committed, or at
+// once outside WithTx. It is for work a rollback could not undo, such as
+// deleting files.
+func AfterCommit(ctx context.Context, fn func()) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		st.afterCommit = append(st.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// conn is what single statements run on: the transaction ctx carries, or
+// db.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		return st.tx
+	}
+	return db
+}
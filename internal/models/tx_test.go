@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aspandyar/forum/internal/testutil"
+)
+
+func TestWithTx(t *testing.T) {
+	db := newTestDB(t)
+	reports := &ReportModel{DB: db}
+	admin := seedUser(t, db, "ada")
+	ctx := context.Background()
+	actions := `SELECT COUNT(*) FROM moderation_actions WHERE actor_id = ?`
+
+	record := func(ctx context.Context) error {
+		return reports.Record(ctx, ModerationAction{ActorID: admin, Action: ActionExpiryChanged})
+	}
+
+	err := WithTx(ctx, db, func(ctx context.Context) error {
+		if err := record(ctx); err != nil {
+			return err
+		}
+		// A nested unit of work joins the outer one.
+		return WithTx(ctx, db, record)
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if n := testutil.Count(t, db, actions, admin); n != 2 {
+		t.Fatalf("committed actions = %d, want 2", n)
+	}
+
+	errStop := errors.New("stop")
+	err = WithTx(ctx, db, func(ctx context.Context) error {
+		if err := record(ctx); err != nil {
+			return err
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("WithTx error = %v, want %v", err, errStop)
+	}
+	if n := testutil.Count(t, db, actions, admin); n != 2 {
+		t.Fatalf("actions after rollback = %d, want 2", n)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("WithTx swallowed a panic")
+			}
+		}()
+		WithTx(ctx, db, func(ctx context.Context) error {
+			record(ctx)
+			panic("boom")
+		})
+	}()
+	if n := testutil.Count(t, db, actions, admin); n != 2 {
+		t.Fatalf("actions after panic = %d, want 2", n)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := WithTx(cancelled, db, record); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithTx on cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestWithTxPublishesAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+	live := &recordingPublisher{}
+	forums := &ForumModel{DB: db, Live: live}
+	reports := &ReportModel{DB: db}
+	author := seedUser(t, db, "bea")
+	moder := seedUser(t, db, "cal")
+	forumID := seedForum(t, db, author, "Pending post", PostPending, "go")
+	ctx := context.Background()
+
+	testutil.FailInserts(t, db, "moderation_actions")
+	err := WithTx(ctx, db, func(ctx context.Context) error {
+		if err := forums.SetState(ctx, forumID, PostRejected, moder, "off topic"); err != nil {
+			return err
+		}
+		return reports.Record(ctx, ModerationAction{ActorID: moder, Action: ActionPostRejected, ForumID: forumID})
+	})
+	if err == nil {
+		t.Fatal("WithTx succeeded past the injected failure")
+	}
+	if st, _ := forums.Status(forumID); st.State != PostPending {
+		t.Fatalf("state after rollback = %d, want pending", st.State)
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM user_notifications WHERE recipient_id = ?`, author); n != 0 {
+		t.Fatalf("author notified of a rolled back rejection: %d", n)
+	}
+	if events := live.take(); len(events) != 0 {
+		t.Fatalf("published %v for a rolled back rejection", events)
+	}
+
+	if _, err := db.Exec(`DROP TRIGGER fail_moderation_actions`); err != nil {
+		t.Fatal(err)
+	}
+	err = WithTx(ctx, db, func(ctx context.Context) error {
+		if err := forums.SetState(ctx, forumID, PostRejected, moder, "off topic"); err != nil {
+			return err
+		}
+		if events := live.take(); len(events) != 0 {
+			t.Errorf("published %v before commit", events)
+		}
+		return reports.Record(ctx, ModerationAction{ActorID: moder, Action: ActionPostRejected, ForumID: forumID})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if st, _ := forums.Status(forumID); st.State != PostRejected {
+		t.Fatalf("state = %d, want rejected", st.State)
+	}
+	if events := live.take(); len(events) == 0 {
+		t.Fatal("nothing published after commit")
+	}
+}
+
+func TestUserInsertIsAtomic(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserModel{DB: db}
+
+	testutil.FailInserts(t, db, "roles")
+	if err := users.Insert(context.Background(), "eve", "eve@example.com", "secret123", 2); err == nil {
+		t.Fatal("Insert succeeded past the injected failure")
+	}
+	if n := testutil.Count(t, db, `SELECT COUNT(*) FROM users WHERE email = ?`, "eve@example.com"); n != 0 {
+		t.Fatalf("user without a role left behind: %d rows", n)
+	}
+}
+
+func TestReportResolveIsAtomic(t *testing.T) {
+	db := newTestDB(t)
+	reports := &ReportModel{DB: db}
+	forums := &ForumModel{DB: db}
+	admin := seedUser(t, db, "fay")
+	moder := seedUser(t, db, "gus")
+	author := seedUser(t, db, "hal")
+	forumID := seedForum(t, db, author, "Reported post", PostPublished, "go")
+
+	id, err := reports.File(forumID, moder, []string{"obscene"}, "")
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+
+	testutil.FailInserts(t, db, "moderation_actions")
+	if _, err := reports.Resolve(context.Background(), id, admin, "hidden"); err == nil {
+		t.Fatal("Resolve succeeded past the injected failure")
+	}
+	if r, _ := reports.Get(id); r.State != ReportOpen {
+		t.Fatalf("report state = %s, want open", r.State)
+	}
+	if st, _ := forums.Status(forumID); st.State != PostPublished {
+		t.Fatalf("post state = %d, want published", st.State)
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
@@ -16,45 +17,46 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	EmailVerified  bool
 }
 
 type UserModel struct {
 	DB *sql.DB
 }
 
-func (m *UserModel) Insert(name, email, password string, role int) error {
+// Insert adds a user with role. The user and their role are written
+// together or not at all.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string, role int) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created) 
-	VALUES (?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'));`
-
-	result, err := m.DB.Exec(stmt, name, email, hashedPassword)
-	if err != nil {
-		var sqliteErr sqlite3.Error
-		if errors.As(err, &sqliteErr) {
-			if sqliteErr.Code == sqlite3.ErrConstraint && strings.Contains(sqliteErr.Error(), "UNIQUE constraint failed") {
-				return ErrDuplicateEmail
+	return inTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO users (name, email, hashed_password, created) 
+		VALUES (?, ?, ?, strftime('%Y-%m-%d %H:%M:%S', 'now'));`
+
+		result, err := tx.ExecContext(ctx, stmt, name, email, hashedPassword)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) {
+				if sqliteErr.Code == sqlite3.ErrConstraint && strings.Contains(sqliteErr.Error(), "UNIQUE constraint failed") {
+					return ErrDuplicateEmail
+				}
 			}
+			return err
 		}
-		return err
-	}
 
-	user_id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
+		user_id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
 
-	stmt = `INSERT INTO roles (role, user_id)
-	VALUES (?, ?);`
-	_, err = m.DB.Exec(stmt, role, user_id)
-	if err != nil {
+		stmt = `INSERT INTO roles (role, user_id)
+		VALUES (?, ?);`
+		_, err = tx.ExecContext(ctx, stmt, role, user_id)
 		return err
-	}
-
-	return nil
+	}, nil)
 }
 
 func (m *UserModel) InsertTags(tag string) error {
@@ -70,14 +72,28 @@ func (m *UserModel) InsertTags(tag string) error {
 }
 
 func (m *UserModel) RemoveTag(tag string) error {
-	stmt := `DELETE FROM forum_tags WHERE tags = ?;`
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := m.DB.Exec(stmt, tag)
+	stmt := `DELETE FROM forum_post_tags
+	WHERE tag_id IN (SELECT id FROM forum_tags WHERE tags = ?);`
+
+	_, err = tx.Exec(stmt, tag)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	stmt = `DELETE FROM forum_tags WHERE tags = ?;`
+
+	_, err = tx.Exec(stmt, tag)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
@@ -107,6 +123,47 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+// ByEmail returns the user registered with email, or ErrNoRecord.
+func (m *UserModel) ByEmail(email string) (*User, error) {
+	u := &User{}
+	var verified sql.NullTime
+
+	stmt := `SELECT id, name, email, created, email_verified FROM users WHERE email = ?`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	u.EmailVerified = verified.Valid
+
+	return u, nil
+}
+
+// SetPassword replaces the password of userID, giving accounts created
+// through a provider their first one.
+func (m *UserModel) SetPassword(ctx context.Context, userID int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, m.DB).ExecContext(ctx, `UPDATE users SET hashed_password = ?, has_password = 1 WHERE id = ?`, hashedPassword, userID)
+	return err
+}
+
+// MarkEmailVerified records that userID proved they own their address. The
+// first verification time is kept.
+func (m *UserModel) MarkEmailVerified(ctx context.Context, userID int) error {
+	stmt := `UPDATE users SET email_verified = strftime('%Y-%m-%d %H:%M:%S', 'now')
+	WHERE id = ? AND email_verified IS NULL`
+
+	_, err := conn(ctx, m.DB).ExecContext(ctx, stmt, userID)
+	return err
+}
+
 func (m *UserModel) GetUserRole(user_id int) (int, error) {
 	var role int
 	stmt := `SELECT role FROM roles
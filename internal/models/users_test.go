@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"testing"
 )
@@ -9,7 +10,7 @@ func TestUserInsertAuthenticateAndRole(t *testing.T) {
 	db := newTestDB(t)
 	model := &UserModel{DB: db}
 
-	if err := model.Insert("neo", "neo@example.com", "secret123", 3); err != nil {
+	if err := model.Insert(context.Background(), "neo", "neo@example.com", "secret123", 3); err != nil {
 		t.Fatalf("Insert: %v", err)
 	}
 
@@ -34,10 +35,10 @@ func TestUserInsertDuplicateEmail(t *testing.T) {
 	db := newTestDB(t)
 	model := &UserModel{DB: db}
 
-	if err := model.Insert("dup1", "dup@example.com", "secret123", 2); err != nil {
+	if err := model.Insert(context.Background(), "dup1", "dup@example.com", "secret123", 2); err != nil {
 		t.Fatalf("initial Insert: %v", err)
 	}
-	err := model.Insert("dup2", "dup@example.com", "secret456", 2)
+	err := model.Insert(context.Background(), "dup2", "dup@example.com", "secret456", 2)
 	if !errors.Is(err, ErrDuplicateEmail) {
 		t.Fatalf("expected ErrDuplicateEmail, got %v", err)
 	}
@@ -47,7 +48,7 @@ func TestUserAuthenticateInvalidCredentials(t *testing.T) {
 	db := newTestDB(t)
 	model := &UserModel{DB: db}
 
-	if err := model.Insert("auth", "auth@example.com", "secret123", 2); err != nil {
+	if err := model.Insert(context.Background(), "auth", "auth@example.com", "secret123", 2); err != nil {
 		t.Fatalf("Insert: %v", err)
 	}
 
@@ -84,3 +85,40 @@ func TestUserTagManagementAndAdminRoleInsert(t *testing.T) {
 		t.Fatalf("expected admin role row, got count=%d", count)
 	}
 }
+
+func TestUserPasswordAndVerification(t *testing.T) {
+	db := newTestDB(t)
+	model := &UserModel{DB: db}
+
+	if err := model.Insert(context.Background(), "trinity", "trinity@example.com", "secret123", 2); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	if _, err := model.ByEmail("nobody@example.com"); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("ByEmail unknown err = %v, want ErrNoRecord", err)
+	}
+	u, err := model.ByEmail("trinity@example.com")
+	if err != nil {
+		t.Fatalf("ByEmail: %v", err)
+	}
+	if u.Name != "trinity" || u.EmailVerified {
+		t.Fatalf("ByEmail = %+v", u)
+	}
+
+	if err := model.SetPassword(context.Background(), u.ID, "newsecret456"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if _, err := model.Authenticate("trinity@example.com", "secret123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("old password still works: %v", err)
+	}
+	if id, err := model.Authenticate("trinity@example.com", "newsecret456"); err != nil || id != u.ID {
+		t.Fatalf("Authenticate new password = %d, %v", id, err)
+	}
+
+	if err := model.MarkEmailVerified(context.Background(), u.ID); err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	if u, _ = model.ByEmail("trinity@example.com"); !u.EmailVerified {
+		t.Fatal("email not verified")
+	}
+}
@@ -0,0 +1,196 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far the provider's clock may be ahead of ours.
+const clockSkew = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKey decodes the RSA or P-256 key of k.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("oauth: bad RSA exponent in key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("oauth: unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("oauth: key %q is not on P-256", k.Kid)
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("oauth: unsupported key type %q", k.Kty)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience is the aud claim, which may be a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type idClaims struct {
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	AuthParty string   `json:"azp"`
+	Expiry    int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+}
+
+// parseJWT splits a compact JWS into its header, raw claims and signature.
+func parseJWT(token string) (h jwtHeader, claims []byte, signed []byte, sig []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return h, nil, nil, nil, fmt.Errorf("%w: not a JWS", ErrInvalidToken)
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return h, nil, nil, nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(header, &h); err != nil {
+		return h, nil, nil, nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	claims, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return h, nil, nil, nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	sig, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return h, nil, nil, nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	return h, claims, []byte(parts[0] + "." + parts[1]), sig, nil
+}
+
+// verifySignature checks sig over signed with key for alg. Only RS256 and
+// ES256 are accepted; in particular "none" and HMAC never are.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	sum := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: RS256 with a non-RSA key", ErrInvalidToken)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("%w: malformed ES256 signature", ErrInvalidToken)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+}
+
+// checkClaims validates the registered claims of an ID token issued to
+// clientID by issuer for the login that sent nonce.
+func checkClaims(raw []byte, issuer, clientID, nonce string, now time.Time) (map[string]interface{}, error) {
+	var c idClaims
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case c.Issuer != issuer:
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidToken, c.Issuer)
+	case !contains(c.Audience, clientID):
+		return nil, fmt.Errorf("%w: audience %q", ErrInvalidToken, c.Audience)
+	case len(c.Audience) > 1 && c.AuthParty != clientID:
+		return nil, fmt.Errorf("%w: authorized party %q", ErrInvalidToken, c.AuthParty)
+	case c.Expiry == 0 || now.After(time.Unix(c.Expiry, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case c.IssuedAt > now.Add(clockSkew).Unix():
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case nonce != "" && c.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	claims := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
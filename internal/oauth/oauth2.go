@@ -0,0 +1,176 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OAuth2 is a plain OAuth 2.0 provider whose user comes from a userinfo
+// endpoint. The subject is read from "sub" or "id", the name from "name",
+// "preferred_username" or "login".
+type OAuth2 struct {
+	Config
+}
+
+func NewOAuth2(c Config) *OAuth2 {
+	return &OAuth2{Config: c}
+}
+
+func (p *OAuth2) Name() string  { return p.Config.Name }
+func (p *OAuth2) Label() string { return p.Config.Label }
+
+func (p *OAuth2) AuthCodeURL(state, nonce, verifier string) string {
+	return authCodeURL(&p.Config, p.AuthURL, state, nonce, verifier)
+}
+
+func (p *OAuth2) Authenticate(ctx context.Context, code, verifier, nonce string) (User, error) {
+	tok, err := exchange(ctx, &p.Config, p.TokenURL, code, verifier)
+	if err != nil {
+		return User{}, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := getJSON(ctx, p.client(), p.UserInfoURL, tok.AccessToken, &claims); err != nil {
+		return User{}, err
+	}
+	return userFromClaims(claims, p.TrustEmail)
+}
+
+func authCodeURL(c *Config, endpoint, state, nonce, verifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {c.RedirectURL},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(c.Scopes) > 0 {
+		q.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if nonce != "" {
+		q.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + q.Encode()
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange redeems code at the token endpoint, proving possession of the
+// PKCE verifier.
+func exchange(ctx context.Context, c *Config, endpoint, code, verifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrExchange, resp.Status, err)
+	}
+	// Some providers answer errors with 200.
+	if resp.StatusCode != http.StatusOK || tok.Error != "" || tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s %s %s", ErrExchange, resp.Status, tok.Error, tok.ErrorDescription)
+	}
+	return &tok, nil
+}
+
+// getJSON fetches endpoint with the bearer accessToken into dst.
+func getJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: %s returned %s", endpoint, resp.Status)
+	}
+	dec := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	// Keep numeric user ids exact.
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
+
+// userFromClaims maps userinfo or ID token claims to a User. The email is
+// kept when email_verified is true, or when it is absent and trustEmail.
+func userFromClaims(claims map[string]interface{}, trustEmail bool) (User, error) {
+	var u User
+	for _, key := range []string{"sub", "id"} {
+		switch v := claims[key].(type) {
+		case string:
+			u.Subject = v
+		case json.Number:
+			u.Subject = v.String()
+		case float64:
+			u.Subject = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if u.Subject != "" {
+			break
+		}
+	}
+	if u.Subject == "" {
+		return User{}, ErrNoSubject
+	}
+
+	for _, key := range []string{"name", "preferred_username", "login"} {
+		if v, _ := claims[key].(string); v != "" {
+			u.Name = v
+			break
+		}
+	}
+
+	email, _ := claims["email"].(string)
+	verified := trustEmail
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+	if verified {
+		u.Email = email
+	}
+	return u, nil
+}
@@ -0,0 +1,507 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testIdP is a stand-in OpenID Connect provider. It hands out one code per
+// authorization, remembers the PKCE challenge and nonce sent with it, and
+// signs ID tokens with an RSA key published in its JWKS.
+type testIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]testGrant
+	// claims tweaks the ID token before it is signed.
+	claims func(map[string]interface{})
+	// sign replaces the signature step.
+	sign func(header, payload string) string
+	// userinfo is served at /userinfo.
+	userinfo map[string]interface{}
+	// noEmailInToken leaves email out of the ID token.
+	noEmailInToken bool
+	jwksHits       int
+}
+
+type testGrant struct {
+	challenge, nonce, clientID string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{t: t, key: key, kid: "k1", codes: map[string]testGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		idp.jwksHits++
+		kid := idp.kid
+		idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(idp.userinfo)
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize plays the browser: it follows authURL and returns the code the
+// provider would redirect back with.
+func (idp *testIdP) authorize(authURL string) string {
+	idp.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+		idp.t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	code := RandomString()
+	idp.mu.Lock()
+	idp.codes[code] = testGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), clientID: q.Get("client_id")}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || r.PostForm.Get("client_secret") != "secret" || Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            idp.URL,
+		"aud":            grant.clientID,
+		"sub":            "user-42",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          grant.nonce,
+		"name":           "Ada",
+		"email":          "ada@example.com",
+		"email_verified": true,
+	}
+	if idp.noEmailInToken {
+		delete(claims, "email")
+		delete(claims, "email_verified")
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access-1",
+		"token_type":   "Bearer",
+		"id_token":     idp.idToken(claims),
+	})
+}
+
+func (idp *testIdP) idToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": idp.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	h := base64.RawURLEncoding.EncodeToString(header)
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	if idp.sign != nil {
+		return h + "." + p + "." + idp.sign(h, p)
+	}
+	sum := sha256.Sum256([]byte(h + "." + p))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return h + "." + p + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (idp *testIdP) provider(t *testing.T) Provider {
+	t.Helper()
+	p, err := New(Config{
+		Name:         "keycloak",
+		ClientID:     "forum",
+		ClientSecret: "secret",
+		RedirectURL:  "https://forum.test/login/keycloak/callback",
+		Issuer:       idp.URL,
+		Client:       idp.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// login runs a whole authorization code flow against p.
+func login(idp *testIdP, p Provider) (User, error) {
+	verifier, nonce := RandomString(), RandomString()
+	code := idp.authorize(p.AuthCodeURL("state-1", nonce, verifier))
+	return p.Authenticate(context.Background(), code, verifier, nonce)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider(t)
+
+	if p.Label() != "keycloak" {
+		t.Fatalf("label defaults to the name, got %q", p.Label())
+	}
+
+	authURL, _ := url.Parse(p.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	q := authURL.Query()
+	if q.Get("state") != "state-1" || q.Get("nonce") != "nonce-1" || q.Get("scope") != "openid email profile" ||
+		q.Get("code_challenge") != Challenge("verifier-1") || strings.Contains(authURL.RawQuery, "verifier-1") {
+		t.Fatalf("authorization URL %s", authURL)
+	}
+
+	user, err := login(idp, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{Subject: "user-42", Name: "Ada", Email: "ada@example.com"}) {
+		t.Fatalf("got %+v", user)
+	}
+
+	// Keys are cached between logins.
+	if _, err := login(idp, p); err != nil {
+		t.Fatal(err)
+	}
+	if idp.jwksHits != 1 {
+		t.Fatalf("fetched the key set %d times, want 1", idp.jwksHits)
+	}
+}
+
+func TestOIDCRejectsBadTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(map[string]interface{})
+		sign   func(h, p string) string
+	}{
+		{name: "issuer", claims: func(c map[string]interface{}) { c["iss"] = "https://evil.test" }},
+		{name: "audience", claims: func(c map[string]interface{}) { c["aud"] = "someone-else" }},
+		{name: "authorized party", claims: func(c map[string]interface{}) {
+			c["aud"] = []string{"forum", "someone-else"}
+			c["azp"] = "someone-else"
+		}},
+		{name: "expired", claims: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-5 * time.Minute).Unix() }},
+		{name: "future", claims: func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "nonce", claims: func(c map[string]interface{}) { c["nonce"] = "replayed" }},
+		{name: "signature", sign: func(h, p string) string {
+			return base64.RawURLEncoding.EncodeToString(make([]byte, 256))
+		}},
+		{name: "unsigned", sign: func(h, p string) string { return "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			idp.claims, idp.sign = tt.claims, tt.sign
+			_, err := login(idp, idp.provider(t))
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCAlgorithmNone(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider(t)
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`))
+	payload, _ := json.Marshal(map[string]interface{}{
+		"iss": idp.URL, "aud": "forum", "sub": "user-42", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token := header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	oidc := p.(*OIDC)
+	d, err := oidc.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oidc.verify(context.Background(), d, token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestOIDCPKCEMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider(t)
+
+	code := idp.authorize(p.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	_, err := p.Authenticate(context.Background(), code, "another-verifier", "nonce-1")
+	if !errors.Is(err, ErrExchange) {
+		t.Fatalf("got %v, want ErrExchange", err)
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider(t)
+	if _, err := login(idp, p); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider rotates to a new key id; the cached set is refetched
+	// once the refresh interval has passed.
+	idp.mu.Lock()
+	idp.kid = "k2"
+	idp.mu.Unlock()
+
+	if _, err := login(idp, p); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refetched within the interval: %v", err)
+	}
+	oidc := p.(*OIDC)
+	oidc.now = func() time.Time { return time.Now().Add(2 * jwksRefreshInterval) }
+	if _, err := login(idp, p); err != nil {
+		t.Fatal(err)
+	}
+	if idp.jwksHits != 2 {
+		t.Fatalf("fetched the key set %d times, want 2", idp.jwksHits)
+	}
+}
+
+func TestOIDCUserInfoFallback(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.noEmailInToken = true
+	idp.userinfo = map[string]interface{}{"sub": "user-42", "email": "ada@example.com", "email_verified": true}
+
+	user, err := login(idp, idp.provider(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "ada@example.com" {
+		t.Fatalf("got %+v", user)
+	}
+
+	idp.userinfo["sub"] = "someone-else"
+	if _, err := login(idp, idp.provider(t)); err == nil {
+		t.Fatal("accepted userinfo for another subject")
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := NewOIDC(Config{Name: "x", ClientID: "forum", RedirectURL: "https://forum.test/cb", Issuer: idp.URL + "/realms/other", Client: idp.Client()})
+	if _, err := p.Authenticate(context.Background(), "code", "verifier", "nonce"); err == nil {
+		t.Fatal("accepted a discovery document from another issuer")
+	}
+}
+
+func TestES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+	pub, err := k.publicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := []byte("header.payload")
+	sum := sha256.Sum256(signed)
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	if err := verifySignature("ES256", pub, signed, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignature("ES256", pub, []byte("header.tampered"), sig); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+	if err := verifySignature("HS256", pub, signed, sig); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+}
+
+// fakeOAuth2 serves a plain OAuth 2.0 provider shaped like GitHub: numeric
+// ids, no email_verified, and a separate emails endpoint.
+func fakeOAuth2(t *testing.T, profile map[string]interface{}, emails []map[string]interface{}) *httptest.Server {
+	t.Helper()
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.URL.Query().Get("code_challenge")
+		w.Write([]byte("code-1"))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "code-1" || Challenge(r.PostForm.Get("code_verifier")) != challenge {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access-1"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(profile)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(emails)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func oauth2Login(t *testing.T, srv *httptest.Server, p Provider, verifier string) (User, error) {
+	t.Helper()
+	resp, err := srv.Client().Get(p.AuthCodeURL("state-1", "", "verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return p.Authenticate(context.Background(), "code-1", verifier, "")
+}
+
+func TestOAuth2Login(t *testing.T) {
+	srv := fakeOAuth2(t, map[string]interface{}{"id": 9007199254740993, "login": "ada", "name": nil, "email": "public@example.com"}, nil)
+
+	cfg := Config{
+		Name:         "gitlab",
+		ClientID:     "forum",
+		ClientSecret: "secret",
+		RedirectURL:  "https://forum.test/login/gitlab/callback",
+		AuthURL:      srv.URL + "/authorize",
+		TokenURL:     srv.URL + "/token",
+		UserInfoURL:  srv.URL + "/user",
+		Client:       srv.Client(),
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := oauth2Login(t, srv, p, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{Subject: "9007199254740993", Name: "ada"}) {
+		t.Fatalf("unverified email kept or id rounded: %+v", user)
+	}
+
+	cfg.TrustEmail = true
+	p, _ = New(cfg)
+	user, err = oauth2Login(t, srv, p, "verifier-1")
+	if err != nil || user.Email != "public@example.com" {
+		t.Fatalf("got %+v, %v", user, err)
+	}
+
+	if _, err := oauth2Login(t, srv, p, "wrong"); !errors.Is(err, ErrExchange) {
+		t.Fatalf("got %v, want ErrExchange", err)
+	}
+
+	cfg.UserInfoURL = ""
+	if _, err := New(cfg); err == nil {
+		t.Fatal("built an OAuth 2.0 provider without a userinfo URL")
+	}
+}
+
+func TestGitHubPrimaryVerifiedEmail(t *testing.T) {
+	srv := fakeOAuth2(t,
+		map[string]interface{}{"id": 7, "login": "octo", "name": "Octo Cat", "email": "public@example.com"},
+		[]map[string]interface{}{
+			{"email": "public@example.com", "primary": false, "verified": false},
+			{"email": "octo@example.com", "primary": true, "verified": true},
+		})
+
+	p := GitHub(Config{
+		ClientID:     "forum",
+		ClientSecret: "secret",
+		RedirectURL:  "https://forum.test/login/github/callback",
+		AuthURL:      srv.URL + "/authorize",
+		TokenURL:     srv.URL + "/token",
+		UserInfoURL:  srv.URL + "/user",
+		Client:       srv.Client(),
+	})
+	if p.Name() != "github" || p.Label() != "GitHub" {
+		t.Fatalf("preset named %q/%q", p.Name(), p.Label())
+	}
+
+	user, err := oauth2Login(t, srv, p, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{Subject: "7", Name: "Octo Cat", Email: "octo@example.com"}) {
+		t.Fatalf("got %+v", user)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	a := GitHub(Config{ClientID: "a"})
+	b := Google(Config{ClientID: "b"})
+	r := NewRegistry(a, b)
+
+	if p, ok := r.Get("google"); !ok || p != b {
+		t.Fatal("google not registered")
+	}
+	if _, ok := r.Get("gitlab"); ok {
+		t.Fatal("found an unregistered provider")
+	}
+
+	c := GitHub(Config{ClientID: "c"})
+	r.Register(c)
+	if all := r.All(); len(all) != 2 || all[0] != c {
+		t.Fatalf("register did not replace github: %v", all)
+	}
+}
@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits how often an unknown key id makes us fetch
+// the provider's keys again.
+const jwksRefreshInterval = time.Minute
+
+// OIDC is an OpenID Connect provider. Endpoints not set in its Config are
+// read from the issuer's discovery document on first use, and the user is
+// taken from the verified ID token.
+type OIDC struct {
+	Config
+
+	mu        sync.Mutex
+	discovery *discoveryDoc
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type discoveryDoc struct {
+	Issuer           string `json:"issuer"`
+	AuthEndpoint     string `json:"authorization_endpoint"`
+	TokenEndpoint    string `json:"token_endpoint"`
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+	JWKSURI          string `json:"jwks_uri"`
+}
+
+func NewOIDC(c Config) *OIDC {
+	c.Issuer = strings.TrimRight(c.Issuer, "/")
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDC{Config: c, now: time.Now}
+}
+
+func (p *OIDC) Name() string  { return p.Config.Name }
+func (p *OIDC) Label() string { return p.Config.Label }
+
+// AuthCodeURL needs the discovery document; if it cannot be fetched the
+// browser is sent to the issuer's conventional /authorize path, and the
+// error surfaces in Authenticate.
+func (p *OIDC) AuthCodeURL(state, nonce, verifier string) string {
+	endpoint := p.AuthURL
+	if endpoint == "" {
+		if d, err := p.discover(context.Background()); err == nil {
+			endpoint = d.AuthEndpoint
+		} else {
+			endpoint = p.Issuer + "/authorize"
+		}
+	}
+	return authCodeURL(&p.Config, endpoint, state, nonce, verifier)
+}
+
+func (p *OIDC) Authenticate(ctx context.Context, code, verifier, nonce string) (User, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	tok, err := exchange(ctx, &p.Config, d.TokenEndpoint, code, verifier)
+	if err != nil {
+		return User{}, err
+	}
+	if tok.IDToken == "" {
+		return User{}, fmt.Errorf("%w: token response has no id_token", ErrInvalidToken)
+	}
+
+	claims, err := p.verify(ctx, d, tok.IDToken, nonce)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := userFromClaims(claims, false)
+	if err != nil {
+		return User{}, err
+	}
+
+	// Some providers keep profile claims out of the ID token.
+	if (user.Email == "" || user.Name == "") && d.UserInfoEndpoint != "" {
+		info := map[string]interface{}{}
+		if err := getJSON(ctx, p.client(), d.UserInfoEndpoint, tok.AccessToken, &info); err != nil {
+			return User{}, err
+		}
+		extra, err := userFromClaims(info, false)
+		if err != nil || extra.Subject != user.Subject {
+			return User{}, fmt.Errorf("oauth: userinfo of %s does not match the ID token", p.Config.Name)
+		}
+		if user.Email == "" {
+			user.Email = extra.Email
+		}
+		if user.Name == "" {
+			user.Name = extra.Name
+		}
+	}
+	return user, nil
+}
+
+// discover returns the issuer's endpoints, with any set in Config taking
+// precedence. A document naming another issuer is rejected.
+func (p *OIDC) discover(ctx context.Context) (*discoveryDoc, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discoveryDoc
+	if err := getJSON(ctx, p.client(), p.Issuer+"/.well-known/openid-configuration", "", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("oauth: discovery document of %s names issuer %q", p.Issuer, d.Issuer)
+	}
+	// Keep the issuer exactly as published; ID tokens must match it.
+	p.Issuer = d.Issuer
+
+	for _, override := range []struct{ set, found *string }{
+		{&p.AuthURL, &d.AuthEndpoint},
+		{&p.TokenURL, &d.TokenEndpoint},
+		{&p.UserInfoURL, &d.UserInfoEndpoint},
+	} {
+		if *override.set != "" {
+			*override.found = *override.set
+		}
+	}
+	if d.AuthEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oauth: discovery document of %s lacks endpoints", p.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// verify checks the signature and claims of an ID token.
+func (p *OIDC) verify(ctx context.Context, d *discoveryDoc, token, nonce string) (map[string]interface{}, error) {
+	h, raw, signed, sig, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.key(ctx, d, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, signed, sig); err != nil {
+		return nil, err
+	}
+	return checkClaims(raw, p.Issuer, p.ClientID, nonce, p.now())
+}
+
+// key returns the signing key kid, refetching the key set when the
+// provider has rotated to a key we have not seen.
+func (p *OIDC) key(ctx context.Context, d *discoveryDoc, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	if !p.fetched.IsZero() && p.now().Sub(p.fetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	var set jwks
+	if err := getJSON(ctx, p.client(), d.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+	p.fetched = p.now()
+	p.keys = map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = pub
+		}
+	}
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// lookup finds kid; a token without kid matches a set of exactly one key.
+func (p *OIDC) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
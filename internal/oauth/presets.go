@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+)
+
+// Google is Google's OpenID Connect provider; c needs the client id, secret
+// and redirect URL.
+func Google(c Config) Provider {
+	if c.Name == "" {
+		c.Name = "google"
+	}
+	if c.Label == "" {
+		c.Label = "Google"
+	}
+	if c.Issuer == "" {
+		c.Issuer = "https://accounts.google.com"
+	}
+	return NewOIDC(c)
+}
+
+// GitHub signs in with GitHub, which speaks plain OAuth 2.0. Its profile
+// has no verified flag, so the email is the account's primary verified
+// address from the emails endpoint. Endpoint URLs left empty in c default to
+// github.com; a GitHub Enterprise server sets its own.
+func GitHub(c Config) Provider {
+	if c.Name == "" {
+		c.Name = "github"
+	}
+	if c.Label == "" {
+		c.Label = "GitHub"
+	}
+	if c.AuthURL == "" {
+		c.AuthURL = "https://github.com/login/oauth/authorize"
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = "https://github.com/login/oauth/access_token"
+	}
+	if c.UserInfoURL == "" {
+		c.UserInfoURL = "https://api.github.com/user"
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"read:user", "user:email"}
+	}
+	c.TrustEmail = false
+	return &gitHub{OAuth2: NewOAuth2(c)}
+}
+
+type gitHub struct {
+	*OAuth2
+}
+
+func (p *gitHub) Authenticate(ctx context.Context, code, verifier, nonce string) (User, error) {
+	tok, err := exchange(ctx, &p.Config, p.TokenURL, code, verifier)
+	if err != nil {
+		return User{}, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := getJSON(ctx, p.client(), p.UserInfoURL, tok.AccessToken, &claims); err != nil {
+		return User{}, err
+	}
+	user, err := userFromClaims(claims, false)
+	if err != nil {
+		return User{}, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	emailsURL := strings.TrimSuffix(p.UserInfoURL, "/") + "/emails"
+	if err := getJSON(ctx, p.client(), emailsURL, tok.AccessToken, &emails); err != nil {
+		return User{}, err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			user.Email = e.Email
+		}
+	}
+	return user, nil
+}
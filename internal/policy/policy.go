@@ -0,0 +1,232 @@
+// Package policy decides what each role may do. Roles are the integers
+// stored in users.role; permissions are named strings granted to roles in
+// the role_permissions table and editable by admins.
+package policy
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Roles stored in users.role.
+const (
+	Guest     = 1
+	User      = 2
+	Moderator = 3
+	Admin     = 4
+)
+
+// Roles lists every role in ascending order of privilege.
+var Roles = []int{Guest, User, Moderator, Admin}
+
+// RoleName returns the display name of a role.
+func RoleName(role int) string {
+	switch role {
+	case Guest:
+		return "guest"
+	case User:
+		return "user"
+	case Moderator:
+		return "moderator"
+	case Admin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+type Permission string
+
+const (
+	PostPublish       Permission = "post.publish"
+	PostEditAny       Permission = "post.edit.any"
+	PostDeleteAny     Permission = "post.delete.any"
+	PostApprove       Permission = "post.approve"
+	CommentEditAny    Permission = "comment.edit.any"
+	CommentDeleteAny  Permission = "comment.delete.any"
+	ReportFile        Permission = "report.file"
+	ReportReview      Permission = "report.review"
+	ReportAssign      Permission = "report.assign"
+	ReportResolve     Permission = "report.resolve"
+	ModerationQueue   Permission = "moderation.queue"
+	ModerationRequest Permission = "moderation.request"
+	RoleAssign        Permission = "role.assign"
+	TagManage         Permission = "tag.manage"
+	AuditRead         Permission = "audit.read"
+	HistoryRead       Permission = "history.read"
+	HistoryRestore    Permission = "history.restore"
+	ExpiryManage      Permission = "expiry.manage"
+)
+
+// Permissions lists every permission with what it allows, in the order the
+// admin page shows them.
+var Permissions = []struct {
+	Name        Permission
+	Description string
+}{
+	{PostPublish, "Publish posts without review"},
+	{PostEditAny, "Edit anyone's posts"},
+	{PostDeleteAny, "Delete anyone's posts"},
+	{PostApprove, "Approve queued posts"},
+	{CommentEditAny, "Edit anyone's comments"},
+	{CommentDeleteAny, "Delete anyone's comments"},
+	{ReportFile, "Report posts"},
+	{ReportReview, "See the report queue, take and dismiss reports"},
+	{ReportAssign, "Assign reports to other staff"},
+	{ReportResolve, "Resolve reports by hiding the post"},
+	{ModerationQueue, "Read and clear the moderation queue"},
+	{ModerationRequest, "Ask to become a moderator"},
+	{RoleAssign, "Promote and demote moderators and edit role permissions"},
+	{TagManage, "Add, remove, rename and merge tags"},
+	{AuditRead, "Read the moderation audit log"},
+	{HistoryRead, "Read the edit history of anyone's posts and comments"},
+	{HistoryRestore, "Restore earlier versions of posts and comments"},
+	{ExpiryManage, "Choose the lifetimes offered for posts"},
+}
+
+// Defaults are the grants seeded by the role_permissions migration.
+var Defaults = map[int][]Permission{
+	User:      {ModerationRequest},
+	Moderator: {PostApprove, ReportFile, ReportReview, ModerationQueue, HistoryRead},
+	Admin: {
+		PostPublish, PostEditAny, PostDeleteAny, PostApprove, CommentEditAny, CommentDeleteAny,
+		ReportFile, ReportReview, ReportAssign, ReportResolve, ModerationQueue,
+		RoleAssign, TagManage, AuditRead, HistoryRead, HistoryRestore, ExpiryManage,
+	},
+}
+
+var (
+	ErrUnknownRole       = errors.New("policy: unknown role")
+	ErrUnknownPermission = errors.New("policy: unknown permission")
+	// ErrLockout is returned when a change would leave no role able to
+	// edit permissions.
+	ErrLockout = errors.New("policy: admins must keep role.assign")
+)
+
+// Valid reports whether p is a known permission.
+func Valid(p Permission) bool {
+	for _, known := range Permissions {
+		if known.Name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists role grants.
+type Store interface {
+	Grants() (map[int][]string, error)
+	SetGrants(role int, permissions []string) error
+}
+
+// Policy answers permission checks from grants loaded once from its store
+// and kept in memory; Set writes through.
+type Policy struct {
+	store Store
+
+	mu     sync.RWMutex
+	grants map[int]map[Permission]bool
+}
+
+func New(store Store) *Policy {
+	return &Policy{store: store}
+}
+
+func setOf(perms []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(perms))
+	for _, perm := range perms {
+		set[perm] = true
+	}
+	return set
+}
+
+func (p *Policy) load() (map[int]map[Permission]bool, error) {
+	p.mu.RLock()
+	grants := p.grants
+	p.mu.RUnlock()
+	if grants != nil {
+		return grants, nil
+	}
+
+	stored, err := p.store.Grants()
+	if err != nil {
+		return nil, err
+	}
+	grants = make(map[int]map[Permission]bool, len(stored))
+	for role, names := range stored {
+		perms := make([]Permission, len(names))
+		for i, name := range names {
+			perms[i] = Permission(name)
+		}
+		grants[role] = setOf(perms)
+	}
+
+	p.mu.Lock()
+	p.grants = grants
+	p.mu.Unlock()
+	return grants, nil
+}
+
+// Can reports whether role holds perm.
+func (p *Policy) Can(role int, perm Permission) (bool, error) {
+	grants, err := p.load()
+	if err != nil {
+		return false, err
+	}
+	return grants[role][perm], nil
+}
+
+// Granted returns the permissions held by role, sorted.
+func (p *Policy) Granted(role int) ([]Permission, error) {
+	grants, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]Permission, 0, len(grants[role]))
+	for perm := range grants[role] {
+		perms = append(perms, perm)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms, nil
+}
+
+// Set replaces the permissions of role. Admins cannot lose role.assign, so
+// someone can always undo a mistake.
+func (p *Policy) Set(role int, perms []Permission) error {
+	if RoleName(role) == "unknown" {
+		return ErrUnknownRole
+	}
+	set := setOf(perms)
+	for perm := range set {
+		if !Valid(perm) {
+			return ErrUnknownPermission
+		}
+	}
+	if role == Admin && !set[RoleAssign] {
+		return ErrLockout
+	}
+
+	names := make([]string, 0, len(set))
+	for _, known := range Permissions {
+		if set[known.Name] {
+			names = append(names, string(known.Name))
+		}
+	}
+	if err := p.store.SetGrants(role, names); err != nil {
+		return err
+	}
+
+	// Readers hold the old map without a lock, so swap in a copy.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.grants != nil {
+		grants := make(map[int]map[Permission]bool, len(p.grants)+1)
+		for r, perms := range p.grants {
+			grants[r] = perms
+		}
+		grants[role] = set
+		p.grants = grants
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type memStore struct {
+	grants map[int][]string
+	loads  int
+	err    error
+}
+
+func (s *memStore) Grants() (map[int][]string, error) {
+	s.loads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make(map[int][]string, len(s.grants))
+	for role, perms := range s.grants {
+		out[role] = append([]string(nil), perms...)
+	}
+	return out, nil
+}
+
+func (s *memStore) SetGrants(role int, permissions []string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.grants[role] = permissions
+	return nil
+}
+
+func defaultStore() *memStore {
+	s := &memStore{grants: map[int][]string{}}
+	for role, perms := range Defaults {
+		for _, perm := range perms {
+			s.grants[role] = append(s.grants[role], string(perm))
+		}
+	}
+	return s
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	for role, perms := range Defaults {
+		if RoleName(role) == "unknown" {
+			t.Errorf("Defaults has unknown role %d", role)
+		}
+		for _, perm := range perms {
+			if !Valid(perm) {
+				t.Errorf("Defaults[%s] has unknown permission %q", RoleName(role), perm)
+			}
+		}
+	}
+	if Valid("post.frobnicate") {
+		t.Fatal("Valid accepted an unknown permission")
+	}
+}
+
+func TestCan(t *testing.T) {
+	store := defaultStore()
+	p := New(store)
+
+	tests := []struct {
+		role int
+		perm Permission
+		want bool
+	}{
+		{Guest, ReportFile, false},
+		{User, ModerationRequest, true},
+		{User, ReportFile, false},
+		{Moderator, ReportReview, true},
+		{Moderator, ReportResolve, false},
+		{Admin, RoleAssign, true},
+		{Admin, ModerationRequest, false},
+		{99, PostPublish, false},
+	}
+	for _, tt := range tests {
+		got, err := p.Can(tt.role, tt.perm)
+		if err != nil {
+			t.Fatalf("Can(%d, %s): %v", tt.role, tt.perm, err)
+		}
+		if got != tt.want {
+			t.Errorf("Can(%s, %s) = %v, want %v", RoleName(tt.role), tt.perm, got, tt.want)
+		}
+	}
+	if store.loads != 1 {
+		t.Fatalf("store loaded %d times, want 1", store.loads)
+	}
+}
+
+func TestCanStoreError(t *testing.T) {
+	boom := errors.New("boom")
+	p := New(&memStore{err: boom})
+	if _, err := p.Can(Admin, RoleAssign); !errors.Is(err, boom) {
+		t.Fatalf("Can err = %v, want %v", err, boom)
+	}
+}
+
+func TestGranted(t *testing.T) {
+	p := New(defaultStore())
+	got, err := p.Granted(Moderator)
+	if err != nil {
+		t.Fatalf("Granted: %v", err)
+	}
+	want := []Permission{HistoryRead, ModerationQueue, PostApprove, ReportFile, ReportReview}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Granted(moderator) = %v, want %v", got, want)
+	}
+	if got, _ := p.Granted(Guest); len(got) != 0 {
+		t.Fatalf("Granted(guest) = %v, want none", got)
+	}
+}
+
+func TestSet(t *testing.T) {
+	store := defaultStore()
+	p := New(store)
+	if ok, _ := p.Can(Moderator, TagManage); ok {
+		t.Fatal("moderator can manage tags before Set")
+	}
+
+	if err := p.Set(Moderator, []Permission{TagManage, ReportReview, TagManage}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if want := []string{"report.review", "tag.manage"}; !reflect.DeepEqual(store.grants[Moderator], want) {
+		t.Fatalf("stored grants = %v, want %v", store.grants[Moderator], want)
+	}
+	if ok, _ := p.Can(Moderator, TagManage); !ok {
+		t.Fatal("Set did not grant tag.manage")
+	}
+	if ok, _ := p.Can(Moderator, PostApprove); ok {
+		t.Fatal("Set did not revoke post.approve")
+	}
+	if ok, _ := p.Can(Admin, RoleAssign); !ok {
+		t.Fatal("Set changed another role")
+	}
+	if store.loads != 1 {
+		t.Fatalf("store reloaded after Set: %d loads", store.loads)
+	}
+}
+
+func TestSetRejects(t *testing.T) {
+	store := defaultStore()
+	p := New(store)
+
+	tests := []struct {
+		name  string
+		role  int
+		perms []Permission
+		want  error
+	}{
+		{"unknown role", 0, nil, ErrUnknownRole},
+		{"unknown permission", User, []Permission{"post.frobnicate"}, ErrUnknownPermission},
+		{"admin lockout", Admin, []Permission{TagManage}, ErrLockout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Set(tt.role, tt.perms); !errors.Is(err, tt.want) {
+				t.Fatalf("Set err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if ok, _ := p.Can(Admin, TagManage); !ok {
+		t.Fatal("rejected Set changed admin grants")
+	}
+	if ok, _ := p.Can(User, ModerationRequest); !ok {
+		t.Fatal("rejected Set changed user grants")
+	}
+}
@@ -1,27 +1,116 @@
 package sqlite
 
-import "github.com/aspandyar/forum/internal/models"
+import (
+	"context"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
 
 type UserRepository struct {
 	Model *models.UserModel
 }
 
-func (r *UserRepository) Insert(name, email, password string, role int) error {
-	return r.Model.Insert(name, email, password, role)
+func (r *UserRepository) Insert(ctx context.Context, name, email, password string, role int) error {
+	return r.Model.Insert(ctx, name, email, password, role)
 }
 
 func (r *UserRepository) Authenticate(email, password string) (int, error) {
 	return r.Model.Authenticate(email, password)
 }
 
+func (r *UserRepository) GetUserRole(userID int) (int, error) {
+	return r.Model.GetUserRole(userID)
+}
+
+func (r *UserRepository) ByEmail(email string) (*models.User, error) {
+	return r.Model.ByEmail(email)
+}
+
+func (r *UserRepository) SetPassword(ctx context.Context, userID int, password string) error {
+	return r.Model.SetPassword(ctx, userID, password)
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID int) error {
+	return r.Model.MarkEmailVerified(ctx, userID)
+}
+
 type SessionRepository struct {
 	Model *models.SessionModel
 }
 
-func (r *SessionRepository) CreateSession(userID int) (*models.Session, error) {
-	return r.Model.CreateSession(userID)
+func (r *SessionRepository) CreateSession(ctx context.Context, userID int, device models.SessionDevice) (*models.Session, error) {
+	return r.Model.CreateSession(ctx, userID, device)
+}
+
+func (r *SessionRepository) InvalidateSession(ctx context.Context, token string) error {
+	return r.Model.InvalidateSession(ctx, token)
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, token string) (int, time.Time, error) {
+	return r.Model.GetSession(ctx, token)
+}
+
+func (r *SessionRepository) Renew(ctx context.Context, token string) (time.Time, bool, error) {
+	return r.Model.Renew(ctx, token)
+}
+
+func (r *SessionRepository) Sessions(ctx context.Context, userID int, currentToken string) ([]*models.Session, error) {
+	return r.Model.Sessions(ctx, userID, currentToken)
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, userID, id int) error {
+	return r.Model.Revoke(ctx, userID, id)
+}
+
+func (r *SessionRepository) RevokeOthers(ctx context.Context, userID int, keepToken string) (int64, error) {
+	return r.Model.RevokeOthers(ctx, userID, keepToken)
+}
+
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int) (int64, error) {
+	return r.Model.RevokeAll(ctx, userID)
+}
+
+func (r *SessionRepository) Prune(ctx context.Context, now time.Time) (int64, error) {
+	return r.Model.Prune(ctx, now)
+}
+
+type TokenRepository struct {
+	Model *models.AccountTokenModel
+}
+
+func (r *TokenRepository) Issue(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	return r.Model.Issue(ctx, userID, purpose, ttl)
+}
+
+func (r *TokenRepository) Consume(ctx context.Context, token, purpose string) (int, error) {
+	return r.Model.Consume(ctx, token, purpose)
+}
+
+func (r *TokenRepository) Prune(now time.Time) (int64, error) {
+	return r.Model.Prune(now)
+}
+
+type IdentityRepository struct {
+	Model *models.OAuthIdentityModel
+}
+
+func (r *IdentityRepository) UserID(provider, subject string) (int, error) {
+	return r.Model.UserID(provider, subject)
+}
+
+func (r *IdentityRepository) InsertUser(provider, subject, name, email string, role int) (int, error) {
+	return r.Model.InsertUser(provider, subject, name, email, role)
+}
+
+func (r *IdentityRepository) Link(userID int, provider, subject, email string) error {
+	return r.Model.Link(userID, provider, subject, email)
+}
+
+func (r *IdentityRepository) Identities(userID int) ([]*models.OAuthIdentity, error) {
+	return r.Model.Identities(userID)
 }
 
-func (r *SessionRepository) InvalidateSession(token string) error {
-	return r.Model.InvalidateSession(token)
+func (r *IdentityRepository) Unlink(userID int, provider string) error {
+	return r.Model.Unlink(userID, provider)
 }
@@ -11,14 +11,30 @@ func (r *CommentRepository) CommentPost(forumID, userID int, comment string) (in
 	return r.CommentModel.CommentPost(forumID, userID, comment)
 }
 
-func (r *CommentRepository) EditCommentPost(forumID, userID int, comment string, commentID int) error {
-	return r.CommentModel.EditCommentPost(forumID, userID, comment, commentID)
+func (r *CommentRepository) ReplyPost(forumID, userID, parentID int, comment string) (int, error) {
+	return r.CommentModel.ReplyPost(forumID, userID, parentID, comment)
 }
 
-func (r *CommentRepository) RemoveCommentPost(commentID int) error {
-	return r.CommentModel.RemoveCommentPost(commentID)
+func (r *CommentRepository) EditCommentPost(forumID, userID int, comment string, commentID, editorID int) error {
+	return r.CommentModel.EditCommentPost(forumID, userID, comment, commentID, editorID)
+}
+
+func (r *CommentRepository) RemoveCommentPost(commentID, userID int) error {
+	return r.CommentModel.RemoveCommentPost(commentID, userID)
+}
+
+func (r *CommentRepository) RestoreCommentPost(commentID int) error {
+	return r.CommentModel.RestoreCommentPost(commentID)
 }
 
 func (r *CommentRepository) ShowAllUserComments(userID int) ([]*models.ForumComment, error) {
 	return r.ForumModel.ShowAllUserComments(userID)
 }
+
+func (r *CommentRepository) GetUserIDFromComment(commentID int) (int, error) {
+	return r.ForumModel.GetUserIDFromComment(commentID)
+}
+
+func (r *CommentRepository) GetForumIDFromComment(commentID int) (int, error) {
+	return r.ForumModel.GetForumIDFromComment(commentID)
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type ExpiryRepository struct {
+	Model *models.ExpiryModel
+}
+
+func (r *ExpiryRepository) Policies() ([]*models.ExpiryPolicy, error) {
+	return r.Model.Policies()
+}
+
+func (r *ExpiryRepository) Policy(days int) (*models.ExpiryPolicy, error) {
+	return r.Model.Policy(days)
+}
+
+func (r *ExpiryRepository) SetPolicy(days int, label string) error {
+	return r.Model.SetPolicy(days, label)
+}
+
+func (r *ExpiryRepository) RemovePolicy(days int) error {
+	return r.Model.RemovePolicy(days)
+}
+
+func (r *ExpiryRepository) SetDefault(days int) error {
+	return r.Model.SetDefault(days)
+}
+
+func (r *ExpiryRepository) Remind(before time.Time) (int, error) {
+	return r.Model.Remind(before)
+}
+
+func (r *ExpiryRepository) Archive(now time.Time) (int, error) {
+	return r.Model.Archive(now)
+}
+
+func (r *ExpiryRepository) Archived(cutoff time.Time) ([]*models.Forum, error) {
+	return r.Model.Archived(cutoff)
+}
@@ -1,6 +1,8 @@
 package sqlite
 
 import (
+	"context"
+
 	"github.com/aspandyar/forum/internal/models"
 )
 
@@ -8,16 +10,32 @@ type ForumRepository struct {
 	Model *models.ForumModel
 }
 
-func (r *ForumRepository) Insert(title, content, tags string, expires, userID int, imagePath string) (int, error) {
-	return r.Model.Insert(title, content, tags, expires, userID, imagePath)
+func (r *ForumRepository) Insert(ctx context.Context, title, content, tags string, expires, userID int, imagePath string) (int, error) {
+	return r.Model.Insert(ctx, title, content, tags, expires, userID, imagePath)
+}
+
+func (r *ForumRepository) Edit(ctx context.Context, title, content, tags string, expires, userID int, imagePath string, forumID, editorID int) error {
+	return r.Model.Edit(ctx, title, content, tags, expires, userID, imagePath, forumID, editorID)
+}
+
+func (r *ForumRepository) Remove(ctx context.Context, forumID, userID int) error {
+	return r.Model.Remove(ctx, forumID, userID)
+}
+
+func (r *ForumRepository) Restore(ctx context.Context, forumID int) error {
+	return r.Model.Restore(ctx, forumID)
+}
+
+func (r *ForumRepository) Purge(ctx context.Context, forumID int) error {
+	return r.Model.Purge(ctx, forumID)
 }
 
-func (r *ForumRepository) Edit(title, content, tags string, expires, userID int, imagePath string, forumID int) error {
-	return r.Model.Edit(title, content, tags, expires, userID, imagePath, forumID)
+func (r *ForumRepository) ImagePath(forumID int) (string, error) {
+	return r.Model.ImagePath(forumID)
 }
 
-func (r *ForumRepository) Remove(forumID int) error {
-	return r.Model.Remove(forumID)
+func (r *ForumRepository) ImageInUse(ctx context.Context, path string) (bool, error) {
+	return r.Model.ImageInUse(ctx, path)
 }
 
 func (r *ForumRepository) Latest() ([]*models.Forum, error) {
@@ -39,3 +57,31 @@ func (r *ForumRepository) ShowAllUserPosts(userID int) ([]*models.Forum, error)
 func (r *ForumRepository) ShowAllUserLikes(userID int) ([]*models.Forum, error) {
 	return r.Model.ShowAllUserLikes(userID)
 }
+
+func (r *ForumRepository) Get(id, userID int, isOwnForum bool) (*models.Forum, error) {
+	return r.Model.Get(id, userID, isOwnForum)
+}
+
+func (r *ForumRepository) GetUserIDFromForum(forumID int) (int, error) {
+	return r.Model.GetUserIDFromForum(forumID)
+}
+
+func (r *ForumRepository) ChangeForumStatus(forumID, status int) error {
+	return r.Model.ChangeForumStatus(forumID, status)
+}
+
+func (r *ForumRepository) Status(forumID int) (*models.PostStatus, error) {
+	return r.Model.Status(forumID)
+}
+
+func (r *ForumRepository) SetState(ctx context.Context, forumID, state, actorID int, reason string) error {
+	return r.Model.SetState(ctx, forumID, state, actorID, reason)
+}
+
+func (r *ForumRepository) Renew(forumID, days, actorID int) error {
+	return r.Model.Renew(forumID, days, actorID)
+}
+
+func (r *ForumRepository) AskForNewForum(ctx context.Context, forumID, userID int, body string) error {
+	return r.Model.AskForNewForum(ctx, forumID, userID, body)
+}
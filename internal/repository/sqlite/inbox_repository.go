@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type InboxRepository struct {
+	Model *models.InboxModel
+}
+
+func (r *InboxRepository) List(recipientID int, unreadOnly bool, limit int) ([]*models.InboxNotification, error) {
+	return r.Model.List(recipientID, unreadOnly, limit)
+}
+
+func (r *InboxRepository) UnreadCount(recipientID int) (int, error) {
+	return r.Model.UnreadCount(recipientID)
+}
+
+func (r *InboxRepository) MarkRead(recipientID, id int) (*models.InboxNotification, error) {
+	return r.Model.MarkRead(recipientID, id)
+}
+
+func (r *InboxRepository) MarkAllRead(recipientID int) error {
+	return r.Model.MarkAllRead(recipientID)
+}
+
+func (r *InboxRepository) Prune(readBefore, unreadBefore time.Time) (int64, error) {
+	return r.Model.Prune(readBefore, unreadBefore)
+}
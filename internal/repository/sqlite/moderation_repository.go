@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type ModerationRepository struct {
+	Model *models.ForumModel
+}
+
+func (r *ModerationRepository) AskForModeration(userID int) error {
+	return r.Model.AskForModeration(userID)
+}
+
+func (r *ModerationRepository) ShowUserNotification(role int) ([]*models.Notification, error) {
+	return r.Model.ShowUserNotification(role)
+}
+
+func (r *ModerationRepository) RemoveUserNotification(ctx context.Context, id int) error {
+	return r.Model.RemoveUserNotification(ctx, id)
+}
+
+func (r *ModerationRepository) AnswerFromAdmin(ctx context.Context, getUserID int, body string) error {
+	return r.Model.AnswerFromAdmin(ctx, getUserID, body)
+}
+
+func (r *ModerationRepository) ChangeUserRole(ctx context.Context, userID, role int) error {
+	return r.Model.ChangeUserRole(ctx, userID, role)
+}
+
+func (r *ModerationRepository) GetRoleByUserID(userID int) (int, error) {
+	return r.Model.GetRoleByUserID(userID)
+}
+
+type ReportRepository struct {
+	Model *models.ReportModel
+}
+
+func (r *ReportRepository) File(forumID, reporterID int, reasons []string, details string) (int, error) {
+	return r.Model.File(forumID, reporterID, reasons, details)
+}
+
+func (r *ReportRepository) Get(id int) (*models.Report, error) {
+	return r.Model.Get(id)
+}
+
+func (r *ReportRepository) List(state string) ([]*models.Report, error) {
+	return r.Model.List(state)
+}
+
+func (r *ReportRepository) Assign(id, assigneeID, actorID int) error {
+	return r.Model.Assign(id, assigneeID, actorID)
+}
+
+func (r *ReportRepository) Resolve(ctx context.Context, id, actorID int, note string) (*models.Report, error) {
+	return r.Model.Resolve(ctx, id, actorID, note)
+}
+
+func (r *ReportRepository) Dismiss(ctx context.Context, id, actorID int, note string) (*models.Report, error) {
+	return r.Model.Dismiss(ctx, id, actorID, note)
+}
+
+func (r *ReportRepository) Record(ctx context.Context, a models.ModerationAction) error {
+	return r.Model.Record(ctx, a)
+}
+
+func (r *ReportRepository) Actions(filter models.AuditFilter, limit int) ([]*models.ModerationAction, error) {
+	return r.Model.Actions(filter, limit)
+}
+
+type TagRepository struct {
+	ForumModel *models.ForumModel
+	UserModel  *models.UserModel
+}
+
+func (r *TagRepository) GetAllTags() ([]string, error) {
+	return r.ForumModel.GetAllTags()
+}
+
+func (r *TagRepository) InsertTags(tag string) error {
+	return r.UserModel.InsertTags(tag)
+}
+
+func (r *TagRepository) RemoveTag(tag string) error {
+	return r.UserModel.RemoveTag(tag)
+}
+
+func (r *TagRepository) TagCounts() ([]*models.TagCount, error) {
+	return r.ForumModel.TagCounts()
+}
+
+func (r *TagRepository) RenameTag(oldName, newName string) error {
+	return r.ForumModel.RenameTag(oldName, newName)
+}
+
+func (r *TagRepository) MergeTags(source, target string) error {
+	return r.ForumModel.MergeTags(source, target)
+}
@@ -0,0 +1,16 @@
+package sqlite
+
+import "github.com/aspandyar/forum/internal/models"
+
+// PermissionRepository is the policy.Store over the role_permissions table.
+type PermissionRepository struct {
+	Model *models.PermissionModel
+}
+
+func (r *PermissionRepository) Grants() (map[int][]string, error) {
+	return r.Model.Grants()
+}
+
+func (r *PermissionRepository) SetGrants(role int, permissions []string) error {
+	return r.Model.SetGrants(role, permissions)
+}
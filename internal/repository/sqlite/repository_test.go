@@ -1,12 +1,13 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
-	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/aspandyar/forum/internal/migrations"
 	"github.com/aspandyar/forum/internal/models"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -18,18 +19,12 @@ func newRepoTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
-	schemaBytes, err := os.ReadFile(filepath.Join("..", "..", "..", "init-up.sql"))
+	migrator, err := migrations.New(db)
 	if err != nil {
-		t.Fatalf("read schema: %v", err)
+		t.Fatalf("load migrations: %v", err)
 	}
-	for _, stmt := range strings.Split(string(schemaBytes), ";") {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		if _, err := db.Exec(stmt); err != nil {
-			t.Fatalf("exec schema: %v", err)
-		}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("apply migrations: %v", err)
 	}
 	t.Cleanup(func() { _ = db.Close() })
 	return db
@@ -38,7 +33,7 @@ func newRepoTestDB(t *testing.T) *sql.DB {
 func seedRepoUser(t *testing.T, db *sql.DB, email string) int {
 	t.Helper()
 	userModel := &models.UserModel{DB: db}
-	if err := userModel.Insert(strings.Split(email, "@")[0], email, "password123", 2); err != nil {
+	if err := userModel.Insert(context.Background(), strings.Split(email, "@")[0], email, "password123", 2); err != nil {
 		t.Fatalf("insert user: %v", err)
 	}
 	var id int
@@ -53,18 +48,24 @@ func TestAuthRepositories(t *testing.T) {
 	userRepo := &UserRepository{Model: &models.UserModel{DB: db}}
 	sessionRepo := &SessionRepository{Model: &models.SessionModel{DB: db}}
 
-	if err := userRepo.Insert("alice", "alice@example.com", "password123", 2); err != nil {
+	if err := userRepo.Insert(context.Background(), "alice", "alice@example.com", "password123", 2); err != nil {
 		t.Fatalf("Insert: %v", err)
 	}
 	uid, err := userRepo.Authenticate("alice@example.com", "password123")
 	if err != nil || uid <= 0 {
 		t.Fatalf("Authenticate: uid=%d err=%v", uid, err)
 	}
-	session, err := sessionRepo.CreateSession(uid)
+	session, err := sessionRepo.CreateSession(context.Background(), uid, models.SessionDevice{UserAgent: "test"})
 	if err != nil || session == nil {
 		t.Fatalf("CreateSession: %v", err)
 	}
-	if err := sessionRepo.InvalidateSession(session.Token); err != nil {
+	if _, renewed, err := sessionRepo.Renew(context.Background(), session.Token); err != nil || renewed {
+		t.Fatalf("Renew of a fresh session: renewed=%v err=%v", renewed, err)
+	}
+	if sessions, err := sessionRepo.Sessions(context.Background(), uid, session.Token); err != nil || len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("Sessions: %+v err=%v", sessions, err)
+	}
+	if err := sessionRepo.InvalidateSession(context.Background(), session.Token); err != nil {
 		t.Fatalf("InvalidateSession: %v", err)
 	}
 }
@@ -79,11 +80,11 @@ func TestForumAndLikeAndCommentRepositories(t *testing.T) {
 	}
 
 	uid := seedRepoUser(t, db, "bob@example.com")
-	forumID, err := forumRepo.Insert("title", "body", "go, web", 7, uid, "")
+	forumID, err := forumRepo.Insert(context.Background(), "title", "body", "go, web", 7, uid, "")
 	if err != nil {
 		t.Fatalf("forum insert: %v", err)
 	}
-	if err := forumRepo.Edit("title2", "body2", "go", 7, uid, "", forumID); err != nil {
+	if err := forumRepo.Edit(context.Background(), "title2", "body2", "go", 7, uid, "", forumID, uid); err != nil {
 		t.Fatalf("forum edit: %v", err)
 	}
 	if _, err := forumRepo.Latest(); err != nil {
@@ -114,7 +115,7 @@ func TestForumAndLikeAndCommentRepositories(t *testing.T) {
 	if err := db.QueryRow(`SELECT id FROM forum_comments WHERE forum_id=? AND user_id=?`, forumID, uid).Scan(&commentID); err != nil {
 		t.Fatalf("query comment id: %v", err)
 	}
-	if err := commentRepo.EditCommentPost(forumID, uid, "edited", commentID); err != nil {
+	if err := commentRepo.EditCommentPost(forumID, uid, "edited", commentID, uid); err != nil {
 		t.Fatalf("edit comment: %v", err)
 	}
 	if _, err := likeRepo.LikeOrDislikeComment(commentID, uid, 1); err != nil {
@@ -123,10 +124,16 @@ func TestForumAndLikeAndCommentRepositories(t *testing.T) {
 	if _, err := commentRepo.ShowAllUserComments(uid); err != nil {
 		t.Fatalf("show user comments: %v", err)
 	}
-	if err := commentRepo.RemoveCommentPost(commentID); err != nil {
+	if err := commentRepo.RemoveCommentPost(commentID, uid); err != nil {
 		t.Fatalf("remove comment: %v", err)
 	}
-	if err := forumRepo.Remove(forumID); err != nil {
+	if err := forumRepo.Remove(context.Background(), forumID, uid); err != nil {
 		t.Fatalf("forum remove: %v", err)
 	}
+	if err := forumRepo.Restore(context.Background(), forumID); err != nil {
+		t.Fatalf("forum restore: %v", err)
+	}
+	if err := forumRepo.Purge(context.Background(), forumID); err != nil {
+		t.Fatalf("forum purge: %v", err)
+	}
 }
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type RevisionRepository struct {
+	Model *models.RevisionModel
+}
+
+func (r *RevisionRepository) ForumHistory(forumID int) ([]*models.Revision, error) {
+	return r.Model.ForumHistory(forumID)
+}
+
+func (r *RevisionRepository) CommentHistory(forumID int) ([]*models.Revision, error) {
+	return r.Model.CommentHistory(forumID)
+}
+
+func (r *RevisionRepository) ForumAuthor(forumID int) (int, error) {
+	return r.Model.ForumAuthor(forumID)
+}
+
+func (r *RevisionRepository) ForumRevision(id int) (*models.Revision, error) {
+	return r.Model.ForumRevision(id)
+}
+
+func (r *RevisionRepository) CommentRevision(id int) (*models.Revision, error) {
+	return r.Model.CommentRevision(id)
+}
+
+func (r *RevisionRepository) RestoreForum(ctx context.Context, id, editorID int) error {
+	return r.Model.RestoreForum(ctx, id, editorID)
+}
+
+func (r *RevisionRepository) RestoreComment(ctx context.Context, id, editorID int) error {
+	return r.Model.RestoreComment(ctx, id, editorID)
+}
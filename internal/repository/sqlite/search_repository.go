@@ -0,0 +1,11 @@
+package sqlite
+
+import "github.com/aspandyar/forum/internal/models"
+
+type SearchRepository struct {
+	Model *models.SearchModel
+}
+
+func (r *SearchRepository) Search(q models.SearchQuery) ([]*models.SearchResult, error) {
+	return r.Model.Search(q)
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+type TrashRepository struct {
+	Model *models.TrashModel
+}
+
+func (r *TrashRepository) Posts(ownerID int) ([]*models.TrashItem, error) {
+	return r.Model.Posts(ownerID)
+}
+
+func (r *TrashRepository) Comments(ownerID int) ([]*models.TrashItem, error) {
+	return r.Model.Comments(ownerID)
+}
+
+func (r *TrashRepository) Post(forumID int) (*models.TrashItem, error) {
+	return r.Model.Post(forumID)
+}
+
+func (r *TrashRepository) Comment(commentID int) (*models.TrashItem, error) {
+	return r.Model.Comment(commentID)
+}
+
+func (r *TrashRepository) ExpiredPosts(cutoff time.Time) ([]*models.TrashItem, error) {
+	return r.Model.ExpiredPosts(cutoff)
+}
+
+func (r *TrashRepository) PurgeComments(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.Model.PurgeComments(ctx, cutoff)
+}
@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+// Transactor runs service operations as one unit of work on DB.
+type Transactor struct {
+	DB *sql.DB
+}
+
+func (t *Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return models.WithTx(ctx, t.DB, fn)
+}
+
+func (t *Transactor) AfterCommit(ctx context.Context, fn func()) {
+	models.AfterCommit(ctx, fn)
+}
@@ -1,18 +1,322 @@
 package auth
 
-import "github.com/aspandyar/forum/internal/models"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/aspandyar/forum/internal/mail"
+	"github.com/aspandyar/forum/internal/models"
+)
+
+var ErrInvalidSession = errors.New("auth: invalid or expired session")
+
+// DefaultBaseURL prefixes mailed links when Service.BaseURL is empty.
+const DefaultBaseURL = "https://localhost:4000"
 
 type UserRepository interface {
-	Insert(name, email, password string, role int) error
+	Insert(ctx context.Context, name, email, password string, role int) error
 	Authenticate(email, password string) (int, error)
+	GetUserRole(userID int) (int, error)
+	ByEmail(email string) (*models.User, error)
+	SetPassword(ctx context.Context, userID int, password string) error
+	MarkEmailVerified(ctx context.Context, userID int) error
 }
 
 type SessionRepository interface {
-	CreateSession(userID int) (*models.Session, error)
-	InvalidateSession(token string) error
+	CreateSession(ctx context.Context, userID int, device models.SessionDevice) (*models.Session, error)
+	InvalidateSession(ctx context.Context, token string) error
+	GetSession(ctx context.Context, token string) (int, time.Time, error)
+	Renew(ctx context.Context, token string) (time.Time, bool, error)
+	Sessions(ctx context.Context, userID int, currentToken string) ([]*models.Session, error)
+	Revoke(ctx context.Context, userID, id int) error
+	RevokeOthers(ctx context.Context, userID int, keepToken string) (int64, error)
+	RevokeAll(ctx context.Context, userID int) (int64, error)
+	Prune(ctx context.Context, now time.Time) (int64, error)
+}
+
+type TokenRepository interface {
+	Issue(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error)
+	Consume(ctx context.Context, token, purpose string) (int, error)
+	Prune(now time.Time) (int64, error)
+}
+
+type IdentityRepository interface {
+	UserID(provider, subject string) (int, error)
+	InsertUser(provider, subject, name, email string, role int) (int, error)
+	Link(userID int, provider, subject, email string) error
+	Identities(userID int) ([]*models.OAuthIdentity, error)
+	Unlink(userID int, provider string) error
+}
+
+// Transactor runs fn as one unit of work. Repository calls made with the
+// context fn receives commit together or not at all.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
+// ExternalUser is a person as reported by an OAuth provider.
+type ExternalUser struct {
+	Provider string
+	Subject  string
+	Name     string
+	Email    string
+}
+
+// ErrEmailInUse is returned by OAuthLogin when a new login's email belongs
+// to an existing account. The owner must sign in and link the provider
+// themselves; matching on email alone would hand the account to whoever
+// controls that address at the provider.
+var ErrEmailInUse = errors.New("auth: email belongs to an existing account")
+
+// ErrNoEmail is returned by OAuthLogin when a new login comes without a
+// verified email address to register.
+var ErrNoEmail = errors.New("auth: provider did not share a verified email")
+
 type Service struct {
-	Users    UserRepository
-	Sessions SessionRepository
+	Tx         Transactor
+	Users      UserRepository
+	Sessions   SessionRepository
+	Tokens     TokenRepository
+	Identities IdentityRepository
+	Mailer     mail.Mailer
+	// BaseURL is the public address of the site, used in mailed links.
+	BaseURL string
+}
+
+// Signup registers an ordinary user account.
+func (s *Service) Signup(ctx context.Context, name, email, password string) error {
+	return s.Users.Insert(ctx, name, email, password, models.UserRole)
+}
+
+// Login checks the credentials and opens a new session on device.
+func (s *Service) Login(ctx context.Context, email, password string, device models.SessionDevice) (*models.Session, error) {
+	userID, err := s.Users.Authenticate(email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Sessions.CreateSession(ctx, userID, device)
+}
+
+// StartSession opens a session for an already verified user.
+func (s *Service) StartSession(ctx context.Context, userID int, device models.SessionDevice) (*models.Session, error) {
+	return s.Sessions.CreateSession(ctx, userID, device)
+}
+
+// OAuthLogin opens a session for the user linked to ext, signing up a new
+// account when the login is unknown. Names taken by someone else get a
+// numeric suffix.
+func (s *Service) OAuthLogin(ctx context.Context, ext ExternalUser, device models.SessionDevice) (*models.Session, error) {
+	userID, err := s.Identities.UserID(ext.Provider, ext.Subject)
+	if errors.Is(err, models.ErrNoRecord) {
+		userID, err = s.oauthSignup(ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Sessions.CreateSession(ctx, userID, device)
+}
+
+func (s *Service) oauthSignup(ext ExternalUser) (int, error) {
+	if ext.Email == "" {
+		return 0, ErrNoEmail
+	}
+	if _, err := s.Users.ByEmail(ext.Email); err == nil {
+		return 0, ErrEmailInUse
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	name := ext.Name
+	for i := 2; ; i++ {
+		userID, err := s.Identities.InsertUser(ext.Provider, ext.Subject, name, ext.Email, models.UserRole)
+		switch {
+		case errors.Is(err, models.ErrDuplicateName) && i <= 20:
+			name = fmt.Sprintf("%s %d", ext.Name, i)
+		case errors.Is(err, models.ErrDuplicateEmail):
+			return 0, ErrEmailInUse
+		default:
+			return userID, err
+		}
+	}
+}
+
+// LinkIdentity adds ext as another way for userID to sign in.
+func (s *Service) LinkIdentity(userID int, ext ExternalUser) error {
+	return s.Identities.Link(userID, ext.Provider, ext.Subject, ext.Email)
+}
+
+// UnlinkIdentity removes the provider login of userID; see
+// models.OAuthIdentityModel.Unlink.
+func (s *Service) UnlinkIdentity(userID int, provider string) error {
+	return s.Identities.Unlink(userID, provider)
+}
+
+// ListIdentities lists the provider logins linked to userID.
+func (s *Service) ListIdentities(userID int) ([]*models.OAuthIdentity, error) {
+	return s.Identities.Identities(userID)
+}
+
+func (s *Service) Logout(ctx context.Context, token string) error {
+	return s.Sessions.InvalidateSession(ctx, token)
+}
+
+// UserID resolves an unexpired session token to its user.
+func (s *Service) UserID(ctx context.Context, token string) (int, error) {
+	if token == "" {
+		return 0, ErrInvalidSession
+	}
+
+	userID, expiry, err := s.Sessions.GetSession(ctx, token)
+	if err != nil || !time.Now().Before(expiry) {
+		return 0, ErrInvalidSession
+	}
+
+	return userID, nil
+}
+
+// Renew slides the expiry of token forward; see models.SessionModel.Renew.
+func (s *Service) Renew(ctx context.Context, token string) (time.Time, bool, error) {
+	if token == "" {
+		return time.Time{}, false, nil
+	}
+	return s.Sessions.Renew(ctx, token)
+}
+
+// ListSessions lists the signed-in devices of userID, marking the one using
+// currentToken.
+func (s *Service) ListSessions(ctx context.Context, userID int, currentToken string) ([]*models.Session, error) {
+	return s.Sessions.Sessions(ctx, userID, currentToken)
+}
+
+// RevokeSession signs out one of userID's sessions. It returns
+// models.ErrNoRecord when the session is not theirs.
+func (s *Service) RevokeSession(ctx context.Context, userID, id int) error {
+	return s.Sessions.Revoke(ctx, userID, id)
+}
+
+// RevokeOtherSessions signs userID out everywhere but the current session.
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID int, currentToken string) (int64, error) {
+	return s.Sessions.RevokeOthers(ctx, userID, currentToken)
+}
+
+// PruneSessions deletes sessions that expired before now.
+func (s *Service) PruneSessions(ctx context.Context, now time.Time) (int64, error) {
+	return s.Sessions.Prune(ctx, now)
+}
+
+// RequestPasswordReset mails a reset link to email. Unknown addresses are
+// not an error, so the caller cannot tell which emails are registered.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.Users.ByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.Tokens.Issue(ctx, user.ID, models.TokenPasswordReset, models.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your forum password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your forum account. "+
+			"To choose a new one, open this link within %s:\n\n%s\n\n"+
+			"If it was not you, ignore this email; your password stays the same.\n",
+			user.Name, validFor(models.PasswordResetTTL), s.link("/user/password/reset", token)),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset
+// and signs the user out everywhere. Bad tokens give models.ErrInvalidToken.
+// The token stays usable if any step fails.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		userID, err := s.Tokens.Consume(ctx, token, models.TokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		if err := s.Users.SetPassword(ctx, userID, password); err != nil {
+			return err
+		}
+		// Whoever knew the old password may still be signed in.
+		_, err = s.Sessions.RevokeAll(ctx, userID)
+		return err
+	})
+}
+
+// SendVerification mails an address verification link to email. Unknown
+// and already verified addresses are skipped silently.
+func (s *Service) SendVerification(ctx context.Context, email string) error {
+	user, err := s.Users.ByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	token, err := s.Tokens.Issue(ctx, user.ID, models.TokenEmailVerify, models.EmailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your forum email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your address by opening this link within %s:\n\n%s\n",
+			user.Name, validFor(models.EmailVerifyTTL), s.link("/user/verify", token)),
+	})
+}
+
+// VerifyEmail marks the address of a token from SendVerification as
+// verified. Bad tokens give models.ErrInvalidToken.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		userID, err := s.Tokens.Consume(ctx, token, models.TokenEmailVerify)
+		if err != nil {
+			return err
+		}
+		return s.Users.MarkEmailVerified(ctx, userID)
+	})
+}
+
+// PruneTokens deletes used and expired account tokens.
+func (s *Service) PruneTokens(now time.Time) (int64, error) {
+	return s.Tokens.Prune(now)
+}
+
+func (s *Service) link(path, token string) string {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+// validFor spells out a link lifetime for an email: "1 hour", "48 hours".
+func validFor(d time.Duration) string {
+	n, unit := int(d/time.Minute), "minute"
+	if d >= time.Hour {
+		n, unit = int(d/time.Hour), "hour"
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func (s *Service) Role(userID int) (int, error) {
+	return s.Users.GetUserRole(userID)
 }
@@ -0,0 +1,117 @@
+package forum
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+type ExpiryRepository interface {
+	Policies() ([]*models.ExpiryPolicy, error)
+	Policy(days int) (*models.ExpiryPolicy, error)
+	SetPolicy(days int, label string) error
+	RemovePolicy(days int) error
+	SetDefault(days int) error
+	Remind(before time.Time) (int, error)
+	Archive(now time.Time) (int, error)
+	Archived(cutoff time.Time) ([]*models.Forum, error)
+}
+
+// ExpiryPolicies returns the lifetimes offered when writing or renewing a
+// post.
+func (s *Service) ExpiryPolicies() ([]*models.ExpiryPolicy, error) {
+	return s.Expiry.Policies()
+}
+
+// Renew gives a post a fresh lifetime of days from now, publishing it again
+// if it was archived. Authors renew their own posts; roles with
+// post.edit.any renew anyone's.
+func (s *Service) Renew(forumID, days, userID int) error {
+	st, err := s.post(forumID)
+	if err != nil {
+		return err
+	}
+	ok, err := s.canManage(st.OwnerID, userID, policy.PostEditAny)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	if _, err := s.Expiry.Policy(days); err != nil {
+		return err
+	}
+
+	return s.Repo.Renew(forumID, days, userID)
+}
+
+// SetExpiryPolicy offers a lifetime of days, 0 for never, under label.
+func (s *Service) SetExpiryPolicy(ctx context.Context, days int, label string, userID int) error {
+	if err := s.require(userID, policy.ExpiryManage); err != nil {
+		return err
+	}
+	if err := s.Expiry.SetPolicy(days, label); err != nil {
+		return err
+	}
+	return s.recordExpiry(ctx, userID, "offered "+expiryName(days)+" as "+label)
+}
+
+// RemoveExpiryPolicy stops offering a lifetime; posts keep their expiry.
+func (s *Service) RemoveExpiryPolicy(ctx context.Context, days, userID int) error {
+	if err := s.require(userID, policy.ExpiryManage); err != nil {
+		return err
+	}
+	if err := s.Expiry.RemovePolicy(days); err != nil {
+		return err
+	}
+	return s.recordExpiry(ctx, userID, "removed "+expiryName(days))
+}
+
+// SetDefaultExpiry makes days the lifetime preselected for new posts.
+func (s *Service) SetDefaultExpiry(ctx context.Context, days, userID int) error {
+	if err := s.require(userID, policy.ExpiryManage); err != nil {
+		return err
+	}
+	if err := s.Expiry.SetDefault(days); err != nil {
+		return err
+	}
+	return s.recordExpiry(ctx, userID, "default "+expiryName(days))
+}
+
+func (s *Service) recordExpiry(ctx context.Context, userID int, note string) error {
+	return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: models.ActionExpiryChanged, Note: note})
+}
+
+func expiryName(days int) string {
+	if days == 0 {
+		return "never"
+	}
+	return strconv.Itoa(days) + " days"
+}
+
+// SweepExpiry runs the expiry schedule as of now: authors of posts expiring
+// within models.ExpiryReminder are reminded, expired posts are archived,
+// and posts archived longer than models.ArchiveRetention are purged with
+// their images. It returns how many posts each step touched.
+func (s *Service) SweepExpiry(ctx context.Context, now time.Time) (reminded, archived, purged int, err error) {
+	if reminded, err = s.Expiry.Remind(now.Add(models.ExpiryReminder)); err != nil {
+		return reminded, 0, 0, err
+	}
+	if archived, err = s.Expiry.Archive(now); err != nil {
+		return reminded, archived, 0, err
+	}
+
+	posts, err := s.Expiry.Archived(now.Add(-models.ArchiveRetention))
+	if err != nil {
+		return reminded, archived, 0, err
+	}
+	for i, p := range posts {
+		if err := s.purgePost(ctx, p.ID, p.ImagePath); err != nil {
+			return reminded, archived, i, err
+		}
+	}
+	return reminded, archived, len(posts), nil
+}
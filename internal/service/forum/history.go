@@ -0,0 +1,134 @@
+package forum
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+type RevisionRepository interface {
+	ForumHistory(forumID int) ([]*models.Revision, error)
+	CommentHistory(forumID int) ([]*models.Revision, error)
+	ForumAuthor(forumID int) (int, error)
+	ForumRevision(id int) (*models.Revision, error)
+	CommentRevision(id int) (*models.Revision, error)
+	RestoreForum(ctx context.Context, id, editorID int) error
+	RestoreComment(ctx context.Context, id, editorID int) error
+}
+
+// History is the edit history of a post and its comments, newest version
+// first. The current version of each comes first with an ID of 0.
+type History struct {
+	Post     []*models.Revision
+	Comments []*models.Revision
+}
+
+// History returns the edit history of a post to its author and to staff
+// with history.read.
+func (s *Service) History(forumID, viewerID int) (*History, error) {
+	ownerID, err := s.author(forumID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.canManage(ownerID, viewerID, policy.PostEditAny)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.require(viewerID, policy.HistoryRead); err != nil {
+			return nil, err
+		}
+	}
+
+	h := &History{}
+	h.Post, err = s.Revisions.ForumHistory(forumID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	h.Comments, err = s.Revisions.CommentHistory(forumID)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// author returns the author of a post whatever its state. Unlike owner it
+// finds rejected, pending, trashed and expired posts, whose history staff
+// still review.
+func (s *Service) author(forumID int) (int, error) {
+	ownerID, err := s.Revisions.ForumAuthor(forumID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return ownerID, nil
+}
+
+// RestoreRevision makes an earlier version of a post current again and
+// returns the post id. The version it replaces is kept, so a restore can be
+// undone from the history like any edit.
+func (s *Service) RestoreRevision(ctx context.Context, revisionID, userID int) (int, error) {
+	if err := s.require(userID, policy.HistoryRestore); err != nil {
+		return 0, err
+	}
+	r, err := s.Revisions.ForumRevision(revisionID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if _, err := s.author(r.ForumID); err != nil {
+		return 0, err
+	}
+
+	return r.ForumID, s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Revisions.RestoreForum(ctx, revisionID, userID); err != nil {
+			return err
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{
+			ActorID: userID,
+			Action:  models.ActionRevisionRestored,
+			ForumID: r.ForumID,
+			Note:    "post revision #" + strconv.Itoa(revisionID),
+		})
+	})
+}
+
+// RestoreCommentRevision makes an earlier text of a comment current again
+// and returns its post id.
+func (s *Service) RestoreCommentRevision(ctx context.Context, revisionID, userID int) (int, error) {
+	if err := s.require(userID, policy.HistoryRestore); err != nil {
+		return 0, err
+	}
+	r, err := s.Revisions.CommentRevision(revisionID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if _, err := s.author(r.ForumID); err != nil {
+		return 0, err
+	}
+
+	return r.ForumID, s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Revisions.RestoreComment(ctx, revisionID, userID); err != nil {
+			return err
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{
+			ActorID: userID,
+			Action:  models.ActionRevisionRestored,
+			ForumID: r.ForumID,
+			Note:    "comment #" + strconv.Itoa(r.CommentID) + " revision #" + strconv.Itoa(revisionID),
+		})
+	})
+}
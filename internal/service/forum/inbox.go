@@ -0,0 +1,43 @@
+package forum
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+)
+
+// Inbox returns the user's newest notifications, only unread ones when
+// unreadOnly is set.
+func (s *Service) Inbox(userID int, unreadOnly bool) ([]*models.InboxNotification, error) {
+	return s.Inboxes.List(userID, unreadOnly, models.InboxPageSize)
+}
+
+func (s *Service) UnreadNotifications(userID int) (int, error) {
+	return s.Inboxes.UnreadCount(userID)
+}
+
+// ReadNotification marks one of the user's notifications as read and returns
+// it, so callers can follow it to the post or comment.
+func (s *Service) ReadNotification(notificationID, userID int) (*models.InboxNotification, error) {
+	n, err := s.Inboxes.MarkRead(userID, notificationID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *Service) ReadAllNotifications(userID int) error {
+	return s.Inboxes.MarkAllRead(userID)
+}
+
+// PruneInbox applies the notification retention windows relative to now.
+func (s *Service) PruneInbox(now time.Time) (int64, error) {
+	return s.Inboxes.Prune(
+		now.Add(-models.ReadNotificationRetention),
+		now.Add(-models.UnreadNotificationRetention),
+	)
+}
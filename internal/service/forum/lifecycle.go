@@ -0,0 +1,120 @@
+package forum
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+// post returns the lifecycle status of a post in any state, or ErrNotFound.
+func (s *Service) post(forumID int) (*models.PostStatus, error) {
+	st, err := s.Repo.Status(forumID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, ErrNotFound
+	}
+	return st, err
+}
+
+// maySee reports whether userID may see a post in its current state.
+// Published and archived posts are shown to everyone; the others to their
+// author and, unless they are drafts, to roles that review posts.
+func (s *Service) maySee(st *models.PostStatus, userID int) (bool, error) {
+	if st.State == models.PostPublished || st.State == models.PostArchived || (userID > 0 && st.OwnerID == userID) {
+		return true, nil
+	}
+	if st.State == models.PostDraft {
+		return false, nil
+	}
+	return s.can(userID, policy.PostApprove)
+}
+
+// authored returns the status of a post userID wrote. Posts by others give
+// ErrForbidden.
+func (s *Service) authored(forumID, userID int) (*models.PostStatus, error) {
+	st, err := s.post(forumID)
+	if err != nil {
+		return nil, err
+	}
+	if userID <= 0 || st.OwnerID != userID {
+		return nil, ErrForbidden
+	}
+	return st, nil
+}
+
+// Submit sends a draft or rejected post for review, or publishes it at once
+// for roles with post.publish.
+func (s *Service) Submit(ctx context.Context, forumID, userID int) error {
+	if _, err := s.authored(forumID, userID); err != nil {
+		return err
+	}
+	publish, err := s.can(userID, policy.PostPublish)
+	if err != nil {
+		return err
+	}
+
+	state := models.PostPending
+	if publish {
+		state = models.PostPublished
+	}
+	return s.Repo.SetState(ctx, forumID, state, userID, "")
+}
+
+// Withdraw takes a pending or rejected post back to the author's drafts.
+func (s *Service) Withdraw(ctx context.Context, forumID, userID int) error {
+	if _, err := s.authored(forumID, userID); err != nil {
+		return err
+	}
+	return s.Repo.SetState(ctx, forumID, models.PostDraft, userID, "")
+}
+
+// RejectPost turns down a pending post, or takes down a published one, and
+// tells its author why.
+func (s *Service) RejectPost(ctx context.Context, forumID, userID int, reason string) error {
+	if err := s.require(userID, policy.PostApprove); err != nil {
+		return err
+	}
+	if _, err := s.post(forumID); err != nil {
+		return err
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Repo.SetState(ctx, forumID, models.PostRejected, userID, reason); err != nil {
+			return err
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: models.ActionPostRejected, ForumID: forumID, Note: reason})
+	})
+}
+
+// Archive takes a published post off the listings without deleting it; it
+// stays readable by URL but takes no comments or votes.
+func (s *Service) Archive(ctx context.Context, forumID, userID int) error {
+	return s.archive(ctx, forumID, userID, models.PostArchived)
+}
+
+// Unarchive publishes an archived post again. Expired posts are renewed
+// instead.
+func (s *Service) Unarchive(ctx context.Context, forumID, userID int) error {
+	return s.archive(ctx, forumID, userID, models.PostPublished)
+}
+
+func (s *Service) archive(ctx context.Context, forumID, userID, state int) error {
+	st, err := s.post(forumID)
+	if err != nil {
+		return err
+	}
+	ok, err := s.canManage(st.OwnerID, userID, policy.PostDeleteAny)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	if state == models.PostPublished && (st.State != models.PostArchived || !st.Expires.After(time.Now())) {
+		return models.ErrInvalidState
+	}
+
+	return s.Repo.SetState(ctx, forumID, state, userID, "")
+}
@@ -0,0 +1,268 @@
+package forum
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+func (s *Service) role(userID int) (int, error) {
+	return s.Moderation.GetRoleByUserID(userID)
+}
+
+// Notifications returns the moderation queue visible to the user's role.
+func (s *Service) Notifications(userID int) ([]*models.Notification, error) {
+	if err := s.require(userID, policy.ModerationQueue); err != nil {
+		return nil, err
+	}
+	role, err := s.role(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Moderation.ShowUserNotification(role)
+}
+
+func (s *Service) DismissNotification(ctx context.Context, notificationID, userID int) error {
+	if err := s.require(userID, policy.ModerationQueue); err != nil {
+		return err
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Moderation.RemoveUserNotification(ctx, notificationID); err != nil {
+			return err
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: models.ActionNotificationRemoved, Note: "#" + strconv.Itoa(notificationID)})
+	})
+}
+
+func (s *Service) RequestModeration(userID int) error {
+	if err := s.require(userID, policy.ModerationRequest); err != nil {
+		return err
+	}
+
+	return s.Moderation.AskForModeration(userID)
+}
+
+// Report files a moderator report against a published post and returns
+// its id.
+func (s *Service) Report(forumID, moderID int, reasons []string, details string) (int, error) {
+	if err := s.require(moderID, policy.ReportFile); err != nil {
+		return 0, err
+	}
+	if _, err := s.owner(forumID); err != nil {
+		return 0, err
+	}
+
+	return s.Reports.File(forumID, moderID, reasons, details)
+}
+
+// ReportQueue lists the reports in state; an empty state is the open queue.
+func (s *Service) ReportQueue(state string, userID int) ([]*models.Report, error) {
+	if err := s.require(userID, policy.ReportReview); err != nil {
+		return nil, err
+	}
+
+	return s.Reports.List(state)
+}
+
+// GetReport returns one report to staff.
+func (s *Service) GetReport(reportID, userID int) (*models.Report, error) {
+	if err := s.require(userID, policy.ReportReview); err != nil {
+		return nil, err
+	}
+
+	return s.Reports.Get(reportID)
+}
+
+// AssignReport puts a report in review. Reviewers may take a report
+// themselves; assigning it to another reviewer needs report.assign.
+func (s *Service) AssignReport(reportID, assigneeID, userID int) error {
+	if err := s.require(userID, policy.ReportReview); err != nil {
+		return err
+	}
+
+	if assigneeID != userID {
+		if err := s.require(userID, policy.ReportAssign); err != nil {
+			return err
+		}
+		err := s.require(assigneeID, policy.ReportReview)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Reports.Assign(reportID, assigneeID, userID)
+}
+
+// ResolveReport upholds a report: the post is hidden and the reporting
+// moderator is told. Nothing changes unless all of it does.
+func (s *Service) ResolveReport(ctx context.Context, reportID, userID int, note string) error {
+	if err := s.require(userID, policy.ReportResolve); err != nil {
+		return err
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		report, err := s.Reports.Resolve(ctx, reportID, userID, note)
+		if err != nil {
+			return err
+		}
+		return s.Moderation.AnswerFromAdmin(ctx, report.ReporterID, "report #"+strconv.Itoa(reportID)+" resolved")
+	})
+}
+
+// DismissReport closes a report without touching the post.
+func (s *Service) DismissReport(ctx context.Context, reportID, userID int, note string) error {
+	if err := s.require(userID, policy.ReportReview); err != nil {
+		return err
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		report, err := s.Reports.Dismiss(ctx, reportID, userID, note)
+		if err != nil {
+			return err
+		}
+		if report.ReporterID == userID {
+			return nil
+		}
+		return s.Moderation.AnswerFromAdmin(ctx, report.ReporterID, "report #"+strconv.Itoa(reportID)+" dismissed")
+	})
+}
+
+// AuditLog returns moderation actions newest first.
+func (s *Service) AuditLog(filter models.AuditFilter, userID int) ([]*models.ModerationAction, error) {
+	if err := s.require(userID, policy.AuditRead); err != nil {
+		return nil, err
+	}
+
+	return s.Reports.Actions(filter, models.AuditPageSize)
+}
+
+// ApprovePost publishes a pending post and closes its queue entry.
+// Posts that are not pending give models.ErrInvalidState.
+func (s *Service) ApprovePost(ctx context.Context, notificationID, forumID, userID int) error {
+	if err := s.require(userID, policy.PostApprove); err != nil {
+		return err
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Repo.SetState(ctx, forumID, models.PostPublished, userID, ""); err != nil {
+			return err
+		}
+		if err := s.Moderation.RemoveUserNotification(ctx, notificationID); err != nil {
+			return err
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: models.ActionPostApproved, ForumID: forumID})
+	})
+}
+
+// SetModerator promotes (or, with promote false, demotes) a user and closes
+// the queue entry that triggered it, if any. The role change, the queue
+// and the audit log change together or not at all.
+func (s *Service) SetModerator(ctx context.Context, notificationID, targetID int, promote bool, userID int) error {
+	if err := s.require(userID, policy.RoleAssign); err != nil {
+		return err
+	}
+
+	newRole := models.UserRole
+	action := models.ActionModeratorDemoted
+	if promote {
+		newRole = models.ModeratorRole
+		action = models.ActionModeratorPromoted
+	}
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Moderation.ChangeUserRole(ctx, targetID, newRole); err != nil {
+			return err
+		}
+		if notificationID > 0 {
+			if err := s.Moderation.RemoveUserNotification(ctx, notificationID); err != nil {
+				return err
+			}
+		}
+		return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: action, TargetUserID: targetID})
+	})
+}
+
+// Permissions returns the grants of every role to users who may edit them.
+func (s *Service) Permissions(userID int) (map[int][]policy.Permission, error) {
+	if err := s.require(userID, policy.RoleAssign); err != nil {
+		return nil, err
+	}
+
+	grants := make(map[int][]policy.Permission, len(policy.Roles))
+	for _, role := range policy.Roles {
+		perms, err := s.Policy.Granted(role)
+		if err != nil {
+			return nil, err
+		}
+		grants[role] = perms
+	}
+	return grants, nil
+}
+
+// SetPermissions replaces the grants of role and records the change in the
+// audit log.
+func (s *Service) SetPermissions(ctx context.Context, role int, perms []policy.Permission, userID int) error {
+	if err := s.require(userID, policy.RoleAssign); err != nil {
+		return err
+	}
+	if err := s.Policy.Set(role, perms); err != nil {
+		return err
+	}
+
+	granted, err := s.Policy.Granted(role)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(granted))
+	for i, perm := range granted {
+		names[i] = string(perm)
+	}
+	note := policy.RoleName(role) + ": " + strings.Join(names, ", ")
+	return s.Reports.Record(ctx, models.ModerationAction{ActorID: userID, Action: models.ActionPermissionsChanged, Note: note})
+}
+
+func (s *Service) AddTags(tags []string, userID int) error {
+	return s.changeTags(tags, userID, s.Tags.InsertTags)
+}
+
+func (s *Service) RemoveTags(tags []string, userID int) error {
+	return s.changeTags(tags, userID, s.Tags.RemoveTag)
+}
+
+// RenameTag renames a tag on every post that uses it.
+func (s *Service) RenameTag(oldName, newName string, userID int) error {
+	if err := s.require(userID, policy.TagManage); err != nil {
+		return err
+	}
+	return s.Tags.RenameTag(oldName, newName)
+}
+
+// MergeTags retags every post carrying source with target and removes source.
+func (s *Service) MergeTags(source, target string, userID int) error {
+	if err := s.require(userID, policy.TagManage); err != nil {
+		return err
+	}
+	return s.Tags.MergeTags(source, target)
+}
+
+func (s *Service) changeTags(tags []string, userID int, apply func(string) error) error {
+	if err := s.require(userID, policy.TagManage); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if err := apply(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
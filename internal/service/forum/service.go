@@ -1,18 +1,471 @@
 package forum
 
-import "github.com/aspandyar/forum/internal/models"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+var (
+	ErrForbidden = errors.New("forum: action not permitted")
+	ErrNotFound  = errors.New("forum: not found")
+)
 
 type Repository interface {
-	Insert(title, content, tags string, expires, userID int, imagePath string) (int, error)
-	Edit(title, content, tags string, expires, userID int, imagePath string, forumID int) error
-	Remove(forumID int) error
+	Insert(ctx context.Context, title, content, tags string, expires, userID int, imagePath string) (int, error)
+	Edit(ctx context.Context, title, content, tags string, expires, userID int, imagePath string, forumID, editorID int) error
+	Remove(ctx context.Context, forumID, userID int) error
+	Restore(ctx context.Context, forumID int) error
+	Purge(ctx context.Context, forumID int) error
+	ImagePath(forumID int) (string, error)
+	ImageInUse(ctx context.Context, path string) (bool, error)
 	Latest() ([]*models.Forum, error)
 	ShowAll() ([]*models.Forum, error)
 	ShowCategory(tags []string) ([]*models.Forum, error)
 	ShowAllUserPosts(userID int) ([]*models.Forum, error)
 	ShowAllUserLikes(userID int) ([]*models.Forum, error)
+	Get(id, userID int, isOwnForum bool) (*models.Forum, error)
+	GetUserIDFromForum(forumID int) (int, error)
+	ChangeForumStatus(forumID, status int) error
+	Status(forumID int) (*models.PostStatus, error)
+	SetState(ctx context.Context, forumID, state, actorID int, reason string) error
+	Renew(forumID, days, actorID int) error
+	AskForNewForum(ctx context.Context, forumID, userID int, body string) error
+}
+
+// ImageStore deletes uploaded images no post shows any more.
+type ImageStore interface {
+	Delete(path string) error
+}
+
+type CommentRepository interface {
+	CommentPost(forumID, userID int, comment string) (int, error)
+	ReplyPost(forumID, userID, parentID int, comment string) (int, error)
+	EditCommentPost(forumID, userID int, comment string, commentID, editorID int) error
+	RemoveCommentPost(commentID, userID int) error
+	RestoreCommentPost(commentID int) error
+	ShowAllUserComments(userID int) ([]*models.ForumComment, error)
+	GetUserIDFromComment(commentID int) (int, error)
+	GetForumIDFromComment(commentID int) (int, error)
+}
+
+type LikeRepository interface {
+	LikeOrDislike(forumID, userID, likeStatus int) (int, error)
+	LikeOrDislikeComment(commentID, userID, likeStatus int) (int, error)
+}
+
+type ModerationRepository interface {
+	AskForModeration(userID int) error
+	ShowUserNotification(role int) ([]*models.Notification, error)
+	RemoveUserNotification(ctx context.Context, id int) error
+	AnswerFromAdmin(ctx context.Context, getUserID int, body string) error
+	ChangeUserRole(ctx context.Context, userID, role int) error
+	GetRoleByUserID(userID int) (int, error)
+}
+
+type ReportRepository interface {
+	File(forumID, reporterID int, reasons []string, details string) (int, error)
+	Get(id int) (*models.Report, error)
+	List(state string) ([]*models.Report, error)
+	Assign(id, assigneeID, actorID int) error
+	Resolve(ctx context.Context, id, actorID int, note string) (*models.Report, error)
+	Dismiss(ctx context.Context, id, actorID int, note string) (*models.Report, error)
+	Record(ctx context.Context, a models.ModerationAction) error
+	Actions(filter models.AuditFilter, limit int) ([]*models.ModerationAction, error)
+}
+
+type TagRepository interface {
+	GetAllTags() ([]string, error)
+	InsertTags(tag string) error
+	RemoveTag(tag string) error
+	TagCounts() ([]*models.TagCount, error)
+	RenameTag(oldName, newName string) error
+	MergeTags(source, target string) error
+}
+
+type SearchRepository interface {
+	Search(q models.SearchQuery) ([]*models.SearchResult, error)
+}
+
+type InboxRepository interface {
+	List(recipientID int, unreadOnly bool, limit int) ([]*models.InboxNotification, error)
+	UnreadCount(recipientID int) (int, error)
+	MarkRead(recipientID, id int) (*models.InboxNotification, error)
+	MarkAllRead(recipientID int) error
+	Prune(readBefore, unreadBefore time.Time) (int64, error)
+}
+
+// Transactor runs fn as one unit of work. Repository calls made with the
+// context fn receives commit together or not at all. AfterCommit holds fn
+// back until the unit of work ctx belongs to has committed.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	AfterCommit(ctx context.Context, fn func())
 }
 
 type Service struct {
-	Repo Repository
+	Tx         Transactor
+	Repo       Repository
+	Comments   CommentRepository
+	Likes      LikeRepository
+	Moderation ModerationRepository
+	Reports    ReportRepository
+	Tags       TagRepository
+	Search     SearchRepository
+	Inboxes    InboxRepository
+	Revisions  RevisionRepository
+	Trash      TrashRepository
+	Expiry     ExpiryRepository
+	Images     ImageStore
+	Policy     *policy.Policy
+}
+
+// Post carries the editable fields of a forum post. On update an empty
+// ImagePath keeps the current image unless RemoveImage is set. Draft is
+// only read by Create.
+type Post struct {
+	Title       string
+	Content     string
+	Tags        string
+	Expires     int
+	ImagePath   string
+	RemoveImage bool
+	Draft       bool
+}
+
+func (s *Service) Latest() ([]*models.Forum, error) {
+	return s.Repo.Latest()
+}
+
+func (s *Service) ShowAll() ([]*models.Forum, error) {
+	return s.Repo.ShowAll()
+}
+
+func (s *Service) ShowCategory(tags []string) ([]*models.Forum, error) {
+	return s.Repo.ShowCategory(tags)
+}
+
+func (s *Service) UserPosts(userID int) ([]*models.Forum, error) {
+	return s.Repo.ShowAllUserPosts(userID)
+}
+
+func (s *Service) UserLikes(userID int) ([]*models.Forum, error) {
+	return s.Repo.ShowAllUserLikes(userID)
+}
+
+func (s *Service) UserComments(userID int) ([]*models.ForumComment, error) {
+	return s.Comments.ShowAllUserComments(userID)
+}
+
+// Find runs a full-text search over visible posts and comments.
+func (s *Service) Find(q models.SearchQuery) ([]*models.SearchResult, error) {
+	return s.Search.Search(q)
+}
+
+func (s *Service) AllTags() ([]string, error) {
+	return s.Tags.GetAllTags()
+}
+
+// TagCounts lists every tag with the number of visible posts using it.
+func (s *Service) TagCounts() ([]*models.TagCount, error) {
+	return s.Tags.TagCounts()
+}
+
+// owner returns the author of a visible post, or ErrNotFound.
+func (s *Service) owner(forumID int) (int, error) {
+	ownerID, err := s.Repo.GetUserIDFromForum(forumID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if ownerID <= 0 {
+		return 0, ErrNotFound
+	}
+	return ownerID, nil
+}
+
+// can reports whether userID's role holds perm. Guests (userID 0) hold
+// nothing.
+func (s *Service) can(userID int, perm policy.Permission) (bool, error) {
+	if userID <= 0 {
+		return false, nil
+	}
+	role, err := s.role(userID)
+	if err != nil {
+		return false, err
+	}
+	return s.Policy.Can(role, perm)
+}
+
+// require returns ErrForbidden unless userID's role holds perm.
+func (s *Service) require(userID int, perm policy.Permission) error {
+	ok, err := s.can(userID, perm)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// canManage reports whether userID owns the content or may act on anyone's
+// with perm.
+func (s *Service) canManage(ownerID, userID int, perm policy.Permission) (bool, error) {
+	if userID > 0 && ownerID == userID {
+		return true, nil
+	}
+	return s.can(userID, perm)
+}
+
+// View loads a post with its comments as seen by viewerID (0 for guests).
+// Posts that are not published are not found by anyone but their author
+// and reviewers.
+func (s *Service) View(forumID, viewerID int) (*models.Forum, error) {
+	st, err := s.post(forumID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := s.maySee(st, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrNotFound
+	}
+	manage, err := s.canManage(st.OwnerID, viewerID, policy.PostEditAny)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.Repo.Get(forumID, viewerID, manage)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	f.IsAuthor = viewerID > 0 && st.OwnerID == viewerID
+
+	manageComments, err := s.can(viewerID, policy.CommentEditAny)
+	if err != nil {
+		return nil, err
+	}
+	if manageComments {
+		f.ManageComments()
+	}
+	return f, nil
+}
+
+// Create stores a new post. Drafts are kept for their author only; other
+// posts by roles with post.publish go live immediately, all others are
+// queued for moderator approval.
+func (s *Service) Create(ctx context.Context, p Post, userID int) (int, error) {
+	publish, err := s.can(userID, policy.PostPublish)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = s.Repo.Insert(ctx, p.Title, p.Content, p.Tags, p.Expires, userID, p.ImagePath); err != nil {
+			return err
+		}
+
+		switch {
+		case p.Draft:
+			return s.Repo.SetState(ctx, id, models.PostDraft, userID, "")
+		case publish:
+			return s.Repo.SetState(ctx, id, models.PostPublished, userID, "")
+		default:
+			return s.Repo.AskForNewForum(ctx, id, userID, p.Title+"\n"+p.Content)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Service) Update(ctx context.Context, forumID int, p Post, userID int) error {
+	st, err := s.post(forumID)
+	if err != nil {
+		return err
+	}
+	ownerID := st.OwnerID
+	ok, err := s.canManage(ownerID, userID, policy.PostEditAny)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+
+	old, err := s.Repo.ImagePath(forumID)
+	if err != nil {
+		return err
+	}
+	if p.ImagePath == "" && !p.RemoveImage {
+		p.ImagePath = old
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Repo.Edit(ctx, p.Title, p.Content, p.Tags, p.Expires, ownerID, p.ImagePath, forumID, userID); err != nil {
+			return err
+		}
+		if old != p.ImagePath {
+			return s.ReleaseImage(ctx, old)
+		}
+		return nil
+	})
+}
+
+func (s *Service) Delete(ctx context.Context, forumID, userID int) error {
+	st, err := s.post(forumID)
+	if err != nil {
+		return err
+	}
+	ok, err := s.canManage(st.OwnerID, userID, policy.PostDeleteAny)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+
+	err = s.Repo.Remove(ctx, forumID, userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ReleaseImage deletes the uploaded image at path once no post, or earlier
+// version of one, shows it. Called inside a unit of work that drops a
+// reference to path, the check sees that change and the file goes only
+// after it commits, so a rollback never leaves a post without its image.
+// A failed delete only leaves an unused file behind.
+func (s *Service) ReleaseImage(ctx context.Context, path string) error {
+	if path == "" || s.Images == nil {
+		return nil
+	}
+	used, err := s.Repo.ImageInUse(ctx, path)
+	if err != nil || used {
+		return err
+	}
+	s.Tx.AfterCommit(ctx, func() { s.Images.Delete(path) })
+	return nil
+}
+
+func (s *Service) React(forumID, userID, likeStatus int) error {
+	if _, err := s.owner(forumID); err != nil {
+		return err
+	}
+
+	_, err := s.Likes.LikeOrDislike(forumID, userID, likeStatus)
+	return err
+}
+
+// ReactComment toggles the user's vote on a comment and returns the post id.
+func (s *Service) ReactComment(commentID, userID, likeStatus int) (int, error) {
+	if _, err := s.commentOwner(commentID); err != nil {
+		return 0, err
+	}
+
+	return s.Likes.LikeOrDislikeComment(commentID, userID, likeStatus)
+}
+
+func (s *Service) Comment(forumID, userID int, comment string) error {
+	if _, err := s.owner(forumID); err != nil {
+		return err
+	}
+
+	_, err := s.Comments.CommentPost(forumID, userID, comment)
+	return err
+}
+
+// Reply answers the comment parentID and returns the post id and the id of
+// the new comment.
+func (s *Service) Reply(parentID, userID int, comment string) (int, int, error) {
+	if _, err := s.commentOwner(parentID); err != nil {
+		return 0, 0, err
+	}
+
+	forumID, err := s.Comments.GetForumIDFromComment(parentID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := s.owner(forumID); err != nil {
+		return 0, 0, err
+	}
+
+	id, err := s.Comments.ReplyPost(forumID, userID, parentID, comment)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, 0, ErrNotFound
+		}
+		return 0, 0, err
+	}
+	return forumID, id, nil
+}
+
+func (s *Service) commentOwner(commentID int) (int, error) {
+	ownerID, err := s.Comments.GetUserIDFromComment(commentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if ownerID <= 0 {
+		return 0, ErrNotFound
+	}
+	return ownerID, nil
+}
+
+// EditComment replaces the text of a comment and returns its post id.
+func (s *Service) EditComment(commentID, userID int, comment string) (int, error) {
+	ownerID, err := s.commentOwner(commentID)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := s.canManage(ownerID, userID, policy.CommentEditAny)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrForbidden
+	}
+
+	forumID, err := s.Comments.GetForumIDFromComment(commentID)
+	if err != nil {
+		return 0, err
+	}
+
+	return forumID, s.Comments.EditCommentPost(forumID, ownerID, comment, commentID, userID)
+}
+
+func (s *Service) DeleteComment(commentID, userID int) error {
+	ownerID, err := s.commentOwner(commentID)
+	if err != nil {
+		return err
+	}
+	ok, err := s.canManage(ownerID, userID, policy.CommentDeleteAny)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+
+	return s.Comments.RemoveCommentPost(commentID, userID)
 }
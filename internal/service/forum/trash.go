@@ -0,0 +1,147 @@
+package forum
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aspandyar/forum/internal/models"
+	"github.com/aspandyar/forum/internal/policy"
+)
+
+type TrashRepository interface {
+	Posts(ownerID int) ([]*models.TrashItem, error)
+	Comments(ownerID int) ([]*models.TrashItem, error)
+	Post(forumID int) (*models.TrashItem, error)
+	Comment(commentID int) (*models.TrashItem, error)
+	ExpiredPosts(cutoff time.Time) ([]*models.TrashItem, error)
+	PurgeComments(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Trash is the part of the trash one user sees. AllPosts and AllComments
+// are set for staff, who see and may restore everyone's.
+type Trash struct {
+	Posts       []*models.TrashItem
+	Comments    []*models.TrashItem
+	AllPosts    bool
+	AllComments bool
+}
+
+// ListTrash returns the deleted posts and comments userID wrote, or every
+// one for roles that may delete anyone's.
+func (s *Service) ListTrash(userID int) (*Trash, error) {
+	if userID <= 0 {
+		return nil, ErrForbidden
+	}
+
+	t := &Trash{}
+	var err error
+	if t.AllPosts, err = s.can(userID, policy.PostDeleteAny); err != nil {
+		return nil, err
+	}
+	if t.AllComments, err = s.can(userID, policy.CommentDeleteAny); err != nil {
+		return nil, err
+	}
+
+	owner := func(all bool) int {
+		if all {
+			return 0
+		}
+		return userID
+	}
+	if t.Posts, err = s.Trash.Posts(owner(t.AllPosts)); err != nil {
+		return nil, err
+	}
+	if t.Comments, err = s.Trash.Comments(owner(t.AllComments)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// mayRestore reports whether userID may take item out of the trash. Roles
+// holding perm may restore anything; authors only what they deleted
+// themselves, not what staff removed.
+func (s *Service) mayRestore(item *models.TrashItem, userID int, perm policy.Permission) error {
+	if userID > 0 && item.OwnerID == userID && item.DeletedByID == userID {
+		return nil
+	}
+	return s.require(userID, perm)
+}
+
+// RestorePost takes a post out of the trash.
+func (s *Service) RestorePost(ctx context.Context, forumID, userID int) error {
+	item, err := s.Trash.Post(forumID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := s.mayRestore(item, userID, policy.PostDeleteAny); err != nil {
+		return err
+	}
+
+	return s.Repo.Restore(ctx, forumID)
+}
+
+// RestoreComment takes a comment out of the trash and returns its post id.
+func (s *Service) RestoreComment(commentID, userID int) (int, error) {
+	item, err := s.Trash.Comment(commentID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if err := s.mayRestore(item, userID, policy.CommentDeleteAny); err != nil {
+		return 0, err
+	}
+
+	return item.ForumID, s.Comments.RestoreCommentPost(commentID)
+}
+
+// PurgeTrash deletes for good what has been in the trash longer than
+// models.TrashRetention, relative to now, and returns how many posts and
+// comments went. Images only the purged posts showed are deleted too.
+func (s *Service) PurgeTrash(ctx context.Context, now time.Time) (int, int64, error) {
+	cutoff := now.Add(-models.TrashRetention)
+
+	posts, err := s.Trash.ExpiredPosts(cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, p := range posts {
+		if err := s.purgePost(ctx, p.ForumID, p.ImagePath); err != nil {
+			return i, 0, err
+		}
+	}
+
+	comments, err := s.Trash.PurgeComments(ctx, cutoff)
+	return len(posts), comments, err
+}
+
+// purgePost deletes a post for good with the images only it showed.
+func (s *Service) purgePost(ctx context.Context, forumID int, imagePath string) error {
+	// Earlier versions may show images the post no longer does; they go
+	// with it.
+	images := map[string]bool{imagePath: true}
+	history, err := s.Revisions.ForumHistory(forumID)
+	if err != nil {
+		return err
+	}
+	for _, r := range history {
+		images[r.ImagePath] = true
+	}
+
+	return s.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.Repo.Purge(ctx, forumID); err != nil {
+			return err
+		}
+		for path := range images {
+			if err := s.ReleaseImage(ctx, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+// Package testutil holds database helpers shared by the tests of several
+// packages.
+package testutil
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// FailInserts makes every insert into table fail until the returned func
+// is called, so a test can break an operation after its earlier statements
+// have run.
+func FailInserts(t testing.TB, db *sql.DB, table string) func() {
+	t.Helper()
+
+	_, err := db.Exec(`CREATE TRIGGER fail_` + table + ` BEFORE INSERT ON ` + table + `
+	BEGIN SELECT RAISE(ABORT, 'injected failure'); END;`)
+	if err != nil {
+		t.Fatalf("install failure on %s: %v", table, err)
+	}
+	return func() {
+		if _, err := db.Exec(`DROP TRIGGER fail_` + table); err != nil {
+			t.Fatalf("drop failure on %s: %v", table, err)
+		}
+	}
+}
+
+// Count runs a COUNT query and returns its result.
+func Count(t testing.TB, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("count %q: %v", query, err)
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Envelope wraps every successful API response body.
+type Envelope struct {
+	Data interface{} `json:"data"`
+}
+
+// ErrorBody is the payload of the error envelope.
+type ErrorBody struct {
+	Status  int               `json:"status"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type ErrorEnvelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+func Write(w http.ResponseWriter, status int, data interface{}) error {
+	return writeJSON(w, status, Envelope{Data: data})
+}
+
+// Error writes the error envelope. An empty message defaults to the status
+// text; fields carries per-field validation messages and may be nil.
+func Error(w http.ResponseWriter, status int, message string, fields map[string]string) error {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	body := ErrorBody{
+		Status:  status,
+		Code:    Code(status),
+		Message: message,
+		Fields:  fields,
+	}
+	return writeJSON(w, status, ErrorEnvelope{Error: body})
+}
+
+// Code turns a status into a stable machine-readable code, e.g.
+// 404 -> "not_found".
+func Code(status int) string {
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		return "error"
+	}
+	text = strings.ReplaceAll(text, "-", " ")
+	text = strings.ReplaceAll(text, "'", "")
+	return strings.Join(strings.Fields(text), "_")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
@@ -0,0 +1,58 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := Write(rr, http.StatusCreated, map[string]int{"id": 7}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status=%d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("content-type=%q", rr.Header().Get("Content-Type"))
+	}
+	if rr.Body.String() != "{\"data\":{\"id\":7}}\n" {
+		t.Fatalf("body=%q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := Write(rr, http.StatusOK, func() {}); err == nil {
+		t.Fatal("expected marshal error")
+	}
+}
+
+func TestError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := Error(rr, http.StatusUnprocessableEntity, "", map[string]string{"title": "blank"}); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	var env ErrorEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if env.Error.Status != 422 || env.Error.Code != "unprocessable_entity" || env.Error.Message != "Unprocessable Entity" || env.Error.Fields["title"] != "blank" {
+		t.Fatalf("unexpected envelope: %#v", env)
+	}
+}
+
+func TestCode(t *testing.T) {
+	cases := map[int]string{
+		http.StatusNotFound:            "not_found",
+		http.StatusMethodNotAllowed:    "method_not_allowed",
+		http.StatusInternalServerError: "internal_server_error",
+		http.StatusTooManyRequests:     "too_many_requests",
+		599:                            "error",
+	}
+	for status, want := range cases {
+		if got := Code(status); got != want {
+			t.Fatalf("Code(%d)=%q want %q", status, got, want)
+		}
+	}
+}
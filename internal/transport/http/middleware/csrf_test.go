@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func csrfHandler(exempt func(*http.Request) bool) (http.Handler, *string) {
+	var seen string
+	h := CSRF(exempt, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = CSRFToken(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return h, &seen
+}
+
+func never(*http.Request) bool { return false }
+
+func TestCSRFIssuesToken(t *testing.T) {
+	h, seen := csrfHandler(never)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].Name != CSRFCookie {
+		t.Fatalf("GET status=%d cookies=%v", rr.Code, cookies)
+	}
+	if *seen != cookies[0].Value || len(*seen) != 43 || !cookies[0].HttpOnly {
+		t.Fatalf("context token %q, cookie %+v", *seen, cookies[0])
+	}
+
+	// A visitor who already has a token keeps it.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if len(rr.Result().Cookies()) != 0 || *seen != cookies[0].Value {
+		t.Fatalf("token reissued: %v", rr.Result().Cookies())
+	}
+}
+
+func TestCSRFChecksUnsafeMethods(t *testing.T) {
+	h, _ := csrfHandler(never)
+	token := strings.Repeat("a", 43)
+	cookie := &http.Cookie{Name: CSRFCookie, Value: token}
+
+	form := func(value string) *http.Request {
+		body := url.Values{CSRFField: {value}, "title": {"x"}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	var mp bytes.Buffer
+	mw := multipart.NewWriter(&mp)
+	mw.WriteField(CSRFField, token)
+	mw.Close()
+	multipartReq := httptest.NewRequest(http.MethodPost, "/", &mp)
+	multipartReq.Header.Set("Content-Type", mw.FormDataContentType())
+	multipartReq.AddCookie(cookie)
+
+	headerReq := httptest.NewRequest(http.MethodDelete, "/", nil)
+	headerReq.Header.Set(CSRFHeader, token)
+	headerReq.AddCookie(cookie)
+
+	noCookie := form(token)
+
+	wrong := form(strings.Repeat("b", 43))
+	wrong.AddCookie(cookie)
+
+	missing := form("")
+	missing.AddCookie(cookie)
+
+	good := form(token)
+	good.AddCookie(cookie)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"form field", good, http.StatusNoContent},
+		{"multipart field", multipartReq, http.StatusNoContent},
+		{"header", headerReq, http.StatusNoContent},
+		{"no cookie", noCookie, http.StatusForbidden},
+		{"wrong token", wrong, http.StatusForbidden},
+		{"missing token", missing, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, tt.req)
+			if rr.Code != tt.want {
+				t.Fatalf("status=%d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+
+	// The handler still sees the rest of the form.
+	good = form(token)
+	good.AddCookie(cookie)
+	CSRF(never, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("title") != "x" {
+			t.Errorf("title = %q after CSRF check", r.FormValue("title"))
+		}
+	})).ServeHTTP(httptest.NewRecorder(), good)
+}
+
+func TestCSRFExempt(t *testing.T) {
+	h, _ := csrfHandler(func(r *http.Request) bool { return r.Header.Get("Authorization") != "" })
+
+	req := httptest.NewRequest(http.MethodPost, "/api", nil)
+	req.Header.Set("Authorization", "Bearer t")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("exempt status=%d", rr.Code)
+	}
+}
@@ -47,6 +47,22 @@ func SecureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// LimitBody caps request bodies at n bytes. A request announcing a larger
+// body is refused before anything reads it.
+func LimitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				w.Header().Set("Connection", "close")
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func LogRequest(logger *log.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -82,3 +98,17 @@ func RequireAuthentication(isAuthenticated func(*http.Request) bool) func(http.H
 		})
 	}
 }
+
+// RequirePermission lets the request through only when allowed reports
+// true for it; otherwise deny answers it.
+func RequirePermission(allowed func(*http.Request) bool, deny func(http.ResponseWriter)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowed(r) {
+				deny(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
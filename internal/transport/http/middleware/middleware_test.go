@@ -77,3 +77,59 @@ func TestLogRequestAndRecoverPanic(t *testing.T) {
 		t.Fatalf("expected captured panic error, got %v", captured)
 	}
 }
+
+func TestRequirePermission(t *testing.T) {
+	allowed := false
+	h := RequirePermission(func(*http.Request) bool { return allowed }, func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusForbidden)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("denied code=%d", rr.Code)
+	}
+
+	allowed = true
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("allowed code=%d", rr.Code)
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	var read int
+	h := LimitBody(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		read = len(b)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345678")))
+	if rr.Code != http.StatusNoContent || read != 8 {
+		t.Fatalf("body at the limit code=%d read=%d", rr.Code, read)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456789")))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("announced large body code=%d", rr.Code)
+	}
+
+	// Without a length the body is cut off while reading.
+	req := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("123456789")))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unannounced large body code=%d", rr.Code)
+	}
+}
@@ -3,11 +3,12 @@ package render
 import (
 	"bytes"
 	"fmt"
+	"html/template"
 	"net/http"
 	"path/filepath"
-	"text/template"
 	"time"
 
+	"github.com/aspandyar/forum/internal/markdown"
 	"github.com/aspandyar/forum/internal/models"
 )
 
@@ -15,18 +16,76 @@ type TemplateData struct {
 	CurrentYear     int
 	Forum           *models.Forum
 	Forums          []*models.Forum
+	Pagination      *Pagination
 	Form            interface{}
 	Flash           string
 	IsAuthenticated bool
 	Role            int
+	// Can holds the permissions of Role, keyed by name, for
+	// {{if index .Can "report.review"}}.
+	Can                 map[string]bool
+	UnreadNotifications int
+	// CSRFToken is rendered into every POST form by {{template "csrf" $}}.
+	CSRFToken string
+	// SignInProviders are the external logins offered on the login, signup
+	// and settings pages.
+	SignInProviders []SignInProvider
+	// ImageURL and ThumbnailURL are short-lived URLs of the image of the
+	// post on a view page.
+	ImageURL     string
+	ThumbnailURL string
+	// ExpiryPolicies are the lifetimes offered by the renew form on a view
+	// page.
+	ExpiryPolicies []*models.ExpiryPolicy
+}
+
+// SignInProvider is a configured OAuth provider. Start begins its flow; add
+// ?link=1 to link it to the signed-in account instead.
+type SignInProvider struct {
+	Name  string
+	Label string
+	Start string
+}
+
+// CSRFScope carries the CSRF token into partials that are executed with
+// their own data, such as the recursive comment thread.
+type CSRFScope struct {
+	CSRFToken string
+	Data      interface{}
+}
+
+func withCSRF(token string, data interface{}) CSRFScope {
+	return CSRFScope{CSRFToken: token, Data: data}
+}
+
+// Pagination holds the sort menu and the previous/next links rendered under
+// a paged forum table. Empty links are not shown.
+type Pagination struct {
+	Sorts []SortLink
+	Prev  string
+	Next  string
+}
+
+type SortLink struct {
+	Label   string
+	URL     string
+	Current bool
 }
 
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// renderMarkdown marks the output of the markdown package as safe: it
+// escapes everything that is not on its own allowlist of tags.
+func renderMarkdown(src string) template.HTML {
+	return template.HTML(markdown.Render(src))
+}
+
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"withCSRF":  withCSRF,
+	"markdown":  renderMarkdown,
 }
 
 func NewTemplateCache() (map[string]*template.Template, error) {
@@ -67,12 +126,14 @@ func Render(w http.ResponseWriter, cache map[string]*template.Template, status i
 		return fmt.Errorf("the template %s does not exist", page)
 	}
 
+	// Render into a buffer first, so an error leaves the response untouched
+	// and the status can still be set.
 	buf := new(bytes.Buffer)
-	if err := ts.ExecuteTemplate(w, "base", data); err != nil {
+	if err := ts.ExecuteTemplate(buf, "base", data); err != nil {
 		return err
 	}
 
-	_ = status
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 	return nil
 }
@@ -1,9 +1,9 @@
 package render
 
 import (
+	"html/template"
 	"net/http/httptest"
 	"testing"
-	"text/template"
 	"time"
 )
 
@@ -53,11 +53,11 @@ func TestRender_WithManualTemplates(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	if err := Render(rr, cache, 200, "ok.tmpl.html", &TemplateData{}); err != nil {
+	if err := Render(rr, cache, 422, "ok.tmpl.html", &TemplateData{}); err != nil {
 		t.Fatalf("Render ok template err=%v", err)
 	}
-	if rr.Body.String() != "render-ok" {
-		t.Fatalf("unexpected render body %q", rr.Body.String())
+	if rr.Code != 422 || rr.Body.String() != "render-ok" {
+		t.Fatalf("unexpected render %d %q", rr.Code, rr.Body.String())
 	}
 
 	badTpl := template.Must(template.New("bad").Parse(`{{define "not_base"}}x{{end}}`))
@@ -66,4 +66,21 @@ func TestRender_WithManualTemplates(t *testing.T) {
 	if err := Render(rr, cache, 200, "bad.tmpl.html", &TemplateData{}); err == nil {
 		t.Fatal("expected execute template error for missing base")
 	}
+	if rr.Body.Len() != 0 || rr.Code != 200 {
+		t.Fatalf("failed render wrote %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRender_Escaping(t *testing.T) {
+	tpl := template.Must(template.New("page").Funcs(functions).Parse(`{{define "base"}}{{.Flash}}|{{markdown .Flash}}{{end}}`))
+	cache := map[string]*template.Template{"page.tmpl.html": tpl}
+
+	rr := httptest.NewRecorder()
+	if err := Render(rr, cache, 200, "page.tmpl.html", &TemplateData{Flash: "<b>*hi*</b>"}); err != nil {
+		t.Fatalf("Render err=%v", err)
+	}
+	want := "&lt;b&gt;*hi*&lt;/b&gt;|<p>&lt;b&gt;<em>hi</em>&lt;/b&gt;</p>\n"
+	if rr.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rr.Body.String(), want)
+	}
 }
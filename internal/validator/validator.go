@@ -58,6 +58,16 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	return false
 }
 
+func PermittedValue(value string, permittedValues ...string) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
 }
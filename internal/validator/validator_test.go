@@ -38,6 +38,15 @@ func TestPermittedInt(t *testing.T) {
 	}
 }
 
+func TestPermittedValue(t *testing.T) {
+	if !PermittedValue("open", "open", "resolved") {
+		t.Fatal("expected value in set to pass")
+	}
+	if PermittedValue("closed", "open", "resolved") {
+		t.Fatal("expected value outside set to fail")
+	}
+}
+
 func TestMatches(t *testing.T) {
 	rx := regexp.MustCompile(`^g.*o$`)
 	if !Matches("go", rx) {
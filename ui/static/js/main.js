@@ -28,14 +28,93 @@ function bindForumImageInteractions() {
     });
 
     image.addEventListener("click", () => {
-        const imagePath = image.getAttribute("src");
+        const imagePath = image.dataset.full || image.getAttribute("src");
         if (imagePath) {
             openPopup(imagePath);
         }
     });
 }
 
+function setReactionCounts(container, likes, dislikes) {
+    if (!container) {
+        return;
+    }
+    const counts = container.querySelectorAll(".reaction-count");
+    if (counts.length === 2) {
+        counts[0].textContent = likes;
+        counts[1].textContent = dislikes;
+    }
+}
+
+function bindForumEvents() {
+    const forum = document.querySelector("[data-live-forum]");
+    if (!forum || !window.EventSource) {
+        return;
+    }
+
+    const events = new EventSource("/forum/events/" + forum.dataset.liveForum);
+    const notice = document.querySelector(".live-notice");
+
+    events.addEventListener("comment", () => {
+        if (notice) {
+            notice.hidden = false;
+        }
+    });
+    events.addEventListener("reaction", (e) => {
+        const data = JSON.parse(e.data);
+        if (data.comment_id) {
+            setReactionCounts(document.querySelector("#comment-" + data.comment_id + " .comment-reactions"), data.likes, data.dislikes);
+        } else {
+            setReactionCounts(forum.querySelector(".reactions"), data.likes, data.dislikes);
+        }
+    });
+    events.addEventListener("reset", () => window.location.reload());
+}
+
+function bindInboxEvents() {
+    const link = document.querySelector("[data-live-inbox]");
+    if (!link || !window.EventSource) {
+        return;
+    }
+
+    const badge = link.querySelector(".badge");
+    const events = new EventSource("/user/inbox/events");
+
+    events.addEventListener("unread", (e) => {
+        const data = JSON.parse(e.data);
+        badge.textContent = data.unread;
+        badge.hidden = data.unread === 0;
+    });
+}
+
+function bindMarkdownPreview() {
+    document.querySelectorAll("[data-preview]").forEach((button) => {
+        const form = button.closest("form");
+        const source = form.querySelector("[name='" + button.dataset.preview + "']");
+        const output = button.parentElement.querySelector(".preview");
+        const token = form.querySelector("[name='csrf_token']");
+
+        button.addEventListener("click", async () => {
+            const body = new URLSearchParams({ content: source.value });
+            const response = await fetch("/forum/preview", {
+                method: "POST",
+                headers: { "X-CSRF-Token": token ? token.value : "" },
+                body: body,
+            });
+            if (!response.ok) {
+                return;
+            }
+            // The server sanitizes the preview the same way as a post.
+            output.innerHTML = await response.text();
+            output.hidden = false;
+        });
+    });
+}
+
 document.addEventListener("DOMContentLoaded", () => {
     highlightActiveNavLink();
     bindForumImageInteractions();
+    bindForumEvents();
+    bindInboxEvents();
+    bindMarkdownPreview();
 });
\ No newline at end of file